@@ -0,0 +1,104 @@
+package spireclient
+
+import (
+	"context"
+	"fmt"
+
+	agentv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/agent/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AgentNotFoundError is returned when SPIRE Server has no agent with the requested SPIFFE ID
+type AgentNotFoundError struct {
+	SpiffeID string
+}
+
+func (e *AgentNotFoundError) Error() string {
+	return fmt.Sprintf("agent %s not found", e.SpiffeID)
+}
+
+// GetAgentBySpiffeID returns the attested agent with the given SPIFFE ID
+// Returns an *AgentNotFoundError if the agent does not exist
+func (c *Client) GetAgentBySpiffeID(ctx context.Context, spiffeID string) (*types.Agent, error) {
+	id, err := toProtoSPIFFEID(spiffeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent SPIFFE ID: %w", err)
+	}
+
+	agent, err := c.AgentClient().GetAgent(ctx, &agentv1.GetAgentRequest{Id: id})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &AgentNotFoundError{SpiffeID: spiffeID}
+		}
+		return nil, fmt.Errorf("failed to get agent: %w", err)
+	}
+
+	return agent, nil
+}
+
+// ListAgentsBySelector lists all agents that have the given selector, following page tokens until exhausted
+func (c *Client) ListAgentsBySelector(ctx context.Context, selectorType, selectorValue string) ([]*types.Agent, error) {
+	if selectorType == "" || selectorValue == "" {
+		return nil, fmt.Errorf("selector type and value are required")
+	}
+
+	filter := &agentv1.ListAgentsRequest_Filter{
+		BySelectorMatch: &types.SelectorMatch{
+			Selectors: []*types.Selector{{Type: selectorType, Value: selectorValue}},
+			// Agents usually carry several selectors, so match any agent whose set includes this one
+			Match: types.SelectorMatch_MATCH_SUPERSET,
+		},
+	}
+
+	return c.listAgents(ctx, filter)
+}
+
+// ListAgents lists all attested agents, following page tokens until exhausted
+func (c *Client) ListAgents(ctx context.Context) ([]*types.Agent, error) {
+	return c.listAgents(ctx, nil)
+}
+
+// listAgents lists the agents matching filter across all pages
+func (c *Client) listAgents(ctx context.Context, filter *agentv1.ListAgentsRequest_Filter) ([]*types.Agent, error) {
+	var agents []*types.Agent
+	pageToken := ""
+
+	for page := 0; page < maxListPages; page++ {
+		resp, err := c.AgentClient().ListAgents(ctx, &agentv1.ListAgentsRequest{
+			Filter:    filter,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list agents: %w", err)
+		}
+
+		agents = append(agents, resp.Agents...)
+
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			return agents, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to list agents: exceeded %d pages", maxListPages)
+}
+
+// BanAgent bans the agent with the given SPIFFE ID so it can no longer renew its SVID
+// Returns an *AgentNotFoundError if the agent does not exist
+func (c *Client) BanAgent(ctx context.Context, spiffeID string) error {
+	id, err := toProtoSPIFFEID(spiffeID)
+	if err != nil {
+		return fmt.Errorf("invalid agent SPIFFE ID: %w", err)
+	}
+
+	if _, err := c.AgentClient().BanAgent(ctx, &agentv1.BanAgentRequest{Id: id}); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &AgentNotFoundError{SpiffeID: spiffeID}
+		}
+		return fmt.Errorf("failed to ban agent: %w", err)
+	}
+
+	return nil
+}
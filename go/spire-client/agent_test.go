@@ -0,0 +1,240 @@
+package spireclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	agentv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/agent/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeAgentServer serves agents keyed by SPIFFE ID and pages of ListAgents results
+type fakeAgentServer struct {
+	agentv1.UnimplementedAgentServer
+	agents  map[string]*types.Agent
+	getErr  error
+	listErr error
+
+	// pages are returned by ListAgents, one per request
+	pages        [][]*types.Agent
+	listRequests []*agentv1.ListAgentsRequest
+
+	banned []string
+}
+
+func (s *fakeAgentServer) GetAgent(ctx context.Context, req *agentv1.GetAgentRequest) (*types.Agent, error) {
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+
+	id := fmt.Sprintf("spiffe://%s%s", req.Id.TrustDomain, req.Id.Path)
+	agent, ok := s.agents[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "agent not found")
+	}
+	return agent, nil
+}
+
+func (s *fakeAgentServer) ListAgents(ctx context.Context, req *agentv1.ListAgentsRequest) (*agentv1.ListAgentsResponse, error) {
+	s.listRequests = append(s.listRequests, req)
+
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+
+	page := 0
+	if req.PageToken != "" {
+		var err error
+		page, err = strconv.Atoi(req.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page token %q", req.PageToken)
+		}
+	}
+
+	resp := &agentv1.ListAgentsResponse{}
+	if page < len(s.pages) {
+		resp.Agents = s.pages[page]
+	}
+	if page+1 < len(s.pages) {
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	return resp, nil
+}
+
+func (s *fakeAgentServer) BanAgent(ctx context.Context, req *agentv1.BanAgentRequest) (*emptypb.Empty, error) {
+	id := fmt.Sprintf("spiffe://%s%s", req.Id.TrustDomain, req.Id.Path)
+	if _, ok := s.agents[id]; !ok {
+		return nil, status.Errorf(codes.NotFound, "agent not found")
+	}
+	s.banned = append(s.banned, id)
+	return &emptypb.Empty{}, nil
+}
+
+// newAgentTestClient returns a client connected to a test server running the given fake Agent service
+func newAgentTestClient(t *testing.T, server *fakeAgentServer) *Client {
+	t.Helper()
+	return newTestClient(t, func(s *grpc.Server) {
+		agentv1.RegisterAgentServer(s, server)
+	})
+}
+
+// testAgent returns an agent with the given path in the example.org trust domain
+func testAgent(path string) *types.Agent {
+	return &types.Agent{
+		Id:              &types.SPIFFEID{TrustDomain: "example.org", Path: path},
+		AttestationType: "join_token",
+	}
+}
+
+func TestClient_GetAgentBySpiffeID(t *testing.T) {
+	agent := testAgent("/spire/agent/join_token/abc")
+
+	tests := []struct {
+		name     string
+		spiffeID string
+		getErr   error
+		wantErr  string
+		notFound bool
+	}{
+		{
+			name:     "found",
+			spiffeID: "spiffe://example.org/spire/agent/join_token/abc",
+		},
+		{
+			name:     "not found",
+			spiffeID: "spiffe://example.org/spire/agent/join_token/missing",
+			wantErr:  "agent spiffe://example.org/spire/agent/join_token/missing not found",
+			notFound: true,
+		},
+		{
+			name:     "invalid SPIFFE ID",
+			spiffeID: "https://example.org/agent",
+			wantErr:  "invalid agent SPIFFE ID",
+		},
+		{
+			name:     "server error",
+			spiffeID: "spiffe://example.org/spire/agent/join_token/abc",
+			getErr:   status.Error(codes.Internal, "datastore unavailable"),
+			wantErr:  "failed to get agent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeAgentServer{
+				agents: map[string]*types.Agent{"spiffe://example.org/spire/agent/join_token/abc": agent},
+				getErr: tt.getErr,
+			}
+			client := newAgentTestClient(t, server)
+
+			got, err := client.GetAgentBySpiffeID(context.Background(), tt.spiffeID)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.Nil(t, got)
+
+				var notFound *AgentNotFoundError
+				assert.Equal(t, tt.notFound, errors.As(err, &notFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "/spire/agent/join_token/abc", got.Id.Path)
+		})
+	}
+}
+
+func TestClient_ListAgentsBySelector(t *testing.T) {
+	t.Run("follows pages and builds filter", func(t *testing.T) {
+		server := &fakeAgentServer{
+			pages: [][]*types.Agent{
+				{testAgent("/agent/1"), testAgent("/agent/2")},
+				{testAgent("/agent/3")},
+			},
+		}
+		client := newAgentTestClient(t, server)
+
+		agents, err := client.ListAgentsBySelector(context.Background(), "k8s_psat", "cluster:demo")
+		require.NoError(t, err)
+		assert.Len(t, agents, 3)
+
+		require.Len(t, server.listRequests, 2)
+		match := server.listRequests[0].Filter.BySelectorMatch
+		require.NotNil(t, match)
+		assert.Equal(t, types.SelectorMatch_MATCH_SUPERSET, match.Match)
+		require.Len(t, match.Selectors, 1)
+		assert.Equal(t, "k8s_psat", match.Selectors[0].Type)
+		assert.Equal(t, "cluster:demo", match.Selectors[0].Value)
+		assert.Equal(t, "1", server.listRequests[1].PageToken)
+	})
+
+	t.Run("missing selector", func(t *testing.T) {
+		client := newAgentTestClient(t, &fakeAgentServer{})
+
+		agents, err := client.ListAgentsBySelector(context.Background(), "k8s_psat", "")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "selector type and value are required")
+		assert.Nil(t, agents)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		client := newAgentTestClient(t, &fakeAgentServer{listErr: status.Error(codes.Unavailable, "unavailable")})
+
+		agents, err := client.ListAgentsBySelector(context.Background(), "k8s_psat", "cluster:demo")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to list agents")
+		assert.Nil(t, agents)
+	})
+}
+
+func TestClient_ListAgents(t *testing.T) {
+	server := &fakeAgentServer{
+		pages: [][]*types.Agent{
+			{testAgent("/agent/1"), testAgent("/agent/2")},
+			{testAgent("/agent/3")},
+		},
+	}
+	client := newAgentTestClient(t, server)
+
+	agents, err := client.ListAgents(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, agents, 3)
+
+	require.Len(t, server.listRequests, 2)
+	assert.Nil(t, server.listRequests[0].Filter)
+	assert.Equal(t, "1", server.listRequests[1].PageToken)
+}
+
+func TestClient_BanAgent(t *testing.T) {
+	server := &fakeAgentServer{
+		agents: map[string]*types.Agent{"spiffe://example.org/agent/1": testAgent("/agent/1")},
+	}
+	client := newAgentTestClient(t, server)
+
+	t.Run("bans agent", func(t *testing.T) {
+		require.NoError(t, client.BanAgent(context.Background(), "spiffe://example.org/agent/1"))
+		assert.Equal(t, []string{"spiffe://example.org/agent/1"}, server.banned)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := client.BanAgent(context.Background(), "spiffe://example.org/agent/missing")
+		var notFound *AgentNotFoundError
+		require.ErrorAs(t, err, &notFound)
+		assert.Equal(t, "spiffe://example.org/agent/missing", notFound.SpiffeID)
+	})
+
+	t.Run("invalid SPIFFE ID", func(t *testing.T) {
+		err := client.BanAgent(context.Background(), "not-a-spiffe-id")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid agent SPIFFE ID")
+	})
+}
@@ -0,0 +1,302 @@
+package spireclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// bundleWatchInterval is how often WatchBundle polls the server for bundle changes
+	bundleWatchInterval = 30 * time.Second
+	// bundleWatchInitialBackoff is the first retry delay after a transient error
+	bundleWatchInitialBackoff = time.Second
+	// bundleWatchMaxBackoff caps the retry delay after repeated transient errors
+	bundleWatchMaxBackoff = time.Minute
+)
+
+// FetchX509Authorities fetches the server's trust bundle and returns its parsed X.509 authorities
+func (c *Client) FetchX509Authorities(ctx context.Context) ([]*x509.Certificate, error) {
+	bundle, err := c.BundleClient().GetBundle(ctx, &bundlev1.GetBundleRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle: %w", err)
+	}
+
+	return parseX509Authorities(bundle)
+}
+
+// FetchX509Bundle fetches the server's trust bundle and returns it as an X.509 bundle for its trust domain
+func (c *Client) FetchX509Bundle(ctx context.Context) (*x509bundle.Bundle, error) {
+	bundle, err := c.BundleClient().GetBundle(ctx, &bundlev1.GetBundleRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle: %w", err)
+	}
+
+	td, err := spiffeid.TrustDomainFromString(bundle.TrustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust domain %q in bundle: %w", bundle.TrustDomain, err)
+	}
+	authorities, err := parseX509Authorities(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509bundle.FromX509Authorities(td, authorities), nil
+}
+
+// parseX509Authorities parses the X.509 authorities of an API bundle
+func parseX509Authorities(bundle *types.Bundle) ([]*x509.Certificate, error) {
+	authorities := make([]*x509.Certificate, 0, len(bundle.X509Authorities))
+	for i, authority := range bundle.X509Authorities {
+		cert, err := x509.ParseCertificate(authority.Asn1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse X.509 authority at index %d: %w", i, err)
+		}
+		authorities = append(authorities, cert)
+	}
+
+	return authorities, nil
+}
+
+// BuildCertPool fetches the server's X.509 authorities and loads them into a certificate pool
+func (c *Client) BuildCertPool(ctx context.Context) (*x509.CertPool, error) {
+	authorities, err := c.FetchX509Authorities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	for _, authority := range authorities {
+		pool.AddCert(authority)
+	}
+
+	return pool, nil
+}
+
+// WatchBundle watches the server's trust bundle and calls onChange with the initial bundle and every change
+// The Bundle API has no streaming RPC, so the bundle is polled. Transient errors are retried with
+// exponential backoff. Blocks until ctx is done or a non-transient error occurs
+func (c *Client) WatchBundle(ctx context.Context, onChange func(*types.Bundle)) error {
+	var current *types.Bundle
+	backoff := bundleWatchInitialBackoff
+
+	for {
+		wait := bundleWatchInterval
+
+		bundle, err := c.BundleClient().GetBundle(ctx, &bundlev1.GetBundleRequest{})
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil && !isTransientError(err):
+			return fmt.Errorf("failed to get bundle: %w", err)
+		case err != nil:
+			wait = backoff
+			backoff *= 2
+			if backoff > bundleWatchMaxBackoff {
+				backoff = bundleWatchMaxBackoff
+			}
+		default:
+			backoff = bundleWatchInitialBackoff
+			if current == nil || !proto.Equal(current, bundle) {
+				current = bundle
+				onChange(bundle)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// WatchBundleAsync runs WatchBundle in a goroutine
+// Calling cancel stops the watch; errCh receives the result of WatchBundle and is then closed
+func (c *Client) WatchBundleAsync(ctx context.Context, onChange func(*types.Bundle)) (cancel func(), errCh <-chan error) {
+	ctx, cancel = context.WithCancel(ctx)
+	ch := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		ch <- c.WatchBundle(ctx, onChange)
+	}()
+
+	return cancel, ch
+}
+
+// isTransientError reports whether a gRPC error is worth retrying
+func isTransientError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// BundleDiff describes how the X.509 authorities of two trust bundles differ
+type BundleDiff struct {
+	// AddedAuthorities are present in the second bundle but not the first
+	AddedAuthorities []*x509.Certificate
+	// RemovedAuthorities are present in the first bundle but not the second
+	RemovedAuthorities []*x509.Certificate
+	// Unchanged is the number of authorities present in both bundles
+	Unchanged int
+}
+
+// CompareBundles compares the X.509 authorities of a and b by their DER encoding
+// A nil bundle is treated as empty, and the order of authorities does not matter
+func CompareBundles(a, b *x509bundle.Bundle) BundleDiff {
+	before := bundleAuthorities(a)
+	after := bundleAuthorities(b)
+
+	var diff BundleDiff
+	for _, cert := range after {
+		if containsAuthority(before, cert) {
+			diff.Unchanged++
+		} else {
+			diff.AddedAuthorities = append(diff.AddedAuthorities, cert)
+		}
+	}
+	for _, cert := range before {
+		if !containsAuthority(after, cert) {
+			diff.RemovedAuthorities = append(diff.RemovedAuthorities, cert)
+		}
+	}
+
+	return diff
+}
+
+// IsEmpty reports whether both bundles hold the same authorities
+func (d BundleDiff) IsEmpty() bool {
+	return len(d.AddedAuthorities) == 0 && len(d.RemovedAuthorities) == 0
+}
+
+// Summary returns a human-readable summary with one line per added or removed authority
+func (d BundleDiff) Summary() string {
+	if d.IsEmpty() {
+		return fmt.Sprintf("bundles are identical (%d authorities)", d.Unchanged)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "bundles differ: %d added, %d removed, %d unchanged", len(d.AddedAuthorities), len(d.RemovedAuthorities), d.Unchanged)
+	for _, cert := range d.AddedAuthorities {
+		fmt.Fprintf(&sb, "\n  + %s", describeAuthority(cert))
+	}
+	for _, cert := range d.RemovedAuthorities {
+		fmt.Fprintf(&sb, "\n  - %s", describeAuthority(cert))
+	}
+	return sb.String()
+}
+
+// bundleAuthorities returns the X.509 authorities of bundle, or nil for a nil bundle
+func bundleAuthorities(bundle *x509bundle.Bundle) []*x509.Certificate {
+	if bundle == nil {
+		return nil
+	}
+	return bundle.X509Authorities()
+}
+
+// containsAuthority reports whether certs contains a certificate with the same DER encoding as cert
+func containsAuthority(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeAuthority formats the subject, expiry and SHA-256 fingerprint of an authority
+func describeAuthority(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return fmt.Sprintf("%s (expires %s, SHA-256 %x)", cert.Subject, cert.NotAfter.UTC().Format(time.RFC3339), sum[:8])
+}
+
+// Formats accepted by WriteBundleToWriter
+const (
+	// BundleFormatPEM writes each authority as a PEM CERTIFICATE block
+	BundleFormatPEM = "pem"
+	// BundleFormatDER writes the DER encodings of the authorities back to back
+	BundleFormatDER = "der"
+	// BundleFormatJWKS writes a JWK Set with each authority's public key and certificate (x5c)
+	BundleFormatJWKS = "jwks"
+	// BundleFormatSPIFFE writes the SPIFFE bundle format, a JWK Set whose keys have use "x509-svid"
+	BundleFormatSPIFFE = "spiffe-bundle"
+)
+
+// WriteBundleToWriter writes the X.509 authorities of bundle to w in the given format
+// JSON formats are indented and end with a newline
+func WriteBundleToWriter(w io.Writer, bundle *x509bundle.Bundle, format string) error {
+	if bundle == nil {
+		return &ValidationError{Err: errors.New("bundle is required")}
+	}
+
+	switch format {
+	case BundleFormatPEM:
+		for _, cert := range bundle.X509Authorities() {
+			if err := pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return fmt.Errorf("failed to write bundle: %w", err)
+			}
+		}
+		return nil
+	case BundleFormatDER:
+		for _, cert := range bundle.X509Authorities() {
+			if _, err := w.Write(cert.Raw); err != nil {
+				return fmt.Errorf("failed to write bundle: %w", err)
+			}
+		}
+		return nil
+	case BundleFormatJWKS:
+		var jwks jose.JSONWebKeySet
+		for _, cert := range bundle.X509Authorities() {
+			jwks.Keys = append(jwks.Keys, jose.JSONWebKey{Key: cert.PublicKey, Certificates: []*x509.Certificate{cert}})
+		}
+		data, err := json.Marshal(jwks)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JWK set: %w", err)
+		}
+		return writeIndentedJSON(w, data)
+	case BundleFormatSPIFFE:
+		data, err := spiffebundle.FromX509Bundle(bundle).Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to marshal SPIFFE bundle: %w", err)
+		}
+		return writeIndentedJSON(w, data)
+	default:
+		return &ValidationError{Err: fmt.Errorf("unsupported bundle format %q (want %s, %s, %s or %s)",
+			format, BundleFormatPEM, BundleFormatDER, BundleFormatJWKS, BundleFormatSPIFFE)}
+	}
+}
+
+// writeIndentedJSON writes data indented with two spaces, followed by a newline
+func writeIndentedJSON(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return fmt.Errorf("failed to indent JSON: %w", err)
+	}
+	buf.WriteByte('\n')
+	if _, err := buf.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
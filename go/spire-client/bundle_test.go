@@ -0,0 +1,389 @@
+package spireclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBundleServer serves a fixed bundle from GetBundle
+type fakeBundleServer struct {
+	bundlev1.UnimplementedBundleServer
+	bundle *types.Bundle
+	err    error
+
+	// getBundle overrides bundle and err when set; call counts from zero
+	getBundle func(call int) (*types.Bundle, error)
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *fakeBundleServer) GetBundle(ctx context.Context, req *bundlev1.GetBundleRequest) (*types.Bundle, error) {
+	s.mu.Lock()
+	call := s.calls
+	s.calls++
+	s.mu.Unlock()
+
+	if s.getBundle != nil {
+		return s.getBundle(call)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.bundle, nil
+}
+
+// newBundleTestClient returns a client connected to a fake Bundle service
+func newBundleTestClient(t *testing.T, server *fakeBundleServer) *Client {
+	t.Helper()
+	return newTestClient(t, func(s *grpc.Server) {
+		bundlev1.RegisterBundleServer(s, server)
+	})
+}
+
+// testFixtureBundle returns a bundle containing the CA fixture
+func testFixtureBundle() *types.Bundle {
+	return &types.Bundle{
+		TrustDomain: "example.org",
+		X509Authorities: []*types.X509Certificate{
+			{Asn1: testCACert.Raw},
+		},
+	}
+}
+
+func TestClient_FetchX509Authorities(t *testing.T) {
+	t.Run("parses authorities", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: testFixtureBundle()})
+
+		authorities, err := client.FetchX509Authorities(context.Background())
+		require.NoError(t, err)
+		require.Len(t, authorities, 1)
+		assert.True(t, authorities[0].Equal(testCACert))
+	})
+
+	t.Run("invalid authority", func(t *testing.T) {
+		bundle := testFixtureBundle()
+		bundle.X509Authorities = append(bundle.X509Authorities, &types.X509Certificate{Asn1: []byte{0x00, 0x01}})
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: bundle})
+
+		authorities, err := client.FetchX509Authorities(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse X.509 authority at index 1")
+		assert.Nil(t, authorities)
+	})
+
+	t.Run("GetBundle error", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{err: status.Error(codes.Internal, "boom")})
+
+		authorities, err := client.FetchX509Authorities(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to get bundle")
+		assert.Nil(t, authorities)
+	})
+}
+
+func TestClient_FetchX509Bundle(t *testing.T) {
+	t.Run("builds bundle for the trust domain", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: testFixtureBundle()})
+
+		bundle, err := client.FetchX509Bundle(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "example.org", bundle.TrustDomain().String())
+		require.Len(t, bundle.X509Authorities(), 1)
+		assert.True(t, bundle.X509Authorities()[0].Equal(testCACert))
+	})
+
+	t.Run("invalid trust domain", func(t *testing.T) {
+		bundle := testFixtureBundle()
+		bundle.TrustDomain = "Invalid Domain"
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: bundle})
+
+		_, err := client.FetchX509Bundle(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid trust domain")
+	})
+}
+
+func TestClient_BuildCertPool(t *testing.T) {
+	t.Run("pool verifies CA-signed certificate", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: testFixtureBundle()})
+
+		pool, err := client.BuildCertPool(context.Background())
+		require.NoError(t, err)
+
+		config, err := NewTLSConfig(WithRootCAs(pool))
+		require.NoError(t, err)
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/server")}, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("GetBundle error", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{err: status.Error(codes.Unavailable, "down")})
+
+		pool, err := client.BuildCertPool(context.Background())
+		assert.Error(t, err)
+		assert.Nil(t, pool)
+	})
+}
+
+// shortenBundleWatchIntervals speeds up WatchBundle polling for the duration of a test
+func shortenBundleWatchIntervals(t *testing.T) {
+	interval, initial, max := bundleWatchInterval, bundleWatchInitialBackoff, bundleWatchMaxBackoff
+	bundleWatchInterval = 5 * time.Millisecond
+	bundleWatchInitialBackoff = time.Millisecond
+	bundleWatchMaxBackoff = 4 * time.Millisecond
+	t.Cleanup(func() {
+		bundleWatchInterval, bundleWatchInitialBackoff, bundleWatchMaxBackoff = interval, initial, max
+	})
+}
+
+func TestClient_WatchBundle(t *testing.T) {
+	shortenBundleWatchIntervals(t)
+
+	t.Run("reports changes and retries transient errors", func(t *testing.T) {
+		server := &fakeBundleServer{
+			getBundle: func(call int) (*types.Bundle, error) {
+				switch {
+				case call < 2:
+					return &types.Bundle{TrustDomain: "example.org", SequenceNumber: 1}, nil
+				case call < 5:
+					return nil, status.Error(codes.Unavailable, "restarting")
+				default:
+					return &types.Bundle{TrustDomain: "example.org", SequenceNumber: 2}, nil
+				}
+			},
+		}
+		client := newBundleTestClient(t, server)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var sequences []uint64
+		err := client.WatchBundle(ctx, func(bundle *types.Bundle) {
+			sequences = append(sequences, bundle.SequenceNumber)
+			if len(sequences) == 2 {
+				cancel()
+			}
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []uint64{1, 2}, sequences)
+	})
+
+	t.Run("stops on non-transient error", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{err: status.Error(codes.PermissionDenied, "denied")})
+
+		err := client.WatchBundle(context.Background(), func(*types.Bundle) {
+			t.Error("onChange should not be called")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestClient_WatchBundleAsync(t *testing.T) {
+	shortenBundleWatchIntervals(t)
+
+	client := newBundleTestClient(t, &fakeBundleServer{bundle: testFixtureBundle()})
+
+	received := make(chan *types.Bundle, 1)
+	cancel, errCh := client.WatchBundleAsync(context.Background(), func(bundle *types.Bundle) {
+		received <- bundle
+	})
+
+	select {
+	case bundle := <-received:
+		assert.Equal(t, "example.org", bundle.TrustDomain)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for bundle")
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+	_, open := <-errCh
+	assert.False(t, open)
+}
+
+// newTestAuthority creates a self-signed CA certificate with the given common name
+func newTestAuthority(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestCompareBundles(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	shared1 := newTestAuthority(t, "shared CA 1")
+	shared2 := newTestAuthority(t, "shared CA 2")
+	retired := newTestAuthority(t, "retired CA")
+	rotated := newTestAuthority(t, "rotated CA")
+
+	a := x509bundle.FromX509Authorities(td, []*x509.Certificate{shared1, retired, shared2})
+	b := x509bundle.FromX509Authorities(td, []*x509.Certificate{rotated, shared2, shared1})
+
+	t.Run("diverging", func(t *testing.T) {
+		diff := CompareBundles(a, b)
+		assert.Equal(t, []*x509.Certificate{rotated}, diff.AddedAuthorities)
+		assert.Equal(t, []*x509.Certificate{retired}, diff.RemovedAuthorities)
+		assert.Equal(t, 2, diff.Unchanged)
+		assert.False(t, diff.IsEmpty())
+
+		summary := diff.Summary()
+		assert.Contains(t, summary, "bundles differ: 1 added, 1 removed, 2 unchanged")
+		assert.Contains(t, summary, "+ CN=rotated CA")
+		assert.Contains(t, summary, "- CN=retired CA")
+	})
+
+	t.Run("reversed", func(t *testing.T) {
+		diff := CompareBundles(b, a)
+		assert.Equal(t, []*x509.Certificate{retired}, diff.AddedAuthorities)
+		assert.Equal(t, []*x509.Certificate{rotated}, diff.RemovedAuthorities)
+	})
+
+	t.Run("identical", func(t *testing.T) {
+		// A re-parsed copy compares equal because only the DER encoding matters
+		copied, err := x509.ParseCertificate(shared1.Raw)
+		require.NoError(t, err)
+		c := x509bundle.FromX509Authorities(td, []*x509.Certificate{shared2, copied})
+		d := x509bundle.FromX509Authorities(td, []*x509.Certificate{shared1, shared2})
+
+		diff := CompareBundles(c, d)
+		assert.True(t, diff.IsEmpty())
+		assert.Empty(t, diff.AddedAuthorities)
+		assert.Empty(t, diff.RemovedAuthorities)
+		assert.Equal(t, 2, diff.Unchanged)
+		assert.Equal(t, "bundles are identical (2 authorities)", diff.Summary())
+	})
+
+	t.Run("nil bundle", func(t *testing.T) {
+		diff := CompareBundles(nil, a)
+		assert.Len(t, diff.AddedAuthorities, 3)
+		assert.Empty(t, diff.RemovedAuthorities)
+		assert.Zero(t, diff.Unchanged)
+
+		assert.True(t, CompareBundles(nil, nil).IsEmpty())
+	})
+}
+
+func TestWriteBundleToWriter(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca1 := newTestAuthority(t, "CA one")
+	ca2 := newTestAuthority(t, "CA two")
+	bundle := x509bundle.FromX509Authorities(td, []*x509.Certificate{ca1, ca2})
+
+	write := func(t *testing.T, format string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, WriteBundleToWriter(&buf, bundle, format))
+		return buf.Bytes()
+	}
+
+	t.Run("pem", func(t *testing.T) {
+		out := write(t, BundleFormatPEM)
+
+		block, rest := pem.Decode(out)
+		require.NotNil(t, block)
+		assert.Equal(t, "CERTIFICATE", block.Type)
+		assert.Equal(t, ca1.Raw, block.Bytes)
+		block, rest = pem.Decode(rest)
+		require.NotNil(t, block)
+		assert.Equal(t, ca2.Raw, block.Bytes)
+		assert.Empty(t, rest)
+	})
+
+	t.Run("der", func(t *testing.T) {
+		certs, err := x509.ParseCertificates(write(t, BundleFormatDER))
+		require.NoError(t, err)
+		assert.Equal(t, []*x509.Certificate{ca1, ca2}, certs)
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		out := write(t, BundleFormatJWKS)
+		assert.True(t, bytes.HasSuffix(out, []byte("}\n")))
+
+		var jwks jose.JSONWebKeySet
+		require.NoError(t, json.Unmarshal(out, &jwks))
+		require.Len(t, jwks.Keys, 2)
+		for i, ca := range []*x509.Certificate{ca1, ca2} {
+			assert.Equal(t, ca.PublicKey, jwks.Keys[i].Key)
+			require.Len(t, jwks.Keys[i].Certificates, 1)
+			assert.True(t, jwks.Keys[i].Certificates[0].Equal(ca))
+			assert.Empty(t, jwks.Keys[i].Use)
+		}
+	})
+
+	t.Run("spiffe-bundle", func(t *testing.T) {
+		out := write(t, BundleFormatSPIFFE)
+
+		var doc struct {
+			Keys []struct {
+				Use string   `json:"use"`
+				X5c []string `json:"x5c"`
+			} `json:"keys"`
+		}
+		require.NoError(t, json.Unmarshal(out, &doc))
+		require.Len(t, doc.Keys, 2)
+		assert.Equal(t, "x509-svid", doc.Keys[0].Use)
+		assert.Len(t, doc.Keys[0].X5c, 1)
+
+		parsed, err := spiffebundle.Parse(td, out)
+		require.NoError(t, err)
+		assert.Equal(t, []*x509.Certificate{ca1, ca2}, parsed.X509Authorities())
+	})
+
+	t.Run("empty bundle", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteBundleToWriter(&buf, x509bundle.New(td), BundleFormatPEM))
+		assert.Empty(t, buf.Bytes())
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		err := WriteBundleToWriter(&bytes.Buffer{}, bundle, "p12")
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Contains(t, err.Error(), `unsupported bundle format "p12"`)
+	})
+
+	t.Run("nil bundle", func(t *testing.T) {
+		var validationErr *ValidationError
+		assert.ErrorAs(t, WriteBundleToWriter(&bytes.Buffer{}, nil, BundleFormatPEM), &validationErr)
+	})
+}
@@ -0,0 +1,153 @@
+package spireclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultBundleRefreshInterval is how often a BundleRefresher fetches the trust bundle when no interval is given
+const DefaultBundleRefreshInterval = 5 * time.Minute
+
+// BundleRefresher keeps the root CAs of a TLS configuration in step with the SPIRE Server trust bundle
+// The bundle is fetched periodically so that connections keep verifying after a CA rotation
+type BundleRefresher struct {
+	client   *Client
+	interval time.Duration
+	config   *tls.Config
+
+	mu      sync.RWMutex
+	rootCAs *x509.CertPool
+	started bool
+}
+
+// NewBundleRefresher creates a refresher that fetches the trust bundle from client every interval
+// opts are applied as for NewTLSConfig; the server chain is additionally verified against the latest bundle
+// A non-positive interval uses DefaultBundleRefreshInterval. Call Start to fetch the first bundle
+func NewBundleRefresher(client *Client, interval time.Duration, opts ...TLSOption) (*BundleRefresher, error) {
+	if client == nil {
+		return nil, &ValidationError{Err: errors.New("client is required")}
+	}
+	if interval <= 0 {
+		interval = DefaultBundleRefreshInterval
+	}
+
+	config, err := NewTLSConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &BundleRefresher{
+		client:   client,
+		interval: interval,
+		config:   config,
+	}
+
+	// The pool is read at handshake time rather than stored in config.RootCAs,
+	// so it can be swapped while the configuration is in use
+	next := config.VerifyPeerCertificate
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+
+		rootCAs := r.RootCAs()
+		if rootCAs == nil {
+			return &TLSConfigError{Err: errors.New("trust bundle has not been fetched yet")}
+		}
+		if len(rawCerts) == 0 {
+			return &TLSConfigError{Err: errors.New("no server certificate presented")}
+		}
+
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return &TLSConfigError{Err: fmt.Errorf("failed to parse server certificate: %w", err)}
+		}
+		return verifyChain(cert, rawCerts[1:], rootCAs)
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the TLS configuration verified against the latest trust bundle
+// The same configuration is returned on every call and must not be modified
+func (r *BundleRefresher) TLSConfig() *tls.Config {
+	return r.config
+}
+
+// RootCAs returns the pool built from the most recently fetched trust bundle, or nil before the first fetch
+func (r *BundleRefresher) RootCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rootCAs
+}
+
+// Start fetches the trust bundle and then refreshes it in the background every interval until ctx is done
+// An error is returned if the first fetch fails. Later failures are logged through the client's Logger
+// and the previous bundle is kept
+func (r *BundleRefresher) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.started {
+		r.mu.Unlock()
+		return errors.New("refresher already started")
+	}
+	r.started = true
+	r.mu.Unlock()
+
+	if err := r.refresh(ctx); err != nil {
+		r.mu.Lock()
+		r.started = false
+		r.mu.Unlock()
+		return err
+	}
+
+	go r.run(ctx)
+
+	return nil
+}
+
+// run refreshes the trust bundle every interval until ctx is done
+func (r *BundleRefresher) run(ctx context.Context) {
+	logger := configLogger(r.client.config)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.refresh(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("failed to refresh trust bundle", err)
+			}
+		}
+	}
+}
+
+// refresh fetches the trust bundle and swaps in a pool of its X.509 authorities
+// A bundle without X.509 authorities is rejected so that a bad response cannot empty the pool
+func (r *BundleRefresher) refresh(ctx context.Context) error {
+	authorities, err := r.client.FetchX509Authorities(ctx)
+	if err != nil {
+		return err
+	}
+	if len(authorities) == 0 {
+		return fmt.Errorf("trust bundle has no X.509 authorities")
+	}
+
+	pool := x509.NewCertPool()
+	for _, authority := range authorities {
+		pool.AddCert(authority)
+	}
+
+	r.mu.Lock()
+	r.rootCAs = pool
+	r.mu.Unlock()
+	return nil
+}
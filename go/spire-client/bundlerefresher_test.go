@@ -0,0 +1,120 @@
+package spireclient
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBundleRefresher(t *testing.T) {
+	rotated := newTestAuthority(t, "rotated CA")
+	serverCert := newTestCASignedCert(t, "spiffe://example.org/spire/server")
+
+	t.Run("swaps the pool when the bundle rotates", func(t *testing.T) {
+		// The first fetch returns the CA fixture and later fetches the rotated CA
+		server := &fakeBundleServer{getBundle: func(call int) (*types.Bundle, error) {
+			if call == 0 {
+				return testFixtureBundle(), nil
+			}
+			return &types.Bundle{
+				TrustDomain:     "example.org",
+				X509Authorities: []*types.X509Certificate{{Asn1: rotated.Raw}},
+			}, nil
+		}}
+		client := newBundleTestClient(t, server)
+
+		refresher, err := NewBundleRefresher(client, 50*time.Millisecond)
+		require.NoError(t, err)
+		config := refresher.TLSConfig()
+
+		assert.Nil(t, refresher.RootCAs())
+		assert.ErrorContains(t, config.VerifyPeerCertificate([][]byte{serverCert}, nil), "trust bundle has not been fetched yet")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, refresher.Start(ctx))
+
+		first := refresher.RootCAs()
+		require.NotNil(t, first)
+		assert.NoError(t, config.VerifyPeerCertificate([][]byte{serverCert}, nil))
+
+		require.Eventually(t, func() bool {
+			return !refresher.RootCAs().Equal(first)
+		}, 5*time.Second, 10*time.Millisecond, "pool should change after the bundle rotates")
+
+		want := x509.NewCertPool()
+		want.AddCert(rotated)
+		assert.True(t, refresher.RootCAs().Equal(want))
+
+		// The same configuration now rejects certificates issued by the retired CA
+		var tlsErr *TLSConfigError
+		assert.ErrorAs(t, config.VerifyPeerCertificate([][]byte{serverCert}, nil), &tlsErr)
+	})
+
+	t.Run("keeps the previous pool when a refresh fails", func(t *testing.T) {
+		server := &fakeBundleServer{getBundle: func(call int) (*types.Bundle, error) {
+			switch call {
+			case 0:
+				return testFixtureBundle(), nil
+			case 1:
+				return &types.Bundle{TrustDomain: "example.org"}, nil
+			default:
+				return nil, status.Error(codes.Unavailable, "server down")
+			}
+		}}
+		client := newBundleTestClient(t, server)
+
+		refresher, err := NewBundleRefresher(client, 20*time.Millisecond)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, refresher.Start(ctx))
+		first := refresher.RootCAs()
+
+		require.Eventually(t, func() bool {
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			return server.calls >= 3
+		}, 5*time.Second, 10*time.Millisecond)
+
+		assert.Same(t, first, refresher.RootCAs())
+		assert.NoError(t, refresher.TLSConfig().VerifyPeerCertificate([][]byte{serverCert}, nil))
+	})
+
+	t.Run("first fetch failure", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{err: status.Error(codes.Internal, "boom")})
+
+		refresher, err := NewBundleRefresher(client, time.Minute)
+		require.NoError(t, err)
+
+		err = refresher.Start(context.Background())
+		assert.ErrorContains(t, err, "failed to get bundle")
+		assert.Nil(t, refresher.RootCAs())
+	})
+
+	t.Run("already started", func(t *testing.T) {
+		client := newBundleTestClient(t, &fakeBundleServer{bundle: testFixtureBundle()})
+
+		refresher, err := NewBundleRefresher(client, time.Minute)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, refresher.Start(ctx))
+		assert.ErrorContains(t, refresher.Start(ctx), "already started")
+	})
+
+	t.Run("requires a client", func(t *testing.T) {
+		_, err := NewBundleRefresher(nil, time.Minute)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
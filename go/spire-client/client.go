@@ -3,74 +3,307 @@ package spireclient
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
 // Client represents a SPIRE Server client
 type Client struct {
+	mu     sync.RWMutex
 	conn   *grpc.ClientConn
 	config *Config
 }
 
 // Config holds the configuration for the SPIRE client
 type Config struct {
-	// Address is the SPIRE Server address (host:port)
+	// Address is the SPIRE Server address (host:port), or a Unix domain socket path
+	// given as "unix:///path/to/api.sock" or "/path/to/api.sock"
 	Address string
 	// TLSConfig is the TLS configuration for the connection
 	TLSConfig *tls.Config
 	// TLSOptions are options for creating TLS configuration if TLSConfig is not provided
 	TLSOptions []TLSOption
+	// DialOptions are additional gRPC dial options applied after the transport credentials
+	DialOptions []grpc.DialOption
+	// DialTimeout bounds connection establishment independently of the caller's context
+	// When non-zero, dialing blocks until the connection is ready or the timeout expires
+	DialTimeout time.Duration
+	// KeepaliveParams enables client-side keepalive pings so idle connections are not dropped by load balancers
+	KeepaliveParams *keepalive.ClientParameters
+	// RetryPolicy enables automatic retries of RPCs failing with transient status codes
+	RetryPolicy *RetryPolicy
+	// OnStateChange is called from a background goroutine on every connectivity state transition
+	// Monitoring stops when the context passed to the constructor (or Reconnect) is done or the connection shuts down
+	OnStateChange func(old, new connectivity.State)
+	// MaxRecvMsgSize is the maximum response size in bytes, for example for bundles with many federated authorities
+	// Zero keeps the gRPC default of 4 MB
+	MaxRecvMsgSize int
+	// MaxSendMsgSize is the maximum request size in bytes; zero keeps the gRPC default
+	MaxSendMsgSize int
+	// Logger receives dial attempts, connection state changes and call durations; nil disables logging
+	Logger Logger
 }
 
-// New creates a new SPIRE client with TLS connection
-func New(ctx context.Context, address string) (*Client, error) {
+// DefaultKeepaliveParams returns keepalive parameters suitable for long-lived connections to SPIRE Server
+func DefaultKeepaliveParams() *keepalive.ClientParameters {
+	return &keepalive.ClientParameters{
+		Time:                30 * time.Second,
+		Timeout:             10 * time.Second,
+		PermitWithoutStream: true,
+	}
+}
+
+// ClientOption configures a client created by NewClientWithOptions
+type ClientOption interface {
+	applyClient(*Config)
+}
+
+// clientOptionFunc adapts a function to ClientOption
+type clientOptionFunc func(*Config)
+
+func (f clientOptionFunc) applyClient(config *Config) {
+	f(config)
+}
+
+// WithTLSConfig returns a client option that sets Config.TLSConfig
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return clientOptionFunc(func(config *Config) {
+		config.TLSConfig = cfg
+	})
+}
+
+// WithTLSOptions returns a client option that appends to Config.TLSOptions
+func WithTLSOptions(opts ...TLSOption) ClientOption {
+	return clientOptionFunc(func(config *Config) {
+		config.TLSOptions = append(config.TLSOptions, opts...)
+	})
+}
+
+// WithDialOptions returns a client option that appends to Config.DialOptions
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return clientOptionFunc(func(config *Config) {
+		config.DialOptions = append(config.DialOptions, opts...)
+	})
+}
+
+// WithKeepalive returns a client option that sets Config.KeepaliveParams
+func WithKeepalive(params keepalive.ClientParameters) ClientOption {
+	return clientOptionFunc(func(config *Config) {
+		config.KeepaliveParams = &params
+	})
+}
+
+// withConfig returns a client option that replaces the configuration with a copy of cfg
+func withConfig(cfg *Config) ClientOption {
+	return clientOptionFunc(func(config *Config) {
+		*config = *cfg
+		config.DialOptions = append([]grpc.DialOption{}, cfg.DialOptions...)
+	})
+}
+
+// DialTimeoutOption sets the dial timeout and is accepted both as a grpc.DialOption and as a ClientOption
+type DialTimeoutOption struct {
+	grpc.EmptyDialOption
+	timeout time.Duration
+}
+
+func (o DialTimeoutOption) applyClient(config *Config) {
+	config.DialTimeout = o.timeout
+}
+
+// WithDialTimeout returns an option that sets Config.DialTimeout
+func WithDialTimeout(d time.Duration) DialTimeoutOption {
+	return DialTimeoutOption{timeout: d}
+}
+
+// WithMaxMessageSize returns a dial option that sets the maximum receive and send message sizes in bytes
+// A zero size keeps the gRPC default
+func WithMaxMessageSize(recv, send int) grpc.DialOption {
+	return grpc.WithDefaultCallOptions(maxMessageSizeCallOptions(recv, send)...)
+}
+
+// maxMessageSizeCallOptions returns call options for the non-zero message size limits
+func maxMessageSizeCallOptions(recv, send int) []grpc.CallOption {
+	var opts []grpc.CallOption
+	if recv != 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(recv))
+	}
+	if send != 0 {
+		opts = append(opts, grpc.MaxCallSendMsgSize(send))
+	}
+	return opts
+}
+
+// NewClientWithOptions creates a new SPIRE client configured by opts
+// Options are applied in order, so later options override earlier ones
+func NewClientWithOptions(ctx context.Context, address string, opts ...ClientOption) (*Client, error) {
 	if address == "" {
-		return nil, fmt.Errorf("address is required")
+		return nil, &ValidationError{Err: errors.New("address is required")}
 	}
 
-	config := &Config{
-		Address: address,
+	config := &Config{Address: address}
+	for _, opt := range opts {
+		opt.applyClient(config)
 	}
 
 	return newClient(ctx, config)
 }
 
+// New creates a new SPIRE client with TLS connection
+func New(ctx context.Context, address string, opts ...grpc.DialOption) (*Client, error) {
+	return NewClientWithOptions(ctx, address, WithDialOptions(opts...))
+}
+
 // NewMTLS creates a new SPIRE client with mTLS connection
-func NewMTLS(ctx context.Context, address string, certFile, keyFile string) (*Client, error) {
+func NewMTLS(ctx context.Context, address string, certFile, keyFile string, opts ...grpc.DialOption) (*Client, error) {
 	if address == "" {
-		return nil, fmt.Errorf("address is required")
+		return nil, &ValidationError{Err: errors.New("address is required")}
 	}
 
 	if certFile == "" || keyFile == "" {
-		return nil, fmt.Errorf("both certFile and keyFile are required for mTLS")
+		return nil, &ValidationError{Err: errors.New("both certFile and keyFile are required for mTLS")}
+	}
+
+	return NewClientWithOptions(ctx, address,
+		WithTLSOptions(WithClientCertificates(certFile, keyFile)),
+		WithDialOptions(opts...),
+	)
+}
+
+// NewAgentClient creates a new client connected through a local Unix domain socket
+// Unix domain socket connections are protected by filesystem permissions rather than TLS,
+// so opts are recorded in the configuration but not used to secure the connection
+func NewAgentClient(ctx context.Context, socketPath string, opts ...TLSOption) (*Client, error) {
+	if socketPath == "" {
+		return nil, &ValidationError{Err: errors.New("socket path is required")}
 	}
 
-	config := &Config{
-		Address: address,
-		TLSOptions: []TLSOption{
-			WithClientCertificates(certFile, keyFile),
-		},
+	if !isUnixAddress(socketPath) {
+		socketPath = "unix://" + socketPath
 	}
 
-	return newClient(ctx, config)
+	return NewClientWithOptions(ctx, socketPath, WithTLSOptions(opts...))
 }
 
 // NewWithConfig creates a new SPIRE client with custom configuration
-func NewWithConfig(ctx context.Context, config *Config) (*Client, error) {
-	return newClient(ctx, config)
+// opts are applied after config.DialOptions
+func NewWithConfig(ctx context.Context, config *Config, opts ...grpc.DialOption) (*Client, error) {
+	if config == nil {
+		return nil, &ValidationError{Err: errors.New("config is required")}
+	}
+
+	// withConfig copies the config so the caller's DialOptions are not modified
+	return NewClientWithOptions(ctx, config.Address, withConfig(config), WithDialOptions(opts...))
 }
 
 // newClient is the internal client creation function
 func newClient(ctx context.Context, config *Config) (*Client, error) {
+	if err := validateConfig(ctx, config); err != nil {
+		return nil, &ValidationError{Err: err}
+	}
+
+	conn, err := dial(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	watchState(ctx, conn, stateChangeFunc(config))
+
+	return &Client{
+		conn:   conn,
+		config: config,
+	}, nil
+}
+
+// ValidateConfig checks cfg for missing fields and inconsistent field combinations
+// All violations are reported together in a *ConfigValidationError
+func ValidateConfig(cfg *Config) error {
+	return validateConfig(context.Background(), cfg)
+}
+
+// validateConfig is ValidateConfig that also checks the dial timeout against the deadline of ctx
+func validateConfig(ctx context.Context, config *Config) error {
 	if config == nil {
-		return nil, fmt.Errorf("config is required")
+		return &ConfigValidationError{violations: []string{"config is required"}}
 	}
 
+	var violations []string
 	if config.Address == "" {
-		return nil, fmt.Errorf("address is required")
+		violations = append(violations, "address is required")
+	}
+	if config.TLSConfig != nil && len(config.TLSOptions) > 0 {
+		violations = append(violations, "TLSConfig and TLSOptions are both set; TLSOptions are ignored when TLSConfig is provided, so set only one")
+	}
+
+	timeout := dialTimeout(config)
+	if timeout < 0 {
+		violations = append(violations, fmt.Sprintf("dial timeout must not be negative, got %s", timeout))
+	}
+	if deadline, ok := ctx.Deadline(); ok && timeout > 0 {
+		if remaining := time.Until(deadline); timeout > remaining {
+			violations = append(violations, fmt.Sprintf("dial timeout %s exceeds the %s remaining before the context deadline; lower DialTimeout or extend the context deadline", timeout, remaining.Round(time.Millisecond)))
+		}
+	}
+
+	if config.MaxRecvMsgSize < 0 {
+		violations = append(violations, fmt.Sprintf("MaxRecvMsgSize must not be negative, got %d; use zero for the gRPC default", config.MaxRecvMsgSize))
+	}
+	if config.MaxSendMsgSize < 0 {
+		violations = append(violations, fmt.Sprintf("MaxSendMsgSize must not be negative, got %d; use zero for the gRPC default", config.MaxSendMsgSize))
+	}
+	if config.RetryPolicy != nil {
+		if _, err := config.RetryPolicy.serviceConfig(); err != nil {
+			violations = append(violations, fmt.Sprintf("invalid retry policy: %v", err))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ConfigValidationError{violations: violations}
+	}
+	return nil
+}
+
+// dial establishes the gRPC connection described by config, logging the attempt and its outcome
+func dial(ctx context.Context, config *Config) (*grpc.ClientConn, error) {
+	logger := configLogger(config)
+	timeout := dialTimeout(config)
+	logger.Info("dialing SPIRE Server", "address", config.Address, "timeout", timeout)
+
+	start := time.Now()
+	conn, err := dialConn(ctx, config, timeout)
+	if err != nil {
+		logger.Error("failed to dial SPIRE Server", err, "address", config.Address, "duration", time.Since(start))
+		return nil, err
+	}
+	logger.Info("dialed SPIRE Server", "address", config.Address, "duration", time.Since(start))
+
+	return conn, nil
+}
+
+// dialConn dials config.Address over a Unix domain socket or TLS
+// A non-zero timeout makes the dial block until the connection is ready
+func dialConn(ctx context.Context, config *Config, timeout time.Duration) (*grpc.ClientConn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		blocking := *config
+		blocking.DialOptions = append(append([]grpc.DialOption{}, config.DialOptions...), grpc.WithBlock())
+		config = &blocking
+	}
+
+	if isUnixAddress(config.Address) {
+		return dialUnix(ctx, config)
 	}
 
 	// Use provided TLSConfig or create one with options
@@ -79,36 +312,186 @@ func newClient(ctx context.Context, config *Config) (*Client, error) {
 		var err error
 		tlsConfig, err = NewTLSConfig(config.TLSOptions...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create TLS configuration: %w", err)
+			return nil, &TLSConfigError{Err: fmt.Errorf("failed to create TLS configuration: %w", err)}
 		}
 	}
 
 	// Create TLS credentials
 	creds := credentials.NewTLS(tlsConfig)
 
-	// Dial with TLS
-	conn, err := grpc.DialContext(ctx, config.Address,
-		grpc.WithTransportCredentials(creds),
-	)
+	extraOpts, err := configDialOptions(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to SPIRE Server: %w", err)
+		return nil, err
 	}
 
-	return &Client{
-		conn:   conn,
-		config: config,
-	}, nil
+	// Dial with TLS, followed by any config-derived and caller-supplied options
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, extraOpts...)
+	conn, err := grpc.DialContext(ctx, config.Address, dialOpts...)
+	if err != nil {
+		return nil, &ConnectionError{Err: fmt.Errorf("failed to connect to SPIRE Server: %w", err)}
+	}
+
+	return conn, nil
+}
+
+// dialUnix establishes a gRPC connection over a Unix domain socket
+func dialUnix(ctx context.Context, config *Config) (*grpc.ClientConn, error) {
+	socketPath := strings.TrimPrefix(config.Address, "unix://")
+	if socketPath == "" {
+		return nil, &ValidationError{Err: errors.New("socket path is required")}
+	}
+
+	extraOpts, err := configDialOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socketPath)
+	}
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+	}, extraOpts...)
+	conn, err := grpc.DialContext(ctx, "unix://"+socketPath, dialOpts...)
+	if err != nil {
+		return nil, &ConnectionError{Err: fmt.Errorf("failed to connect to %s: %w", socketPath, err)}
+	}
+
+	return conn, nil
+}
+
+// configDialOptions returns the dial options derived from config fields followed by config.DialOptions
+func configDialOptions(config *Config) ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	if config.KeepaliveParams != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*config.KeepaliveParams))
+	}
+	if config.RetryPolicy != nil {
+		serviceConfig, err := config.RetryPolicy.serviceConfig()
+		if err != nil {
+			return nil, &ValidationError{Err: fmt.Errorf("invalid retry policy: %w", err)}
+		}
+		opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	for _, callOpt := range maxMessageSizeCallOptions(config.MaxRecvMsgSize, config.MaxSendMsgSize) {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpt))
+	}
+	if config.Logger != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(newLoggingInterceptor(config.Logger)))
+	}
+	return append(opts, config.DialOptions...), nil
+}
+
+// dialTimeout returns the dial timeout from config, preferring one set via WithDialTimeout
+func dialTimeout(config *Config) time.Duration {
+	timeout := config.DialTimeout
+	for _, opt := range config.DialOptions {
+		if o, ok := opt.(DialTimeoutOption); ok {
+			timeout = o.timeout
+		}
+	}
+	return timeout
+}
+
+// watchState calls onChange for each state transition of conn until ctx is done or conn shuts down
+// The transition to Shutdown is reported before the watcher exits
+func watchState(ctx context.Context, conn *grpc.ClientConn, onChange func(old, new connectivity.State)) {
+	if onChange == nil {
+		return
+	}
+
+	state := conn.GetState()
+	go func() {
+		for state != connectivity.Shutdown {
+			if !conn.WaitForStateChange(ctx, state) {
+				return
+			}
+			next := conn.GetState()
+			onChange(state, next)
+			state = next
+		}
+	}()
+}
+
+// isUnixAddress reports whether address refers to a Unix domain socket
+func isUnixAddress(address string) bool {
+	return strings.HasPrefix(address, "unix://") || strings.HasPrefix(address, "/")
+}
+
+// Reconnect replaces the underlying gRPC connection with a new one dialed from the stored configuration
+// Service clients obtained before or after the call use the new connection for subsequent RPCs
+func (c *Client) Reconnect(ctx context.Context) error {
+	if c.config == nil {
+		return &ValidationError{Err: errors.New("config is required")}
+	}
+
+	conn, err := dial(ctx, c.config)
+	if err != nil {
+		return err
+	}
+	watchState(ctx, conn, stateChangeFunc(c.config))
+
+	c.mu.Lock()
+	old := c.conn
+	c.conn = conn
+	c.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	return nil
 }
 
 // Close closes the client connection
 func (c *Client) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
+	conn := c.Connection()
+	if conn != nil {
+		return conn.Close()
 	}
 	return nil
 }
 
 // Connection returns the underlying gRPC connection
 func (c *Client) Connection() *grpc.ClientConn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.conn
 }
+
+// ConnectionState returns the connectivity state of the underlying gRPC connection
+// Returns connectivity.Shutdown when there is no connection
+func (c *Client) ConnectionState() connectivity.State {
+	conn := c.Connection()
+	if conn == nil {
+		return connectivity.Shutdown
+	}
+	return conn.GetState()
+}
+
+// WaitForReady blocks until the connection is ready or the context expires
+func (c *Client) WaitForReady(ctx context.Context) error {
+	conn := c.Connection()
+	if conn == nil {
+		return &ConnectionError{Err: errors.New("client is not connected")}
+	}
+
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Shutdown:
+			return &ConnectionError{Err: errors.New("connection is shut down")}
+		case connectivity.Idle:
+			conn.Connect()
+		}
+
+		if !conn.WaitForStateChange(ctx, state) {
+			return &ConnectionError{Err: fmt.Errorf("connection not ready (last state %s): %w", state, ctx.Err())}
+		}
+	}
+}
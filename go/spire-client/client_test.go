@@ -3,12 +3,55 @@ package spireclient
 import (
 	"context"
 	"crypto/tls"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
+// startTestServer starts a TLS gRPC server presenting a SPIFFE certificate and returns its address
+// register is called to install fake services before the server starts
+func startTestServer(t *testing.T, register ...func(*grpc.Server)) string {
+	t.Helper()
+
+	cert := newTestCASignedKeyPair(t, "spiffe://example.org/spire/server")
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})))
+	for _, r := range register {
+		r(server)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// newTestClient starts a test server with the given fake services and returns a client connected to it
+func newTestClient(t *testing.T, register ...func(*grpc.Server)) *Client {
+	t.Helper()
+
+	client, err := New(context.Background(), startTestServer(t, register...))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -193,6 +236,681 @@ func TestNewWithConfig(t *testing.T) {
 	}
 }
 
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    *Config
+		violation string
+	}{
+		{
+			name:      "nil config",
+			config:    nil,
+			violation: "config is required",
+		},
+		{
+			name:      "missing address",
+			config:    &Config{},
+			violation: "address is required",
+		},
+		{
+			name: "TLSConfig and TLSOptions",
+			config: &Config{
+				Address:    "localhost:8081",
+				TLSConfig:  &tls.Config{},
+				TLSOptions: []TLSOption{WithTLS13Only()},
+			},
+			violation: "TLSConfig and TLSOptions are both set",
+		},
+		{
+			name:      "negative dial timeout",
+			config:    &Config{Address: "localhost:8081", DialTimeout: -time.Second},
+			violation: "dial timeout must not be negative, got -1s",
+		},
+		{
+			name:      "negative dial timeout option",
+			config:    &Config{Address: "localhost:8081", DialOptions: []grpc.DialOption{WithDialTimeout(-time.Second)}},
+			violation: "dial timeout must not be negative, got -1s",
+		},
+		{
+			name:      "negative MaxRecvMsgSize",
+			config:    &Config{Address: "localhost:8081", MaxRecvMsgSize: -1},
+			violation: "MaxRecvMsgSize must not be negative, got -1",
+		},
+		{
+			name:      "negative MaxSendMsgSize",
+			config:    &Config{Address: "localhost:8081", MaxSendMsgSize: -1},
+			violation: "MaxSendMsgSize must not be negative, got -1",
+		},
+		{
+			name:      "invalid retry policy",
+			config:    &Config{Address: "localhost:8081", RetryPolicy: &RetryPolicy{MaxAttempts: 1}},
+			violation: "invalid retry policy: max attempts must be between 2 and 5, got 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config)
+
+			var configErr *ConfigValidationError
+			require.ErrorAs(t, err, &configErr)
+			require.Len(t, configErr.Violations(), 1)
+			assert.Contains(t, configErr.Violations()[0], tt.violation)
+		})
+	}
+
+	t.Run("valid config", func(t *testing.T) {
+		assert.NoError(t, ValidateConfig(&Config{
+			Address:        "localhost:8081",
+			TLSOptions:     []TLSOption{WithTLS13Only()},
+			DialTimeout:    time.Second,
+			MaxRecvMsgSize: 16 << 20,
+			RetryPolicy:    DefaultRetryPolicy(),
+		}))
+	})
+
+	t.Run("reports every violation", func(t *testing.T) {
+		err := ValidateConfig(&Config{TLSConfig: &tls.Config{}, TLSOptions: []TLSOption{WithTLS13Only()}, MaxSendMsgSize: -1})
+
+		var configErr *ConfigValidationError
+		require.ErrorAs(t, err, &configErr)
+		assert.Len(t, configErr.Violations(), 3)
+		assert.Equal(t, "invalid client config: "+strings.Join(configErr.Violations(), "; "), err.Error())
+	})
+
+	t.Run("dial timeout beyond context deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := NewWithConfig(ctx, &Config{Address: "localhost:8081", DialTimeout: 5 * time.Second})
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr, "newClient should report violations as a ValidationError")
+		var configErr *ConfigValidationError
+		require.ErrorAs(t, err, &configErr)
+		require.Len(t, configErr.Violations(), 1)
+		assert.Contains(t, configErr.Violations()[0], "dial timeout 5s exceeds the")
+		assert.Contains(t, configErr.Violations()[0], "lower DialTimeout or extend the context deadline")
+	})
+}
+
+func TestNewClientWithOptions(t *testing.T) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS13}
+	userAgent := grpc.WithUserAgent("spire-client-test")
+	params := *DefaultKeepaliveParams()
+
+	tests := []struct {
+		name  string
+		opts  []ClientOption
+		check func(t *testing.T, config *Config)
+	}{
+		{
+			name: "no options",
+			check: func(t *testing.T, config *Config) {
+				assert.Equal(t, &Config{}, config)
+			},
+		},
+		{
+			name: "WithTLSConfig",
+			opts: []ClientOption{WithTLSConfig(tlsCfg)},
+			check: func(t *testing.T, config *Config) {
+				assert.Same(t, tlsCfg, config.TLSConfig)
+			},
+		},
+		{
+			name: "WithTLSOptions",
+			opts: []ClientOption{WithTLSOptions(WithTLS13Only()), WithTLSOptions(WithSNIOverride("spire-server"))},
+			check: func(t *testing.T, config *Config) {
+				assert.Len(t, config.TLSOptions, 2)
+			},
+		},
+		{
+			name: "WithDialOptions",
+			opts: []ClientOption{WithDialOptions(userAgent)},
+			check: func(t *testing.T, config *Config) {
+				require.Len(t, config.DialOptions, 1)
+				assert.Same(t, userAgent, config.DialOptions[0])
+			},
+		},
+		{
+			name: "WithKeepalive",
+			opts: []ClientOption{WithKeepalive(params)},
+			check: func(t *testing.T, config *Config) {
+				require.NotNil(t, config.KeepaliveParams)
+				assert.Equal(t, params, *config.KeepaliveParams)
+			},
+		},
+		{
+			name: "WithDialTimeout",
+			opts: []ClientOption{WithDialTimeout(time.Second)},
+			check: func(t *testing.T, config *Config) {
+				assert.Equal(t, time.Second, config.DialTimeout)
+			},
+		},
+		{
+			name: "combined options",
+			opts: []ClientOption{
+				WithTLSConfig(tlsCfg),
+				WithTLSOptions(WithTLS13Only()),
+				WithDialOptions(userAgent),
+				WithKeepalive(params),
+				WithDialTimeout(time.Minute),
+				WithDialTimeout(time.Second),
+			},
+			check: func(t *testing.T, config *Config) {
+				assert.Same(t, tlsCfg, config.TLSConfig)
+				assert.Len(t, config.TLSOptions, 1)
+				assert.Len(t, config.DialOptions, 1)
+				assert.Equal(t, params, *config.KeepaliveParams)
+				// Later options override earlier ones
+				assert.Equal(t, time.Second, config.DialTimeout)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			for _, opt := range tt.opts {
+				opt.applyClient(config)
+			}
+			tt.check(t, config)
+		})
+	}
+
+	t.Run("sets address", func(t *testing.T) {
+		client, err := NewClientWithOptions(context.Background(), "localhost:8081", WithDialOptions(userAgent))
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Equal(t, "localhost:8081", client.config.Address)
+		assert.Len(t, client.config.DialOptions, 1)
+	})
+
+	t.Run("empty address", func(t *testing.T) {
+		client, err := NewClientWithOptions(context.Background(), "", WithDialTimeout(time.Second))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "address is required")
+		assert.Nil(t, client)
+	})
+
+	t.Run("dial timeout expires", func(t *testing.T) {
+		start := time.Now()
+		client, err := NewClientWithOptions(context.Background(), "localhost:1", WithDialTimeout(200*time.Millisecond))
+		assert.Error(t, err)
+		assert.Nil(t, client)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("connects with combined options", func(t *testing.T) {
+		client, err := NewClientWithOptions(context.Background(), startTestServer(t),
+			WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			WithKeepalive(params),
+			WithDialOptions(userAgent),
+			WithDialTimeout(5*time.Second),
+		)
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Equal(t, connectivity.Ready, client.ConnectionState())
+	})
+}
+
+func TestDialOptions(t *testing.T) {
+	// Nothing listens on this address, so a blocking dial fails once the context expires
+	const unreachable = "localhost:1"
+
+	t.Run("New forwards WithBlock", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := New(ctx, unreachable, grpc.WithBlock())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to connect to SPIRE Server")
+		assert.Nil(t, client)
+	})
+
+	t.Run("NewMTLS forwards WithBlock", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := NewMTLS(ctx, unreachable, "cert.pem", "key.pem", grpc.WithBlock())
+		assert.Error(t, err)
+		assert.Nil(t, client)
+	})
+
+	t.Run("Config.DialOptions forwards WithBlock", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := NewWithConfig(ctx, &Config{
+			Address:     unreachable,
+			DialOptions: []grpc.DialOption{grpc.WithBlock()},
+		})
+		assert.Error(t, err)
+		assert.Nil(t, client)
+	})
+
+	t.Run("NewWithConfig merges options without modifying config", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		config := &Config{Address: unreachable}
+		client, err := NewWithConfig(ctx, config, grpc.WithBlock())
+		assert.Error(t, err)
+		assert.Nil(t, client)
+		assert.Empty(t, config.DialOptions)
+	})
+
+	t.Run("non-blocking dial succeeds", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := New(ctx, unreachable, grpc.WithUserAgent("spire-client-test"))
+		require.NoError(t, err)
+		client.Close()
+	})
+}
+
+func TestDialTimeout(t *testing.T) {
+	const unreachable = "localhost:1"
+	const timeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name string
+		dial func(ctx context.Context) (*Client, error)
+	}{
+		{
+			name: "Config.DialTimeout",
+			dial: func(ctx context.Context) (*Client, error) {
+				return NewWithConfig(ctx, &Config{Address: unreachable, DialTimeout: timeout})
+			},
+		},
+		{
+			name: "WithDialTimeout",
+			dial: func(ctx context.Context) (*Client, error) {
+				return New(ctx, unreachable, WithDialTimeout(timeout))
+			},
+		},
+		{
+			name: "WithDialTimeout overrides Config.DialTimeout",
+			dial: func(ctx context.Context) (*Client, error) {
+				return NewWithConfig(ctx, &Config{Address: unreachable, DialTimeout: time.Minute}, WithDialTimeout(timeout))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The caller's context outlives the dial timeout
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			client, err := tt.dial(ctx)
+			elapsed := time.Since(start)
+
+			assert.Error(t, err)
+			assert.Nil(t, client)
+			assert.Less(t, elapsed, timeout+time.Second)
+			assert.NoError(t, ctx.Err())
+		})
+	}
+
+	t.Run("successful dial is ready", func(t *testing.T) {
+		client, err := New(context.Background(), startTestServer(t), WithDialTimeout(5*time.Second))
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Equal(t, connectivity.Ready, client.ConnectionState())
+	})
+}
+
+func TestKeepaliveParams(t *testing.T) {
+	t.Run("default params", func(t *testing.T) {
+		params := DefaultKeepaliveParams()
+		assert.Equal(t, 30*time.Second, params.Time)
+		assert.Equal(t, 10*time.Second, params.Timeout)
+		assert.True(t, params.PermitWithoutStream)
+	})
+
+	userAgent := grpc.WithUserAgent("spire-client-test")
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantCount int
+	}{
+		{
+			name:      "not set",
+			config:    &Config{DialOptions: []grpc.DialOption{userAgent}},
+			wantCount: 1,
+		},
+		{
+			name:      "set",
+			config:    &Config{KeepaliveParams: DefaultKeepaliveParams(), DialOptions: []grpc.DialOption{userAgent}},
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := configDialOptions(tt.config)
+			require.NoError(t, err)
+			assert.Len(t, opts, tt.wantCount)
+			// Caller-supplied options come last so they take precedence
+			assert.Same(t, userAgent, opts[len(opts)-1])
+		})
+	}
+
+	t.Run("connects with keepalive", func(t *testing.T) {
+		client, err := NewWithConfig(context.Background(), &Config{
+			Address:         startTestServer(t),
+			KeepaliveParams: DefaultKeepaliveParams(),
+		}, WithDialTimeout(5*time.Second))
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Equal(t, connectivity.Ready, client.ConnectionState())
+	})
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	userAgent := grpc.WithUserAgent("spire-client-test")
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantCount int
+	}{
+		{
+			name:      "not set",
+			config:    &Config{DialOptions: []grpc.DialOption{userAgent}},
+			wantCount: 1,
+		},
+		{
+			name:      "recv only",
+			config:    &Config{MaxRecvMsgSize: 16 << 20, DialOptions: []grpc.DialOption{userAgent}},
+			wantCount: 2,
+		},
+		{
+			name:      "recv and send",
+			config:    &Config{MaxRecvMsgSize: 16 << 20, MaxSendMsgSize: 8 << 20, DialOptions: []grpc.DialOption{userAgent}},
+			wantCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := configDialOptions(tt.config)
+			require.NoError(t, err)
+			assert.Len(t, opts, tt.wantCount)
+			assert.Same(t, userAgent, opts[len(opts)-1])
+		})
+	}
+
+	t.Run("call options", func(t *testing.T) {
+		assert.Empty(t, maxMessageSizeCallOptions(0, 0))
+		assert.Equal(t, []grpc.CallOption{grpc.MaxCallRecvMsgSize(1024)}, maxMessageSizeCallOptions(1024, 0))
+		assert.Equal(t, []grpc.CallOption{grpc.MaxCallSendMsgSize(2048)}, maxMessageSizeCallOptions(0, 2048))
+	})
+
+	// A bundle response larger than the receive limit is rejected
+	server := &fakeBundleServer{bundle: testFixtureBundle()}
+	addr := startTestServer(t, func(s *grpc.Server) {
+		bundlev1.RegisterBundleServer(s, server)
+	})
+
+	limits := []struct {
+		name string
+		dial func() (*Client, error)
+	}{
+		{
+			name: "Config.MaxRecvMsgSize",
+			dial: func() (*Client, error) {
+				return NewWithConfig(context.Background(), &Config{Address: addr, MaxRecvMsgSize: 16})
+			},
+		},
+		{
+			name: "WithMaxMessageSize",
+			dial: func() (*Client, error) {
+				return New(context.Background(), addr, WithMaxMessageSize(16, 0))
+			},
+		},
+	}
+
+	for _, tt := range limits {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.dial()
+			require.NoError(t, err)
+			defer client.Close()
+
+			_, err = client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		})
+	}
+
+	t.Run("within limit", func(t *testing.T) {
+		client, err := New(context.Background(), addr, WithMaxMessageSize(16<<20, 16<<20))
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	t.Run("replaces failing connection", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		// Nothing listens on this address, so the connection never becomes ready
+		client, err := New(ctx, "localhost:1")
+		require.NoError(t, err)
+		defer client.Close()
+
+		old := client.Connection()
+		old.Connect()
+
+		err = client.Reconnect(ctx)
+		require.NoError(t, err)
+		assert.NotSame(t, old, client.Connection())
+
+		assert.NotNil(t, client.AgentClient())
+		assert.NotNil(t, client.BundleClient())
+		assert.NotNil(t, client.EntryClient())
+		assert.NotNil(t, client.SVIDClient())
+		assert.NotNil(t, client.TrustDomainClient())
+	})
+
+	t.Run("existing service clients use new connection", func(t *testing.T) {
+		addr := startTestServer(t, func(s *grpc.Server) {
+			bundlev1.RegisterBundleServer(s, &fakeBundleServer{bundle: testFixtureBundle()})
+		})
+		client, err := New(context.Background(), addr)
+		require.NoError(t, err)
+		defer client.Close()
+
+		bundleClient := client.BundleClient()
+		old := client.Connection()
+
+		err = client.Reconnect(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, connectivity.Shutdown, old.GetState())
+
+		_, err = bundleClient.GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("keeps connection when dial fails", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := New(ctx, "localhost:1")
+		require.NoError(t, err)
+		defer client.Close()
+
+		old := client.Connection()
+		client.config.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+
+		err = client.Reconnect(ctx)
+		assert.Error(t, err)
+		assert.Same(t, old, client.Connection())
+	})
+
+	t.Run("without config", func(t *testing.T) {
+		client := &Client{}
+		err := client.Reconnect(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "config is required")
+	})
+
+	t.Run("service client without connection", func(t *testing.T) {
+		client := &Client{}
+		_, err := client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		var connErr *ConnectionError
+		assert.ErrorAs(t, err, &connErr)
+	})
+}
+
+func TestClient_ConnectionState(t *testing.T) {
+	t.Run("nil connection", func(t *testing.T) {
+		client := &Client{}
+		assert.Equal(t, connectivity.Shutdown, client.ConnectionState())
+	})
+
+	t.Run("open connection", func(t *testing.T) {
+		client, err := New(context.Background(), startTestServer(t))
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.NotEqual(t, connectivity.Shutdown, client.ConnectionState())
+	})
+
+	t.Run("closed connection", func(t *testing.T) {
+		client, err := New(context.Background(), startTestServer(t))
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		assert.Equal(t, connectivity.Shutdown, client.ConnectionState())
+	})
+}
+
+func TestClient_WaitForReady(t *testing.T) {
+	t.Run("reachable server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		client, err := New(ctx, startTestServer(t))
+		require.NoError(t, err)
+		defer client.Close()
+
+		require.NoError(t, client.WaitForReady(ctx))
+		assert.Equal(t, connectivity.Ready, client.ConnectionState())
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := New(ctx, "localhost:1")
+		require.NoError(t, err)
+		defer client.Close()
+
+		err = client.WaitForReady(ctx)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("closed connection", func(t *testing.T) {
+		client, err := New(context.Background(), startTestServer(t))
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		err = client.WaitForReady(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "connection is shut down")
+	})
+
+	t.Run("nil connection", func(t *testing.T) {
+		client := &Client{}
+		err := client.WaitForReady(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+// startUnixTestServer starts a plaintext gRPC server with a fake Bundle service on a Unix socket
+func startUnixTestServer(t *testing.T) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	bundlev1.RegisterBundleServer(server, &fakeBundleServer{bundle: testFixtureBundle()})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return socketPath
+}
+
+func TestNewAgentClient(t *testing.T) {
+	t.Run("empty socket path", func(t *testing.T) {
+		client, err := NewAgentClient(context.Background(), "")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "socket path is required")
+		assert.Nil(t, client)
+	})
+
+	t.Run("plain socket path", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		socketPath := startUnixTestServer(t)
+		client, err := NewAgentClient(ctx, socketPath)
+		require.NoError(t, err)
+		defer client.Close()
+
+		authorities, err := client.FetchX509Authorities(ctx)
+		require.NoError(t, err)
+		assert.Len(t, authorities, 1)
+	})
+
+	t.Run("unix scheme address in config", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		socketPath := startUnixTestServer(t)
+		client, err := NewWithConfig(ctx, &Config{Address: "unix://" + socketPath})
+		require.NoError(t, err)
+		defer client.Close()
+
+		require.NoError(t, client.WaitForReady(ctx))
+		authorities, err := client.FetchX509Authorities(ctx)
+		require.NoError(t, err)
+		assert.Len(t, authorities, 1)
+	})
+
+	t.Run("missing socket", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		client, err := NewAgentClient(ctx, filepath.Join(t.TempDir(), "missing.sock"), WithTLS13Only())
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.Error(t, client.WaitForReady(ctx))
+	})
+}
+
+func TestIsUnixAddress(t *testing.T) {
+	assert.True(t, isUnixAddress("unix:///tmp/spire-agent/public/api.sock"))
+	assert.True(t, isUnixAddress("/tmp/spire-agent/public/api.sock"))
+	assert.False(t, isUnixAddress("localhost:8081"))
+}
+
 func TestClient_Close(t *testing.T) {
 	t.Run("close with nil connection", func(t *testing.T) {
 		client := &Client{}
@@ -208,3 +926,87 @@ func TestClient_Connection(t *testing.T) {
 		assert.Nil(t, conn)
 	})
 }
+
+func TestConfig_OnStateChange(t *testing.T) {
+	type transition struct{ old, new connectivity.State }
+
+	// record returns a callback that forwards transitions to the returned channel
+	record := func() (func(old, new connectivity.State), chan transition) {
+		ch := make(chan transition, 100)
+		return func(old, new connectivity.State) { ch <- transition{old, new} }, ch
+	}
+
+	// waitFor collects transitions until one ends in want, checking that they form a chain
+	waitFor := func(t *testing.T, ch chan transition, want connectivity.State) []transition {
+		t.Helper()
+
+		var got []transition
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case tr := <-ch:
+				if len(got) > 0 {
+					assert.Equal(t, got[len(got)-1].new, tr.old, "transitions %v are not contiguous", got)
+				}
+				assert.NotEqual(t, tr.old, tr.new)
+				got = append(got, tr)
+				if tr.new == want {
+					return got
+				}
+			case <-timeout:
+				t.Fatalf("no transition to %s, got %v", want, got)
+			}
+		}
+	}
+
+	t.Run("failed connection attempt", func(t *testing.T) {
+		// Reserve a port and close it so connection attempts are refused
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		onChange, ch := record()
+		client, err := NewWithConfig(context.Background(), &Config{Address: addr, OnStateChange: onChange})
+		require.NoError(t, err)
+		client.Connection().Connect()
+
+		got := waitFor(t, ch, connectivity.TransientFailure)
+		assert.Contains(t, got, transition{connectivity.Connecting, connectivity.TransientFailure})
+
+		require.NoError(t, client.Close())
+		got = waitFor(t, ch, connectivity.Shutdown)
+		assert.Equal(t, connectivity.Shutdown, got[len(got)-1].new)
+	})
+
+	t.Run("successful connection", func(t *testing.T) {
+		onChange, ch := record()
+		client, err := NewWithConfig(context.Background(), &Config{Address: startTestServer(t), OnStateChange: onChange})
+		require.NoError(t, err)
+		defer client.Close()
+		client.Connection().Connect()
+
+		got := waitFor(t, ch, connectivity.Ready)
+		assert.Equal(t, transition{connectivity.Connecting, connectivity.Ready}, got[len(got)-1])
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		onChange, ch := record()
+		client, err := NewWithConfig(ctx, &Config{Address: startTestServer(t), OnStateChange: onChange})
+		require.NoError(t, err)
+		waitFor(t, ch, connectivity.Ready)
+
+		cancel()
+		// Give the watcher time to observe the cancellation before the state changes again
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, client.Close())
+
+		select {
+		case tr := <-ch:
+			t.Errorf("unexpected transition after cancellation: %v", tr)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,391 @@
+// Command spire-admin performs common SPIRE Server administration tasks through the server API
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+	"github.com/spf13/cobra"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+)
+
+// Supported values of the --output flag
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// options holds the connection and output flags shared by all subcommands
+type options struct {
+	address  string
+	certFile string
+	keyFile  string
+	output   string
+	timeout  time.Duration
+}
+
+func main() {
+	if err := newRootCommand(os.Stdout).Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCommand builds the command tree, writing command output to stdout
+func newRootCommand(stdout io.Writer) *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "spire-admin",
+		Short:         "Administer a SPIRE Server through its gRPC API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.validate()
+		},
+	}
+	root.SetOut(stdout)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.address, "address", "localhost:8081", "SPIRE Server address")
+	flags.StringVar(&opts.certFile, "cert", "", "Client certificate PEM file for mTLS")
+	flags.StringVar(&opts.keyFile, "key", "", "Client private key PEM file for mTLS")
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "Output format: table or json")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout for the whole operation")
+
+	root.AddCommand(newBundleCommand(opts), newEntryCommand(opts), newAgentCommand(opts))
+	return root
+}
+
+// validate checks flag combinations that cobra cannot express
+func (o *options) validate() error {
+	if o.output != outputTable && o.output != outputJSON {
+		return fmt.Errorf("unsupported output format %q (want %s or %s)", o.output, outputTable, outputJSON)
+	}
+	if (o.certFile == "") != (o.keyFile == "") {
+		return errors.New("--cert and --key must be specified together")
+	}
+	return nil
+}
+
+// withClient connects to the server and calls fn with a context bounded by --timeout
+func (o *options) withClient(cmd *cobra.Command, fn func(ctx context.Context, client *spireclient.Client) error) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+	defer cancel()
+
+	var client *spireclient.Client
+	var err error
+	if o.certFile != "" {
+		client, err = spireclient.NewMTLS(ctx, o.address, o.certFile, o.keyFile)
+	} else {
+		client, err = spireclient.New(ctx, o.address)
+	}
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return fn(ctx, client)
+}
+
+func newBundleCommand(opts *options) *cobra.Command {
+	bundle := &cobra.Command{Use: "bundle", Short: "Inspect the server trust bundle"}
+
+	bundle.AddCommand(&cobra.Command{
+		Use:   "get",
+		Short: "Print the X.509 authorities of the server trust bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				authorities, err := client.FetchX509Authorities(ctx)
+				if err != nil {
+					return err
+				}
+
+				w := cmd.OutOrStdout()
+				if opts.output == outputJSON {
+					type authority struct {
+						Subject  string `json:"subject"`
+						NotAfter string `json:"not_after"`
+						PEM      string `json:"pem"`
+					}
+					out := make([]authority, 0, len(authorities))
+					for _, cert := range authorities {
+						out = append(out, authority{
+							Subject:  cert.Subject.String(),
+							NotAfter: cert.NotAfter.UTC().Format(time.RFC3339),
+							PEM:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+						})
+					}
+					return writeJSON(w, out)
+				}
+
+				for _, cert := range authorities {
+					if err := pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	})
+
+	var format, outputFile string
+	dump := &cobra.Command{
+		Use:   "dump",
+		Short: "Write the server trust bundle as PEM, DER, a JWK Set or a SPIFFE bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				bundle, err := client.FetchX509Bundle(ctx)
+				if err != nil {
+					return err
+				}
+
+				if outputFile == "" {
+					return spireclient.WriteBundleToWriter(cmd.OutOrStdout(), bundle, format)
+				}
+
+				// Write to a buffer first so an unsupported format does not leave an empty file behind
+				var buf bytes.Buffer
+				if err := spireclient.WriteBundleToWriter(&buf, bundle, format); err != nil {
+					return err
+				}
+				if err := os.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %w", outputFile, err)
+				}
+				return nil
+			})
+		},
+	}
+	dump.Flags().StringVar(&format, "format", spireclient.BundleFormatPEM, "Bundle format: pem, der, jwks or spiffe-bundle")
+	// Shadows the global --output, whose table/json formats do not apply to a dumped bundle
+	dump.Flags().StringVar(&outputFile, "output", "", "File to write the bundle to instead of stdout")
+	bundle.AddCommand(dump)
+
+	return bundle
+}
+
+func newEntryCommand(opts *options) *cobra.Command {
+	entry := &cobra.Command{Use: "entry", Short: "Manage registration entries"}
+
+	var selectors []string
+	var match string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List registration entries, optionally filtered by selector",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := parseSelectorFilter(selectors, match)
+			if err != nil {
+				return err
+			}
+
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				entries, err := client.ListEntriesBySelector(ctx, filter)
+				if err != nil {
+					return err
+				}
+				return printEntries(cmd.OutOrStdout(), opts.output, entries)
+			})
+		},
+	}
+	list.Flags().StringArrayVar(&selectors, "selector", nil, "Selector as type:value (e.g. unix:uid:1000); may be repeated")
+	list.Flags().StringVar(&match, "match", "all", "How multiple selectors are matched: all or any")
+
+	var id string
+	del := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a registration entry",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				if err := client.DeleteEntry(ctx, id); err != nil {
+					return err
+				}
+
+				w := cmd.OutOrStdout()
+				if opts.output == outputJSON {
+					return writeJSON(w, map[string]any{"id": id, "deleted": true})
+				}
+				_, err := fmt.Fprintf(w, "Deleted entry %s\n", id)
+				return err
+			})
+		},
+	}
+	del.Flags().StringVar(&id, "id", "", "ID of the entry to delete")
+	del.MarkFlagRequired("id")
+
+	entry.AddCommand(list, del)
+	return entry
+}
+
+func newAgentCommand(opts *options) *cobra.Command {
+	agent := &cobra.Command{Use: "agent", Short: "Manage attested agents"}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List attested agents",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				agents, err := client.ListAgents(ctx)
+				if err != nil {
+					return err
+				}
+				return printAgents(cmd.OutOrStdout(), opts.output, agents)
+			})
+		},
+	}
+
+	var spiffeID string
+	ban := &cobra.Command{
+		Use:   "ban",
+		Short: "Ban an agent so it can no longer renew its SVID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.withClient(cmd, func(ctx context.Context, client *spireclient.Client) error {
+				if err := client.BanAgent(ctx, spiffeID); err != nil {
+					return err
+				}
+
+				w := cmd.OutOrStdout()
+				if opts.output == outputJSON {
+					return writeJSON(w, map[string]any{"spiffe_id": spiffeID, "banned": true})
+				}
+				_, err := fmt.Fprintf(w, "Banned agent %s\n", spiffeID)
+				return err
+			})
+		},
+	}
+	ban.Flags().StringVar(&spiffeID, "spiffe-id", "", "SPIFFE ID of the agent to ban")
+	ban.MarkFlagRequired("spiffe-id")
+
+	agent.AddCommand(list, ban)
+	return agent
+}
+
+// parseSelectorFilter converts type:value flags into a selector filter
+// The value may itself contain colons, so only the first colon separates the type
+func parseSelectorFilter(selectors []string, match string) (spireclient.SelectorFilter, error) {
+	filter := spireclient.SelectorFilter{}
+
+	switch match {
+	case "all":
+		filter.MatchMode = spireclient.MatchAll
+	case "any":
+		filter.MatchMode = spireclient.MatchAny
+	default:
+		return filter, fmt.Errorf("unsupported match mode %q (want all or any)", match)
+	}
+
+	for _, s := range selectors {
+		typ, value, ok := strings.Cut(s, ":")
+		if !ok || typ == "" || value == "" {
+			return filter, fmt.Errorf("invalid selector %q (want type:value)", s)
+		}
+		filter.Selectors = append(filter.Selectors, spireclient.WorkloadSelector{Type: typ, Value: value})
+	}
+
+	return filter, nil
+}
+
+// entryOutput is the JSON representation of a registration entry
+type entryOutput struct {
+	ID        string   `json:"id"`
+	SpiffeID  string   `json:"spiffe_id"`
+	ParentID  string   `json:"parent_id"`
+	Selectors []string `json:"selectors"`
+}
+
+// printEntries writes entries as a table or JSON array
+func printEntries(w io.Writer, output string, entries []*types.Entry) error {
+	out := make([]entryOutput, 0, len(entries))
+	for _, e := range entries {
+		selectors := make([]string, 0, len(e.Selectors))
+		for _, s := range e.Selectors {
+			selectors = append(selectors, s.Type+":"+s.Value)
+		}
+		out = append(out, entryOutput{
+			ID:        e.Id,
+			SpiffeID:  spiffeIDString(e.SpiffeId),
+			ParentID:  spiffeIDString(e.ParentId),
+			Selectors: selectors,
+		})
+	}
+
+	if output == outputJSON {
+		return writeJSON(w, out)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTRY ID\tSPIFFE ID\tPARENT ID\tSELECTORS")
+	for _, e := range out {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.ID, e.SpiffeID, e.ParentID, strings.Join(e.Selectors, ","))
+	}
+	return tw.Flush()
+}
+
+// agentOutput is the JSON representation of an attested agent
+type agentOutput struct {
+	SpiffeID          string `json:"spiffe_id"`
+	AttestationType   string `json:"attestation_type"`
+	Banned            bool   `json:"banned"`
+	X509SVIDExpiresAt string `json:"x509_svid_expires_at,omitempty"`
+}
+
+// printAgents writes agents as a table or JSON array
+func printAgents(w io.Writer, output string, agents []*types.Agent) error {
+	out := make([]agentOutput, 0, len(agents))
+	for _, a := range agents {
+		o := agentOutput{
+			SpiffeID:        spiffeIDString(a.Id),
+			AttestationType: a.AttestationType,
+			Banned:          a.Banned,
+		}
+		if a.X509SvidExpiresAt != 0 {
+			o.X509SVIDExpiresAt = time.Unix(a.X509SvidExpiresAt, 0).UTC().Format(time.RFC3339)
+		}
+		out = append(out, o)
+	}
+
+	if output == outputJSON {
+		return writeJSON(w, out)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPIFFE ID\tATTESTATION TYPE\tBANNED\tSVID EXPIRES")
+	for _, a := range out {
+		expires := a.X509SVIDExpiresAt
+		if expires == "" {
+			expires = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", a.SpiffeID, a.AttestationType, a.Banned, expires)
+	}
+	return tw.Flush()
+}
+
+// spiffeIDString formats an API SPIFFE ID as a spiffe:// URI
+func spiffeIDString(id *types.SPIFFEID) string {
+	if id == nil {
+		return ""
+	}
+	return "spiffe://" + id.TrustDomain + id.Path
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
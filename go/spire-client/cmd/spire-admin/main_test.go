@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hiyosi/sandbox/go/spire-client/test/mock"
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runCommand executes spire-admin with args against server and returns its output
+func runCommand(t *testing.T, server *mock.MockSPIREServer, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout bytes.Buffer
+	cmd := newRootCommand(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append(args, "--address", server.Addr(), "--timeout", "5s"))
+	err := cmd.Execute()
+	return stdout.String(), err
+}
+
+// newTestCA creates a self-signed CA certificate and returns it with its PEM-encoded key
+func newTestCA(t *testing.T, cn string) (*x509.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestBundleGet(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	ca1, _ := newTestCA(t, "CA one")
+	ca2, _ := newTestCA(t, "CA two")
+	server.AddBundle(&types.Bundle{
+		TrustDomain:     "example.org",
+		X509Authorities: []*types.X509Certificate{{Asn1: ca1.Raw}, {Asn1: ca2.Raw}},
+	})
+
+	t.Run("table", func(t *testing.T) {
+		out, err := runCommand(t, server, "bundle", "get")
+		require.NoError(t, err)
+
+		block, rest := pem.Decode([]byte(out))
+		require.NotNil(t, block)
+		assert.Equal(t, ca1.Raw, block.Bytes)
+		block, rest = pem.Decode(rest)
+		require.NotNil(t, block)
+		assert.Equal(t, ca2.Raw, block.Bytes)
+		assert.Empty(t, bytes.TrimSpace(rest))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := runCommand(t, server, "bundle", "get", "--output", "json")
+		require.NoError(t, err)
+
+		var authorities []struct {
+			Subject  string `json:"subject"`
+			NotAfter string `json:"not_after"`
+			PEM      string `json:"pem"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &authorities))
+		require.Len(t, authorities, 2)
+		assert.Equal(t, "CN=CA one", authorities[0].Subject)
+		assert.Equal(t, "2035-01-01T00:00:00Z", authorities[0].NotAfter)
+		assert.Contains(t, authorities[1].PEM, "-----BEGIN CERTIFICATE-----")
+	})
+}
+
+func TestBundleDump(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	ca1, _ := newTestCA(t, "CA one")
+	ca2, _ := newTestCA(t, "CA two")
+	server.AddBundle(&types.Bundle{
+		TrustDomain:     "example.org",
+		X509Authorities: []*types.X509Certificate{{Asn1: ca1.Raw}, {Asn1: ca2.Raw}},
+	})
+
+	t.Run("pem to stdout", func(t *testing.T) {
+		out, err := runCommand(t, server, "bundle", "dump")
+		require.NoError(t, err)
+
+		certs := decodePEMCertificates(t, []byte(out))
+		assert.Equal(t, [][]byte{ca1.Raw, ca2.Raw}, certs)
+	})
+
+	t.Run("der to file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bundle.der")
+		out, err := runCommand(t, server, "bundle", "dump", "--format", "der", "--output", path)
+		require.NoError(t, err)
+		assert.Empty(t, out)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		certs, err := x509.ParseCertificates(data)
+		require.NoError(t, err)
+		require.Len(t, certs, 2)
+		assert.True(t, certs[1].Equal(ca2))
+	})
+
+	t.Run("spiffe-bundle", func(t *testing.T) {
+		out, err := runCommand(t, server, "bundle", "dump", "--format", "spiffe-bundle")
+		require.NoError(t, err)
+
+		bundle, err := spiffebundle.Parse(spiffeid.RequireTrustDomainFromString("example.org"), []byte(out))
+		require.NoError(t, err)
+		require.Len(t, bundle.X509Authorities(), 2)
+		assert.True(t, bundle.X509Authorities()[0].Equal(ca1))
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		out, err := runCommand(t, server, "bundle", "dump", "--format", "jwks")
+		require.NoError(t, err)
+
+		var jwks struct {
+			Keys []struct {
+				Kty string   `json:"kty"`
+				X5c []string `json:"x5c"`
+			} `json:"keys"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &jwks))
+		require.Len(t, jwks.Keys, 2)
+		assert.Equal(t, "EC", jwks.Keys[0].Kty)
+		assert.Len(t, jwks.Keys[0].X5c, 1)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bundle.p12")
+		_, err := runCommand(t, server, "bundle", "dump", "--format", "p12", "--output", path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unsupported bundle format "p12"`)
+		assert.NoFileExists(t, path)
+	})
+}
+
+// decodePEMCertificates returns the DER bytes of each PEM block in data
+func decodePEMCertificates(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+
+	var certs [][]byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		require.Equal(t, "CERTIFICATE", block.Type)
+		certs = append(certs, block.Bytes)
+	}
+	return certs
+}
+
+func TestEntryList(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	server.AddEntry(&types.Entry{
+		Id:        "entry-1",
+		SpiffeId:  &types.SPIFFEID{TrustDomain: "example.org", Path: "/web"},
+		ParentId:  &types.SPIFFEID{TrustDomain: "example.org", Path: "/agent"},
+		Selectors: []*types.Selector{{Type: "unix", Value: "uid:1000"}, {Type: "unix", Value: "gid:1000"}},
+	})
+	server.AddEntry(&types.Entry{
+		Id:        "entry-2",
+		SpiffeId:  &types.SPIFFEID{TrustDomain: "example.org", Path: "/db"},
+		ParentId:  &types.SPIFFEID{TrustDomain: "example.org", Path: "/agent"},
+		Selectors: []*types.Selector{{Type: "unix", Value: "uid:2000"}},
+	})
+
+	t.Run("table", func(t *testing.T) {
+		out, err := runCommand(t, server, "entry", "list")
+		require.NoError(t, err)
+		assert.Contains(t, out, "ENTRY ID")
+		assert.Contains(t, out, "entry-1")
+		assert.Contains(t, out, "spiffe://example.org/web")
+		assert.Contains(t, out, "unix:uid:1000,unix:gid:1000")
+		assert.Contains(t, out, "entry-2")
+	})
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantIDs []string
+	}{
+		{name: "single selector", args: []string{"--selector", "unix:uid:1000"}, wantIDs: []string{"entry-1"}},
+		{name: "match all", args: []string{"--selector", "unix:uid:1000", "--selector", "unix:uid:2000"}, wantIDs: []string{}},
+		{name: "match any", args: []string{"--selector", "unix:uid:1000", "--selector", "unix:uid:2000", "--match", "any"}, wantIDs: []string{"entry-1", "entry-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCommand(t, server, append([]string{"entry", "list", "-o", "json"}, tt.args...)...)
+			require.NoError(t, err)
+
+			var entries []entryOutput
+			require.NoError(t, json.Unmarshal([]byte(out), &entries))
+			ids := []string{}
+			for _, e := range entries {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	t.Run("invalid selector", func(t *testing.T) {
+		_, err := runCommand(t, server, "entry", "list", "--selector", "unix")
+		assert.ErrorContains(t, err, "invalid selector")
+	})
+}
+
+func TestEntryDelete(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	server.AddEntry(&types.Entry{Id: "entry-1"})
+	server.AddEntry(&types.Entry{Id: "entry-2"})
+
+	out, err := runCommand(t, server, "entry", "delete", "--id", "entry-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Deleted entry entry-1\n", out)
+
+	out, err = runCommand(t, server, "entry", "delete", "--id", "entry-2", "--output", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"entry-2","deleted":true}`, out)
+	assert.Empty(t, server.Entries())
+
+	_, err = runCommand(t, server, "entry", "delete", "--id", "entry-1")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = runCommand(t, server, "entry", "delete")
+	assert.ErrorContains(t, err, `required flag(s) "id" not set`)
+}
+
+func TestAgentListAndBan(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	server.AddAgent(&types.Agent{
+		Id:                &types.SPIFFEID{TrustDomain: "example.org", Path: "/agent/node1"},
+		AttestationType:   "join_token",
+		X509SvidExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+	})
+
+	out, err := runCommand(t, server, "agent", "list")
+	require.NoError(t, err)
+	assert.Contains(t, out, "SPIFFE ID")
+	assert.Contains(t, out, "spiffe://example.org/agent/node1")
+	assert.Contains(t, out, "join_token")
+	assert.Contains(t, out, "2030-01-01T00:00:00Z")
+
+	out, err = runCommand(t, server, "agent", "ban", "--spiffe-id", "spiffe://example.org/agent/node1")
+	require.NoError(t, err)
+	assert.Equal(t, "Banned agent spiffe://example.org/agent/node1\n", out)
+
+	out, err = runCommand(t, server, "agent", "list", "--output", "json")
+	require.NoError(t, err)
+	var agents []agentOutput
+	require.NoError(t, json.Unmarshal([]byte(out), &agents))
+	require.Len(t, agents, 1)
+	assert.True(t, agents[0].Banned)
+
+	_, err = runCommand(t, server, "agent", "ban", "--spiffe-id", "spiffe://example.org/agent/missing")
+	assert.ErrorContains(t, err, "agent spiffe://example.org/agent/missing not found")
+}
+
+func TestFlags(t *testing.T) {
+	server := mock.NewMockSPIREServer(t)
+	server.AddAgent(&types.Agent{Id: &types.SPIFFEID{TrustDomain: "example.org", Path: "/agent/node1"}})
+
+	t.Run("mTLS", func(t *testing.T) {
+		cert, key := newTestCA(t, "client")
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "client.pem")
+		keyFile := filepath.Join(dir, "client.key")
+		require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+		require.NoError(t, os.WriteFile(keyFile, key, 0600))
+
+		out, err := runCommand(t, server, "agent", "list", "--cert", certFile, "--key", keyFile)
+		require.NoError(t, err)
+		assert.Contains(t, out, "spiffe://example.org/agent/node1")
+	})
+
+	t.Run("cert without key", func(t *testing.T) {
+		_, err := runCommand(t, server, "agent", "list", "--cert", "client.pem")
+		assert.ErrorContains(t, err, "--cert and --key must be specified together")
+	})
+
+	t.Run("unsupported output", func(t *testing.T) {
+		_, err := runCommand(t, server, "agent", "list", "--output", "yaml")
+		assert.ErrorContains(t, err, `unsupported output format "yaml"`)
+	})
+}
@@ -0,0 +1,166 @@
+// Command spire-inspect prints information about X.509 SVIDs and trust bundles
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+const usage = `Usage:
+  spire-inspect svid --cert <path> --key <path>
+  spire-inspect bundle --file <path>
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches to the subcommand named by args[0], writing its output to stdout
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("subcommand is required\n%s", usage)
+	}
+
+	switch args[0] {
+	case "svid":
+		return runSVID(args[1:], stdout)
+	case "bundle":
+		return runBundle(args[1:], stdout)
+	default:
+		return fmt.Errorf("unknown subcommand %q\n%s", args[0], usage)
+	}
+}
+
+// runSVID prints the SPIFFE ID, validity, key and SANs of an X.509 SVID
+func runSVID(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("svid", flag.ContinueOnError)
+	certFile := fs.String("cert", "", "Path to the SVID certificate PEM file")
+	keyFile := fs.String("key", "", "Path to the SVID private key PEM file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *certFile == "" || *keyFile == "" {
+		return fmt.Errorf("both --cert and --key are required")
+	}
+
+	svid, err := x509svid.Load(*certFile, *keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load SVID: %w", err)
+	}
+
+	leaf := svid.Certificates[0]
+	fmt.Fprintf(stdout, "SPIFFE ID:     %s\n", svid.ID)
+	fmt.Fprintf(stdout, "Trust Domain:  %s\n", svid.ID.TrustDomain())
+	fmt.Fprintf(stdout, "Expires:       %s\n", leaf.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Fprintf(stdout, "Key:           %s\n", describePublicKey(leaf.PublicKey))
+	fmt.Fprintf(stdout, "DNS SANs:      %s\n", joinOrNone(leaf.DNSNames))
+
+	if err := spireclient.ValidateSPIFFECertificate(leaf); err != nil {
+		fmt.Fprintf(stdout, "SPIFFE Valid:  no (%v)\n", err)
+	} else {
+		fmt.Fprintf(stdout, "SPIFFE Valid:  yes\n")
+	}
+
+	return nil
+}
+
+// runBundle prints the subject, expiry and fingerprint of each CA in a PEM trust bundle
+func runBundle(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	file := fs.String("file", "", "Path to the trust bundle PEM file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("--file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read trust bundle: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for rest := data; len(rest) > 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate %d: %w", len(certs), err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificates found in %s", *file)
+	}
+
+	for i, cert := range certs {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "CA #%d\n", i+1)
+		fmt.Fprintf(stdout, "  Subject:     %s\n", cert.Subject)
+		fmt.Fprintf(stdout, "  Expires:     %s\n", cert.NotAfter.UTC().Format(time.RFC3339))
+		fmt.Fprintf(stdout, "  Fingerprint: %s\n", fingerprint(cert))
+	}
+
+	return nil
+}
+
+// describePublicKey returns the algorithm and size of a public key
+func describePublicKey(pub interface{}) string {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("unknown (%T)", pub)
+	}
+}
+
+// fingerprint returns the colon-separated SHA-256 fingerprint of a certificate
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// joinOrNone joins values with commas, or returns "(none)" if empty
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "(none)"
+	}
+	return strings.Join(values, ", ")
+}
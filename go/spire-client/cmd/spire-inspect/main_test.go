@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSVID(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut []string
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "valid SVID",
+			args: []string{"svid", "--cert", "testdata/svid.pem", "--key", "testdata/svid.key"},
+			wantOut: []string{
+				"SPIFFE ID:     spiffe://example.org/workload\n",
+				"Trust Domain:  example.org\n",
+				"Expires:       2126-",
+				"Key:           RSA 2048\n",
+				"DNS SANs:      workload.example.org\n",
+				"SPIFFE Valid:  yes\n",
+			},
+		},
+		{
+			name:    "missing key flag",
+			args:    []string{"svid", "--cert", "testdata/svid.pem"},
+			wantErr: true,
+			errMsg:  "both --cert and --key are required",
+		},
+		{
+			name:    "CA certificate is not an SVID",
+			args:    []string{"svid", "--cert", "testdata/bundle.pem", "--key", "testdata/svid.key"},
+			wantErr: true,
+			errMsg:  "failed to load SVID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := run(tt.args, &stdout)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, want := range tt.wantOut {
+				assert.Contains(t, stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestRunBundle(t *testing.T) {
+	t.Run("prints each CA", func(t *testing.T) {
+		var stdout bytes.Buffer
+		require.NoError(t, run([]string{"bundle", "--file", "testdata/bundle.pem"}, &stdout))
+
+		out := stdout.String()
+		assert.Contains(t, out, "CA #1\n  Subject:     CN=inspect test CA,O=SPIFFE\n")
+		assert.Contains(t, out, "CA #2\n  Subject:     CN=second test CA,O=Partner\n")
+		assert.Contains(t, out, "  Expires:     2126-")
+
+		// Fingerprints match the SHA-256 of each certificate in the fixture
+		data, err := os.ReadFile("testdata/bundle.pem")
+		require.NoError(t, err)
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			require.NoError(t, err)
+
+			sum := sha256.Sum256(cert.Raw)
+			assert.Contains(t, out, fmt.Sprintf("Fingerprint: %02X:%02X:", sum[0], sum[1]))
+			assert.Contains(t, out, fingerprint(cert))
+		}
+	})
+
+	t.Run("no certificates", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"bundle", "--file", "testdata/svid.key"}, &stdout)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no certificates found")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run([]string{"bundle", "--file", "testdata/missing.pem"}, &stdout)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read trust bundle")
+	})
+}
+
+func TestRunUnknownSubcommand(t *testing.T) {
+	var stdout bytes.Buffer
+
+	err := run(nil, &stdout)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "subcommand is required")
+
+	err = run([]string{"jwt"}, &stdout)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown subcommand "jwt"`)
+}
@@ -0,0 +1,287 @@
+package spireclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	entryv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxListPages bounds the number of pages ListAllEntries requests from a misbehaving server
+const maxListPages = 100
+
+// ListAllEntries lists all registration entries matching filter, following page tokens until exhausted
+func (c *Client) ListAllEntries(ctx context.Context, filter *entryv1.ListEntriesRequest_Filter) ([]*types.Entry, error) {
+	var entries []*types.Entry
+	pageToken := ""
+
+	for page := 0; page < maxListPages; page++ {
+		resp, err := c.EntryClient().ListEntries(ctx, &entryv1.ListEntriesRequest{
+			Filter:    filter,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list entries: %w", err)
+		}
+
+		entries = append(entries, resp.Entries...)
+
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			return entries, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to list entries: exceeded %d pages", maxListPages)
+}
+
+// WorkloadSelector is a selector such as {Type: "unix", Value: "uid:1000"}
+type WorkloadSelector struct {
+	Type  string
+	Value string
+}
+
+// MatchMode controls how the selectors of a SelectorFilter are matched against entries
+type MatchMode int
+
+const (
+	// MatchAny matches entries that have at least one of the selectors
+	MatchAny MatchMode = iota
+	// MatchAll matches entries that have every one of the selectors, possibly among others
+	MatchAll
+)
+
+// SelectorFilter filters registration entries by their selectors
+// A filter without selectors matches all entries
+type SelectorFilter struct {
+	Selectors []WorkloadSelector
+	MatchMode MatchMode
+}
+
+// WildcardFilter returns a filter that matches all registration entries
+func WildcardFilter() SelectorFilter {
+	return SelectorFilter{}
+}
+
+// toProto converts the filter into a ListEntries filter, returning nil for a wildcard filter
+func (f SelectorFilter) toProto() (*entryv1.ListEntriesRequest_Filter, error) {
+	if len(f.Selectors) == 0 {
+		return nil, nil
+	}
+
+	var match types.SelectorMatch_MatchBehavior
+	switch f.MatchMode {
+	case MatchAny:
+		match = types.SelectorMatch_MATCH_ANY
+	case MatchAll:
+		match = types.SelectorMatch_MATCH_SUPERSET
+	default:
+		return nil, &ValidationError{Err: fmt.Errorf("unknown match mode %d", f.MatchMode)}
+	}
+
+	selectors := make([]*types.Selector, 0, len(f.Selectors))
+	for _, s := range f.Selectors {
+		if s.Type == "" || s.Value == "" {
+			return nil, &ValidationError{Err: fmt.Errorf("selector type and value are required, got %q:%q", s.Type, s.Value)}
+		}
+		selectors = append(selectors, &types.Selector{Type: s.Type, Value: s.Value})
+	}
+
+	return &entryv1.ListEntriesRequest_Filter{
+		BySelectors: &types.SelectorMatch{
+			Selectors: selectors,
+			Match:     match,
+		},
+	}, nil
+}
+
+// ListEntriesBySelector lists all registration entries matching the selector filter
+func (c *Client) ListEntriesBySelector(ctx context.Context, filter SelectorFilter) ([]*types.Entry, error) {
+	protoFilter, err := filter.toProto()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListAllEntries(ctx, protoFilter)
+}
+
+// DeleteStaleEntries deletes registration entries created more than olderThan ago
+// Entries without a creation timestamp are kept. Returns the number of deleted entries
+func (c *Client) DeleteStaleEntries(ctx context.Context, olderThan time.Duration) (int, error) {
+	entries, err := c.ListAllEntries(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan).Unix()
+	var ids []string
+	for _, entry := range entries {
+		if entry.CreatedAt != 0 && entry.CreatedAt < cutoff {
+			ids = append(ids, entry.Id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// The v1 Entry API only offers batch deletion, so all stale entries are removed in one call
+	resp, err := c.EntryClient().BatchDeleteEntry(ctx, &entryv1.BatchDeleteEntryRequest{Ids: ids})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete entries: %w", err)
+	}
+
+	deleted := 0
+	var failed []string
+	for _, result := range resp.Results {
+		if codes.Code(result.GetStatus().GetCode()) == codes.OK {
+			deleted++
+		} else {
+			failed = append(failed, result.Id)
+		}
+	}
+
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("failed to delete entries %v", failed)
+	}
+
+	return deleted, nil
+}
+
+// DeleteEntry deletes the registration entry with the given ID
+// A per-entry failure is returned as a gRPC status error, so status.Code reports e.g. codes.NotFound
+func (c *Client) DeleteEntry(ctx context.Context, id string) error {
+	if id == "" {
+		return &ValidationError{Err: errors.New("entry ID is required")}
+	}
+
+	resp, err := c.EntryClient().BatchDeleteEntry(ctx, &entryv1.BatchDeleteEntryRequest{Ids: []string{id}})
+	if err != nil {
+		return fmt.Errorf("failed to delete entry: %w", err)
+	}
+
+	if len(resp.Results) != 1 {
+		return fmt.Errorf("failed to delete entry %s: expected 1 result, got %d", id, len(resp.Results))
+	}
+	if st := resp.Results[0].GetStatus(); codes.Code(st.GetCode()) != codes.OK {
+		return fmt.Errorf("failed to delete entry %s: %w", id, status.Error(codes.Code(st.GetCode()), st.GetMessage()))
+	}
+
+	return nil
+}
+
+// EntryBuilder builds registration entries with chainable setters
+type EntryBuilder struct {
+	spiffeID  string
+	parentID  string
+	dnsNames  []string
+	selectors []*types.Selector
+	ttl       int32
+}
+
+// NewEntryBuilder creates an empty registration entry builder
+func NewEntryBuilder() *EntryBuilder {
+	return &EntryBuilder{}
+}
+
+// WithSpiffeID sets the SPIFFE ID of the entry
+func (b *EntryBuilder) WithSpiffeID(id string) *EntryBuilder {
+	b.spiffeID = id
+	return b
+}
+
+// WithParentID sets the parent SPIFFE ID of the entry
+func (b *EntryBuilder) WithParentID(id string) *EntryBuilder {
+	b.parentID = id
+	return b
+}
+
+// WithDNSSAN adds a DNS name to the entry
+func (b *EntryBuilder) WithDNSSAN(dns string) *EntryBuilder {
+	b.dnsNames = append(b.dnsNames, dns)
+	return b
+}
+
+// WithSelector adds a selector (e.g. "unix", "uid:1000") to the entry
+func (b *EntryBuilder) WithSelector(typ, val string) *EntryBuilder {
+	b.selectors = append(b.selectors, &types.Selector{Type: typ, Value: val})
+	return b
+}
+
+// WithTTL sets the X509-SVID TTL of the entry in seconds
+func (b *EntryBuilder) WithTTL(seconds int32) *EntryBuilder {
+	b.ttl = seconds
+	return b
+}
+
+// Build validates the builder and returns the registration entry
+func (b *EntryBuilder) Build() (*types.Entry, error) {
+	if b.spiffeID == "" {
+		return nil, fmt.Errorf("SPIFFE ID is required")
+	}
+
+	if b.parentID == "" {
+		return nil, fmt.Errorf("parent ID is required")
+	}
+
+	spiffeID, err := toProtoSPIFFEID(b.spiffeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SPIFFE ID: %w", err)
+	}
+
+	parentID, err := toProtoSPIFFEID(b.parentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent ID: %w", err)
+	}
+
+	return &types.Entry{
+		SpiffeId:    spiffeID,
+		ParentId:    parentID,
+		Selectors:   b.selectors,
+		DnsNames:    b.dnsNames,
+		X509SvidTtl: b.ttl,
+	}, nil
+}
+
+// Create validates the builder and creates the registration entry on the server
+func (b *EntryBuilder) Create(ctx context.Context, ec entryv1.EntryClient) (*types.Entry, error) {
+	entry, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ec.BatchCreateEntry(ctx, &entryv1.BatchCreateEntryRequest{
+		Entries: []*types.Entry{entry},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create entry: %w", err)
+	}
+
+	if len(resp.Results) != 1 {
+		return nil, fmt.Errorf("unexpected number of results: %d", len(resp.Results))
+	}
+
+	result := resp.Results[0]
+	if code := codes.Code(result.GetStatus().GetCode()); code != codes.OK {
+		return nil, fmt.Errorf("failed to create entry: %s (%s)", result.GetStatus().GetMessage(), code)
+	}
+
+	return result.Entry, nil
+}
+
+// toProtoSPIFFEID converts a SPIFFE ID string into its API representation
+func toProtoSPIFFEID(id string) (*types.SPIFFEID, error) {
+	trustDomain, path, err := ParseSPIFFEID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SPIFFEID{
+		TrustDomain: trustDomain,
+		Path:        path,
+	}, nil
+}
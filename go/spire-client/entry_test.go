@@ -0,0 +1,403 @@
+package spireclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	entryv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeEntryServer records created entries and returns canned results
+type fakeEntryServer struct {
+	entryv1.UnimplementedEntryServer
+	created    []*types.Entry
+	createCode codes.Code
+
+	// pages are returned by ListEntries, one per request
+	pages        [][]*types.Entry
+	endlessPages bool
+	listRequests []*entryv1.ListEntriesRequest
+
+	deleted    []string
+	deleteFail map[string]bool
+}
+
+func (s *fakeEntryServer) BatchDeleteEntry(ctx context.Context, req *entryv1.BatchDeleteEntryRequest) (*entryv1.BatchDeleteEntryResponse, error) {
+	resp := &entryv1.BatchDeleteEntryResponse{}
+	for _, id := range req.Ids {
+		code := codes.OK
+		if s.deleteFail[id] {
+			code = codes.NotFound
+		} else {
+			s.deleted = append(s.deleted, id)
+		}
+		resp.Results = append(resp.Results, &entryv1.BatchDeleteEntryResponse_Result{
+			Status: &types.Status{Code: int32(code)},
+			Id:     id,
+		})
+	}
+	return resp, nil
+}
+
+func (s *fakeEntryServer) ListEntries(ctx context.Context, req *entryv1.ListEntriesRequest) (*entryv1.ListEntriesResponse, error) {
+	s.listRequests = append(s.listRequests, req)
+
+	if s.endlessPages {
+		return &entryv1.ListEntriesResponse{NextPageToken: "more"}, nil
+	}
+
+	page := 0
+	if req.PageToken != "" {
+		var err error
+		page, err = strconv.Atoi(req.PageToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page token %q", req.PageToken)
+		}
+	}
+
+	resp := &entryv1.ListEntriesResponse{}
+	if page < len(s.pages) {
+		resp.Entries = s.pages[page]
+	}
+	if page+1 < len(s.pages) {
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	return resp, nil
+}
+
+func (s *fakeEntryServer) BatchCreateEntry(ctx context.Context, req *entryv1.BatchCreateEntryRequest) (*entryv1.BatchCreateEntryResponse, error) {
+	resp := &entryv1.BatchCreateEntryResponse{}
+	for _, entry := range req.Entries {
+		if s.createCode != codes.OK {
+			resp.Results = append(resp.Results, &entryv1.BatchCreateEntryResponse_Result{
+				Status: &types.Status{Code: int32(s.createCode), Message: "rejected"},
+			})
+			continue
+		}
+
+		entry.Id = "entry-id"
+		s.created = append(s.created, entry)
+		resp.Results = append(resp.Results, &entryv1.BatchCreateEntryResponse_Result{
+			Status: &types.Status{Code: int32(codes.OK)},
+			Entry:  entry,
+		})
+	}
+	return resp, nil
+}
+
+// newEntryTestClient returns a client connected to a fake Entry service
+func newEntryTestClient(t *testing.T, server *fakeEntryServer) *Client {
+	t.Helper()
+	return newTestClient(t, func(s *grpc.Server) {
+		entryv1.RegisterEntryServer(s, server)
+	})
+}
+
+func TestEntryBuilder_Create(t *testing.T) {
+	t.Run("full builder chain", func(t *testing.T) {
+		server := &fakeEntryServer{}
+		client := newEntryTestClient(t, server)
+
+		entry, err := NewEntryBuilder().
+			WithSpiffeID("spiffe://example.org/workload").
+			WithParentID("spiffe://example.org/agent").
+			WithDNSSAN("workload.example.org").
+			WithSelector("unix", "uid:1000").
+			WithSelector("unix", "gid:1000").
+			WithTTL(3600).
+			Create(context.Background(), client.EntryClient())
+		require.NoError(t, err)
+
+		assert.Equal(t, "entry-id", entry.Id)
+		assert.Equal(t, "example.org", entry.SpiffeId.TrustDomain)
+		assert.Equal(t, "/workload", entry.SpiffeId.Path)
+		assert.Equal(t, "example.org", entry.ParentId.TrustDomain)
+		assert.Equal(t, "/agent", entry.ParentId.Path)
+		assert.Equal(t, []string{"workload.example.org"}, entry.DnsNames)
+		require.Len(t, entry.Selectors, 2)
+		assert.Equal(t, "unix", entry.Selectors[0].Type)
+		assert.Equal(t, "uid:1000", entry.Selectors[0].Value)
+		assert.Equal(t, int32(3600), entry.X509SvidTtl)
+		assert.Len(t, server.created, 1)
+	})
+
+	t.Run("server rejects entry", func(t *testing.T) {
+		client := newEntryTestClient(t, &fakeEntryServer{createCode: codes.AlreadyExists})
+
+		entry, err := NewEntryBuilder().
+			WithSpiffeID("spiffe://example.org/workload").
+			WithParentID("spiffe://example.org/agent").
+			Create(context.Background(), client.EntryClient())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to create entry: rejected (AlreadyExists)")
+		assert.Nil(t, entry)
+	})
+}
+
+func TestEntryBuilder_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *EntryBuilder
+		errMsg  string
+	}{
+		{
+			name:    "missing SPIFFE ID",
+			builder: NewEntryBuilder().WithParentID("spiffe://example.org/agent"),
+			errMsg:  "SPIFFE ID is required",
+		},
+		{
+			name:    "missing parent ID",
+			builder: NewEntryBuilder().WithSpiffeID("spiffe://example.org/workload"),
+			errMsg:  "parent ID is required",
+		},
+		{
+			name: "invalid SPIFFE ID",
+			builder: NewEntryBuilder().
+				WithSpiffeID("https://example.org/workload").
+				WithParentID("spiffe://example.org/agent"),
+			errMsg: "invalid SPIFFE ID",
+		},
+		{
+			name: "invalid parent ID",
+			builder: NewEntryBuilder().
+				WithSpiffeID("spiffe://example.org/workload").
+				WithParentID("spiffe://example.org:8080/agent"),
+			errMsg: "invalid parent ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation fails before the API is called, so no connection is needed
+			client := &Client{conn: &grpc.ClientConn{}}
+
+			entry, err := tt.builder.Create(context.Background(), client.EntryClient())
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Nil(t, entry)
+		})
+	}
+}
+
+// testEntries returns n entries with sequential IDs
+func testEntries(prefix string, n int) []*types.Entry {
+	entries := make([]*types.Entry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, &types.Entry{Id: fmt.Sprintf("%s-%d", prefix, i)})
+	}
+	return entries
+}
+
+func TestClient_ListAllEntries(t *testing.T) {
+	t.Run("collects all pages", func(t *testing.T) {
+		server := &fakeEntryServer{
+			pages: [][]*types.Entry{
+				testEntries("a", 3),
+				testEntries("b", 3),
+				testEntries("c", 2),
+			},
+		}
+		client := newEntryTestClient(t, server)
+
+		filter := &entryv1.ListEntriesRequest_Filter{
+			ByParentId: &types.SPIFFEID{TrustDomain: "example.org", Path: "/agent"},
+		}
+		entries, err := client.ListAllEntries(context.Background(), filter)
+		require.NoError(t, err)
+		assert.Len(t, entries, 8)
+		assert.Equal(t, "a-0", entries[0].Id)
+		assert.Equal(t, "c-1", entries[7].Id)
+
+		require.Len(t, server.listRequests, 3)
+		assert.Equal(t, "", server.listRequests[0].PageToken)
+		assert.Equal(t, "1", server.listRequests[1].PageToken)
+		assert.Equal(t, "2", server.listRequests[2].PageToken)
+		assert.Equal(t, "/agent", server.listRequests[2].Filter.ByParentId.Path)
+	})
+
+	t.Run("no entries", func(t *testing.T) {
+		client := newEntryTestClient(t, &fakeEntryServer{})
+
+		entries, err := client.ListAllEntries(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("stops after max pages", func(t *testing.T) {
+		server := &fakeEntryServer{endlessPages: true}
+		client := newEntryTestClient(t, server)
+
+		entries, err := client.ListAllEntries(context.Background(), nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeded 100 pages")
+		assert.Nil(t, entries)
+		assert.Len(t, server.listRequests, maxListPages)
+	})
+}
+
+func TestClient_DeleteEntry(t *testing.T) {
+	t.Run("deletes entry", func(t *testing.T) {
+		server := &fakeEntryServer{}
+		client := newEntryTestClient(t, server)
+
+		require.NoError(t, client.DeleteEntry(context.Background(), "entry-1"))
+		assert.Equal(t, []string{"entry-1"}, server.deleted)
+	})
+
+	t.Run("per-entry failure", func(t *testing.T) {
+		server := &fakeEntryServer{deleteFail: map[string]bool{"entry-1": true}}
+		client := newEntryTestClient(t, server)
+
+		err := client.DeleteEntry(context.Background(), "entry-1")
+		require.Error(t, err)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.Contains(t, err.Error(), "entry-1")
+	})
+
+	t.Run("empty ID", func(t *testing.T) {
+		err := newEntryTestClient(t, &fakeEntryServer{}).DeleteEntry(context.Background(), "")
+		assert.ErrorAs(t, err, new(*ValidationError))
+	})
+}
+
+func TestSelectorFilter_toProto(t *testing.T) {
+	selectors := []WorkloadSelector{
+		{Type: "unix", Value: "uid:1000"},
+		{Type: "k8s", Value: "ns:default"},
+	}
+
+	tests := []struct {
+		name      string
+		filter    SelectorFilter
+		wantMatch types.SelectorMatch_MatchBehavior
+		wantNil   bool
+		wantErr   bool
+	}{
+		{name: "match any", filter: SelectorFilter{Selectors: selectors, MatchMode: MatchAny}, wantMatch: types.SelectorMatch_MATCH_ANY},
+		{name: "match all", filter: SelectorFilter{Selectors: selectors, MatchMode: MatchAll}, wantMatch: types.SelectorMatch_MATCH_SUPERSET},
+		{name: "wildcard", filter: WildcardFilter(), wantNil: true},
+		{name: "unknown match mode", filter: SelectorFilter{Selectors: selectors, MatchMode: MatchMode(42)}, wantErr: true},
+		{name: "empty selector value", filter: SelectorFilter{Selectors: []WorkloadSelector{{Type: "unix"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.toProto()
+			if tt.wantErr {
+				assert.ErrorAs(t, err, new(*ValidationError))
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.wantNil {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got.BySelectors)
+			assert.Equal(t, tt.wantMatch, got.BySelectors.Match)
+			require.Len(t, got.BySelectors.Selectors, len(selectors))
+			for i, s := range selectors {
+				assert.Equal(t, s.Type, got.BySelectors.Selectors[i].Type)
+				assert.Equal(t, s.Value, got.BySelectors.Selectors[i].Value)
+			}
+			assert.Nil(t, got.ByParentId)
+			assert.Nil(t, got.BySpiffeId)
+		})
+	}
+}
+
+func TestClient_ListEntriesBySelector(t *testing.T) {
+	t.Run("sends typed filter", func(t *testing.T) {
+		server := &fakeEntryServer{pages: [][]*types.Entry{testEntries("a", 2), testEntries("b", 1)}}
+		client := newEntryTestClient(t, server)
+
+		entries, err := client.ListEntriesBySelector(context.Background(), SelectorFilter{
+			Selectors: []WorkloadSelector{{Type: "unix", Value: "uid:1000"}},
+			MatchMode: MatchAll,
+		})
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+
+		require.Len(t, server.listRequests, 2)
+		for _, req := range server.listRequests {
+			require.NotNil(t, req.Filter.GetBySelectors())
+			assert.Equal(t, types.SelectorMatch_MATCH_SUPERSET, req.Filter.BySelectors.Match)
+			assert.Equal(t, "uid:1000", req.Filter.BySelectors.Selectors[0].Value)
+		}
+	})
+
+	t.Run("wildcard sends no filter", func(t *testing.T) {
+		server := &fakeEntryServer{pages: [][]*types.Entry{testEntries("a", 2)}}
+		client := newEntryTestClient(t, server)
+
+		entries, err := client.ListEntriesBySelector(context.Background(), WildcardFilter())
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		require.Len(t, server.listRequests, 1)
+		assert.Nil(t, server.listRequests[0].Filter)
+	})
+
+	t.Run("invalid filter is not sent", func(t *testing.T) {
+		server := &fakeEntryServer{}
+		client := newEntryTestClient(t, server)
+
+		_, err := client.ListEntriesBySelector(context.Background(), SelectorFilter{
+			Selectors: []WorkloadSelector{{Value: "uid:1000"}},
+		})
+		assert.ErrorAs(t, err, new(*ValidationError))
+		assert.Empty(t, server.listRequests)
+	})
+}
+
+func TestClient_DeleteStaleEntries(t *testing.T) {
+	now := time.Now()
+	entries := []*types.Entry{
+		{Id: "stale-1", CreatedAt: now.Add(-48 * time.Hour).Unix()},
+		{Id: "fresh", CreatedAt: now.Add(-time.Hour).Unix()},
+		{Id: "unknown"},
+		{Id: "stale-2", CreatedAt: now.Add(-72 * time.Hour).Unix()},
+	}
+
+	t.Run("deletes entries older than threshold", func(t *testing.T) {
+		server := &fakeEntryServer{pages: [][]*types.Entry{entries[:2], entries[2:]}}
+		client := newEntryTestClient(t, server)
+
+		count, err := client.DeleteStaleEntries(context.Background(), 24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"stale-1", "stale-2"}, server.deleted)
+	})
+
+	t.Run("nothing stale", func(t *testing.T) {
+		server := &fakeEntryServer{pages: [][]*types.Entry{entries}}
+		client := newEntryTestClient(t, server)
+
+		count, err := client.DeleteStaleEntries(context.Background(), 100*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Empty(t, server.deleted)
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		server := &fakeEntryServer{
+			pages:      [][]*types.Entry{entries},
+			deleteFail: map[string]bool{"stale-2": true},
+		}
+		client := newEntryTestClient(t, server)
+
+		count, err := client.DeleteStaleEntries(context.Background(), 24*time.Hour)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "stale-2")
+		assert.Equal(t, 1, count)
+	})
+}
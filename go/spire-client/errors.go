@@ -0,0 +1,85 @@
+package spireclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConnectionError is returned when a connection to SPIRE Server cannot be established or used
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// TLSConfigError is returned when a TLS configuration cannot be built or a peer certificate
+// fails TLS-level verification
+type TLSConfigError struct {
+	Err error
+}
+
+func (e *TLSConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TLSConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when a caller-supplied argument or configuration is invalid
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// SPIFFEIDError is returned when a SPIFFE ID is malformed, missing, or not authorized
+type SPIFFEIDError struct {
+	Err error
+}
+
+func (e *SPIFFEIDError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SPIFFEIDError) Unwrap() error {
+	return e.Err
+}
+
+// TrustDomainError is returned when a server presents a SPIFFE ID outside the pinned trust domains
+type TrustDomainError struct {
+	// Got lists the trust domains of the SPIFFE IDs in the server certificate
+	Got []string
+	// Allowed lists the pinned trust domains
+	Allowed []string
+}
+
+func (e *TrustDomainError) Error() string {
+	return fmt.Sprintf("server trust domains %v are not allowed: expected %v", e.Got, e.Allowed)
+}
+
+// ConfigValidationError is returned by ValidateConfig and lists every inconsistency found in a Config
+type ConfigValidationError struct {
+	violations []string
+}
+
+func (e *ConfigValidationError) Error() string {
+	return "invalid client config: " + strings.Join(e.violations, "; ")
+}
+
+// Violations returns a description of each violated rule, in the order they were checked
+func (e *ConfigValidationError) Violations() []string {
+	return append([]string(nil), e.violations...)
+}
@@ -0,0 +1,136 @@
+package spireclient
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorTypes(t *testing.T) {
+	ctx := context.Background()
+
+	closed, err := New(ctx, startTestServer(t))
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	tests := []struct {
+		name   string
+		err    func() error
+		target any
+	}{
+		{
+			name:   "missing address",
+			err:    func() error { _, err := New(ctx, ""); return err },
+			target: new(*ValidationError),
+		},
+		{
+			name:   "missing mTLS key file",
+			err:    func() error { _, err := NewMTLS(ctx, "localhost:8081", "client.crt", ""); return err },
+			target: new(*ValidationError),
+		},
+		{
+			name:   "missing socket path",
+			err:    func() error { _, err := NewAgentClient(ctx, ""); return err },
+			target: new(*ValidationError),
+		},
+		{
+			name:   "nil config",
+			err:    func() error { _, err := NewWithConfig(ctx, nil); return err },
+			target: new(*ValidationError),
+		},
+		{
+			name: "invalid retry policy",
+			err: func() error {
+				_, err := NewWithConfig(ctx, &Config{Address: "localhost:8081", RetryPolicy: &RetryPolicy{}})
+				return err
+			},
+			target: new(*ValidationError),
+		},
+		{
+			name:   "nil SVID",
+			err:    func() error { _, err := NewMutualAuthTLSConfig(RoleClient, nil, nil, nil); return err },
+			target: new(*ValidationError),
+		},
+		{
+			name: "TLS configuration",
+			err: func() error {
+				_, err := NewWithConfig(ctx, &Config{
+					Address:    "localhost:8081",
+					TLSOptions: []TLSOption{WithTLS13Only(), WithCipherSuites(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)},
+				})
+				return err
+			},
+			target: new(*TLSConfigError),
+		},
+		{
+			name: "wrong key password",
+			err: func() error {
+				_, err := loadEncryptedKeyPair("testdata/encrypted-client.crt", "testdata/encrypted-client.key", "wrong")
+				return err
+			},
+			target: new(*TLSConfigError),
+		},
+		{
+			name: "no server certificate",
+			err: func() error {
+				config, err := NewTLSConfig()
+				require.NoError(t, err)
+				return config.VerifyPeerCertificate(nil, nil)
+			},
+			target: new(*TLSConfigError),
+		},
+		{
+			name: "server certificate without SPIFFE ID",
+			err: func() error {
+				config, err := NewTLSConfig()
+				require.NoError(t, err)
+				return config.VerifyPeerCertificate([][]byte{testSPIFFECerts["non-SPIFFE URI"].Raw}, nil)
+			},
+			target: new(*SPIFFEIDError),
+		},
+		{
+			name: "unauthorized server SPIFFE ID",
+			err: func() error {
+				config, err := NewTLSConfig(WithAuthorizedSPIFFEID("spiffe://example.org/other"))
+				require.NoError(t, err)
+				return config.VerifyPeerCertificate([][]byte{testSPIFFECerts["single SPIFFE ID"].Raw}, nil)
+			},
+			target: new(*SPIFFEIDError),
+		},
+		{
+			name:   "invalid SPIFFE ID",
+			err:    func() error { _, _, err := ParseSPIFFEID("https://example.org/workload"); return err },
+			target: new(*SPIFFEIDError),
+		},
+		{
+			name:   "invalid SPIFFE ID path",
+			err:    func() error { return ValidateSPIFFEIDPath("/a/../b") },
+			target: new(*SPIFFEIDError),
+		},
+		{
+			name: "connection refused",
+			err: func() error {
+				_, err := New(ctx, "127.0.0.1:1", WithDialTimeout(100*time.Millisecond))
+				return err
+			},
+			target: new(*ConnectionError),
+		},
+		{
+			name:   "closed connection",
+			err:    func() error { return closed.WaitForReady(ctx) },
+			target: new(*ConnectionError),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			require.Error(t, err)
+			assert.ErrorAs(t, err, tt.target)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package spireclient
+
+import (
+	"context"
+	"fmt"
+
+	trustdomainv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/trustdomain/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Bundle endpoint profiles accepted by CreateFederationRelationship
+const (
+	BundleEndpointProfileHTTPSWeb    = "https_web"
+	BundleEndpointProfileHTTPSSPIFFE = "https_spiffe"
+)
+
+// FederationRelationshipExistsError is returned when a federation relationship with the trust domain already exists
+type FederationRelationshipExistsError struct {
+	TrustDomain string
+}
+
+func (e *FederationRelationshipExistsError) Error() string {
+	return fmt.Sprintf("federation relationship with %s already exists", e.TrustDomain)
+}
+
+// FederationRelationshipNotFoundError is returned when no federation relationship with the trust domain exists
+type FederationRelationshipNotFoundError struct {
+	TrustDomain string
+}
+
+func (e *FederationRelationshipNotFoundError) Error() string {
+	return fmt.Sprintf("federation relationship with %s not found", e.TrustDomain)
+}
+
+// CreateFederationRelationship creates a federation relationship with trustDomain
+// For the https_spiffe profile the bundle endpoint is expected to present spiffe://<trustDomain>/spire/server
+// Returns a *FederationRelationshipExistsError if the relationship already exists
+func (c *Client) CreateFederationRelationship(ctx context.Context, trustDomain, bundleEndpointURL, profileType string) (*types.FederationRelationship, error) {
+	if trustDomain == "" {
+		return nil, fmt.Errorf("trust domain is required")
+	}
+	if bundleEndpointURL == "" {
+		return nil, fmt.Errorf("bundle endpoint URL is required")
+	}
+
+	relationship := &types.FederationRelationship{
+		TrustDomain:       trustDomain,
+		BundleEndpointUrl: bundleEndpointURL,
+	}
+
+	switch profileType {
+	case BundleEndpointProfileHTTPSWeb:
+		relationship.BundleEndpointProfile = &types.FederationRelationship_HttpsWeb{
+			HttpsWeb: &types.HTTPSWebProfile{},
+		}
+	case BundleEndpointProfileHTTPSSPIFFE:
+		relationship.BundleEndpointProfile = &types.FederationRelationship_HttpsSpiffe{
+			HttpsSpiffe: &types.HTTPSSPIFFEProfile{
+				EndpointSpiffeId: fmt.Sprintf("spiffe://%s/spire/server", trustDomain),
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bundle endpoint profile %q", profileType)
+	}
+
+	resp, err := c.TrustDomainClient().BatchCreateFederationRelationship(ctx, &trustdomainv1.BatchCreateFederationRelationshipRequest{
+		FederationRelationships: []*types.FederationRelationship{relationship},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create federation relationship: %w", err)
+	}
+
+	if len(resp.Results) != 1 {
+		return nil, fmt.Errorf("unexpected number of results: %d", len(resp.Results))
+	}
+
+	result := resp.Results[0]
+	switch code := codes.Code(result.GetStatus().GetCode()); code {
+	case codes.OK:
+		return result.FederationRelationship, nil
+	case codes.AlreadyExists:
+		return nil, &FederationRelationshipExistsError{TrustDomain: trustDomain}
+	default:
+		return nil, fmt.Errorf("failed to create federation relationship: %s (%s)", result.GetStatus().GetMessage(), code)
+	}
+}
+
+// RefreshFederationRelationship makes SPIRE Server fetch the bundle of trustDomain from its bundle endpoint
+// Returns a *FederationRelationshipNotFoundError if no relationship with trustDomain exists
+func (c *Client) RefreshFederationRelationship(ctx context.Context, trustDomain string) error {
+	if trustDomain == "" {
+		return fmt.Errorf("trust domain is required")
+	}
+
+	_, err := c.TrustDomainClient().RefreshBundle(ctx, &trustdomainv1.RefreshBundleRequest{
+		TrustDomain: trustDomain,
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &FederationRelationshipNotFoundError{TrustDomain: trustDomain}
+		}
+		return fmt.Errorf("failed to refresh federation relationship: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package spireclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	trustdomainv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/trustdomain/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeTrustDomainServer records federation requests and returns canned results
+type fakeTrustDomainServer struct {
+	trustdomainv1.UnimplementedTrustDomainServer
+	createCode codes.Code
+	createErr  error
+	created    []*types.FederationRelationship
+
+	refreshErr error
+	refreshed  []string
+}
+
+func (s *fakeTrustDomainServer) BatchCreateFederationRelationship(ctx context.Context, req *trustdomainv1.BatchCreateFederationRelationshipRequest) (*trustdomainv1.BatchCreateFederationRelationshipResponse, error) {
+	if s.createErr != nil {
+		return nil, s.createErr
+	}
+
+	resp := &trustdomainv1.BatchCreateFederationRelationshipResponse{}
+	for _, relationship := range req.FederationRelationships {
+		result := &trustdomainv1.BatchCreateFederationRelationshipResponse_Result{
+			Status: &types.Status{Code: int32(s.createCode), Message: s.createCode.String()},
+		}
+		if s.createCode == codes.OK {
+			s.created = append(s.created, relationship)
+			result.FederationRelationship = relationship
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (s *fakeTrustDomainServer) RefreshBundle(ctx context.Context, req *trustdomainv1.RefreshBundleRequest) (*emptypb.Empty, error) {
+	if s.refreshErr != nil {
+		return nil, s.refreshErr
+	}
+	s.refreshed = append(s.refreshed, req.TrustDomain)
+	return &emptypb.Empty{}, nil
+}
+
+// newTrustDomainTestClient returns a client connected to a test server running the given fake TrustDomain service
+func newTrustDomainTestClient(t *testing.T, server *fakeTrustDomainServer) *Client {
+	t.Helper()
+	return newTestClient(t, func(s *grpc.Server) {
+		trustdomainv1.RegisterTrustDomainServer(s, server)
+	})
+}
+
+func TestClient_CreateFederationRelationship(t *testing.T) {
+	tests := []struct {
+		name        string
+		trustDomain string
+		profileType string
+		createCode  codes.Code
+		createErr   error
+		wantErr     string
+		wantExists  bool
+	}{
+		{
+			name:        "https_web",
+			trustDomain: "partner.org",
+			profileType: BundleEndpointProfileHTTPSWeb,
+		},
+		{
+			name:        "https_spiffe",
+			trustDomain: "partner.org",
+			profileType: BundleEndpointProfileHTTPSSPIFFE,
+		},
+		{
+			name:        "already exists",
+			trustDomain: "partner.org",
+			profileType: BundleEndpointProfileHTTPSWeb,
+			createCode:  codes.AlreadyExists,
+			wantErr:     "federation relationship with partner.org already exists",
+			wantExists:  true,
+		},
+		{
+			name:        "result error",
+			trustDomain: "partner.org",
+			profileType: BundleEndpointProfileHTTPSWeb,
+			createCode:  codes.InvalidArgument,
+			wantErr:     "failed to create federation relationship",
+		},
+		{
+			name:        "RPC error",
+			trustDomain: "partner.org",
+			profileType: BundleEndpointProfileHTTPSWeb,
+			createErr:   status.Error(codes.Unavailable, "unavailable"),
+			wantErr:     "failed to create federation relationship",
+		},
+		{
+			name:        "unsupported profile",
+			trustDomain: "partner.org",
+			profileType: "https_unknown",
+			wantErr:     "unsupported bundle endpoint profile",
+		},
+		{
+			name:        "missing trust domain",
+			profileType: BundleEndpointProfileHTTPSWeb,
+			wantErr:     "trust domain is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeTrustDomainServer{createCode: tt.createCode, createErr: tt.createErr}
+			client := newTrustDomainTestClient(t, server)
+
+			relationship, err := client.CreateFederationRelationship(context.Background(), tt.trustDomain, "https://partner.org:8443", tt.profileType)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.Nil(t, relationship)
+
+				var exists *FederationRelationshipExistsError
+				assert.Equal(t, tt.wantExists, errors.As(err, &exists))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.trustDomain, relationship.TrustDomain)
+			assert.Equal(t, "https://partner.org:8443", relationship.BundleEndpointUrl)
+
+			switch tt.profileType {
+			case BundleEndpointProfileHTTPSWeb:
+				assert.NotNil(t, relationship.GetHttpsWeb())
+			case BundleEndpointProfileHTTPSSPIFFE:
+				assert.Equal(t, "spiffe://partner.org/spire/server", relationship.GetHttpsSpiffe().GetEndpointSpiffeId())
+			}
+		})
+	}
+}
+
+func TestClient_RefreshFederationRelationship(t *testing.T) {
+	tests := []struct {
+		name         string
+		trustDomain  string
+		refreshErr   error
+		wantErr      string
+		wantNotFound bool
+	}{
+		{
+			name:        "success",
+			trustDomain: "partner.org",
+		},
+		{
+			name:         "not found",
+			trustDomain:  "partner.org",
+			refreshErr:   status.Error(codes.NotFound, "no relationship"),
+			wantErr:      "federation relationship with partner.org not found",
+			wantNotFound: true,
+		},
+		{
+			name:        "RPC error",
+			trustDomain: "partner.org",
+			refreshErr:  status.Error(codes.Internal, "bundle endpoint unreachable"),
+			wantErr:     "failed to refresh federation relationship",
+		},
+		{
+			name:    "missing trust domain",
+			wantErr: "trust domain is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeTrustDomainServer{refreshErr: tt.refreshErr}
+			client := newTrustDomainTestClient(t, server)
+
+			err := client.RefreshFederationRelationship(context.Background(), tt.trustDomain)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				var notFound *FederationRelationshipNotFoundError
+				assert.Equal(t, tt.wantNotFound, errors.As(err, &notFound))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.trustDomain}, server.refreshed)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package spireclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// spiffeHTTPTimeout bounds dialing, the TLS handshake and each request of clients from NewSPIFFEHTTPClient
+const spiffeHTTPTimeout = 30 * time.Second
+
+// NewSPIFFEHTTPClient creates an HTTP client that presents svid and accepts servers verified against bundle
+// and authorized by authorizer; HTTP/2 is used when the server supports it
+// svid, bundle and authorizer must not be nil
+func NewSPIFFEHTTPClient(svid *x509svid.SVID, bundle *x509bundle.Bundle, authorizer tlsconfig.Authorizer) *http.Client {
+	dialer := &net.Dialer{Timeout: spiffeHTTPTimeout}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsconfig.MTLSClientConfig(svid, bundle, authorizer),
+		TLSHandshakeTimeout: spiffeHTTPTimeout,
+		// A custom TLS configuration disables HTTP/2 unless it is requested explicitly
+		ForceAttemptHTTP2: true,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   spiffeHTTPTimeout,
+	}
+}
+
+// NewSPIFFEHTTPClientFromFiles creates a client like NewSPIFFEHTTPClient from PEM files on disk
+// bundleFile holds the X.509 authorities of trustDomain
+func NewSPIFFEHTTPClientFromFiles(certFile, keyFile, bundleFile, trustDomain string, authorizer tlsconfig.Authorizer) (*http.Client, error) {
+	if authorizer == nil {
+		return nil, &ValidationError{Err: errors.New("authorizer is required")}
+	}
+
+	td, err := spiffeid.TrustDomainFromString(trustDomain)
+	if err != nil {
+		return nil, &ValidationError{Err: fmt.Errorf("invalid trust domain %q: %w", trustDomain, err)}
+	}
+
+	svid, err := x509svid.Load(certFile, keyFile)
+	if err != nil {
+		return nil, &TLSConfigError{Err: fmt.Errorf("failed to load X.509 SVID: %w", err)}
+	}
+
+	bundle, err := x509bundle.Load(td, bundleFile)
+	if err != nil {
+		return nil, &TLSConfigError{Err: fmt.Errorf("failed to load trust bundle: %w", err)}
+	}
+
+	return NewSPIFFEHTTPClient(svid, bundle, authorizer), nil
+}
@@ -0,0 +1,147 @@
+package spireclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHTTPServerID = "spiffe://example.org/http-server"
+	testHTTPClientID = "spiffe://example.org/http-client"
+)
+
+// startSPIFFEHTTPServer starts an mTLS HTTP/2 server presenting testHTTPServerID
+// The handler responds with the SPIFFE ID of the client certificate
+func startSPIFFEHTTPServer(t *testing.T, bundle *x509bundle.Bundle) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, ExtractSPIFFEIDs(r.TLS.PeerCertificates[0])[0])
+	}))
+	server.TLS = tlsconfig.MTLSServerConfig(newTestSVID(t, testHTTPServerID), bundle, tlsconfig.AuthorizeAny())
+	// StartTLS installs its own certificate unless one is configured
+	server.TLS.Certificates = []tls.Certificate{newTestCASignedKeyPair(t, testHTTPServerID)}
+	server.TLS.GetCertificate = nil
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestNewSPIFFEHTTPClient(t *testing.T) {
+	bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{testCACert})
+	server := startSPIFFEHTTPServer(t, bundle)
+	clientSVID := newTestSVID(t, testHTTPClientID)
+
+	t.Run("authorized server", func(t *testing.T) {
+		client := NewSPIFFEHTTPClient(clientSVID, bundle, tlsconfig.AuthorizeID(spiffeid.RequireFromString(testHTTPServerID)))
+		assert.Equal(t, spiffeHTTPTimeout, client.Timeout)
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, testHTTPClientID, string(body))
+		assert.Equal(t, "HTTP/2.0", resp.Proto)
+	})
+
+	t.Run("unauthorized server", func(t *testing.T) {
+		client := NewSPIFFEHTTPClient(clientSVID, bundle, tlsconfig.AuthorizeID(spiffeid.RequireFromString("spiffe://example.org/other")))
+
+		_, err := client.Get(server.URL)
+		assert.ErrorContains(t, err, "unexpected ID")
+	})
+
+	t.Run("untrusted server", func(t *testing.T) {
+		otherBundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{testSPIFFECerts["single SPIFFE ID"]})
+		client := NewSPIFFEHTTPClient(clientSVID, otherBundle, tlsconfig.AuthorizeAny())
+
+		_, err := client.Get(server.URL)
+		assert.Error(t, err)
+	})
+}
+
+// writeTestSVIDFiles writes svid and the CA fixture as PEM files and returns their paths
+func writeTestSVIDFiles(t *testing.T, svid *x509svid.SVID) (certFile, keyFile, bundleFile string) {
+	t.Helper()
+
+	certPEM, keyPEM, err := svid.Marshal()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "svid.crt")
+	keyFile = filepath.Join(dir, "svid.key")
+	bundleFile = filepath.Join(dir, "bundle.pem")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0644))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(bundleFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: testCACert.Raw}), 0644))
+	return certFile, keyFile, bundleFile
+}
+
+func TestNewSPIFFEHTTPClientFromFiles(t *testing.T) {
+	bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{testCACert})
+	server := startSPIFFEHTTPServer(t, bundle)
+	certFile, keyFile, bundleFile := writeTestSVIDFiles(t, newTestSVID(t, testHTTPClientID))
+
+	t.Run("success", func(t *testing.T) {
+		client, err := NewSPIFFEHTTPClientFromFiles(certFile, keyFile, bundleFile, "example.org", tlsconfig.AuthorizeMemberOf(spiffeid.RequireTrustDomainFromString("example.org")))
+		require.NoError(t, err)
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, testHTTPClientID, string(body))
+	})
+
+	tests := []struct {
+		name       string
+		certFile   string
+		bundleFile string
+		td         string
+		authorizer tlsconfig.Authorizer
+		wantErr    any
+	}{
+		{name: "nil authorizer", certFile: certFile, bundleFile: bundleFile, td: "example.org", wantErr: &ValidationError{}},
+		{name: "invalid trust domain", certFile: certFile, bundleFile: bundleFile, td: "Invalid Domain", authorizer: tlsconfig.AuthorizeAny(), wantErr: &ValidationError{}},
+		{name: "missing certificate", certFile: "missing.crt", bundleFile: bundleFile, td: "example.org", authorizer: tlsconfig.AuthorizeAny(), wantErr: &TLSConfigError{}},
+		{name: "missing bundle", certFile: certFile, bundleFile: "missing.pem", td: "example.org", authorizer: tlsconfig.AuthorizeAny(), wantErr: &TLSConfigError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewSPIFFEHTTPClientFromFiles(tt.certFile, keyFile, tt.bundleFile, tt.td, tt.authorizer)
+			assert.Nil(t, client)
+			require.Error(t, err)
+
+			switch tt.wantErr.(type) {
+			case *ValidationError:
+				var target *ValidationError
+				assert.True(t, errors.As(err, &target), "got %T", err)
+			case *TLSConfigError:
+				var target *TLSConfigError
+				assert.True(t, errors.As(err, &target), "got %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package spireclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultJWTRefreshThreshold is how long before expiry a cached JWT-SVID is refreshed
+const DefaultJWTRefreshThreshold = 30 * time.Second
+
+// jwtSVIDFetcher is the subset of workloadapi.JWTSource used by JWTSVIDSource
+type jwtSVIDFetcher interface {
+	FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error)
+	Close() error
+}
+
+// JWTSVIDSource fetches JWT-SVIDs from the SPIRE Agent Workload API and caches them per audience
+// A cached SVID is refreshed once it is within the refresh threshold of its expiry
+type JWTSVIDSource struct {
+	fetcher          jwtSVIDFetcher
+	refreshThreshold time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*jwtsvid.SVID
+}
+
+// NewJWTSVIDSource creates a JWT-SVID source backed by the Workload API at socketPath
+// A zero refreshThreshold uses DefaultJWTRefreshThreshold
+func NewJWTSVIDSource(ctx context.Context, socketPath string, refreshThreshold time.Duration) (*JWTSVIDSource, error) {
+	if socketPath == "" {
+		return nil, fmt.Errorf("socket path is required")
+	}
+
+	if !isUnixAddress(socketPath) {
+		return nil, fmt.Errorf("socket path must be absolute or use the unix:// scheme: %s", socketPath)
+	}
+
+	addr := socketPath
+	if addr[0] == '/' {
+		addr = "unix://" + addr
+	}
+
+	source, err := workloadapi.NewJWTSource(ctx, workloadapi.WithClientOptions(workloadapi.WithAddr(addr)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWT source: %w", err)
+	}
+
+	return newJWTSVIDSource(source, refreshThreshold), nil
+}
+
+// newJWTSVIDSource creates a JWT-SVID source around an existing fetcher
+func newJWTSVIDSource(fetcher jwtSVIDFetcher, refreshThreshold time.Duration) *JWTSVIDSource {
+	if refreshThreshold <= 0 {
+		refreshThreshold = DefaultJWTRefreshThreshold
+	}
+
+	return &JWTSVIDSource{
+		fetcher:          fetcher,
+		refreshThreshold: refreshThreshold,
+		cache:            make(map[string]*jwtsvid.SVID),
+	}
+}
+
+// FetchToken returns a serialized JWT-SVID for audience, fetching a new one if the cached SVID is near expiry
+func (s *JWTSVIDSource) FetchToken(ctx context.Context, audience string) (string, error) {
+	if audience == "" {
+		return "", fmt.Errorf("audience is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if svid, ok := s.cache[audience]; ok && time.Until(svid.Expiry) > s.refreshThreshold {
+		return svid.Marshal(), nil
+	}
+
+	svid, err := s.fetcher.FetchJWTSVID(ctx, jwtsvid.Params{Audience: audience})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch JWT SVID: %w", err)
+	}
+
+	s.cache[audience] = svid
+	return svid.Marshal(), nil
+}
+
+// Close closes the underlying Workload API source
+func (s *JWTSVIDSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache = make(map[string]*jwtsvid.SVID)
+	return s.fetcher.Close()
+}
+
+// NewJWTSVIDInterceptor returns a unary interceptor that attaches a JWT-SVID for audience to each call
+// The token is sent as "authorization: Bearer <token>" metadata
+func NewJWTSVIDInterceptor(source *JWTSVIDSource, audience string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := withJWTSVID(ctx, source, audience)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// NewJWTSVIDStreamInterceptor returns a stream interceptor that attaches a JWT-SVID for audience to each stream
+func NewJWTSVIDStreamInterceptor(source *JWTSVIDSource, audience string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := withJWTSVID(ctx, source, audience)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// withJWTSVID appends a bearer JWT-SVID for audience to the outgoing metadata of ctx
+func withJWTSVID(ctx context.Context, source *JWTSVIDSource, audience string) (context.Context, error) {
+	token, err := source.FetchToken(ctx, audience)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token), nil
+}
+
+// callerSPIFFEIDKey is the context key under which NewSPIFFEIDExtractorInterceptor stores the caller's SPIFFE ID
+type callerSPIFFEIDKey struct{}
+
+// NewSPIFFEIDExtractorInterceptor returns a unary server interceptor that reads the caller's SPIFFE ID from the
+// "authorization: Bearer <JWT-SVID>" metadata sent by NewJWTSVIDInterceptor and rejects callers not accepted by authorizer
+// The token signature is verified against the JWT authorities in bundles, and its audience must include every entry
+// of audience. The ID is available to handlers through CallerSPIFFEIDFromContext
+// Missing, malformed or unverifiable tokens fail with codes.Unauthenticated and unauthorized IDs with
+// codes.PermissionDenied; a nil bundle source or authorizer rejects every caller
+func NewSPIFFEIDExtractorInterceptor(bundles jwtbundle.Source, audience []string, authorizer SPIFFEIDMatcher) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id, err := callerSPIFFEID(ctx, bundles, audience)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		if authorizer == nil {
+			return nil, status.Errorf(codes.PermissionDenied, "caller SPIFFE ID %s is not authorized: nil matcher", id)
+		}
+		if !authorizer.Match(id) {
+			return nil, status.Errorf(codes.PermissionDenied, "caller SPIFFE ID %s is not authorized: expected %s", id, authorizer)
+		}
+
+		return handler(context.WithValue(ctx, callerSPIFFEIDKey{}, id), req)
+	}
+}
+
+// CallerSPIFFEIDFromContext returns the caller SPIFFE ID stored by NewSPIFFEIDExtractorInterceptor
+// The boolean is false if the interceptor did not run for this context
+func CallerSPIFFEIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(callerSPIFFEIDKey{}).(string)
+	return id, ok
+}
+
+// callerSPIFFEID verifies the bearer JWT-SVID in the incoming metadata of ctx and returns its subject
+func callerSPIFFEID(ctx context.Context, bundles jwtbundle.Source, audience []string) (string, error) {
+	if bundles == nil {
+		return "", errors.New("no JWT bundle source to verify the token")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	switch len(values) {
+	case 0:
+		return "", errors.New("missing authorization metadata")
+	case 1:
+	default:
+		return "", errors.New("multiple authorization metadata values")
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errors.New("authorization metadata is not a bearer token")
+	}
+
+	svid, err := jwtsvid.ParseAndValidate(token, bundles, audience)
+	if err != nil {
+		return "", fmt.Errorf("invalid JWT-SVID: %w", err)
+	}
+	return svid.ID.String(), nil
+}
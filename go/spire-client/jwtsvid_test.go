@@ -0,0 +1,346 @@
+package spireclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/spiffe/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
+	agentv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/agent/v1"
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeJWTSVIDFetcher returns JWT-SVIDs with a fixed lifetime
+type fakeJWTSVIDFetcher struct {
+	t        *testing.T
+	lifetime time.Duration
+	err      error
+	fetches  int
+	closed   bool
+}
+
+func (f *fakeJWTSVIDFetcher) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.fetches++
+	return newTestJWTSVID(f.t, params.Audience, time.Now().Add(f.lifetime)), nil
+}
+
+func (f *fakeJWTSVIDFetcher) Close() error {
+	f.closed = true
+	return nil
+}
+
+// newTestJWTSVID builds an unsigned JWT-SVID with the given audience and expiry
+func newTestJWTSVID(t *testing.T, audience string, expiry time.Time) *jwtsvid.SVID {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	token := encode(map[string]string{"alg": "ES256", "typ": "JWT"}) + "." +
+		encode(map[string]interface{}{
+			"sub": "spiffe://example.org/workload",
+			"aud": []string{audience},
+			"exp": expiry.Unix(),
+			// Make each token unique so refreshes are observable
+			"jti": time.Now().String(),
+		}) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("signature"))
+
+	svid, err := jwtsvid.ParseInsecure(token, []string{audience})
+	require.NoError(t, err)
+	return svid
+}
+
+func TestJWTSVIDSource_FetchToken(t *testing.T) {
+	t.Run("caches token until threshold", func(t *testing.T) {
+		fetcher := &fakeJWTSVIDFetcher{t: t, lifetime: time.Hour}
+		source := newJWTSVIDSource(fetcher, time.Minute)
+
+		first, err := source.FetchToken(context.Background(), "openfga")
+		require.NoError(t, err)
+		second, err := source.FetchToken(context.Background(), "openfga")
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, fetcher.fetches)
+	})
+
+	t.Run("refreshes token near expiry", func(t *testing.T) {
+		fetcher := &fakeJWTSVIDFetcher{t: t, lifetime: 10 * time.Second}
+		source := newJWTSVIDSource(fetcher, time.Minute)
+
+		first, err := source.FetchToken(context.Background(), "openfga")
+		require.NoError(t, err)
+		second, err := source.FetchToken(context.Background(), "openfga")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, 2, fetcher.fetches)
+	})
+
+	t.Run("caches per audience", func(t *testing.T) {
+		fetcher := &fakeJWTSVIDFetcher{t: t, lifetime: time.Hour}
+		source := newJWTSVIDSource(fetcher, 0)
+
+		_, err := source.FetchToken(context.Background(), "openfga")
+		require.NoError(t, err)
+		_, err = source.FetchToken(context.Background(), "other")
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, fetcher.fetches)
+		assert.Equal(t, DefaultJWTRefreshThreshold, source.refreshThreshold)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		fetcher := &fakeJWTSVIDFetcher{t: t, err: errors.New("agent unavailable")}
+		source := newJWTSVIDSource(fetcher, time.Minute)
+
+		token, err := source.FetchToken(context.Background(), "openfga")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to fetch JWT SVID")
+		assert.Empty(t, token)
+	})
+
+	t.Run("empty audience", func(t *testing.T) {
+		source := newJWTSVIDSource(&fakeJWTSVIDFetcher{t: t}, time.Minute)
+
+		_, err := source.FetchToken(context.Background(), "")
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTSVIDSource_Close(t *testing.T) {
+	fetcher := &fakeJWTSVIDFetcher{t: t}
+	source := newJWTSVIDSource(fetcher, time.Minute)
+
+	require.NoError(t, source.Close())
+	assert.True(t, fetcher.closed)
+}
+
+func TestNewJWTSVIDSource(t *testing.T) {
+	t.Run("empty socket path", func(t *testing.T) {
+		source, err := NewJWTSVIDSource(context.Background(), "", 0)
+		assert.Error(t, err)
+		assert.Nil(t, source)
+	})
+
+	t.Run("relative socket path", func(t *testing.T) {
+		source, err := NewJWTSVIDSource(context.Background(), "api.sock", 0)
+		assert.Error(t, err)
+		assert.Nil(t, source)
+	})
+}
+
+// metadataServer records the authorization metadata received by unary and streaming RPCs
+type metadataServer struct {
+	bundlev1.UnimplementedBundleServer
+	agentv1.UnimplementedAgentServer
+	authorization []string
+}
+
+func (s *metadataServer) GetBundle(ctx context.Context, req *bundlev1.GetBundleRequest) (*types.Bundle, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.authorization = append(s.authorization, md.Get("authorization")...)
+	return &types.Bundle{TrustDomain: "example.org"}, nil
+}
+
+func (s *metadataServer) AttestAgent(stream agentv1.Agent_AttestAgentServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.authorization = append(s.authorization, md.Get("authorization")...)
+	return nil
+}
+
+func TestJWTSVIDInterceptors(t *testing.T) {
+	newClient := func(t *testing.T, server *metadataServer, source *JWTSVIDSource) *Client {
+		t.Helper()
+
+		address := startTestServer(t, func(s *grpc.Server) {
+			bundlev1.RegisterBundleServer(s, server)
+			agentv1.RegisterAgentServer(s, server)
+		})
+		client, err := NewWithConfig(context.Background(), &Config{
+			Address: address,
+			DialOptions: []grpc.DialOption{
+				grpc.WithUnaryInterceptor(NewJWTSVIDInterceptor(source, "spire-server")),
+				grpc.WithStreamInterceptor(NewJWTSVIDStreamInterceptor(source, "spire-server")),
+			},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+
+	t.Run("unary call carries token", func(t *testing.T) {
+		source := newJWTSVIDSource(&fakeJWTSVIDFetcher{t: t, lifetime: time.Hour}, time.Minute)
+		server := &metadataServer{}
+		client := newClient(t, server, source)
+
+		_, err := client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		require.NoError(t, err)
+
+		token, err := source.FetchToken(context.Background(), "spire-server")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bearer " + token}, server.authorization)
+	})
+
+	t.Run("stream carries token", func(t *testing.T) {
+		source := newJWTSVIDSource(&fakeJWTSVIDFetcher{t: t, lifetime: time.Hour}, time.Minute)
+		server := &metadataServer{}
+		client := newClient(t, server, source)
+
+		stream, err := client.AgentClient().AttestAgent(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		require.Error(t, err) // The server ends the stream without a response
+
+		token, err := source.FetchToken(context.Background(), "spire-server")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bearer " + token}, server.authorization)
+	})
+
+	t.Run("fetch error fails the call", func(t *testing.T) {
+		source := newJWTSVIDSource(&fakeJWTSVIDFetcher{t: t, err: errors.New("agent unavailable")}, time.Minute)
+		server := &metadataServer{}
+		client := newClient(t, server, source)
+
+		_, err := client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "agent unavailable")
+		assert.Empty(t, server.authorization)
+
+		_, err = client.AgentClient().AttestAgent(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "agent unavailable")
+	})
+}
+
+// newTestJWTSigner returns a function that signs JWT-SVID claims with a fresh ES256 key under keyID,
+// and the public key to register as a JWT authority
+func newTestJWTSigner(t *testing.T, keyID string) (func(claims jwt.Claims) string, *ecdsa.PublicKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID))
+	require.NoError(t, err)
+
+	return func(claims jwt.Claims) string {
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}, &key.PublicKey
+}
+
+func TestSPIFFEIDExtractorInterceptor(t *testing.T) {
+	sign, publicKey := newTestJWTSigner(t, "authority")
+	bundle := jwtbundle.New(spiffeid.RequireTrustDomainFromString("example.org"))
+	require.NoError(t, bundle.AddJWTAuthority("authority", publicKey))
+
+	claims := func(audience string, expiry time.Time) jwt.Claims {
+		return jwt.Claims{
+			Subject:  "spiffe://example.org/workload",
+			Audience: jwt.Audience{audience},
+			Expiry:   jwt.NewNumericDate(expiry),
+		}
+	}
+	token := sign(claims("spire-server", time.Now().Add(time.Hour)))
+
+	// call runs the interceptor with the given authorization metadata and returns the ID seen by the handler
+	call := func(t *testing.T, bundles jwtbundle.Source, authorizer SPIFFEIDMatcher, authorization ...string) (string, error) {
+		t.Helper()
+
+		ctx := context.Background()
+		if len(authorization) > 0 {
+			md := metadata.MD{}
+			md.Append("authorization", authorization...)
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+
+		var handlerID string
+		interceptor := NewSPIFFEIDExtractorInterceptor(bundles, []string{"spire-server"}, authorizer)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				id, ok := CallerSPIFFEIDFromContext(ctx)
+				require.True(t, ok)
+				handlerID = id
+				return nil, nil
+			})
+		return handlerID, err
+	}
+
+	t.Run("authorized caller", func(t *testing.T) {
+		id, err := call(t, bundle, NewPrefixMatcher("spiffe://example.org/"), "Bearer "+token)
+		require.NoError(t, err)
+		assert.Equal(t, "spiffe://example.org/workload", id)
+	})
+
+	// Same key ID as the trusted authority, different key
+	forge, _ := newTestJWTSigner(t, "authority")
+	forgedToken := forge(claims("spire-server", time.Now().Add(time.Hour)))
+	// Unsigned token as built by newTestJWTSVID
+	unsignedToken := newTestJWTSVID(t, "spire-server", time.Now().Add(time.Hour)).Marshal()
+	otherBundle := jwtbundle.New(spiffeid.RequireTrustDomainFromString("other.org"))
+
+	tests := []struct {
+		name          string
+		bundles       jwtbundle.Source
+		authorizer    SPIFFEIDMatcher
+		authorization []string
+		code          codes.Code
+		errMsg        string
+	}{
+		{name: "missing metadata", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), code: codes.Unauthenticated, errMsg: "missing authorization metadata"},
+		{name: "multiple values", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + token, "Bearer " + token}, code: codes.Unauthenticated, errMsg: "multiple authorization metadata values"},
+		{name: "not a bearer token", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Basic dXNlcjpwYXNz"}, code: codes.Unauthenticated, errMsg: "not a bearer token"},
+		{name: "malformed token", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer not-a-jwt"}, code: codes.Unauthenticated, errMsg: "invalid JWT-SVID"},
+		{name: "unknown signing key", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + forgedToken}, code: codes.Unauthenticated, errMsg: "invalid JWT-SVID"},
+		{name: "unsigned token", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + unsignedToken}, code: codes.Unauthenticated, errMsg: "missing key id"},
+		{name: "no bundle for trust domain", bundles: otherBundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + token}, code: codes.Unauthenticated, errMsg: "no bundle found"},
+		{name: "nil bundle source", bundles: nil, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + token}, code: codes.Unauthenticated, errMsg: "no JWT bundle source"},
+		{name: "wrong audience", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + sign(claims("other", time.Now().Add(time.Hour)))}, code: codes.Unauthenticated, errMsg: "expected audience"},
+		{name: "expired token", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/workload"), authorization: []string{"Bearer " + sign(claims("spire-server", time.Now().Add(-time.Minute)))}, code: codes.Unauthenticated, errMsg: "token has expired"},
+		{name: "unauthorized caller", bundles: bundle, authorizer: NewExactMatcher("spiffe://example.org/other"), authorization: []string{"Bearer " + token}, code: codes.PermissionDenied, errMsg: `expected SPIFFE ID "spiffe://example.org/other"`},
+		{name: "nil authorizer", bundles: bundle, authorizer: nil, authorization: []string{"Bearer " + token}, code: codes.PermissionDenied, errMsg: "nil matcher"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := call(t, tt.bundles, tt.authorizer, tt.authorization...)
+			require.Error(t, err)
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Empty(t, id, "handler should not run")
+		})
+	}
+
+	t.Run("context without interceptor", func(t *testing.T) {
+		_, ok := CallerSPIFFEIDFromContext(context.Background())
+		assert.False(t, ok)
+	})
+}
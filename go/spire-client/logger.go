@@ -0,0 +1,86 @@
+package spireclient
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// Logger receives structured client events as a message followed by alternating keys and values
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, err error, args ...any)
+}
+
+// slogLogger adapts a *slog.Logger to Logger
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// SlogLogger returns a Logger that writes to l, recording errors under the "error" key
+func SlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+func (s slogLogger) Info(msg string, args ...any) {
+	s.l.Info(msg, args...)
+}
+
+func (s slogLogger) Error(msg string, err error, args ...any) {
+	s.l.Error(msg, append(args, "error", err)...)
+}
+
+// noopLogger discards all events
+type noopLogger struct{}
+
+// NoopLogger returns a Logger that discards all events
+func NoopLogger() Logger {
+	return noopLogger{}
+}
+
+func (noopLogger) Info(string, ...any) {}
+
+func (noopLogger) Error(string, error, ...any) {}
+
+// configLogger returns config.Logger, or a no-op logger when none is set
+func configLogger(config *Config) Logger {
+	if config.Logger == nil {
+		return NoopLogger()
+	}
+	return config.Logger
+}
+
+// stateChangeFunc returns the callback for connection state transitions described by config
+// Returns nil when neither OnStateChange nor Logger is set
+func stateChangeFunc(config *Config) func(old, new connectivity.State) {
+	if config.Logger == nil {
+		return config.OnStateChange
+	}
+
+	return func(old, new connectivity.State) {
+		config.Logger.Info("connection state changed", "address", config.Address, "from", old.String(), "to", new.String())
+		if config.OnStateChange != nil {
+			config.OnStateChange(old, new)
+		}
+	}
+}
+
+// newLoggingInterceptor returns a unary interceptor that logs the method, status code and duration of each call
+func newLoggingInterceptor(logger Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		args := []any{"method", method, "code", status.Code(err).String(), "duration", time.Since(start)}
+		if err != nil {
+			logger.Error("call failed", err, args...)
+		} else {
+			logger.Info("call completed", args...)
+		}
+		return err
+	}
+}
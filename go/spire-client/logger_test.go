@@ -0,0 +1,190 @@
+package spireclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// logBuffer is a buffer safe for the concurrent writes of the state watcher
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records decodes the JSON log records written so far
+func (b *logBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// find returns the first record with msg whose attributes include want
+func (b *logBuffer) find(t *testing.T, msg string, want map[string]any) map[string]any {
+	t.Helper()
+
+	for _, record := range b.records(t) {
+		if record["msg"] != msg {
+			continue
+		}
+		matched := true
+		for k, v := range want {
+			if record[k] != v {
+				matched = false
+			}
+		}
+		if matched {
+			return record
+		}
+	}
+	return nil
+}
+
+// newTestLogger returns a JSON SlogLogger writing to the returned buffer
+func newTestLogger() (Logger, *logBuffer) {
+	buf := &logBuffer{}
+	return SlogLogger(slog.New(slog.NewJSONHandler(buf, nil))), buf
+}
+
+func TestSlogLogger(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	logger.Info("connected", "address", "localhost:8081")
+	logger.Error("dial failed", errors.New("refused"), "address", "localhost:8081")
+
+	records := buf.records(t)
+	require.Len(t, records, 2)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "localhost:8081", records[0]["address"])
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, "refused", records[1]["error"])
+	assert.Equal(t, "localhost:8081", records[1]["address"])
+}
+
+func TestNoopLogger(t *testing.T) {
+	logger := NoopLogger()
+	assert.NotPanics(t, func() {
+		logger.Info("ignored", "key", "value")
+		logger.Error("ignored", errors.New("boom"))
+	})
+}
+
+func TestConfig_Logger(t *testing.T) {
+	t.Run("logs dial, state changes and calls", func(t *testing.T) {
+		server := &fakeBundleServer{bundle: testFixtureBundle()}
+		addr := startTestServer(t, func(s *grpc.Server) {
+			bundlev1.RegisterBundleServer(s, server)
+		})
+
+		logger, buf := newTestLogger()
+		client, err := NewWithConfig(context.Background(), &Config{
+			Address:     addr,
+			DialTimeout: 5 * time.Second,
+			Logger:      logger,
+		})
+		require.NoError(t, err)
+		defer client.Close()
+
+		assert.NotNil(t, buf.find(t, "dialing SPIRE Server", map[string]any{"address": addr}))
+		assert.NotNil(t, buf.find(t, "dialed SPIRE Server", map[string]any{"address": addr}))
+
+		_, err = client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		require.NoError(t, err)
+
+		call := buf.find(t, "call completed", map[string]any{
+			"method": "/spire.api.server.bundle.v1.Bundle/GetBundle",
+			"code":   codes.OK.String(),
+		})
+		require.NotNil(t, call)
+		assert.Contains(t, call, "duration")
+
+		require.NoError(t, client.Close())
+		assert.Eventually(t, func() bool {
+			return buf.find(t, "connection state changed", map[string]any{"address": addr, "from": "READY", "to": "SHUTDOWN"}) != nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("logs failed calls", func(t *testing.T) {
+		server := &fakeBundleServer{err: status.Error(codes.Unavailable, "restarting")}
+		addr := startTestServer(t, func(s *grpc.Server) {
+			bundlev1.RegisterBundleServer(s, server)
+		})
+
+		logger, buf := newTestLogger()
+		client, err := NewWithConfig(context.Background(), &Config{Address: addr, Logger: logger})
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = client.BundleClient().GetBundle(context.Background(), &bundlev1.GetBundleRequest{})
+		require.Error(t, err)
+
+		call := buf.find(t, "call failed", map[string]any{"code": codes.Unavailable.String(), "level": "ERROR"})
+		require.NotNil(t, call)
+		assert.Contains(t, call["error"], "restarting")
+	})
+
+	t.Run("logs failed dial", func(t *testing.T) {
+		logger, buf := newTestLogger()
+		_, err := NewWithConfig(context.Background(), &Config{
+			Address:     "localhost:1",
+			DialTimeout: 200 * time.Millisecond,
+			Logger:      logger,
+		})
+		require.Error(t, err)
+
+		record := buf.find(t, "failed to dial SPIRE Server", map[string]any{"address": "localhost:1", "level": "ERROR"})
+		require.NotNil(t, record)
+		assert.Contains(t, record["error"], "failed to connect to SPIRE Server")
+	})
+
+	t.Run("keeps OnStateChange", func(t *testing.T) {
+		logger, _ := newTestLogger()
+		changed := make(chan struct{}, 100)
+		client, err := NewWithConfig(context.Background(), &Config{
+			Address:       startTestServer(t),
+			DialTimeout:   5 * time.Second,
+			Logger:        logger,
+			OnStateChange: func(_, _ connectivity.State) { changed <- struct{}{} },
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnStateChange was not called")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package spireclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SPIFFEIDMatcher decides whether a SPIFFE ID is authorized, for use with WithAuthorizedSPIFFEIDMatcher
+// String describes the accepted IDs and is included in authorization errors
+type SPIFFEIDMatcher interface {
+	Match(spiffeID string) bool
+	String() string
+}
+
+// exactMatcher matches a single SPIFFE ID
+type exactMatcher struct {
+	id string
+}
+
+// NewExactMatcher returns a matcher accepting only id
+// An empty id matches no SPIFFE ID
+func NewExactMatcher(id string) SPIFFEIDMatcher {
+	return exactMatcher{id: id}
+}
+
+func (m exactMatcher) Match(spiffeID string) bool {
+	return spiffeID != "" && spiffeID == m.id
+}
+
+func (m exactMatcher) String() string {
+	return fmt.Sprintf("SPIFFE ID %q", m.id)
+}
+
+// prefixMatcher matches SPIFFE IDs below a path prefix
+type prefixMatcher struct {
+	prefix string
+}
+
+// NewPrefixMatcher returns a matcher accepting any SPIFFE ID under prefix, such as "spiffe://example.org/ns/prod/"
+// Matching is on whole path segments, with or without the trailing slash: "spiffe://example.org/ns/prod"
+// accepts "spiffe://example.org/ns/prod/web" but not "spiffe://example.org/ns/production" or the prefix itself
+// An empty prefix matches no SPIFFE ID
+func NewPrefixMatcher(prefix string) SPIFFEIDMatcher {
+	return prefixMatcher{prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+func (m prefixMatcher) Match(spiffeID string) bool {
+	return m.prefix != "" && strings.HasPrefix(spiffeID, m.prefix+"/")
+}
+
+func (m prefixMatcher) String() string {
+	return fmt.Sprintf("SPIFFE ID under %q", m.prefix+"/")
+}
+
+// regexpMatcher matches SPIFFE IDs against a regular expression
+type regexpMatcher struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// NewRegexpMatcher returns a matcher accepting SPIFFE IDs that match pattern in full
+// The pattern is anchored at both ends, so "spiffe://example.org/ns/[^/]+/sa/web" does not match a longer ID
+func NewRegexpMatcher(pattern string) (SPIFFEIDMatcher, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, &ValidationError{Err: fmt.Errorf("invalid SPIFFE ID pattern %q: %w", pattern, err)}
+	}
+
+	return regexpMatcher{pattern: pattern, re: re}, nil
+}
+
+func (m regexpMatcher) Match(spiffeID string) bool {
+	return spiffeID != "" && m.re.MatchString(spiffeID)
+}
+
+func (m regexpMatcher) String() string {
+	return fmt.Sprintf("SPIFFE ID matching %q", m.pattern)
+}
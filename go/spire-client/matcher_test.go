@@ -0,0 +1,131 @@
+package spireclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExactMatcher(t *testing.T) {
+	m := NewExactMatcher("spiffe://example.org/ns/prod/sa/web")
+
+	assert.True(t, m.Match("spiffe://example.org/ns/prod/sa/web"))
+	assert.False(t, m.Match("spiffe://example.org/ns/prod/sa/web/extra"))
+	assert.False(t, m.Match("spiffe://example.org/ns/prod/sa"))
+	assert.False(t, m.Match("SPIFFE://example.org/ns/prod/sa/web"))
+	assert.False(t, m.Match(""))
+	assert.Equal(t, `SPIFFE ID "spiffe://example.org/ns/prod/sa/web"`, m.String())
+
+	t.Run("empty ID", func(t *testing.T) {
+		m := NewExactMatcher("")
+		assert.False(t, m.Match(""))
+		assert.False(t, m.Match("spiffe://example.org/web"))
+	})
+}
+
+func TestPrefixMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		spiffeID string
+		want     bool
+	}{
+		{name: "direct child", prefix: "spiffe://example.org/ns/prod/", spiffeID: "spiffe://example.org/ns/prod/web", want: true},
+		{name: "nested child", prefix: "spiffe://example.org/ns/prod/", spiffeID: "spiffe://example.org/ns/prod/sa/web", want: true},
+		{name: "prefix without trailing slash", prefix: "spiffe://example.org/ns/prod", spiffeID: "spiffe://example.org/ns/prod/web", want: true},
+		{name: "partial segment", prefix: "spiffe://example.org/ns/prod", spiffeID: "spiffe://example.org/ns/production/web", want: false},
+		{name: "prefix itself", prefix: "spiffe://example.org/ns/prod/", spiffeID: "spiffe://example.org/ns/prod", want: false},
+		{name: "sibling", prefix: "spiffe://example.org/ns/prod/", spiffeID: "spiffe://example.org/ns/dev/web", want: false},
+		{name: "other trust domain", prefix: "spiffe://example.org/", spiffeID: "spiffe://example.org.evil/web", want: false},
+		{name: "whole trust domain", prefix: "spiffe://example.org/", spiffeID: "spiffe://example.org/web", want: true},
+		{name: "empty prefix", prefix: "", spiffeID: "spiffe://example.org/web", want: false},
+		{name: "slash prefix", prefix: "/", spiffeID: "/web", want: false},
+		{name: "empty SPIFFE ID", prefix: "spiffe://example.org/", spiffeID: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewPrefixMatcher(tt.prefix).Match(tt.spiffeID))
+		})
+	}
+
+	assert.Equal(t, `SPIFFE ID under "spiffe://example.org/ns/prod/"`, NewPrefixMatcher("spiffe://example.org/ns/prod").String())
+}
+
+func TestRegexpMatcher(t *testing.T) {
+	m, err := NewRegexpMatcher(`spiffe://example\.org/ns/[^/]+/sa/web`)
+	require.NoError(t, err)
+
+	assert.True(t, m.Match("spiffe://example.org/ns/prod/sa/web"))
+	assert.True(t, m.Match("spiffe://example.org/ns/dev/sa/web"))
+	assert.False(t, m.Match("spiffe://example.org/ns/prod/sa/web/extra"), "pattern is anchored at the end")
+	assert.False(t, m.Match("x-spiffe://example.org/ns/prod/sa/web"), "pattern is anchored at the start")
+	assert.False(t, m.Match("spiffe://example.org/ns/a/b/sa/web"))
+	assert.False(t, m.Match(""))
+	assert.Equal(t, `SPIFFE ID matching "spiffe://example\\.org/ns/[^/]+/sa/web"`, m.String())
+
+	t.Run("alternation is anchored as a whole", func(t *testing.T) {
+		m, err := NewRegexpMatcher(`spiffe://example.org/a|spiffe://example.org/b`)
+		require.NoError(t, err)
+		assert.True(t, m.Match("spiffe://example.org/b"))
+		assert.False(t, m.Match("spiffe://example.org/a/extra"))
+	})
+
+	t.Run("empty pattern", func(t *testing.T) {
+		m, err := NewRegexpMatcher("")
+		require.NoError(t, err)
+		assert.False(t, m.Match(""))
+		assert.False(t, m.Match("spiffe://example.org/web"))
+	})
+
+	t.Run("compilation error", func(t *testing.T) {
+		m, err := NewRegexpMatcher(`spiffe://example.org/(unclosed`)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Contains(t, err.Error(), "invalid SPIFFE ID pattern")
+		assert.Nil(t, m)
+	})
+}
+
+func TestWithAuthorizedSPIFFEIDMatcher(t *testing.T) {
+	serverCert := newTestCASignedCert(t, "spiffe://example.org/ns/prod/sa/web")
+	regexpMatcher, err := NewRegexpMatcher(`spiffe://example\.org/ns/(prod|staging)/sa/.+`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		matcher SPIFFEIDMatcher
+		errMsg  string
+	}{
+		{name: "exact", matcher: NewExactMatcher("spiffe://example.org/ns/prod/sa/web")},
+		{name: "prefix", matcher: NewPrefixMatcher("spiffe://example.org/ns/prod/")},
+		{name: "regexp", matcher: regexpMatcher},
+		{
+			name:    "prefix mismatch",
+			matcher: NewPrefixMatcher("spiffe://example.org/ns/dev/"),
+			errMsg:  `server SPIFFE ID [spiffe://example.org/ns/prod/sa/web] is not authorized: expected SPIFFE ID under "spiffe://example.org/ns/dev/"`,
+		},
+		{
+			name:    "nil matcher",
+			matcher: nil,
+			errMsg:  "nil matcher",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(WithAuthorizedSPIFFEIDMatcher(tt.matcher))
+			require.NoError(t, err)
+
+			err = config.VerifyPeerCertificate([][]byte{serverCert}, nil)
+			if tt.errMsg != "" {
+				var idErr *SPIFFEIDError
+				require.ErrorAs(t, err, &idErr)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
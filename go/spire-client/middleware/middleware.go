@@ -0,0 +1,35 @@
+// Package middleware provides HTTP middleware that exposes the SPIFFE IDs of mTLS peers to handlers
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+)
+
+// spiffeIDKey is the context key under which SPIFFEIDMiddleware stores the peer's SPIFFE IDs
+type spiffeIDKey struct{}
+
+// SPIFFEIDMiddleware stores the SPIFFE IDs from the peer's leaf certificate in the request context
+// Requests without an mTLS peer or without SPIFFE IDs are not rejected; an empty slice is stored instead
+func SPIFFEIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := []string{}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			if peerIDs := spireclient.ExtractSPIFFEIDs(r.TLS.PeerCertificates[0]); len(peerIDs) > 0 {
+				ids = peerIDs
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), spiffeIDKey{}, ids)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SPIFFEIDFromContext returns the SPIFFE IDs stored by SPIFFEIDMiddleware
+// The boolean is false if the middleware did not run for this context
+func SPIFFEIDFromContext(ctx context.Context) ([]string, bool) {
+	ids, ok := ctx.Value(spiffeIDKey{}).([]string)
+	return ids, ok
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCert(t *testing.T, uris ...string) *x509.Certificate {
+	t.Helper()
+
+	cert := &x509.Certificate{}
+	for _, raw := range uris {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		cert.URIs = append(cert.URIs, u)
+	}
+	return cert
+}
+
+func TestSPIFFEIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  []string
+	}{
+		{
+			name:  "single SPIFFE ID",
+			state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{newTestCert(t, "spiffe://example.org/workload")}},
+			want:  []string{"spiffe://example.org/workload"},
+		},
+		{
+			name: "leaf certificate only",
+			state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+				newTestCert(t, "spiffe://example.org/workload", "https://example.org/not-spiffe"),
+				newTestCert(t, "spiffe://example.org/intermediate"),
+			}},
+			want: []string{"spiffe://example.org/workload"},
+		},
+		{
+			name:  "no SPIFFE IDs",
+			state: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{newTestCert(t, "https://example.org")}},
+			want:  []string{},
+		},
+		{
+			name:  "no peer certificate",
+			state: &tls.ConnectionState{},
+			want:  []string{},
+		},
+		{
+			name: "plain HTTP",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got []string
+				ok  bool
+			)
+			handler := SPIFFEIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok = SPIFFEIDFromContext(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tt.state
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			require.True(t, ok, "handler did not receive SPIFFE IDs")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSPIFFEIDFromContext_WithoutMiddleware(t *testing.T) {
+	ids, ok := SPIFFEIDFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Nil(t, ids)
+}
@@ -0,0 +1,134 @@
+package spireclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy configures automatic retries of failed RPCs through the gRPC service config
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the original call, between 2 and 5
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+	// BackoffMultiplier grows the delay after each retry
+	BackoffMultiplier float64
+	// RetryableStatusCodes are the status codes that trigger a retry
+	RetryableStatusCodes []codes.Code
+}
+
+// DefaultRetryPolicy returns a retry policy for the transient failures SPIRE Server reports
+// while restarting or under load
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       4,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        2 * time.Second,
+		BackoffMultiplier: 2,
+		RetryableStatusCodes: []codes.Code{
+			codes.Unavailable,
+			codes.ResourceExhausted,
+			codes.Aborted,
+		},
+	}
+}
+
+// serviceConfig returns the gRPC service config JSON applying the policy to all methods
+func (p *RetryPolicy) serviceConfig() (string, error) {
+	// gRPC silently caps attempts at 5 and treats fewer than 2 as invalid
+	if p.MaxAttempts < 2 || p.MaxAttempts > 5 {
+		return "", fmt.Errorf("max attempts must be between 2 and 5, got %d", p.MaxAttempts)
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff <= 0 {
+		return "", fmt.Errorf("backoff durations must be positive")
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		return "", fmt.Errorf("max backoff %s is less than initial backoff %s", p.MaxBackoff, p.InitialBackoff)
+	}
+	if p.BackoffMultiplier <= 0 {
+		return "", fmt.Errorf("backoff multiplier must be positive")
+	}
+	if len(p.RetryableStatusCodes) == 0 {
+		return "", fmt.Errorf("at least one retryable status code is required")
+	}
+
+	statusCodes := make([]string, 0, len(p.RetryableStatusCodes))
+	for _, code := range p.RetryableStatusCodes {
+		if _, ok := statusCodeNames[code]; !ok || code == codes.OK {
+			return "", fmt.Errorf("invalid retryable status code %d", code)
+		}
+		statusCodes = append(statusCodes, statusCodeName(code))
+	}
+
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		// An empty name matches every method of every service
+		Name        []struct{}  `json:"name"`
+		RetryPolicy retryPolicy `json:"retryPolicy"`
+	}
+
+	config := struct {
+		MethodConfig []methodConfig `json:"methodConfig"`
+	}{
+		MethodConfig: []methodConfig{{
+			Name: []struct{}{{}},
+			RetryPolicy: retryPolicy{
+				MaxAttempts:          p.MaxAttempts,
+				InitialBackoff:       durationString(p.InitialBackoff),
+				MaxBackoff:           durationString(p.MaxBackoff),
+				BackoffMultiplier:    p.BackoffMultiplier,
+				RetryableStatusCodes: statusCodes,
+			},
+		}},
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal service config: %w", err)
+	}
+	return string(b), nil
+}
+
+// durationString formats d in the protobuf JSON duration format, e.g. "0.1s"
+func durationString(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// statusCodeNames are the names the gRPC service config accepts for each status code.
+// They follow the gRPC spec, which spells CANCELLED differently from codes.Canceled
+var statusCodeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// statusCodeName returns the canonical name of a status code, e.g. "DEADLINE_EXCEEDED"
+func statusCodeName(code codes.Code) string {
+	return statusCodeNames[code]
+}
@@ -0,0 +1,133 @@
+package spireclient
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestRetryPolicyServiceConfig(t *testing.T) {
+	serviceConfig, err := DefaultRetryPolicy().serviceConfig()
+	require.NoError(t, err)
+	require.True(t, json.Valid([]byte(serviceConfig)))
+
+	var parsed struct {
+		MethodConfig []struct {
+			Name        []map[string]string `json:"name"`
+			RetryPolicy struct {
+				MaxAttempts          int      `json:"maxAttempts"`
+				InitialBackoff       string   `json:"initialBackoff"`
+				MaxBackoff           string   `json:"maxBackoff"`
+				BackoffMultiplier    float64  `json:"backoffMultiplier"`
+				RetryableStatusCodes []string `json:"retryableStatusCodes"`
+			} `json:"retryPolicy"`
+		} `json:"methodConfig"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(serviceConfig), &parsed))
+	require.Len(t, parsed.MethodConfig, 1)
+
+	mc := parsed.MethodConfig[0]
+	assert.Equal(t, []map[string]string{{}}, mc.Name)
+	assert.Equal(t, 4, mc.RetryPolicy.MaxAttempts)
+	assert.Equal(t, "0.1s", mc.RetryPolicy.InitialBackoff)
+	assert.Equal(t, "2s", mc.RetryPolicy.MaxBackoff)
+	assert.Equal(t, 2.0, mc.RetryPolicy.BackoffMultiplier)
+	assert.Equal(t, []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED", "ABORTED"}, mc.RetryPolicy.RetryableStatusCodes)
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(p *RetryPolicy)
+		wantErr string
+	}{
+		{
+			name:    "too few attempts",
+			modify:  func(p *RetryPolicy) { p.MaxAttempts = 1 },
+			wantErr: "max attempts must be between 2 and 5",
+		},
+		{
+			name:    "too many attempts",
+			modify:  func(p *RetryPolicy) { p.MaxAttempts = 6 },
+			wantErr: "max attempts must be between 2 and 5",
+		},
+		{
+			name:    "zero backoff",
+			modify:  func(p *RetryPolicy) { p.InitialBackoff = 0 },
+			wantErr: "backoff durations must be positive",
+		},
+		{
+			name:    "max backoff below initial",
+			modify:  func(p *RetryPolicy) { p.MaxBackoff = 50 * time.Millisecond },
+			wantErr: "max backoff 50ms is less than initial backoff 100ms",
+		},
+		{
+			name:    "zero multiplier",
+			modify:  func(p *RetryPolicy) { p.BackoffMultiplier = 0 },
+			wantErr: "backoff multiplier must be positive",
+		},
+		{
+			name:    "no status codes",
+			modify:  func(p *RetryPolicy) { p.RetryableStatusCodes = nil },
+			wantErr: "at least one retryable status code is required",
+		},
+		{
+			name:    "OK status code",
+			modify:  func(p *RetryPolicy) { p.RetryableStatusCodes = []codes.Code{codes.OK} },
+			wantErr: "invalid retryable status code 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultRetryPolicy()
+			tt.modify(policy)
+
+			_, err := policy.serviceConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			_, err = configDialOptions(&Config{RetryPolicy: policy})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid retry policy")
+		})
+	}
+}
+
+func TestStatusCodeName(t *testing.T) {
+	assert.Equal(t, "OK", statusCodeName(codes.OK))
+	assert.Equal(t, "CANCELLED", statusCodeName(codes.Canceled))
+	assert.Equal(t, "DEADLINE_EXCEEDED", statusCodeName(codes.DeadlineExceeded))
+	assert.Equal(t, "UNAUTHENTICATED", statusCodeName(codes.Unauthenticated))
+}
+
+func TestRetryPolicyConnects(t *testing.T) {
+	client, err := NewWithConfig(context.Background(), &Config{
+		Address:     startTestServer(t),
+		RetryPolicy: DefaultRetryPolicy(),
+	}, WithDialTimeout(5*time.Second))
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert.Equal(t, connectivity.Ready, client.ConnectionState())
+}
+
+func TestRetryPolicyConnectsWithCanceled(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	policy.RetryableStatusCodes = append(policy.RetryableStatusCodes, codes.Canceled)
+
+	client, err := NewWithConfig(context.Background(), &Config{
+		Address:     startTestServer(t),
+		RetryPolicy: policy,
+	}, WithDialTimeout(5*time.Second))
+	require.NoError(t, err)
+	defer client.Close()
+
+	assert.Equal(t, connectivity.Ready, client.ConnectionState())
+}
@@ -1,34 +1,68 @@
 package spireclient
 
 import (
+	"context"
+	"errors"
+
 	agentv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/agent/v1"
 	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
 	entryv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1"
 	svidv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/svid/v1"
 	trustdomainv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/trustdomain/v1"
+	"google.golang.org/grpc"
 )
 
+// clientConn resolves the client's current connection on every call, so service clients
+// keep working after Reconnect replaces the connection
+type clientConn struct {
+	client *Client
+}
+
+func (cc clientConn) conn() (*grpc.ClientConn, error) {
+	conn := cc.client.Connection()
+	if conn == nil {
+		return nil, &ConnectionError{Err: errors.New("client is not connected")}
+	}
+	return conn, nil
+}
+
+func (cc clientConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	conn, err := cc.conn()
+	if err != nil {
+		return err
+	}
+	return conn.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (cc clientConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	conn, err := cc.conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.NewStream(ctx, desc, method, opts...)
+}
+
 // AgentClient returns the Agent service client
 func (c *Client) AgentClient() agentv1.AgentClient {
-	return agentv1.NewAgentClient(c.conn)
+	return agentv1.NewAgentClient(clientConn{client: c})
 }
 
 // BundleClient returns the Bundle service client
 func (c *Client) BundleClient() bundlev1.BundleClient {
-	return bundlev1.NewBundleClient(c.conn)
+	return bundlev1.NewBundleClient(clientConn{client: c})
 }
 
 // EntryClient returns the Entry service client
 func (c *Client) EntryClient() entryv1.EntryClient {
-	return entryv1.NewEntryClient(c.conn)
+	return entryv1.NewEntryClient(clientConn{client: c})
 }
 
 // SVIDClient returns the SVID service client
 func (c *Client) SVIDClient() svidv1.SVIDClient {
-	return svidv1.NewSVIDClient(c.conn)
+	return svidv1.NewSVIDClient(clientConn{client: c})
 }
 
 // TrustDomainClient returns the TrustDomain service client
 func (c *Client) TrustDomainClient() trustdomainv1.TrustDomainClient {
-	return trustdomainv1.NewTrustDomainClient(c.conn)
+	return trustdomainv1.NewTrustDomainClient(clientConn{client: c})
 }
@@ -0,0 +1,69 @@
+package spireclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+
+	svidv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/svid/v1"
+)
+
+// MintX509SVID mints an X509-SVID for spiffeID using a newly generated RSA key
+// ttl is in seconds; zero uses the server default
+func (c *Client) MintX509SVID(ctx context.Context, spiffeID string, ttl int32) (*tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	return c.MintX509SVIDWithKey(ctx, spiffeID, ttl, key)
+}
+
+// MintX509SVIDWithKey mints an X509-SVID for spiffeID bound to the caller's key
+// ttl is in seconds; zero uses the server default
+func (c *Client) MintX509SVIDWithKey(ctx context.Context, spiffeID string, ttl int32, key crypto.Signer) (*tls.Certificate, error) {
+	if key == nil {
+		return nil, fmt.Errorf("key is required")
+	}
+
+	uri, err := url.Parse(spiffeID)
+	if err != nil || !isValidSPIFFEID(uri) {
+		return nil, fmt.Errorf("invalid SPIFFE ID %q", spiffeID)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		URIs: []*url.URL{uri},
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+
+	resp, err := c.SVIDClient().MintX509SVID(ctx, &svidv1.MintX509SVIDRequest{
+		Csr: csr,
+		Ttl: ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint X509-SVID: %w", err)
+	}
+
+	certChain := resp.GetSvid().GetCertChain()
+	if len(certChain) == 0 {
+		return nil, fmt.Errorf("X509-SVID response has no certificates")
+	}
+
+	leaf, err := x509.ParseCertificate(certChain[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse X509-SVID: %w", err)
+	}
+
+	return &tls.Certificate{
+		Certificate: certChain,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
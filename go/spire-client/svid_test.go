@@ -0,0 +1,112 @@
+package spireclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	svidv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/svid/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSVIDServer signs X509-SVID CSRs with the CA fixture
+type fakeSVIDServer struct {
+	svidv1.UnimplementedSVIDServer
+	ttl int32
+}
+
+func (s *fakeSVIDServer) MintX509SVID(ctx context.Context, req *svidv1.MintX509SVIDRequest) (*svidv1.MintX509SVIDResponse, error) {
+	csr, err := x509.ParseCertificateRequest(req.Csr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.ttl = req.Ttl
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Duration(req.Ttl) * time.Second),
+		URIs:         csr.URIs,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, testCACert, csr.PublicKey, testCAKey)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &svidv1.MintX509SVIDResponse{
+		Svid: &types.X509SVID{
+			CertChain: [][]byte{certBytes},
+			ExpiresAt: template.NotAfter.Unix(),
+		},
+	}, nil
+}
+
+// newSVIDTestClient returns a client connected to a fake SVID service
+func newSVIDTestClient(t *testing.T, server *fakeSVIDServer) *Client {
+	t.Helper()
+	return newTestClient(t, func(s *grpc.Server) {
+		svidv1.RegisterSVIDServer(s, server)
+	})
+}
+
+func TestClient_MintX509SVID(t *testing.T) {
+	t.Run("returns usable certificate", func(t *testing.T) {
+		server := &fakeSVIDServer{}
+		client := newSVIDTestClient(t, server)
+
+		cert, err := client.MintX509SVID(context.Background(), "spiffe://example.org/workload", 600)
+		require.NoError(t, err)
+		require.NotNil(t, cert.Leaf)
+		assert.Equal(t, "spiffe://example.org/workload", cert.Leaf.URIs[0].String())
+		assert.Len(t, cert.Certificate, 1)
+		assert.NotNil(t, cert.PrivateKey)
+		assert.Equal(t, int32(600), server.ttl)
+
+		// The leaf chains to the CA fixture
+		config, err := NewTLSConfig(WithRootCAsFromFile(testCAFile))
+		require.NoError(t, err)
+		assert.NoError(t, config.VerifyPeerCertificate(cert.Certificate, nil))
+	})
+
+	t.Run("invalid SPIFFE ID", func(t *testing.T) {
+		client := newSVIDTestClient(t, &fakeSVIDServer{})
+
+		cert, err := client.MintX509SVID(context.Background(), "https://example.org/workload", 600)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid SPIFFE ID")
+		assert.Nil(t, cert)
+	})
+}
+
+func TestClient_MintX509SVIDWithKey(t *testing.T) {
+	t.Run("uses caller key", func(t *testing.T) {
+		client := newSVIDTestClient(t, &fakeSVIDServer{})
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		cert, err := client.MintX509SVIDWithKey(context.Background(), "spiffe://example.org/workload", 600, key)
+		require.NoError(t, err)
+		assert.Same(t, key, cert.PrivateKey)
+		assert.True(t, key.PublicKey.Equal(cert.Leaf.PublicKey))
+	})
+
+	t.Run("nil key", func(t *testing.T) {
+		client := newSVIDTestClient(t, &fakeSVIDServer{})
+
+		cert, err := client.MintX509SVIDWithKey(context.Background(), "spiffe://example.org/workload", 600, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key is required")
+		assert.Nil(t, cert)
+	})
+}
@@ -0,0 +1,152 @@
+package spireclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// X509SVIDFileWatcher reloads an X509-SVID whenever its certificate or key file is rewritten
+// This serves deployments without the Workload API, where renewed SVIDs are written to a known path
+type X509SVIDFileWatcher struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.RWMutex
+	svid      *x509svid.SVID
+	callbacks []func(*x509svid.SVID)
+	started   bool
+}
+
+// NewX509SVIDFileWatcher loads the X509-SVID from certFile and keyFile and returns a watcher for them
+// Call Start to begin watching for rotation
+func NewX509SVIDFileWatcher(certFile, keyFile string) (*X509SVIDFileWatcher, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, &ValidationError{Err: errors.New("both certFile and keyFile are required")}
+	}
+
+	svid, err := x509svid.Load(certFile, keyFile)
+	if err != nil {
+		return nil, &TLSConfigError{Err: fmt.Errorf("failed to load X.509 SVID: %w", err)}
+	}
+
+	return &X509SVIDFileWatcher{
+		certFile: filepath.Clean(certFile),
+		keyFile:  filepath.Clean(keyFile),
+		svid:     svid,
+	}, nil
+}
+
+// SVID returns the most recently loaded X509-SVID
+func (w *X509SVIDFileWatcher) SVID() *x509svid.SVID {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.svid
+}
+
+// OnRotation registers fn to be called with the new SVID each time a different certificate is loaded
+// Callbacks run on the watcher goroutine in registration order
+func (w *X509SVIDFileWatcher) OnRotation(fn func(*x509svid.SVID)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Start watches the certificate and key files in the background until ctx is done
+// The watch is established before Start returns, so later writes are not missed
+// The parent directories are watched so that files replaced by rename are still observed
+func (w *X509SVIDFileWatcher) Start(ctx context.Context) error {
+	w.mu.Lock()
+	if w.started {
+		w.mu.Unlock()
+		return errors.New("watcher already started")
+	}
+	w.started = true
+	w.mu.Unlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	for _, dir := range uniqueDirs(w.certFile, w.keyFile) {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	go w.watch(ctx, watcher)
+
+	return nil
+}
+
+// watch reloads the SVID on relevant file events until ctx is done
+func (w *X509SVIDFileWatcher) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Name != w.certFile && event.Name != w.keyFile {
+				continue
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			// The certificate and key are written separately, so loading fails until both files
+			// match; the write of the second file triggers another attempt
+			w.reload()
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// reload loads the SVID from disk and notifies the callbacks if the certificate changed
+func (w *X509SVIDFileWatcher) reload() {
+	svid, err := x509svid.Load(w.certFile, w.keyFile)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.svid != nil && w.svid.Certificates[0].Equal(svid.Certificates[0]) {
+		w.mu.Unlock()
+		return
+	}
+	w.svid = svid
+	callbacks := append([]func(*x509svid.SVID){}, w.callbacks...)
+	w.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(svid)
+	}
+}
+
+// uniqueDirs returns the distinct parent directories of paths
+func uniqueDirs(paths ...string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,146 @@
+package spireclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWatchedID = "spiffe://example.org/watched"
+
+// startTestFileWatcher writes an initial SVID, starts a watcher on it and returns the watcher,
+// the file paths and a channel receiving rotated SVIDs
+func startTestFileWatcher(t *testing.T) (*X509SVIDFileWatcher, string, string, chan *x509svid.SVID) {
+	t.Helper()
+
+	certFile, keyFile, _ := writeTestSVIDFiles(t, newTestSVID(t, testWatchedID))
+	watcher, err := NewX509SVIDFileWatcher(certFile, keyFile)
+	require.NoError(t, err)
+
+	rotated := make(chan *x509svid.SVID, 10)
+	watcher.OnRotation(func(svid *x509svid.SVID) { rotated <- svid })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, watcher.Start(ctx))
+
+	return watcher, certFile, keyFile, rotated
+}
+
+// writeSVIDFiles writes svid over certFile and keyFile, key first
+func writeSVIDFiles(t *testing.T, svid *x509svid.SVID, certFile, keyFile string) {
+	t.Helper()
+
+	certPEM, keyPEM, err := svid.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0644))
+}
+
+// waitForRotation returns the next rotated SVID, failing if none arrives within 2 seconds
+func waitForRotation(t *testing.T, rotated chan *x509svid.SVID) *x509svid.SVID {
+	t.Helper()
+
+	select {
+	case svid := <-rotated:
+		return svid
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRotation was not called within 2s")
+		return nil
+	}
+}
+
+func TestNewX509SVIDFileWatcher(t *testing.T) {
+	certFile, keyFile, _ := writeTestSVIDFiles(t, newTestSVID(t, testWatchedID))
+
+	t.Run("loads initial SVID", func(t *testing.T) {
+		watcher, err := NewX509SVIDFileWatcher(certFile, keyFile)
+		require.NoError(t, err)
+		assert.Equal(t, testWatchedID, watcher.SVID().ID.String())
+	})
+
+	t.Run("missing file names", func(t *testing.T) {
+		_, err := NewX509SVIDFileWatcher(certFile, "")
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("unreadable SVID", func(t *testing.T) {
+		_, err := NewX509SVIDFileWatcher(filepath.Join(t.TempDir(), "missing.crt"), keyFile)
+		var tlsErr *TLSConfigError
+		assert.ErrorAs(t, err, &tlsErr)
+	})
+}
+
+func TestX509SVIDFileWatcher_Rotation(t *testing.T) {
+	t.Run("overwritten files", func(t *testing.T) {
+		watcher, certFile, keyFile, rotated := startTestFileWatcher(t)
+		initial := watcher.SVID()
+
+		next := newTestSVID(t, testWatchedID)
+		writeSVIDFiles(t, next, certFile, keyFile)
+
+		svid := waitForRotation(t, rotated)
+		assert.True(t, svid.Certificates[0].Equal(next.Certificates[0]))
+		assert.False(t, svid.Certificates[0].Equal(initial.Certificates[0]))
+		assert.Same(t, svid, watcher.SVID())
+	})
+
+	t.Run("files replaced by rename", func(t *testing.T) {
+		watcher, certFile, keyFile, rotated := startTestFileWatcher(t)
+
+		next := newTestSVID(t, testWatchedID)
+		certPEM, keyPEM, err := next.Marshal()
+		require.NoError(t, err)
+		dir := filepath.Dir(certFile)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "svid.key.tmp"), keyPEM, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "svid.crt.tmp"), certPEM, 0644))
+		require.NoError(t, os.Rename(filepath.Join(dir, "svid.key.tmp"), keyFile))
+		require.NoError(t, os.Rename(filepath.Join(dir, "svid.crt.tmp"), certFile))
+
+		svid := waitForRotation(t, rotated)
+		assert.True(t, svid.Certificates[0].Equal(next.Certificates[0]))
+		assert.Same(t, svid, watcher.SVID())
+	})
+
+	t.Run("unchanged certificate is not reported", func(t *testing.T) {
+		watcher, certFile, keyFile, rotated := startTestFileWatcher(t)
+
+		writeSVIDFiles(t, watcher.SVID(), certFile, keyFile)
+
+		select {
+		case <-rotated:
+			t.Fatal("OnRotation called for an unchanged certificate")
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestSVIDFiles(t, newTestSVID(t, testWatchedID))
+		watcher, err := NewX509SVIDFileWatcher(certFile, keyFile)
+		require.NoError(t, err)
+		rotated := make(chan *x509svid.SVID, 10)
+		watcher.OnRotation(func(svid *x509svid.SVID) { rotated <- svid })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		require.NoError(t, watcher.Start(ctx))
+		assert.Error(t, watcher.Start(ctx), "Start should fail when already started")
+		cancel()
+		// Give the watcher goroutine time to observe the cancellation
+		time.Sleep(100 * time.Millisecond)
+
+		writeSVIDFiles(t, newTestSVID(t, testWatchedID), certFile, keyFile)
+
+		select {
+		case <-rotated:
+			t.Fatal("OnRotation called after the context was cancelled")
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+}
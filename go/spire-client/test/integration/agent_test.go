@@ -159,18 +159,9 @@ func TestAgentAPI_AttestAgent(t *testing.T) {
 				Leaf:        agentCert,
 			}
 			
-			// Get SPIRE Server bundle for server verification
-			bundleClient := client.BundleClient()
-			bundleResp, err := bundleClient.GetBundle(ctx, &bundlev1.GetBundleRequest{})
-			require.NoError(t, err, "Failed to get bundle")
-			
-			// Create root CA pool from SPIRE bundle
-			rootCAs := x509.NewCertPool()
-			for _, caCert := range bundleResp.X509Authorities {
-				cert, err := x509.ParseCertificate(caCert.Asn1)
-				require.NoError(t, err, "Failed to parse CA certificate")
-				rootCAs.AddCert(cert)
-			}
+			// Create root CA pool from SPIRE bundle for server verification
+			rootCAs, err := client.BuildCertPool(ctx)
+			require.NoError(t, err, "Failed to build root CA pool from bundle")
 			
 			// Create mTLS client configuration
 			mtlsConfig := &spireclient.Config{
@@ -179,20 +170,8 @@ func TestAgentAPI_AttestAgent(t *testing.T) {
 					Certificates: []tls.Certificate{tlsCert},
 					RootCAs:      rootCAs,
 					// Use InsecureSkipVerify for testing since server cert may not have proper SAN
+					// The server SPIFFE ID is checked with VerifyMTLSHandshake below
 					InsecureSkipVerify: true,
-					// Custom verification function to validate SPIFFE ID
-					VerifyConnection: func(cs tls.ConnectionState) error {
-						// In production, you would verify the SPIFFE ID in the server certificate
-						// For now, just log the server certificate info
-						if len(cs.PeerCertificates) > 0 {
-							serverCert := cs.PeerCertificates[0]
-							t.Logf("Server certificate subject: %s", serverCert.Subject.String())
-							if len(serverCert.URIs) > 0 {
-								t.Logf("Server SPIFFE ID: %s", serverCert.URIs[0].String())
-							}
-						}
-						return nil
-					},
 				},
 			}
 			
@@ -210,6 +189,13 @@ func TestAgentAPI_AttestAgent(t *testing.T) {
 			t.Logf("mTLS connection successful!")
 			t.Logf("Retrieved bundle via mTLS: trust domain = %s", mtlsBundleResp.TrustDomain)
 			t.Logf("Bundle has %d X.509 authorities", len(mtlsBundleResp.X509Authorities))
+
+			// gRPC does not expose its TLS connection, so dial directly with the same configuration
+			// to assert which certificate the server presented
+			tlsConn, err := tls.Dial("tcp", mtlsConfig.Address, mtlsConfig.TLSConfig.Clone())
+			require.NoError(t, err, "Failed to dial SPIRE Server with the agent SVID")
+			defer tlsConn.Close()
+			require.NoError(t, VerifyMTLSHandshake(tlsConn, "", "spiffe://example.org/spire/server"), "Unexpected server SPIFFE ID")
 		})
 
 		// Close the stream
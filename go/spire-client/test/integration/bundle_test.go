@@ -2,12 +2,22 @@ package integration
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
+	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+	"github.com/hiyosi/sandbox/go/spire-client/test/mock"
 	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // TestBundleAPI_GetBundle tests retrieving trust bundle from SPIRE Server
@@ -38,4 +48,153 @@ func TestBundleAPI_GetBundle(t *testing.T) {
 		t.Logf("Bundle has %d X.509 authorities", len(resp.X509Authorities))
 	})
 
-}
\ No newline at end of file
+}
+
+// TestBundleAPI_MockServer tests the Bundle API against an in-process mock SPIRE Server
+func TestBundleAPI_MockServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := mock.NewMockSPIREServer(t)
+	client := CreateMockTestClient(t, server)
+	bundleClient := client.BundleClient()
+
+	t.Run("GetBundle without bundle", func(t *testing.T) {
+		_, err := bundleClient.GetBundle(ctx, &bundlev1.GetBundleRequest{})
+		require.Error(t, err)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	server.AddBundle(&types.Bundle{
+		TrustDomain:     "example.org",
+		X509Authorities: []*types.X509Certificate{{Asn1: []byte("ca")}},
+		SequenceNumber:  1,
+	})
+	server.AddBundle(&types.Bundle{TrustDomain: "partner.org"})
+
+	t.Run("GetBundle", func(t *testing.T) {
+		resp, err := bundleClient.GetBundle(ctx, &bundlev1.GetBundleRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "example.org", resp.TrustDomain)
+		assert.Len(t, resp.X509Authorities, 1)
+		assert.Equal(t, uint64(1), resp.SequenceNumber)
+	})
+
+	t.Run("ListFederatedBundles", func(t *testing.T) {
+		resp, err := bundleClient.ListFederatedBundles(ctx, &bundlev1.ListFederatedBundlesRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Bundles, 1)
+		assert.Equal(t, "partner.org", resp.Bundles[0].TrustDomain)
+	})
+
+	t.Run("GetFederatedBundle", func(t *testing.T) {
+		resp, err := bundleClient.GetFederatedBundle(ctx, &bundlev1.GetFederatedBundleRequest{TrustDomain: "partner.org"})
+		require.NoError(t, err)
+		assert.Equal(t, "partner.org", resp.TrustDomain)
+
+		_, err = bundleClient.GetFederatedBundle(ctx, &bundlev1.GetFederatedBundleRequest{TrustDomain: "unknown.org"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+// interopCertScript is the certificate generator used by the Rust interop tests
+const interopCertScript = "../../../../rust/spiffe-client/interop-tests/generate_spiffe_certs.go"
+
+// generateInteropCerts runs generate_spiffe_certs.go to create a new CA hierarchy in a temporary directory
+func generateInteropCerts(t *testing.T) string {
+	t.Helper()
+
+	script, err := filepath.Abs(interopCertScript)
+	require.NoError(t, err)
+	dir := t.TempDir()
+
+	// The script has no module of its own, so it is run from its directory
+	cmd := exec.Command("go", "run", filepath.Base(script), "-cert-dir", dir, "-key-type", "ecdsa-p256")
+	cmd.Dir = filepath.Dir(script)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generate_spiffe_certs.go failed:\n%s", out)
+
+	return dir
+}
+
+// caBundle returns the CA certificate in dir as a SPIRE trust bundle
+func caBundle(t *testing.T, dir string) *types.Bundle {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block, "ca.crt has no PEM block")
+
+	return &types.Bundle{
+		TrustDomain:     "example.org",
+		X509Authorities: []*types.X509Certificate{{Asn1: block.Bytes}},
+	}
+}
+
+// startGoServer accepts TLS connections with the go-server certificate in dir until the test ends
+func startGoServer(t *testing.T, dir string) string {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "go-server.crt"), filepath.Join(dir, "go-server.key"))
+	require.NoError(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// TestBundleRotation checks that a BundleRefresher follows a CA rotation on SPIRE Server
+func TestBundleRotation(t *testing.T) {
+	SkipIfNotIntegration(t)
+
+	ca1Dir, ca2Dir := generateInteropCerts(t), generateInteropCerts(t)
+	ca1Server, ca2Server := startGoServer(t, ca1Dir), startGoServer(t, ca2Dir)
+
+	server := mock.NewMockSPIREServer(t)
+	server.SetBundle(caBundle(t, ca1Dir))
+	client := CreateMockTestClient(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	refresher, err := spireclient.NewBundleRefresher(client, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, refresher.Start(ctx))
+
+	dial := func(address string) error {
+		conn, err := tls.Dial("tcp", address, refresher.TLSConfig())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return VerifyMTLSHandshake(conn, "", "spiffe://example.org/go-server")
+	}
+
+	require.NoError(t, dial(ca1Server), "connection with CA1 certificates before rotation")
+	require.Error(t, dial(ca2Server), "CA2 must not be trusted before rotation")
+
+	// Rotate the CA on the server and wait for the refresher to fetch it
+	before := refresher.RootCAs()
+	server.SetBundle(caBundle(t, ca2Dir))
+	require.Eventually(t, func() bool {
+		return !refresher.RootCAs().Equal(before)
+	}, 10*time.Second, 50*time.Millisecond, "refresher did not pick up the rotated bundle")
+
+	assert.NoError(t, dial(ca2Server), "connection with CA2 certificates after rotation")
+	assert.Error(t, dial(ca1Server), "CA1 must not be trusted after rotation")
+}
@@ -0,0 +1,103 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+	entryv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestEntryAPI_Lifecycle tests creating, reading, listing, updating and deleting a registration entry
+func TestEntryAPI_Lifecycle(t *testing.T) {
+	SkipIfNotIntegration(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := CreateTestClient(t)
+	t.Cleanup(func() { client.Close() })
+	entryClient := client.EntryClient()
+	require.NotNil(t, entryClient, "Entry client should not be nil")
+
+	// A unique SPIFFE ID keeps reruns and concurrent runs from seeing each other's entries
+	path := fmt.Sprintf("/integration/entry-%d", time.Now().UnixNano())
+	spiffeID := "spiffe://example.org" + path
+	filter := &entryv1.ListEntriesRequest_Filter{
+		BySpiffeId: &types.SPIFFEID{TrustDomain: "example.org", Path: path},
+	}
+
+	created, err := spireclient.NewEntryBuilder().
+		WithSpiffeID(spiffeID).
+		WithParentID("spiffe://example.org/integration/parent").
+		WithSelector("unix", "uid:1000").
+		WithTTL(3600).
+		Create(ctx, entryClient)
+	require.NoError(t, err, "Failed to create entry")
+	require.NotEmpty(t, created.Id, "Created entry should have an ID")
+	t.Logf("Created entry %s for %s", created.Id, spiffeID)
+
+	// Delete the entry even if an assertion below fails; cleanups run in reverse, so before the client is closed
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := client.DeleteEntry(ctx, created.Id); err != nil && status.Code(err) != codes.NotFound {
+			t.Logf("Cleanup of entry %s: %v", created.Id, err)
+		}
+	})
+
+	t.Run("GetEntry", func(t *testing.T) {
+		entry, err := entryClient.GetEntry(ctx, &entryv1.GetEntryRequest{Id: created.Id})
+		require.NoError(t, err, "Failed to get entry")
+		assert.Equal(t, path, entry.SpiffeId.Path)
+		assert.Equal(t, int32(3600), entry.X509SvidTtl)
+	})
+
+	t.Run("ListEntries", func(t *testing.T) {
+		entries, err := client.ListAllEntries(ctx, filter)
+		require.NoError(t, err, "Failed to list entries")
+		require.Len(t, entries, 1, "Created entry should be listed")
+		assert.Equal(t, created.Id, entries[0].Id)
+	})
+
+	t.Run("BatchUpdateEntry", func(t *testing.T) {
+		// The Entry API has no PatchEntry; BatchUpdateEntry with an input mask updates only the masked fields
+		resp, err := entryClient.BatchUpdateEntry(ctx, &entryv1.BatchUpdateEntryRequest{
+			Entries: []*types.Entry{{
+				Id:          created.Id,
+				X509SvidTtl: 7200,
+				DnsNames:    []string{"entry.example.org"},
+			}},
+			InputMask: &types.EntryMask{X509SvidTtl: true, DnsNames: true},
+		})
+		require.NoError(t, err, "Failed to update entry")
+		require.Len(t, resp.Results, 1)
+		require.Equal(t, int32(codes.OK), resp.Results[0].GetStatus().GetCode(), resp.Results[0].GetStatus().GetMessage())
+
+		entry, err := entryClient.GetEntry(ctx, &entryv1.GetEntryRequest{Id: created.Id})
+		require.NoError(t, err, "Failed to get updated entry")
+		assert.Equal(t, int32(7200), entry.X509SvidTtl)
+		assert.Equal(t, []string{"entry.example.org"}, entry.DnsNames)
+		assert.Equal(t, path, entry.SpiffeId.Path, "Unmasked fields should be unchanged")
+	})
+
+	t.Run("BatchDeleteEntry", func(t *testing.T) {
+		resp, err := entryClient.BatchDeleteEntry(ctx, &entryv1.BatchDeleteEntryRequest{Ids: []string{created.Id}})
+		require.NoError(t, err, "Failed to delete entry")
+		require.Len(t, resp.Results, 1)
+		assert.Equal(t, int32(codes.OK), resp.Results[0].GetStatus().GetCode(), resp.Results[0].GetStatus().GetMessage())
+
+		// No orphan entries for the test SPIFFE ID remain
+		entries, err := client.ListAllEntries(ctx, filter)
+		require.NoError(t, err, "Failed to list entries")
+		assert.Empty(t, entries, "Deleted entry should not be listed")
+	})
+}
@@ -3,18 +3,102 @@ package integration
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	spireclient "github.com/hiyosi/sandbox/go/spire-client"
+	"github.com/hiyosi/sandbox/go/spire-client/test/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
+// readyPollInterval is the interval between health checks in WaitForReady
+const readyPollInterval = 100 * time.Millisecond
+
+// WaitForReady polls the gRPC health service at address until it reports SERVING or ctx is done
+// Servers that do not implement the health service are treated as ready once they answer
+func WaitForReady(ctx context.Context, address string) error {
+	// The probe only checks readiness, so the server certificate is not verified
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	healthClient := healthpb.NewHealthClient(conn)
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+			return nil
+		case err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING:
+			return nil
+		case err == nil:
+			err = fmt.Errorf("health status is %s", resp.GetStatus())
+		}
+		// Keep the last answer from the server rather than the deadline error of an interrupted check
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server %s is not ready: %w", address, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// VerifyMTLSHandshake checks that the TLS handshake on conn completed and the peer presented the expected SPIFFE ID
+// conn only exposes its peer's certificate, so pass expectedServerSPIFFEID for a client-side connection
+// and expectedClientSPIFFEID for a server-side connection, leaving the other empty
+func VerifyMTLSHandshake(conn *tls.Conn, expectedClientSPIFFEID, expectedServerSPIFFEID string) error {
+	var peer, expected string
+	switch {
+	case expectedClientSPIFFEID != "" && expectedServerSPIFFEID != "":
+		return errors.New("only the peer of conn can be verified; set either the expected client or server SPIFFE ID")
+	case expectedServerSPIFFEID != "":
+		peer, expected = "server", expectedServerSPIFFEID
+	case expectedClientSPIFFEID != "":
+		peer, expected = "client", expectedClientSPIFFEID
+	default:
+		return errors.New("an expected client or server SPIFFE ID is required")
+	}
+
+	state := conn.ConnectionState()
+	if !state.HandshakeComplete {
+		return errors.New("TLS handshake has not completed")
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("%s presented no certificate", peer)
+	}
+
+	ids := spireclient.ExtractSPIFFEIDs(state.PeerCertificates[0])
+	if len(ids) != 1 {
+		return fmt.Errorf("%s certificate has %d SPIFFE IDs, expected exactly one", peer, len(ids))
+	}
+	if ids[0] != expected {
+		return fmt.Errorf("%s SPIFFE ID is %s, expected %s", peer, ids[0], expected)
+	}
+
+	return nil
+}
+
 // CreateTestClient creates a SPIRE client for integration testing
 func CreateTestClient(t *testing.T) *spireclient.Client {
 	t.Helper()
 
-	// Create client with insecure TLS for testing
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -29,6 +113,11 @@ func CreateTestClient(t *testing.T) *spireclient.Client {
 		address = "localhost:8081"
 	}
 
+	if err := WaitForReady(ctx, address); err != nil {
+		t.Fatalf("SPIRE Server is not ready: %v", err)
+	}
+
+	// Create client with insecure TLS for testing
 	config := &spireclient.Config{
 		Address:   address,
 		TLSConfig: tlsConfig,
@@ -42,11 +131,32 @@ func CreateTestClient(t *testing.T) *spireclient.Client {
 	return client
 }
 
+// CreateMockTestClient creates a SPIRE client connected to an in-process mock SPIRE Server
+func CreateMockTestClient(t *testing.T, server *mock.MockSPIREServer) *spireclient.Client {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := WaitForReady(ctx, server.Addr()); err != nil {
+		t.Fatalf("Mock SPIRE Server is not ready: %v", err)
+	}
+
+	// The mock server presents a SPIFFE certificate, so the default TLS configuration is sufficient
+	client, err := spireclient.New(ctx, server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to create SPIRE client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
 // SkipIfNotIntegration skips the test if integration tests are not enabled
 func SkipIfNotIntegration(t *testing.T) {
 	t.Helper()
-	
+
 	if os.Getenv("INTEGRATION_TEST") != "true" {
 		t.Skip("Skipping integration test. Set INTEGRATION_TEST=true to run.")
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,162 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hiyosi/sandbox/go/spire-client/test/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForReady(t *testing.T) {
+	t.Run("serving", func(t *testing.T) {
+		server := mock.NewMockSPIREServer(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		assert.NoError(t, WaitForReady(ctx, server.Addr()))
+	})
+
+	t.Run("becomes serving", func(t *testing.T) {
+		server := mock.NewMockSPIREServer(t)
+		server.SetServing(false)
+		time.AfterFunc(300*time.Millisecond, func() { server.SetServing(true) })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		require.NoError(t, WaitForReady(ctx, server.Addr()))
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("not serving", func(t *testing.T) {
+		server := mock.NewMockSPIREServer(t)
+		server.SetServing(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		err := WaitForReady(ctx, server.Addr())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "NOT_SERVING")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		err := WaitForReady(ctx, "localhost:1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not ready")
+	})
+}
+
+// newTestSPIFFECertificate returns a self-signed certificate carrying the given SPIFFE IDs
+func newTestSPIFFECertificate(t *testing.T, spiffeIDs ...string) tls.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	for _, id := range spiffeIDs {
+		uri, err := url.Parse(id)
+		require.NoError(t, err)
+		template.URIs = append(template.URIs, uri)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// mtlsPipe completes an mTLS handshake over net.Pipe and returns the client and server connections
+func mtlsPipe(t *testing.T, clientCert, serverCert tls.Certificate) (*tls.Conn, *tls.Conn) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	client := tls.Client(clientConn, &tls.Config{Certificates: []tls.Certificate{clientCert}, InsecureSkipVerify: true})
+	server := tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientAuth: tls.RequireAnyClientCert})
+	// Closing the pipe directly avoids blocking on close_notify, which the peer never reads
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.Handshake() }()
+	require.NoError(t, client.Handshake())
+	require.NoError(t, <-errs)
+
+	return client, server
+}
+
+func TestVerifyMTLSHandshake(t *testing.T) {
+	const (
+		clientID = "spiffe://example.org/agent"
+		serverID = "spiffe://example.org/spire/server"
+	)
+	client, server := mtlsPipe(t, newTestSPIFFECertificate(t, clientID), newTestSPIFFECertificate(t, serverID))
+
+	t.Run("client perspective", func(t *testing.T) {
+		assert.NoError(t, VerifyMTLSHandshake(client, "", serverID))
+	})
+
+	t.Run("server perspective", func(t *testing.T) {
+		assert.NoError(t, VerifyMTLSHandshake(server, clientID, ""))
+	})
+
+	t.Run("unexpected server SPIFFE ID", func(t *testing.T) {
+		err := VerifyMTLSHandshake(client, "", "spiffe://example.org/other")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server SPIFFE ID is "+serverID)
+	})
+
+	t.Run("unexpected client SPIFFE ID", func(t *testing.T) {
+		err := VerifyMTLSHandshake(server, "spiffe://example.org/other", "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "client SPIFFE ID is "+clientID)
+	})
+
+	t.Run("expected IDs for both ends", func(t *testing.T) {
+		assert.Error(t, VerifyMTLSHandshake(client, clientID, serverID))
+	})
+
+	t.Run("no expected ID", func(t *testing.T) {
+		assert.Error(t, VerifyMTLSHandshake(client, "", ""))
+	})
+
+	t.Run("certificate without SPIFFE ID", func(t *testing.T) {
+		client, _ := mtlsPipe(t, newTestSPIFFECertificate(t, clientID), newTestSPIFFECertificate(t))
+
+		err := VerifyMTLSHandshake(client, "", serverID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has 0 SPIFFE IDs")
+	})
+
+	t.Run("handshake not completed", func(t *testing.T) {
+		conn, _ := net.Pipe()
+		defer conn.Close()
+
+		err := VerifyMTLSHandshake(tls.Client(conn, &tls.Config{}), "", serverID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "has not completed")
+	})
+}
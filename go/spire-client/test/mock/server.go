@@ -0,0 +1,313 @@
+// Package mock provides an in-process SPIRE Server for tests that need deterministic API data
+package mock
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	agentv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/agent/v1"
+	bundlev1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/bundle/v1"
+	entryv1 "github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1"
+	"github.com/spiffe/spire-api-sdk/proto/spire/api/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ServerSPIFFEID is the SPIFFE ID presented by the mock server certificate
+const ServerSPIFFEID = "spiffe://example.org/spire/server"
+
+// MockSPIREServer is an in-process gRPC server implementing the Bundle, Agent and Entry APIs
+// It also serves the gRPC health checking protocol, reporting SERVING by default
+type MockSPIREServer struct {
+	bundlev1.UnimplementedBundleServer
+	agentv1.UnimplementedAgentServer
+	entryv1.UnimplementedEntryServer
+
+	addr   string
+	health *health.Server
+
+	mu      sync.RWMutex
+	bundles []*types.Bundle
+	agents  []*types.Agent
+	entries []*types.Entry
+}
+
+// NewMockSPIREServer starts a mock SPIRE Server over TLS and stops it when the test finishes
+// The server certificate carries ServerSPIFFEID so clients using the default TLS configuration can connect
+func NewMockSPIREServer(t *testing.T) *MockSPIREServer {
+	t.Helper()
+
+	cert, err := newServerCertificate()
+	if err != nil {
+		t.Fatalf("Failed to create mock server certificate: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	m := &MockSPIREServer{addr: listener.Addr().String(), health: health.NewServer()}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})))
+	bundlev1.RegisterBundleServer(server, m)
+	agentv1.RegisterAgentServer(server, m)
+	entryv1.RegisterEntryServer(server, m)
+	healthpb.RegisterHealthServer(server, m.health)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return m
+}
+
+// Addr returns the address the mock server listens on
+func (m *MockSPIREServer) Addr() string {
+	return m.addr
+}
+
+// SetServing sets the status reported by the health service
+func (m *MockSPIREServer) SetServing(serving bool) {
+	servingStatus := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		servingStatus = healthpb.HealthCheckResponse_SERVING
+	}
+	m.health.SetServingStatus("", servingStatus)
+}
+
+// AddBundle adds a bundle; the first bundle is returned by GetBundle and the rest as federated bundles
+func (m *MockSPIREServer) AddBundle(b *types.Bundle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bundles = append(m.bundles, b)
+}
+
+// SetBundle replaces the bundle returned by GetBundle, keeping any federated bundles
+func (m *MockSPIREServer) SetBundle(b *types.Bundle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.bundles) == 0 {
+		m.bundles = append(m.bundles, b)
+		return
+	}
+	m.bundles[0] = b
+}
+
+// AddAgent adds an agent returned by GetAgent and ListAgents
+func (m *MockSPIREServer) AddAgent(a *types.Agent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.agents = append(m.agents, a)
+}
+
+// AddEntry adds a registration entry returned by ListEntries
+func (m *MockSPIREServer) AddEntry(e *types.Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+}
+
+// Entries returns the registration entries that have not been deleted
+func (m *MockSPIREServer) Entries() []*types.Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*types.Entry{}, m.entries...)
+}
+
+// GetBundle returns the first bundle added to the server
+func (m *MockSPIREServer) GetBundle(ctx context.Context, req *bundlev1.GetBundleRequest) (*types.Bundle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.bundles) == 0 {
+		return nil, status.Error(codes.NotFound, "bundle not found")
+	}
+	return m.bundles[0], nil
+}
+
+// ListFederatedBundles returns all bundles except the first
+func (m *MockSPIREServer) ListFederatedBundles(ctx context.Context, req *bundlev1.ListFederatedBundlesRequest) (*bundlev1.ListFederatedBundlesResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	resp := &bundlev1.ListFederatedBundlesResponse{}
+	if len(m.bundles) > 1 {
+		resp.Bundles = append(resp.Bundles, m.bundles[1:]...)
+	}
+	return resp, nil
+}
+
+// GetFederatedBundle returns the federated bundle for the requested trust domain
+func (m *MockSPIREServer) GetFederatedBundle(ctx context.Context, req *bundlev1.GetFederatedBundleRequest) (*types.Bundle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i, b := range m.bundles {
+		if i > 0 && b.TrustDomain == req.TrustDomain {
+			return b, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "federated bundle for %s not found", req.TrustDomain)
+}
+
+// GetAgent returns the agent with the requested SPIFFE ID
+func (m *MockSPIREServer) GetAgent(ctx context.Context, req *agentv1.GetAgentRequest) (*types.Agent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, a := range m.agents {
+		if a.GetId().GetTrustDomain() == req.GetId().GetTrustDomain() && a.GetId().GetPath() == req.GetId().GetPath() {
+			return a, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "agent not found")
+}
+
+// ListAgents returns all agents in a single page; filters are not applied
+func (m *MockSPIREServer) ListAgents(ctx context.Context, req *agentv1.ListAgentsRequest) (*agentv1.ListAgentsResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &agentv1.ListAgentsResponse{Agents: append([]*types.Agent{}, m.agents...)}, nil
+}
+
+// CountAgents returns the number of agents added to the server
+func (m *MockSPIREServer) CountAgents(ctx context.Context, req *agentv1.CountAgentsRequest) (*agentv1.CountAgentsResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &agentv1.CountAgentsResponse{Count: int32(len(m.agents))}, nil
+}
+
+// BanAgent marks the agent with the requested SPIFFE ID as banned
+func (m *MockSPIREServer) BanAgent(ctx context.Context, req *agentv1.BanAgentRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range m.agents {
+		if a.GetId().GetTrustDomain() == req.GetId().GetTrustDomain() && a.GetId().GetPath() == req.GetId().GetPath() {
+			a.Banned = true
+			return &emptypb.Empty{}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "agent not found")
+}
+
+// ListEntries returns all entries in a single page, applying only the selector filter
+func (m *MockSPIREServer) ListEntries(ctx context.Context, req *entryv1.ListEntriesRequest) (*entryv1.ListEntriesResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	resp := &entryv1.ListEntriesResponse{}
+	for _, e := range m.entries {
+		if matchSelectors(e.Selectors, req.GetFilter().GetBySelectors()) {
+			resp.Entries = append(resp.Entries, e)
+		}
+	}
+	return resp, nil
+}
+
+// BatchDeleteEntry deletes the requested entries, reporting NotFound for unknown IDs
+func (m *MockSPIREServer) BatchDeleteEntry(ctx context.Context, req *entryv1.BatchDeleteEntryRequest) (*entryv1.BatchDeleteEntryResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &entryv1.BatchDeleteEntryResponse{}
+	for _, id := range req.Ids {
+		code, message := codes.NotFound, "entry not found"
+		for i, e := range m.entries {
+			if e.Id == id {
+				m.entries = append(m.entries[:i], m.entries[i+1:]...)
+				code, message = codes.OK, ""
+				break
+			}
+		}
+		resp.Results = append(resp.Results, &entryv1.BatchDeleteEntryResponse_Result{
+			Status: &types.Status{Code: int32(code), Message: message},
+			Id:     id,
+		})
+	}
+	return resp, nil
+}
+
+// matchSelectors reports whether selectors satisfy match; a nil match accepts everything
+// Only MATCH_ANY and MATCH_SUPERSET are distinguished, other behaviors are treated as MATCH_SUPERSET
+func matchSelectors(selectors []*types.Selector, match *types.SelectorMatch) bool {
+	if match == nil {
+		return true
+	}
+
+	has := func(want *types.Selector) bool {
+		for _, s := range selectors {
+			if s.Type == want.Type && s.Value == want.Value {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, want := range match.Selectors {
+		found := has(want)
+		if match.Match == types.SelectorMatch_MATCH_ANY && found {
+			return true
+		}
+		if match.Match != types.SelectorMatch_MATCH_ANY && !found {
+			return false
+		}
+	}
+	return match.Match != types.SelectorMatch_MATCH_ANY
+}
+
+// newServerCertificate creates a self-signed certificate with ServerSPIFFEID as its URI SAN
+func newServerCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	uri, err := url.Parse(ServerSPIFFEID)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: "mock SPIRE Server",
+		},
+		NotBefore:   time.Now().Add(-time.Minute),
+		NotAfter:    time.Now().Add(time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		URIs:        []*url.URL{uri},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{certBytes},
+		PrivateKey:  key,
+	}, nil
+}
@@ -1,10 +1,36 @@
 package spireclient
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/youmark/pkcs8"
+)
+
+// maxSPIFFEIDPathLength is the maximum length in bytes of a SPIFFE ID path
+const maxSPIFFEIDPathLength = 2048
+
+// Role selects which side of an mTLS connection a configuration is built for
+type Role int
+
+const (
+	// RoleClient builds a configuration for dialing mTLS servers
+	RoleClient Role = iota
+	// RoleServer builds a configuration for accepting mTLS clients
+	RoleServer
 )
 
 // TLSOption represents TLS configuration options
@@ -31,79 +57,688 @@ func WithClientCertificatesFromMemory(certPEM, keyPEM []byte) TLSOption {
 	}
 }
 
-// NewTLSConfig creates a new TLS configuration for SPIFFE-compliant server certificate validation
-// Supports both TLS and mTLS connections based on provided options
-func NewTLSConfig(opts ...TLSOption) (*tls.Config, error) {
-	config := &tls.Config{
-		// SPIFFE-compliant verification
-		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+// WithEncryptedClientCertificates configures client certificates for mTLS with a password-protected key
+// The key may be an encrypted PKCS#8 or legacy encrypted PEM block
+// If the key pair cannot be loaded, the handshake fails with the loading error
+func WithEncryptedClientCertificates(certFile, keyFile, password string) TLSOption {
+	return func(c *tls.Config) {
+		cert, err := loadEncryptedKeyPair(certFile, keyFile, password)
+		if err != nil {
+			c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return nil, err
+			}
+			return
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+}
+
+// loadEncryptedKeyPair reads a certificate and an encrypted private key and returns the decrypted key pair
+func loadEncryptedKeyPair(certFile, keyFile, password string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to read certificate file: %w", err)}
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to read key file: %w", err)}
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, &TLSConfigError{Err: errors.New("no PEM block found in key file")}
+	}
+
+	var keyDER []byte
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to decrypt private key: %w", err)}
+		}
+		keyDER, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to marshal private key: %w", err)}
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}
+	case x509.IsEncryptedPEMBlock(block):
+		// Legacy RFC 1423 encryption is insecure but still produced by some tools
+		keyDER, err = x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to decrypt private key: %w", err)}
+		}
+		block = &pem.Block{Type: block.Type, Bytes: keyDER}
+	default:
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("private key is not encrypted (PEM type %q)", block.Type)}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(block))
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to load key pair: %w", err)}
+	}
+
+	return cert, nil
+}
+
+// WithRootCAs configures a CA pool used to verify the server certificate chain
+// When set, standard TLS verification is enabled and the chain is also checked in VerifyPeerCertificate
+func WithRootCAs(pool *x509.CertPool) TLSOption {
+	return func(c *tls.Config) {
+		c.RootCAs = pool
+		c.InsecureSkipVerify = false
+	}
+}
+
+// WithRootCAsFromFile configures a CA pool loaded from a PEM file for server verification
+func WithRootCAsFromFile(caFile string) TLSOption {
+	return func(c *tls.Config) {
+		// An unreadable file yields an empty pool so that verification fails closed
+		pool := x509.NewCertPool()
+		if caPEM, err := os.ReadFile(caFile); err == nil {
+			pool.AppendCertsFromPEM(caPEM)
+		}
+		c.RootCAs = pool
+		c.InsecureSkipVerify = false
+	}
+}
+
+// WithTLS13Only restricts the connection to TLS 1.3
+// Go's TLS 1.3 cipher suites always apply, so it cannot be combined with WithCipherSuites
+func WithTLS13Only() TLSOption {
+	return func(c *tls.Config) {
+		c.MinVersion = tls.VersionTLS13
+		c.MaxVersion = tls.VersionTLS13
+	}
+}
+
+// WithCipherSuites configures the enabled TLS 1.2 cipher suites
+func WithCipherSuites(suites ...uint16) TLSOption {
+	return func(c *tls.Config) {
+		c.CipherSuites = suites
+	}
+}
+
+// WithSNIOverride sets the server name sent in the ClientHello instead of the host from the dial address
+// Useful behind a TCP proxy whose DNS name differs from the server's; gRPC also uses it as the authority
+func WithSNIOverride(serverName string) TLSOption {
+	return func(c *tls.Config) {
+		c.ServerName = serverName
+	}
+}
+
+// WithSessionTickets enables TLS session resumption using sessionCache, saving a full handshake on reconnects
+// crypto/tls skips VerifyPeerCertificate on resumed sessions, so it is run from VerifyConnection instead
+// and the cached server certificate is still checked for its SPIFFE ID
+func WithSessionTickets(sessionCache tls.ClientSessionCache) TLSOption {
+	return func(c *tls.Config) {
+		c.ClientSessionCache = sessionCache
+
+		next := c.VerifyConnection
+		c.VerifyConnection = func(cs tls.ConnectionState) error {
+			// Read VerifyPeerCertificate at handshake time so options applied later are included
+			if cs.DidResume && c.VerifyPeerCertificate != nil {
+				rawCerts := make([][]byte, len(cs.PeerCertificates))
+				for i, cert := range cs.PeerCertificates {
+					rawCerts[i] = cert.Raw
+				}
+				if err := c.VerifyPeerCertificate(rawCerts, cs.VerifiedChains); err != nil {
+					return err
+				}
+			}
+			if next != nil {
+				return next(cs)
+			}
+			return nil
+		}
+	}
+}
+
+// NewLRUSessionCache returns a client session cache holding up to capacity sessions for use with WithSessionTickets
+// A capacity below one uses the crypto/tls default
+func NewLRUSessionCache(capacity int) tls.ClientSessionCache {
+	return tls.NewLRUClientSessionCache(capacity)
+}
+
+// WithAuthorizedSPIFFEID restricts the server to the given SPIFFE ID
+func WithAuthorizedSPIFFEID(id string) TLSOption {
+	return WithAuthorizedSPIFFEIDs(id)
+}
+
+// WithAuthorizedSPIFFEIDs restricts the server to one of the given SPIFFE IDs
+func WithAuthorizedSPIFFEIDs(ids ...string) TLSOption {
+	return withPeerAuthorizer(fmt.Sprintf("one of %v", ids), func(uri *url.URL) bool {
+		for _, id := range ids {
+			if uri.String() == id {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithAuthorizedTrustDomain restricts the server to any SPIFFE ID within the given trust domain
+func WithAuthorizedTrustDomain(domain string) TLSOption {
+	return withPeerAuthorizer(fmt.Sprintf("member of trust domain %q", domain), func(uri *url.URL) bool {
+		return uri.Host == domain
+	})
+}
+
+// WithAuthorizedSPIFFEIDMatcher restricts the server to SPIFFE IDs accepted by m
+// A nil matcher accepts no SPIFFE ID
+func WithAuthorizedSPIFFEIDMatcher(m SPIFFEIDMatcher) TLSOption {
+	if m == nil {
+		return withPeerAuthorizer("no SPIFFE ID (nil matcher)", func(*url.URL) bool { return false })
+	}
+	return withPeerAuthorizer(m.String(), func(uri *url.URL) bool {
+		return m.Match(uri.String())
+	})
+}
+
+// WithCustomAuthorizer authorizes the server SPIFFE ID with a go-spiffe authorizer
+// verifiedChains is only populated when root CAs are configured
+func WithCustomAuthorizer(a tlsconfig.Authorizer) TLSOption {
+	return func(c *tls.Config) {
+		next := c.VerifyPeerCertificate
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if next != nil {
+				if err := next(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+			}
+
 			if len(rawCerts) == 0 {
-				return fmt.Errorf("no server certificate presented")
+				return &TLSConfigError{Err: errors.New("no server certificate presented")}
 			}
 
-			// Parse the server certificate
 			cert, err := x509.ParseCertificate(rawCerts[0])
 			if err != nil {
-				return fmt.Errorf("failed to parse server certificate: %w", err)
+				return &TLSConfigError{Err: fmt.Errorf("failed to parse server certificate: %w", err)}
 			}
 
-			// Check for SPIFFE ID in URI SANs
-			if len(cert.URIs) == 0 {
-				return fmt.Errorf("server certificate has no URI SANs (SPIFFE ID required)")
+			id, err := x509svid.IDFromCert(cert)
+			if err != nil {
+				return &SPIFFEIDError{Err: fmt.Errorf("failed to get server SPIFFE ID: %w", err)}
 			}
 
-			// Validate that at least one URI is a valid SPIFFE ID
-			hasValidSPIFFEID := false
-			for _, uri := range cert.URIs {
-				if isValidSPIFFEID(uri) {
-					hasValidSPIFFEID = true
-					break
+			if err := a(id, verifiedChains); err != nil {
+				return &SPIFFEIDError{Err: fmt.Errorf("server SPIFFE ID %s is not authorized: %w", id, err)}
+			}
+			return nil
+		}
+	}
+}
+
+// WithTrustDomainPin rejects servers presenting a SPIFFE ID outside the given trust domains
+// Every spiffe URI SAN must belong to one of domains, otherwise a *TrustDomainError is returned
+func WithTrustDomainPin(domains ...string) TLSOption {
+	return func(c *tls.Config) {
+		next := c.VerifyPeerCertificate
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if next != nil {
+				if err := next(rawCerts, verifiedChains); err != nil {
+					return err
 				}
 			}
 
-			if !hasValidSPIFFEID {
-				return fmt.Errorf("server certificate does not contain a valid SPIFFE ID")
+			if len(rawCerts) == 0 {
+				return &TLSConfigError{Err: errors.New("no server certificate presented")}
+			}
+
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return &TLSConfigError{Err: fmt.Errorf("failed to parse server certificate: %w", err)}
 			}
 
+			var observed []string
+			pinned := true
+			for _, uri := range cert.URIs {
+				if uri.Scheme != "spiffe" {
+					continue
+				}
+				observed = append(observed, uri.Host)
+				if !slices.Contains(domains, uri.Host) {
+					pinned = false
+				}
+			}
+
+			// A certificate without SPIFFE IDs cannot prove its trust domain
+			if !pinned || len(observed) == 0 {
+				return &TrustDomainError{Got: observed, Allowed: domains}
+			}
 			return nil
-		},
+		}
+	}
+}
+
+// withPeerAuthorizer chains an additional SPIFFE ID check onto VerifyPeerCertificate
+// expected describes the authorized IDs and is included in mismatch errors
+func withPeerAuthorizer(expected string, authorized func(*url.URL) bool) TLSOption {
+	return func(c *tls.Config) {
+		next := c.VerifyPeerCertificate
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if next != nil {
+				if err := next(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+			}
+
+			if len(rawCerts) == 0 {
+				return &TLSConfigError{Err: errors.New("no server certificate presented")}
+			}
+
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return &TLSConfigError{Err: fmt.Errorf("failed to parse server certificate: %w", err)}
+			}
+
+			var received []string
+			for _, uri := range cert.URIs {
+				if !isValidSPIFFEID(uri) {
+					continue
+				}
+				if authorized(uri) {
+					return nil
+				}
+				received = append(received, uri.String())
+			}
+
+			return &SPIFFEIDError{Err: fmt.Errorf("server SPIFFE ID %v is not authorized: expected %s", received, expected)}
+		}
+	}
+}
+
+// NewTLSConfig creates a new TLS configuration for SPIFFE-compliant server certificate validation
+// Supports both TLS and mTLS connections based on provided options
+func NewTLSConfig(opts ...TLSOption) (*tls.Config, error) {
+	config := &tls.Config{
 		// Since CA certificate validation is out of scope, we'll accept any certificate
-		// that passes our SPIFFE ID validation
+		// that passes our SPIFFE ID validation unless root CAs are configured
 		InsecureSkipVerify: true,
 		MinVersion:         tls.VersionTLS12,
 	}
 
+	// SPIFFE-compliant verification
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return &TLSConfigError{Err: errors.New("no server certificate presented")}
+		}
+
+		// Parse the server certificate
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return &TLSConfigError{Err: fmt.Errorf("failed to parse server certificate: %w", err)}
+		}
+
+		// Verify the chain when root CAs are configured
+		if config.RootCAs != nil {
+			if err := verifyChain(cert, rawCerts[1:], config.RootCAs); err != nil {
+				return err
+			}
+		}
+
+		// Make sure the presented chain belongs to the leaf, so a SPIFFE ID carried
+		// only by an intermediate cannot stand in for the server's own
+		switch {
+		case len(verifiedChains) > 0:
+			if err := verifyChainLeaf(cert, verifiedChains); err != nil {
+				return err
+			}
+		case config.InsecureSkipVerify && config.RootCAs == nil:
+			if err := verifyPresentedChain(cert, rawCerts[1:]); err != nil {
+				return err
+			}
+		}
+
+		// Check for a valid SPIFFE ID in the leaf's URI SANs
+		if err := ValidateSPIFFECertificate(cert); err != nil {
+			return fmt.Errorf("server %w", err)
+		}
+
+		return nil
+	}
+
 	// Apply options
 	for _, opt := range opts {
 		opt(config)
 	}
 
+	// TLS 1.3 cipher suites are not configurable
+	if config.MinVersion == tls.VersionTLS13 && len(config.CipherSuites) > 0 {
+		return nil, &TLSConfigError{Err: errors.New("cipher suites cannot be configured in TLS 1.3-only mode")}
+	}
+
 	return config, nil
 }
 
+// NewMutualAuthTLSConfig creates an mTLS configuration for the given role from an SVID and trust bundle
+// The peer certificate is verified against bundle and its SPIFFE ID checked with authorizer
+func NewMutualAuthTLSConfig(role Role, svid *x509svid.SVID, bundle *x509bundle.Bundle, authorizer tlsconfig.Authorizer) (*tls.Config, error) {
+	if svid == nil {
+		return nil, &ValidationError{Err: errors.New("SVID is required")}
+	}
+	if bundle == nil {
+		return nil, &ValidationError{Err: errors.New("trust bundle is required")}
+	}
+	if authorizer == nil {
+		return nil, &ValidationError{Err: errors.New("authorizer is required")}
+	}
+
+	switch role {
+	case RoleClient:
+		return tlsconfig.MTLSClientConfig(svid, bundle, authorizer), nil
+	case RoleServer:
+		return tlsconfig.MTLSServerConfig(svid, bundle, authorizer), nil
+	default:
+		return nil, &ValidationError{Err: fmt.Errorf("unknown role %d", role)}
+	}
+}
+
+// StartExpiryWatcher calls onExpiringSoon once, in a new goroutine, when cert's leaf is within warnBefore of expiring
+// If that point has already passed the callback is called right away; it is not called once ctx is done or stop is called
+// A certificate without a parsable leaf is never reported
+func StartExpiryWatcher(ctx context.Context, cert *tls.Certificate, warnBefore time.Duration, onExpiringSoon func(expiresAt time.Time)) (stop func()) {
+	ctx, stop = context.WithCancel(ctx)
+
+	leaf, err := certificateLeaf(cert)
+	if err != nil || onExpiringSoon == nil {
+		return stop
+	}
+	expiresAt := leaf.NotAfter
+
+	go func() {
+		timer := time.NewTimer(time.Until(expiresAt.Add(-warnBefore)))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			// Both cases can be ready together; stopping takes precedence
+			if ctx.Err() == nil {
+				onExpiringSoon(expiresAt)
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	return stop
+}
+
+// certificateLeaf returns cert's parsed leaf, parsing the first certificate if Leaf is not set
+func certificateLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if cert == nil || (cert.Leaf == nil && len(cert.Certificate) == 0) {
+		return nil, &ValidationError{Err: errors.New("certificate is required")}
+	}
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, &TLSConfigError{Err: fmt.Errorf("failed to parse certificate: %w", err)}
+	}
+	return leaf, nil
+}
+
+// ValidateSPIFFECertificate checks that the certificate has at least one valid SPIFFE ID URI SAN
+func ValidateSPIFFECertificate(cert *x509.Certificate) error {
+	if cert == nil {
+		return &ValidationError{Err: errors.New("certificate is required")}
+	}
+
+	if len(cert.URIs) == 0 {
+		return &SPIFFEIDError{Err: errors.New("certificate has no URI SANs (SPIFFE ID required)")}
+	}
+
+	ids := ExtractSPIFFEIDs(cert)
+	if len(ids) == 0 {
+		return &SPIFFEIDError{Err: errors.New("certificate does not contain a valid SPIFFE ID")}
+	}
+
+	// IDs with non-canonical percent-encoding could name the same workload in several ways
+	for _, id := range ids {
+		canonical, err := CanonicalizeSPIFFEID(id)
+		if err != nil {
+			return err
+		}
+		if canonical != id {
+			return &SPIFFEIDError{Err: fmt.Errorf("SPIFFE ID %q is not canonical, expected %q", id, canonical)}
+		}
+	}
+
+	return nil
+}
+
+// ExtractSPIFFEIDs returns all valid SPIFFE IDs in the certificate's URI SANs
+func ExtractSPIFFEIDs(cert *x509.Certificate) []string {
+	if cert == nil {
+		return nil
+	}
+
+	var ids []string
+	for _, uri := range cert.URIs {
+		if isValidSPIFFEID(uri) {
+			ids = append(ids, uri.String())
+		}
+	}
+	return ids
+}
+
+// verifyChain verifies the server certificate against the given roots
+// using any intermediates presented by the peer
+func verifyChain(cert *x509.Certificate, rawIntermediates [][]byte, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, raw := range rawIntermediates {
+		intermediate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return &TLSConfigError{Err: fmt.Errorf("failed to parse intermediate certificate: %w", err)}
+		}
+		intermediates.AddCert(intermediate)
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("failed to verify server certificate chain: %w", err)}
+	}
+
+	return nil
+}
+
+// verifyChainLeaf checks that every chain built by crypto/tls starts with the presented leaf certificate
+func verifyChainLeaf(leaf *x509.Certificate, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		if len(chain) == 0 || !chain[0].Equal(leaf) {
+			return &TLSConfigError{Err: errors.New("verified chain does not start with the server certificate")}
+		}
+	}
+	return nil
+}
+
+// verifyPresentedChain checks that the certificates presented after the leaf form a chain for it
+// The last presented certificate is used as the root, so this proves consistency rather than trust
+// A lone leaf has nothing to check
+func verifyPresentedChain(leaf *x509.Certificate, rawChain [][]byte) error {
+	if len(rawChain) == 0 {
+		return nil
+	}
+
+	chain := make([]*x509.Certificate, 0, len(rawChain))
+	for _, raw := range rawChain {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return &TLSConfigError{Err: fmt.Errorf("failed to parse intermediate certificate: %w", err)}
+		}
+		chain = append(chain, cert)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[len(chain)-1])
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[:len(chain)-1] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("presented certificate chain is inconsistent: %w", err)}
+	}
+	return nil
+}
+
 // isValidSPIFFEID checks if a URI is a valid SPIFFE ID
+// IDs that NormalizeSPIFFEID would change, such as ones with trailing or repeated slashes, are not valid
 func isValidSPIFFEID(uri *url.URL) bool {
+	normalized, err := normalizeSPIFFEID(uri)
+	if err != nil {
+		return false
+	}
+
+	return normalized == "spiffe://"+uri.Host+uri.EscapedPath()
+}
+
+// NormalizeSPIFFEID returns raw with its path cleaned: repeated slashes, "." segments and trailing slashes are removed
+// IDs that are malformed beyond that, or whose path consists only of slashes, are rejected
+// A trust domain ID without a path is returned unchanged
+func NormalizeSPIFFEID(raw string) (string, error) {
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return "", &SPIFFEIDError{Err: fmt.Errorf("failed to parse SPIFFE ID: %w", err)}
+	}
+
+	return normalizeSPIFFEID(uri)
+}
+
+// CanonicalizeSPIFFEID returns raw with its path percent-decoded and then re-encoded so that only
+// characters RFC 3986 does not allow in a path are escaped, using upper-case hex digits
+// An encoded slash (%2F) therefore becomes a path separator. The result is also normalized as by NormalizeSPIFFEID
+// IDs whose decoded path contains a null byte are rejected
+func CanonicalizeSPIFFEID(raw string) (string, error) {
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return "", &SPIFFEIDError{Err: fmt.Errorf("failed to parse SPIFFE ID: %w", err)}
+	}
+
+	decoded, err := url.PathUnescape(uri.EscapedPath())
+	if err != nil {
+		return "", &SPIFFEIDError{Err: fmt.Errorf("failed to decode SPIFFE ID path: %w", err)}
+	}
+	if strings.ContainsRune(decoded, 0) {
+		return "", &SPIFFEIDError{Err: errors.New("path contains a null byte")}
+	}
+
+	canonical := *uri
+	canonical.Path = decoded
+	canonical.RawPath = escapeSPIFFEIDPath(decoded)
+	return normalizeSPIFFEID(&canonical)
+}
+
+// escapeSPIFFEIDPath percent-encodes every byte of path that is not allowed unescaped in an RFC 3986 path:
+// anything other than unreserved characters, sub-delims, ":", "@" and "/"
+func escapeSPIFFEIDPath(path string) string {
+	const upperHex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if isRFC3986PathChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(upperHex[c>>4])
+		b.WriteByte(upperHex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isRFC3986PathChar reports whether c may appear unescaped in a URI path
+func isRFC3986PathChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$&'()*+,;=:@/", c) >= 0
+}
+
+// normalizeSPIFFEID checks the parts of uri that normalization cannot fix and returns the normalized ID
+func normalizeSPIFFEID(uri *url.URL) (string, error) {
 	// SPIFFE IDs must:
 	// 1. Use the "spiffe" scheme
 	// 2. Have a host component (trust domain)
 	// 3. Have no user info, port, query, or fragment
+	// 4. Have a valid path (see ValidateSPIFFEIDPath)
 
 	if uri.Scheme != "spiffe" {
-		return false
+		return "", &SPIFFEIDError{Err: fmt.Errorf("scheme must be spiffe, got %q", uri.Scheme)}
 	}
 
 	if uri.Host == "" {
-		return false
+		return "", &SPIFFEIDError{Err: errors.New("trust domain is required")}
 	}
 
 	if uri.User != nil || uri.RawQuery != "" || uri.Fragment != "" {
-		return false
+		return "", &SPIFFEIDError{Err: errors.New("user info, query and fragment are not allowed")}
 	}
 
 	// Check for port (SPIFFE IDs should not have ports)
 	if uri.Port() != "" {
-		return false
+		return "", &SPIFFEIDError{Err: errors.New("port is not allowed")}
+	}
+
+	// ".." segments are rejected rather than resolved, since resolving them could name a different workload
+	escapedPath := uri.EscapedPath()
+	if err := ValidateSPIFFEIDPath(escapedPath); err != nil {
+		return "", err
+	}
+
+	if escapedPath == "" {
+		return "spiffe://" + uri.Host, nil
+	}
+	cleaned := path.Clean(escapedPath)
+	if cleaned == "/" {
+		return "", &SPIFFEIDError{Err: fmt.Errorf("path %q is empty after normalization", escapedPath)}
+	}
+
+	return "spiffe://" + uri.Host + cleaned, nil
+}
+
+// ValidateSPIFFEIDPath checks the path component of a SPIFFE ID
+// The path must not exceed 2048 bytes, contain ".." segments, or contain null bytes
+func ValidateSPIFFEIDPath(path string) error {
+	if len(path) > maxSPIFFEIDPathLength {
+		return &SPIFFEIDError{Err: fmt.Errorf("path exceeds %d bytes", maxSPIFFEIDPathLength)}
+	}
+
+	if strings.Contains(path, "%00") || strings.Contains(path, "\x00") {
+		return &SPIFFEIDError{Err: errors.New("path contains a null byte")}
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment == ".." {
+			return &SPIFFEIDError{Err: errors.New("path contains a \"..\" segment")}
+		}
+	}
+
+	return nil
+}
+
+// ParseSPIFFEID parses a SPIFFE ID and returns its trust domain and path
+func ParseSPIFFEID(raw string) (trustDomain, path string, err error) {
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return "", "", &SPIFFEIDError{Err: fmt.Errorf("failed to parse SPIFFE ID: %w", err)}
+	}
+
+	if !isValidSPIFFEID(uri) {
+		return "", "", &SPIFFEIDError{Err: fmt.Errorf("%q is not a valid SPIFFE ID", raw)}
 	}
 
-	return true
+	return uri.Host, uri.Path, nil
 }
@@ -0,0 +1,361 @@
+//go:build pkcs11
+
+package spireclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// WithPKCS11ClientCertificate configures a client certificate for mTLS whose private key stays on a PKCS#11 token
+// pkcs11URI is an RFC 7512 URI identifying the key, for example
+// "pkcs11:token=spire;object=agent?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234"
+// The token session stays open for the lifetime of the process
+// If the key pair cannot be loaded, the handshake fails with the loading error
+func WithPKCS11ClientCertificate(certFile, pkcs11URI string) TLSOption {
+	return func(c *tls.Config) {
+		cert, err := loadPKCS11KeyPair(certFile, pkcs11URI)
+		if err != nil {
+			c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return nil, err
+			}
+			return
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+}
+
+// loadPKCS11KeyPair reads a certificate chain from certFile and pairs it with a signer for the key at pkcs11URI
+func loadPKCS11KeyPair(certFile, pkcs11URI string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to read certificate file: %w", err)}
+	}
+
+	var cert tls.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return tls.Certificate{}, &TLSConfigError{Err: errors.New("no certificate found in certificate file")}
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: fmt.Errorf("failed to parse certificate: %w", err)}
+	}
+
+	uri, err := parsePKCS11URI(pkcs11URI)
+	if err != nil {
+		return tls.Certificate{}, &ValidationError{Err: fmt.Errorf("invalid PKCS#11 URI: %w", err)}
+	}
+
+	signer, err := newPKCS11Signer(uri, cert.Leaf.PublicKey)
+	if err != nil {
+		return tls.Certificate{}, &TLSConfigError{Err: err}
+	}
+	cert.PrivateKey = signer
+
+	return cert, nil
+}
+
+// pkcs11URI holds the attributes of an RFC 7512 PKCS#11 URI used to locate a private key
+type pkcs11URI struct {
+	modulePath string
+	slotID     *uint
+	token      string
+	object     string
+	id         []byte
+	pin        string
+}
+
+// parsePKCS11URI parses the supported attributes of a PKCS#11 URI
+// Path attributes: token, slot-id, object, id; query attributes: module-path, pin-value, pin-source
+func parsePKCS11URI(raw string) (*pkcs11URI, error) {
+	opaque, ok := strings.CutPrefix(raw, "pkcs11:")
+	if !ok {
+		return nil, errors.New(`URI must start with "pkcs11:"`)
+	}
+	path, query, _ := strings.Cut(opaque, "?")
+
+	uri := &pkcs11URI{}
+	for _, attr := range splitPKCS11Attributes(path, ";") {
+		name, value, err := parsePKCS11Attribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "token":
+			uri.token = value
+		case "object":
+			uri.object = value
+		case "id":
+			uri.id = []byte(value)
+		case "slot-id":
+			id, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return nil, fmt.Errorf("invalid slot-id %q: %w", value, err)
+			}
+			slotID := uint(id)
+			uri.slotID = &slotID
+		}
+	}
+
+	for _, attr := range splitPKCS11Attributes(query, "&") {
+		name, value, err := parsePKCS11Attribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "module-path":
+			uri.modulePath = value
+		case "pin-value":
+			uri.pin = value
+		case "pin-source":
+			pin, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pin-source: %w", err)
+			}
+			uri.pin = strings.TrimSpace(string(pin))
+		}
+	}
+
+	if uri.modulePath == "" {
+		return nil, errors.New("module-path is required")
+	}
+	if uri.object == "" && len(uri.id) == 0 {
+		return nil, errors.New("object or id is required to select the private key")
+	}
+
+	return uri, nil
+}
+
+// splitPKCS11Attributes splits s on sep, dropping empty attributes
+func splitPKCS11Attributes(s, sep string) []string {
+	var attrs []string
+	for _, attr := range strings.Split(s, sep) {
+		if attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// parsePKCS11Attribute splits a name=value attribute and percent-decodes the value
+func parsePKCS11Attribute(attr string) (string, string, error) {
+	name, value, ok := strings.Cut(attr, "=")
+	if !ok {
+		return "", "", fmt.Errorf("attribute %q is not of the form name=value", attr)
+	}
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return name, value, nil
+}
+
+// pkcs11Signer is a crypto.Signer whose private key operations run on a PKCS#11 token
+type pkcs11Signer struct {
+	ctx    *pkcs11.Ctx
+	public crypto.PublicKey
+	key    pkcs11.ObjectHandle
+
+	// mu serializes use of the session, which PKCS#11 does not allow concurrently
+	mu      sync.Mutex
+	session pkcs11.SessionHandle
+}
+
+// newPKCS11Signer logs in to the token described by uri and returns a signer for its private key
+// public is the key from the certificate and must match the private key on the token
+func newPKCS11Signer(uri *pkcs11URI, public crypto.PublicKey) (*pkcs11Signer, error) {
+	switch public.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T for PKCS#11 signing", public)
+	}
+
+	ctx := pkcs11.New(uri.modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", uri.modulePath)
+	}
+	// The module may already be initialized by another key loaded in this process
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	slot, err := findPKCS11Slot(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+	}
+	// Login state is shared by all sessions of the application on a token
+	if err := ctx.Login(session, pkcs11.CKU_USER, uri.pin); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		ctx.CloseSession(session)
+		return nil, fmt.Errorf("failed to log in to PKCS#11 token: %w", err)
+	}
+
+	key, err := findPKCS11PrivateKey(ctx, session, uri)
+	if err != nil {
+		ctx.CloseSession(session)
+		return nil, err
+	}
+
+	return &pkcs11Signer{ctx: ctx, public: public, key: key, session: session}, nil
+}
+
+// findPKCS11Slot returns the slot holding the token selected by uri, or the first slot with a token
+func findPKCS11Slot(ctx *pkcs11.Ctx, uri *pkcs11URI) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		if uri.slotID != nil && slot != *uri.slotID {
+			continue
+		}
+		if uri.token != "" {
+			info, err := ctx.GetTokenInfo(slot)
+			if err != nil || strings.TrimSpace(info.Label) != uri.token {
+				continue
+			}
+		}
+		return slot, nil
+	}
+
+	return 0, fmt.Errorf("no PKCS#11 token matches token=%q", uri.token)
+}
+
+// findPKCS11PrivateKey returns the single private key matching the object label and id of uri
+func findPKCS11PrivateKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, uri *pkcs11URI) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)}
+	if uri.object != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, uri.object))
+	}
+	if len(uri.id) > 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, uri.id))
+	}
+
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 objects: %w", err)
+	}
+	objects, _, err := ctx.FindObjects(session, 2)
+	ctx.FindObjectsFinal(session)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 objects: %w", err)
+	}
+
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("no private key matches object=%q on the PKCS#11 token", uri.object)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("more than one private key matches object=%q; add an id to the PKCS#11 URI", uri.object)
+	}
+}
+
+// Public returns the public key of the certificate paired with the token key
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs digest on the token; the random source is unused since the token provides its own
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	mechanism, data, err := s.mechanism(digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{mechanism}, s.key); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 signing: %w", err)
+	}
+	signature, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with PKCS#11 key: %w", err)
+	}
+
+	if _, ok := s.public.(*ecdsa.PublicKey); ok {
+		return ecdsaSignatureToASN1(signature)
+	}
+	return signature, nil
+}
+
+// mechanism returns the PKCS#11 mechanism and input for signing digest with opts
+func (s *pkcs11Signer) mechanism(digest []byte, opts crypto.SignerOpts) (*pkcs11.Mechanism, []byte, error) {
+	if _, ok := s.public.(*ecdsa.PublicKey); ok {
+		return pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), digest, nil
+	}
+
+	hash := opts.HashFunc()
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		params, ok := pssHashParams[hash]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported hash %s for RSA-PSS", hash)
+		}
+		// TLS uses a salt as long as the hash; verifiers of PSSSaltLengthAuto accept that too
+		saltLength := pss.SaltLength
+		if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+			saltLength = hash.Size()
+		}
+		return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pkcs11.NewPSSParams(params[0], params[1], uint(saltLength))), digest, nil
+	}
+
+	// CKM_RSA_PKCS expects the DER-encoded DigestInfo rather than the bare digest
+	prefix, ok := digestInfoPrefixes[hash]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported hash %s for RSA PKCS#1 v1.5", hash)
+	}
+	return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil), append(append([]byte{}, prefix...), digest...), nil
+}
+
+// pssHashParams maps a hash to its PKCS#11 hash mechanism and MGF1 generator
+var pssHashParams = map[crypto.Hash][2]uint{
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// digestInfoPrefixes are the DER DigestInfo headers preceding a digest in PKCS#1 v1.5 signatures
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// ecdsaSignatureToASN1 converts the r||s signature returned by CKM_ECDSA to the ASN.1 form used by TLS
+func ecdsaSignatureToASN1(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, fmt.Errorf("invalid ECDSA signature length %d from PKCS#11 token", len(raw))
+	}
+	half := len(raw) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(raw[:half]),
+		S: new(big.Int).SetBytes(raw[half:]),
+	})
+}
@@ -0,0 +1,281 @@
+//go:build pkcs11
+
+package spireclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPKCS11Token = "spire-client-test"
+	testPKCS11PIN   = "1234"
+)
+
+// softHSMModule returns the SoftHSM2 module from PKCS11_MODULE or a common install path, skipping the test if none exists
+func softHSMModule(t *testing.T) string {
+	t.Helper()
+
+	candidates := []string{
+		os.Getenv("PKCS11_MODULE"),
+		"/usr/lib/softhsm/libsofthsm2.so",
+		"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/local/lib/softhsm/libsofthsm2.so",
+	}
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	t.Skip("SoftHSM2 not found; install softhsm2 or set PKCS11_MODULE")
+	return ""
+}
+
+// newSoftHSMToken initializes a SoftHSM2 token in a temporary directory and generates an ECDSA and an RSA key pair
+// It returns the public keys keyed by object label
+func newSoftHSMToken(t *testing.T, module string) map[string]any {
+	t.Helper()
+
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	require.NoError(t, os.Mkdir(tokenDir, 0700))
+	conf := filepath.Join(dir, "softhsm2.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("directories.tokendir = "+tokenDir+"\nobjectstore.backend = file\n"), 0600))
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	require.NotNil(t, ctx)
+	require.NoError(t, ctx.Initialize())
+	// The signer under test initializes the module itself
+	defer ctx.Finalize()
+
+	slots, err := ctx.GetSlotList(false)
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	require.NoError(t, ctx.InitToken(slots[0], "5678", testPKCS11Token))
+
+	// SoftHSM2 moves an initialized token to a new slot
+	slot, err := findPKCS11Slot(ctx, &pkcs11URI{token: testPKCS11Token})
+	require.NoError(t, err)
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	require.NoError(t, err)
+	defer ctx.CloseSession(session)
+
+	require.NoError(t, ctx.Login(session, pkcs11.CKU_SO, "5678"))
+	require.NoError(t, ctx.InitPIN(session, testPKCS11PIN))
+	require.NoError(t, ctx.Logout(session))
+	require.NoError(t, ctx.Login(session, pkcs11.CKU_USER, testPKCS11PIN))
+
+	privateTemplate := func(label string) []*pkcs11.Attribute {
+		return []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		}
+	}
+
+	// P-256 key; CKA_EC_POINT is a DER OCTET STRING holding the uncompressed point
+	ecParams, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	require.NoError(t, err)
+	ecPublic, _, err := ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams),
+		},
+		privateTemplate("ecdsa"))
+	require.NoError(t, err)
+	attrs, err := ctx.GetAttributeValue(session, ecPublic, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)})
+	require.NoError(t, err)
+	var point []byte
+	_, err = asn1.Unmarshal(attrs[0].Value, &point)
+	require.NoError(t, err)
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	require.NotNil(t, x)
+
+	rsaPublic, _, err := ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		},
+		privateTemplate("rsa"))
+	require.NoError(t, err)
+	attrs, err = ctx.GetAttributeValue(session, rsaPublic, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	require.NoError(t, err)
+
+	return map[string]any{
+		"ecdsa": &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+		"rsa": &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		},
+	}
+}
+
+// writeTestCASignedCert writes a CA-signed SPIFFE certificate for public to a PEM file and returns its path
+func writeTestCASignedCert(t *testing.T, spiffeID string, public any) string {
+	t.Helper()
+
+	uri, err := url.Parse(spiffeID)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "pkcs11"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{uri},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, testCACert, public, testCAKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "client.crt")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	return path
+}
+
+func TestWithPKCS11ClientCertificate(t *testing.T) {
+	module := softHSMModule(t)
+	publicKeys := newSoftHSMToken(t, module)
+
+	serverCert := newTestCASignedKeyPair(t, "spiffe://example.org/spire/server")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(testCACert)
+
+	tests := []struct {
+		name       string
+		object     string
+		maxVersion uint16
+	}{
+		{name: "ECDSA TLS 1.3", object: "ecdsa", maxVersion: tls.VersionTLS13},
+		{name: "ECDSA TLS 1.2", object: "ecdsa", maxVersion: tls.VersionTLS12},
+		{name: "RSA-PSS TLS 1.3", object: "rsa", maxVersion: tls.VersionTLS13},
+		{name: "RSA TLS 1.2", object: "rsa", maxVersion: tls.VersionTLS12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const clientID = "spiffe://example.org/pkcs11-client"
+			certFile := writeTestCASignedCert(t, clientID, publicKeys[tt.object])
+			uri := fmt.Sprintf("pkcs11:token=%s;object=%s?module-path=%s&pin-value=%s", testPKCS11Token, tt.object, module, testPKCS11PIN)
+
+			clientConfig, err := NewTLSConfig(WithPKCS11ClientCertificate(certFile, uri))
+			require.NoError(t, err)
+			require.Len(t, clientConfig.Certificates, 1)
+			clientConfig.MaxVersion = tt.maxVersion
+
+			serverConfig := &tls.Config{
+				Certificates: []tls.Certificate{serverCert},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAs,
+			}
+
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+			defer serverConn.Close()
+
+			peerIDs := make(chan []string, 1)
+			go func() {
+				server := tls.Server(serverConn, serverConfig)
+				if err := server.Handshake(); err != nil {
+					peerIDs <- nil
+					return
+				}
+				peerIDs <- ExtractSPIFFEIDs(server.ConnectionState().PeerCertificates[0])
+			}()
+
+			client := tls.Client(clientConn, clientConfig)
+			require.NoError(t, client.Handshake())
+			assert.Equal(t, []string{clientID}, <-peerIDs)
+		})
+	}
+
+	// crypto/tls prefers RSA-PSS in both versions, so PKCS#1 v1.5 is checked against the signer directly
+	t.Run("RSA PKCS#1 v1.5 signature", func(t *testing.T) {
+		certFile := writeTestCASignedCert(t, "spiffe://example.org/pkcs11-client", publicKeys["rsa"])
+		uri := fmt.Sprintf("pkcs11:token=%s;object=rsa?module-path=%s&pin-value=%s", testPKCS11Token, module, testPKCS11PIN)
+
+		cert, err := loadPKCS11KeyPair(certFile, uri)
+		require.NoError(t, err)
+
+		digest := sha256.Sum256([]byte("message"))
+		signature, err := cert.PrivateKey.(crypto.Signer).Sign(rand.Reader, digest[:], crypto.SHA256)
+		require.NoError(t, err)
+		assert.NoError(t, rsa.VerifyPKCS1v15(publicKeys["rsa"].(*rsa.PublicKey), crypto.SHA256, digest[:], signature))
+	})
+
+	t.Run("key not found", func(t *testing.T) {
+		certFile := writeTestCASignedCert(t, "spiffe://example.org/pkcs11-client", publicKeys["ecdsa"])
+		uri := fmt.Sprintf("pkcs11:token=%s;object=missing?module-path=%s&pin-value=%s", testPKCS11Token, module, testPKCS11PIN)
+
+		_, err := loadPKCS11KeyPair(certFile, uri)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `no private key matches object="missing"`)
+	})
+}
+
+func TestParsePKCS11URI(t *testing.T) {
+	t.Run("all attributes", func(t *testing.T) {
+		pinFile := filepath.Join(t.TempDir(), "pin")
+		require.NoError(t, os.WriteFile(pinFile, []byte("secret\n"), 0600))
+
+		uri, err := parsePKCS11URI("pkcs11:token=My%20Token;slot-id=3;object=agent;id=%01%02?module-path=/usr/lib/p11.so&pin-source=file:" + pinFile)
+		require.NoError(t, err)
+		assert.Equal(t, "My Token", uri.token)
+		require.NotNil(t, uri.slotID)
+		assert.Equal(t, uint(3), *uri.slotID)
+		assert.Equal(t, "agent", uri.object)
+		assert.Equal(t, []byte{1, 2}, uri.id)
+		assert.Equal(t, "/usr/lib/p11.so", uri.modulePath)
+		assert.Equal(t, "secret", uri.pin)
+	})
+
+	invalid := map[string]string{
+		"wrong scheme":        "pkcs12:object=agent?module-path=/usr/lib/p11.so",
+		"missing module-path": "pkcs11:object=agent",
+		"missing key":         "pkcs11:token=t?module-path=/usr/lib/p11.so",
+		"malformed attribute": "pkcs11:object?module-path=/usr/lib/p11.so",
+		"invalid slot-id":     "pkcs11:slot-id=x;object=agent?module-path=/usr/lib/p11.so",
+	}
+	for name, raw := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePKCS11URI(raw)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,20 +1,240 @@
 package spireclient
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
 	"math/big"
+	"net"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var (
+	// testCACert is a self-signed CA fixture shared by the TLS tests
+	testCACert *x509.Certificate
+	// testCAKey is the private key of testCACert
+	testCAKey *rsa.PrivateKey
+	// testCAFile is the path of testCACert encoded as PEM
+	testCAFile string
+	// testSPIFFECerts are CA-signed certificates with various URI SANs, keyed by description
+	testSPIFFECerts map[string]*x509.Certificate
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "spireclient-tls-test")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := setupTestCA(dir); err != nil {
+		os.RemoveAll(dir)
+		panic(err)
+	}
+
+	if err := setupTestSPIFFECerts(); err != nil {
+		os.RemoveAll(dir)
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupTestCA generates the self-signed CA fixture and writes it to dir
+func setupTestCA(dir string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName: "test CA",
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return err
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		return err
+	}
+
+	testCACert = cert
+	testCAKey = key
+	testCAFile = caFile
+	return nil
+}
+
+// setupTestSPIFFECerts generates the certificate fixtures used by the SPIFFE certificate validation tests
+func setupTestSPIFFECerts() error {
+	fixtures := map[string][]string{
+		"single SPIFFE ID":   {"spiffe://example.org/workload"},
+		"multiple SPIFFE ID": {"spiffe://example.org/a", "spiffe://example.org/b"},
+		"mixed URIs":         {"https://example.org/a", "spiffe://example.org/b"},
+		"non-SPIFFE URI":     {"https://example.org/workload"},
+		"invalid SPIFFE ID":  {"spiffe://example.org/../workload"},
+		"mixed trust domain": {"spiffe://example.org/a", "spiffe://evil.com/b"},
+		"encoded slash":      {"spiffe://example.org/ns%2Fprod"},
+		"encoded space":      {"spiffe://example.org/work%20load"},
+		"no URIs":            nil,
+	}
+
+	testSPIFFECerts = make(map[string]*x509.Certificate, len(fixtures))
+	for name, rawURIs := range fixtures {
+		var uris []*url.URL
+		for _, raw := range rawURIs {
+			uri, err := url.Parse(raw)
+			if err != nil {
+				return err
+			}
+			uris = append(uris, uri)
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(len(testSPIFFECerts) + 10)),
+			Subject: pkix.Name{
+				CommonName: name,
+			},
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(365 * 24 * time.Hour),
+			URIs:      uris,
+		}
+
+		// The CA key doubles as the leaf key since only the SANs matter here
+		certBytes, err := x509.CreateCertificate(rand.Reader, template, testCACert, &testCAKey.PublicKey, testCAKey)
+		if err != nil {
+			return err
+		}
+
+		cert, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			return err
+		}
+		testSPIFFECerts[name] = cert
+	}
+
+	return nil
+}
+
+// newTestCASignedCert creates a leaf certificate with the given URI SAN signed by the CA fixture
+func newTestCASignedCert(t *testing.T, spiffeID string) []byte {
+	t.Helper()
+	return newTestCASignedKeyPair(t, spiffeID).Certificate[0]
+}
+
+// newTestCASignedKeyPair creates a leaf key pair with the given URI SAN signed by the CA fixture
+func newTestCASignedKeyPair(t *testing.T, spiffeID string) tls.Certificate {
+	t.Helper()
+
+	uri, err := url.Parse(spiffeID)
+	require.NoError(t, err)
+
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			CommonName: "test",
+		},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(365 * 24 * time.Hour),
+		// X509-SVIDs must allow digital signatures (checked by x509svid.Load)
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		URIs:     []*url.URL{uri},
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, certTemplate, testCACert, &key.PublicKey, testCAKey)
+	require.NoError(t, err)
+
+	return tls.Certificate{
+		Certificate: [][]byte{certBytes},
+		PrivateKey:  key,
+	}
+}
+
+// newTestCertChain creates a leaf signed by a new intermediate CA that is signed by the CA fixture
+// It returns the DER certificates in the order a server presents them: leaf, intermediate, root
+// Either URI may be empty to leave that certificate without a URI SAN
+func newTestCertChain(t *testing.T, leafURI, intermediateURI string) [][]byte {
+	t.Helper()
+
+	uris := func(raw string) []*url.URL {
+		if raw == "" {
+			return nil
+		}
+		uri, err := url.Parse(raw)
+		require.NoError(t, err)
+		return []*url.URL{uri}
+	}
+
+	intermediateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	intermediateTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "test intermediate CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		URIs:                  uris(intermediateURI),
+	}
+	intermediateDER, err := x509.CreateCertificate(rand.Reader, intermediateTemplate, testCACert, &intermediateKey.PublicKey, testCAKey)
+	require.NoError(t, err)
+	intermediate, err := x509.ParseCertificate(intermediateDER)
+	require.NoError(t, err)
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		URIs:         uris(leafURI),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, intermediate, &leafKey.PublicKey, intermediateKey)
+	require.NoError(t, err)
+
+	return [][]byte{leafDER, intermediateDER, testCACert.Raw}
+}
+
 func TestNewTLSConfig(t *testing.T) {
 	t.Run("basic TLS config", func(t *testing.T) {
 		config, err := NewTLSConfig()
@@ -40,6 +260,336 @@ func TestNewTLSConfig(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, config)
 	})
+
+	t.Run("with root CAs option", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(testCACert)
+
+		config, err := NewTLSConfig(WithRootCAs(pool))
+		require.NoError(t, err)
+		assert.False(t, config.InsecureSkipVerify)
+		assert.Same(t, pool, config.RootCAs)
+
+		// Certificate signed by the CA passes chain verification
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/server")}, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("with root CAs from file option", func(t *testing.T) {
+		config, err := NewTLSConfig(WithRootCAsFromFile(testCAFile))
+		require.NoError(t, err)
+		assert.False(t, config.InsecureSkipVerify)
+		require.NotNil(t, config.RootCAs)
+
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/server")}, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("with root CAs from missing file option", func(t *testing.T) {
+		config, err := NewTLSConfig(WithRootCAsFromFile("missing-ca.pem"))
+		require.NoError(t, err)
+		assert.False(t, config.InsecureSkipVerify)
+
+		// Empty pool rejects every server certificate
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/server")}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to verify server certificate chain")
+	})
+
+	t.Run("with root CAs rejects untrusted certificate", func(t *testing.T) {
+		config, err := NewTLSConfig(WithRootCAsFromFile(testCAFile))
+		require.NoError(t, err)
+
+		// Self-signed certificate not issued by the CA fixture
+		uri, _ := url.Parse("spiffe://example.org/server")
+		certTemplate := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject: pkix.Name{
+				CommonName: "test",
+			},
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(365 * 24 * time.Hour),
+			URIs:      []*url.URL{uri},
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		certBytes, err := x509.CreateCertificate(rand.Reader, certTemplate, certTemplate, &key.PublicKey, key)
+		require.NoError(t, err)
+
+		err = config.VerifyPeerCertificate([][]byte{certBytes}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to verify server certificate chain")
+	})
+
+	t.Run("with custom authorizer option", func(t *testing.T) {
+		serverID := spiffeid.RequireFromString("spiffe://example.org/server")
+
+		config, err := NewTLSConfig(WithCustomAuthorizer(tlsconfig.AuthorizeID(serverID)))
+		require.NoError(t, err)
+
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/server")}, nil)
+		assert.NoError(t, err)
+
+		err = config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/other")}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "server SPIFFE ID spiffe://example.org/other is not authorized")
+	})
+
+	t.Run("mutual auth client and server roles", func(t *testing.T) {
+		bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{testCACert})
+		clientSVID := newTestSVID(t, "spiffe://example.org/client")
+		serverSVID := newTestSVID(t, "spiffe://example.org/server")
+
+		clientConfig, err := NewMutualAuthTLSConfig(RoleClient, clientSVID, bundle, tlsconfig.AuthorizeID(serverSVID.ID))
+		require.NoError(t, err)
+		serverConfig, err := NewMutualAuthTLSConfig(RoleServer, serverSVID, bundle, tlsconfig.AuthorizeID(clientSVID.ID))
+		require.NoError(t, err)
+
+		assert.NotNil(t, clientConfig.GetClientCertificate)
+		assert.NotNil(t, serverConfig.GetCertificate)
+		assert.Equal(t, tls.RequireAnyClientCert, serverConfig.ClientAuth)
+
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		serverErr := make(chan error, 1)
+		go func() {
+			serverErr <- tls.Server(serverConn, serverConfig).Handshake()
+		}()
+
+		client := tls.Client(clientConn, clientConfig)
+		require.NoError(t, client.Handshake())
+		require.NoError(t, <-serverErr)
+		assert.Equal(t, "spiffe://example.org/server", client.ConnectionState().PeerCertificates[0].URIs[0].String())
+	})
+
+	t.Run("mutual auth invalid arguments", func(t *testing.T) {
+		bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{testCACert})
+		svid := newTestSVID(t, "spiffe://example.org/client")
+		authorizer := tlsconfig.AuthorizeAny()
+
+		tests := []struct {
+			name       string
+			role       Role
+			svid       *x509svid.SVID
+			bundle     *x509bundle.Bundle
+			authorizer tlsconfig.Authorizer
+			errMsg     string
+		}{
+			{"missing SVID", RoleClient, nil, bundle, authorizer, "SVID is required"},
+			{"missing bundle", RoleClient, svid, nil, authorizer, "trust bundle is required"},
+			{"missing authorizer", RoleServer, svid, bundle, nil, "authorizer is required"},
+			{"unknown role", Role(42), svid, bundle, authorizer, "unknown role 42"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				config, err := NewMutualAuthTLSConfig(tt.role, tt.svid, tt.bundle, tt.authorizer)
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				assert.Nil(t, config)
+			})
+		}
+	})
+}
+
+// newTestSVID creates an X.509 SVID with the given SPIFFE ID signed by the CA fixture
+func newTestSVID(t *testing.T, spiffeID string) *x509svid.SVID {
+	t.Helper()
+
+	keyPair := newTestCASignedKeyPair(t, spiffeID)
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+	require.NoError(t, err)
+
+	return &x509svid.SVID{
+		ID:           spiffeid.RequireFromString(spiffeID),
+		Certificates: []*x509.Certificate{leaf},
+		PrivateKey:   keyPair.PrivateKey.(*rsa.PrivateKey),
+	}
+}
+
+func TestWithEncryptedClientCertificates(t *testing.T) {
+	const (
+		certFile = "testdata/encrypted-client.crt"
+		keyFile  = "testdata/encrypted-client.key"
+		password = "testpassword"
+	)
+
+	t.Run("encrypted PKCS#8 key", func(t *testing.T) {
+		config, err := NewTLSConfig(WithEncryptedClientCertificates(certFile, keyFile, password))
+		require.NoError(t, err)
+		require.Len(t, config.Certificates, 1)
+		assert.Nil(t, config.GetClientCertificate)
+
+		leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		require.Len(t, leaf.URIs, 1)
+		assert.Equal(t, "spiffe://example.org/client", leaf.URIs[0].String())
+	})
+
+	t.Run("legacy encrypted PEM key", func(t *testing.T) {
+		keyPair := newTestCASignedKeyPair(t, "spiffe://example.org/client")
+		block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY",
+			x509.MarshalPKCS1PrivateKey(keyPair.PrivateKey.(*rsa.PrivateKey)), []byte(password), x509.PEMCipherAES256)
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		legacyCert := filepath.Join(dir, "client.crt")
+		legacyKey := filepath.Join(dir, "client.key")
+		require.NoError(t, os.WriteFile(legacyCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: keyPair.Certificate[0]}), 0644))
+		require.NoError(t, os.WriteFile(legacyKey, pem.EncodeToMemory(block), 0600))
+
+		cert, err := loadEncryptedKeyPair(legacyCert, legacyKey, password)
+		require.NoError(t, err)
+		assert.Equal(t, keyPair.Certificate[0], cert.Certificate[0])
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, err := loadEncryptedKeyPair(certFile, keyFile, "wrong")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decrypt private key")
+
+		// The option surfaces the error when the certificate is requested
+		config, err := NewTLSConfig(WithEncryptedClientCertificates(certFile, keyFile, "wrong"))
+		require.NoError(t, err)
+		assert.Empty(t, config.Certificates)
+		require.NotNil(t, config.GetClientCertificate)
+
+		_, err = config.GetClientCertificate(&tls.CertificateRequestInfo{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decrypt private key")
+	})
+
+	t.Run("non-encrypted PEM block", func(t *testing.T) {
+		_, err := loadEncryptedKeyPair(certFile, certFile, password)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "private key is not encrypted")
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := loadEncryptedKeyPair(certFile, "testdata/missing.key", password)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read key file")
+	})
+}
+
+func TestValidateSPIFFECertificate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "single SPIFFE ID",
+			cert: testSPIFFECerts["single SPIFFE ID"],
+		},
+		{
+			name: "multiple SPIFFE ID",
+			cert: testSPIFFECerts["multiple SPIFFE ID"],
+		},
+		{
+			name: "mixed URIs",
+			cert: testSPIFFECerts["mixed URIs"],
+		},
+		{
+			name:    "non-SPIFFE URI",
+			cert:    testSPIFFECerts["non-SPIFFE URI"],
+			wantErr: true,
+			errMsg:  "certificate does not contain a valid SPIFFE ID",
+		},
+		{
+			name:    "invalid SPIFFE ID",
+			cert:    testSPIFFECerts["invalid SPIFFE ID"],
+			wantErr: true,
+			errMsg:  "certificate does not contain a valid SPIFFE ID",
+		},
+		{
+			name:    "no URIs",
+			cert:    testSPIFFECerts["no URIs"],
+			wantErr: true,
+			errMsg:  "certificate has no URI SANs",
+		},
+		{
+			name:    "nil certificate",
+			cert:    nil,
+			wantErr: true,
+			errMsg:  "certificate is required",
+		},
+		{
+			name:    "non-canonical percent-encoding",
+			cert:    testSPIFFECerts["encoded slash"],
+			wantErr: true,
+			errMsg:  `expected "spiffe://example.org/ns/prod"`,
+		},
+		{
+			name: "canonical percent-encoding",
+			cert: testSPIFFECerts["encoded space"],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSPIFFECertificate(tt.cert)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtractSPIFFEIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want []string
+	}{
+		{
+			name: "single SPIFFE ID",
+			cert: testSPIFFECerts["single SPIFFE ID"],
+			want: []string{"spiffe://example.org/workload"},
+		},
+		{
+			name: "multiple SPIFFE ID",
+			cert: testSPIFFECerts["multiple SPIFFE ID"],
+			want: []string{"spiffe://example.org/a", "spiffe://example.org/b"},
+		},
+		{
+			name: "mixed URIs",
+			cert: testSPIFFECerts["mixed URIs"],
+			want: []string{"spiffe://example.org/b"},
+		},
+		{
+			name: "non-SPIFFE URI",
+			cert: testSPIFFECerts["non-SPIFFE URI"],
+			want: nil,
+		},
+		{
+			name: "invalid SPIFFE ID",
+			cert: testSPIFFECerts["invalid SPIFFE ID"],
+			want: nil,
+		},
+		{
+			name: "no URIs",
+			cert: testSPIFFECerts["no URIs"],
+			want: nil,
+		},
+		{
+			name: "nil certificate",
+			cert: nil,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExtractSPIFFEIDs(tt.cert))
+		})
+	}
 }
 
 func TestIsValidSPIFFEID(t *testing.T) {
@@ -88,6 +638,61 @@ func TestIsValidSPIFFEID(t *testing.T) {
 			uri:   "spiffe://example.org/workload#fragment",
 			valid: false,
 		},
+		{
+			name:  "trust domain only",
+			uri:   "spiffe://example.org",
+			valid: true,
+		},
+		{
+			name:  "path at length limit",
+			uri:   "spiffe://example.org/" + strings.Repeat("a", 2047),
+			valid: true,
+		},
+		{
+			name:  "path over length limit",
+			uri:   "spiffe://example.org/" + strings.Repeat("a", 2048),
+			valid: false,
+		},
+		{
+			name:  "with dot-dot segment",
+			uri:   "spiffe://example.org/ns/../workload",
+			valid: false,
+		},
+		{
+			name:  "with trailing dot-dot segment",
+			uri:   "spiffe://example.org/workload/..",
+			valid: false,
+		},
+		{
+			name:  "with dots inside segment",
+			uri:   "spiffe://example.org/workload..v2",
+			valid: true,
+		},
+		{
+			name:  "with encoded null byte",
+			uri:   "spiffe://example.org/work%00load",
+			valid: false,
+		},
+		{
+			name:  "with trailing slash",
+			uri:   "spiffe://example.org/workload/",
+			valid: false,
+		},
+		{
+			name:  "with double slash",
+			uri:   "spiffe://example.org//workload",
+			valid: false,
+		},
+		{
+			name:  "with dot segment",
+			uri:   "spiffe://example.org/ns/./workload",
+			valid: false,
+		},
+		{
+			name:  "root path only",
+			uri:   "spiffe://example.org/",
+			valid: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,6 +704,173 @@ func TestIsValidSPIFFEID(t *testing.T) {
 	}
 }
 
+func TestNormalizeSPIFFEID(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{name: "already normalized", raw: "spiffe://example.org/ns/prod/sa/web", want: "spiffe://example.org/ns/prod/sa/web"},
+		{name: "trust domain only", raw: "spiffe://example.org", want: "spiffe://example.org"},
+		{name: "trailing slash", raw: "spiffe://example.org/workload/", want: "spiffe://example.org/workload"},
+		{name: "multiple trailing slashes", raw: "spiffe://example.org/workload///", want: "spiffe://example.org/workload"},
+		{name: "leading double slash", raw: "spiffe://example.org//workload", want: "spiffe://example.org/workload"},
+		{name: "inner double slash", raw: "spiffe://example.org/ns//workload", want: "spiffe://example.org/ns/workload"},
+		{name: "dot segment", raw: "spiffe://example.org/ns/./workload", want: "spiffe://example.org/ns/workload"},
+		{name: "percent-encoding is kept", raw: "spiffe://example.org/work%20load/", want: "spiffe://example.org/work%20load"},
+		{name: "root path only", raw: "spiffe://example.org/", wantErr: "empty after normalization"},
+		{name: "slashes only", raw: "spiffe://example.org///", wantErr: "empty after normalization"},
+		{name: "dot-dot segment", raw: "spiffe://example.org/ns/../workload", wantErr: `".." segment`},
+		{name: "invalid scheme", raw: "https://example.org/workload", wantErr: "scheme must be spiffe"},
+		{name: "missing trust domain", raw: "spiffe:///workload", wantErr: "trust domain is required"},
+		{name: "with port", raw: "spiffe://example.org:8080/workload", wantErr: "port is not allowed"},
+		{name: "with query", raw: "spiffe://example.org/workload?x=1", wantErr: "query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeSPIFFEID(tt.raw)
+			if tt.wantErr != "" {
+				var idErr *SPIFFEIDError
+				require.ErrorAs(t, err, &idErr)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// Normalized IDs are valid and stable
+			uri, err := url.Parse(got)
+			require.NoError(t, err)
+			assert.True(t, isValidSPIFFEID(uri))
+			again, err := NormalizeSPIFFEID(got)
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
+
+func TestCanonicalizeSPIFFEID(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{name: "already canonical", raw: "spiffe://example.org/ns/prod/sa/web", want: "spiffe://example.org/ns/prod/sa/web"},
+		{name: "trust domain only", raw: "spiffe://example.org", want: "spiffe://example.org"},
+		{name: "encoded slash", raw: "spiffe://example.org/ns%2Fprod/sa%2fweb", want: "spiffe://example.org/ns/prod/sa/web"},
+		{name: "encoded unreserved characters", raw: "spiffe://example.org/%77orkload%2D1%5F%7E", want: "spiffe://example.org/workload-1_~"},
+		{name: "encoded sub-delims and colon", raw: "spiffe://example.org/a%3Ab%40c%2Bd", want: "spiffe://example.org/a:b@c+d"},
+		{name: "space stays encoded", raw: "spiffe://example.org/work%20load", want: "spiffe://example.org/work%20load"},
+		{name: "lower-case hex is upper-cased", raw: "spiffe://example.org/caf%c3%a9", want: "spiffe://example.org/caf%C3%A9"},
+		{name: "encoded percent sign", raw: "spiffe://example.org/100%25", want: "spiffe://example.org/100%25"},
+		{name: "decoded path is normalized", raw: "spiffe://example.org/ns%2F%2Fworkload%2F", want: "spiffe://example.org/ns/workload"},
+		{name: "null byte", raw: "spiffe://example.org/work%00load", wantErr: "null byte"},
+		{name: "encoded dot-dot segment", raw: "spiffe://example.org/ns/%2E%2E/workload", wantErr: `".." segment`},
+		{name: "invalid escape", raw: "spiffe://example.org/work%zzload", wantErr: "failed to parse SPIFFE ID"},
+		{name: "invalid scheme", raw: "https://example.org/work%2Fload", wantErr: "scheme must be spiffe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeSPIFFEID(tt.raw)
+			if tt.wantErr != "" {
+				var idErr *SPIFFEIDError
+				require.ErrorAs(t, err, &idErr)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// Canonical IDs round-trip unchanged and remain valid
+			again, err := CanonicalizeSPIFFEID(got)
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+			uri, err := url.Parse(got)
+			require.NoError(t, err)
+			assert.True(t, isValidSPIFFEID(uri))
+		})
+	}
+}
+
+func TestParseSPIFFEID(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		trustDomain string
+		path        string
+		wantErr     bool
+	}{
+		{
+			name:        "workload ID",
+			raw:         "spiffe://example.org/ns/prod/sa/web",
+			trustDomain: "example.org",
+			path:        "/ns/prod/sa/web",
+		},
+		{
+			name:        "trust domain only",
+			raw:         "spiffe://example.org",
+			trustDomain: "example.org",
+			path:        "",
+		},
+		{
+			name:    "empty string",
+			raw:     "",
+			wantErr: true,
+		},
+		{
+			name:    "unparsable URI",
+			raw:     "spiffe://example.org/%zz",
+			wantErr: true,
+		},
+		{
+			name:    "invalid scheme",
+			raw:     "https://example.org/workload",
+			wantErr: true,
+		},
+		{
+			name:    "path over length limit",
+			raw:     "spiffe://example.org/" + strings.Repeat("a", 2048),
+			wantErr: true,
+		},
+		{
+			name:    "dot-dot segment",
+			raw:     "spiffe://example.org/../workload",
+			wantErr: true,
+		},
+		{
+			name:    "encoded null byte",
+			raw:     "spiffe://example.org/%00",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustDomain, path, err := ParseSPIFFEID(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.trustDomain, trustDomain)
+			assert.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestValidateSPIFFEIDPath(t *testing.T) {
+	assert.NoError(t, ValidateSPIFFEIDPath(""))
+	assert.NoError(t, ValidateSPIFFEIDPath("/workload"))
+	assert.Error(t, ValidateSPIFFEIDPath("/"+strings.Repeat("a", 2048)))
+	assert.Error(t, ValidateSPIFFEIDPath("/a/../b"))
+	assert.Error(t, ValidateSPIFFEIDPath("/a%00b"))
+	assert.Error(t, ValidateSPIFFEIDPath("/a\x00b"))
+}
+
 func TestVerifyPeerCertificate(t *testing.T) {
 	config, err := NewTLSConfig()
 	require.NoError(t, err)
@@ -188,4 +960,466 @@ func TestVerifyPeerCertificate(t *testing.T) {
 		// Should pass SPIFFE ID validation
 		assert.NoError(t, err)
 	})
+
+	t.Run("single-cert chain", func(t *testing.T) {
+		assert.NoError(t, config.VerifyPeerCertificate([][]byte{newTestCASignedCert(t, "spiffe://example.org/workload")}, nil))
+	})
+
+	t.Run("three-cert chain", func(t *testing.T) {
+		chain := newTestCertChain(t, "spiffe://example.org/workload", "")
+		assert.NoError(t, config.VerifyPeerCertificate(chain, nil))
+	})
+
+	t.Run("SPIFFE ID only on the intermediate", func(t *testing.T) {
+		chain := newTestCertChain(t, "", "spiffe://example.org/workload")
+		err := config.VerifyPeerCertificate(chain, nil)
+		var idErr *SPIFFEIDError
+		require.ErrorAs(t, err, &idErr)
+		assert.Contains(t, err.Error(), "server certificate has no URI SANs")
+	})
+
+	t.Run("intermediate that did not issue the leaf", func(t *testing.T) {
+		chain := newTestCertChain(t, "spiffe://example.org/workload", "")
+		other := newTestCertChain(t, "spiffe://example.org/other", "")
+
+		err := config.VerifyPeerCertificate([][]byte{chain[0], other[1], other[2]}, nil)
+		var tlsErr *TLSConfigError
+		require.ErrorAs(t, err, &tlsErr)
+		assert.Contains(t, err.Error(), "presented certificate chain is inconsistent")
+	})
+
+	t.Run("verified chains", func(t *testing.T) {
+		rootConfig, err := NewTLSConfig(WithRootCAsFromFile(testCAFile))
+		require.NoError(t, err)
+
+		chain := newTestCertChain(t, "spiffe://example.org/workload", "")
+		parsed := make([]*x509.Certificate, len(chain))
+		for i, der := range chain {
+			parsed[i], err = x509.ParseCertificate(der)
+			require.NoError(t, err)
+		}
+		assert.NoError(t, rootConfig.VerifyPeerCertificate(chain, [][]*x509.Certificate{parsed}))
+
+		// A chain built for a different leaf is rejected
+		other, err := x509.ParseCertificate(newTestCASignedCert(t, "spiffe://example.org/other"))
+		require.NoError(t, err)
+		err = rootConfig.VerifyPeerCertificate(chain, [][]*x509.Certificate{{other, parsed[2]}})
+		var tlsErr *TLSConfigError
+		require.ErrorAs(t, err, &tlsErr)
+		assert.Contains(t, err.Error(), "verified chain does not start with the server certificate")
+	})
+}
+
+func TestAuthorizedSPIFFEIDOptions(t *testing.T) {
+	serverCert := newTestCASignedCert(t, "spiffe://example.org/server")
+
+	tests := []struct {
+		name    string
+		opts    []TLSOption
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "authorized SPIFFE ID matches",
+			opts: []TLSOption{WithAuthorizedSPIFFEID("spiffe://example.org/server")},
+		},
+		{
+			name:    "authorized SPIFFE ID mismatch",
+			opts:    []TLSOption{WithAuthorizedSPIFFEID("spiffe://example.org/other")},
+			wantErr: true,
+			errMsg:  "server SPIFFE ID [spiffe://example.org/server] is not authorized: expected one of [spiffe://example.org/other]",
+		},
+		{
+			name: "authorized SPIFFE IDs contains peer",
+			opts: []TLSOption{WithAuthorizedSPIFFEIDs("spiffe://example.org/other", "spiffe://example.org/server")},
+		},
+		{
+			name:    "authorized SPIFFE IDs does not contain peer",
+			opts:    []TLSOption{WithAuthorizedSPIFFEIDs("spiffe://example.org/a", "spiffe://example.org/b")},
+			wantErr: true,
+			errMsg:  "expected one of [spiffe://example.org/a spiffe://example.org/b]",
+		},
+		{
+			name: "authorized trust domain matches",
+			opts: []TLSOption{WithAuthorizedTrustDomain("example.org")},
+		},
+		{
+			name:    "authorized trust domain mismatch",
+			opts:    []TLSOption{WithAuthorizedTrustDomain("other.org")},
+			wantErr: true,
+			errMsg:  `expected member of trust domain "other.org"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(tt.opts...)
+			require.NoError(t, err)
+
+			err = config.VerifyPeerCertificate([][]byte{serverCert}, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("base SPIFFE validation still applies", func(t *testing.T) {
+		config, err := NewTLSConfig(WithAuthorizedTrustDomain("example.org"))
+		require.NoError(t, err)
+
+		err = config.VerifyPeerCertificate([][]byte{}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no server certificate presented")
+	})
+}
+
+func TestWithTrustDomainPin(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains []string
+		cert    []byte
+		wantGot []string
+	}{
+		{
+			name:    "allowed trust domain",
+			domains: []string{"example.org"},
+			cert:    newTestCASignedCert(t, "spiffe://example.org/server"),
+		},
+		{
+			name:    "one of several allowed trust domains",
+			domains: []string{"other.org", "example.org"},
+			cert:    newTestCASignedCert(t, "spiffe://example.org/server"),
+		},
+		{
+			name:    "unexpected trust domain",
+			domains: []string{"example.org"},
+			cert:    newTestCASignedCert(t, "spiffe://evil.com/backdoor"),
+			wantGot: []string{"evil.com"},
+		},
+		{
+			name:    "one SPIFFE ID outside the pinned trust domains",
+			domains: []string{"example.org"},
+			cert:    testSPIFFECerts["mixed trust domain"].Raw,
+			wantGot: []string{"example.org", "evil.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(WithTrustDomainPin(tt.domains...))
+			require.NoError(t, err)
+
+			err = config.VerifyPeerCertificate([][]byte{tt.cert}, nil)
+			if tt.wantGot == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var tdErr *TrustDomainError
+			require.ErrorAs(t, err, &tdErr)
+			assert.Equal(t, tt.wantGot, tdErr.Got)
+			assert.Equal(t, tt.domains, tdErr.Allowed)
+		})
+	}
+
+	t.Run("base SPIFFE validation still applies", func(t *testing.T) {
+		config, err := NewTLSConfig(WithTrustDomainPin("example.org"))
+		require.NoError(t, err)
+
+		err = config.VerifyPeerCertificate([][]byte{testSPIFFECerts["no URIs"].Raw}, nil)
+		assert.ErrorAs(t, err, new(*SPIFFEIDError))
+	})
+}
+
+func TestTLS13Only(t *testing.T) {
+	t.Run("config fields", func(t *testing.T) {
+		config, err := NewTLSConfig(WithTLS13Only())
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+		assert.Equal(t, uint16(tls.VersionTLS13), config.MaxVersion)
+		assert.Nil(t, config.CipherSuites)
+	})
+
+	t.Run("combined with cipher suites", func(t *testing.T) {
+		suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+		config, err := NewTLSConfig(WithTLS13Only(), WithCipherSuites(suites...))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "cipher suites cannot be configured in TLS 1.3-only mode")
+		assert.Nil(t, config)
+
+		config, err = NewTLSConfig(WithCipherSuites(suites...), WithTLS13Only())
+		assert.Error(t, err)
+		assert.Nil(t, config)
+	})
+
+	t.Run("TLS 1.2 server is rejected", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		uri, _ := url.Parse("spiffe://example.org/server")
+		certTemplate := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject: pkix.Name{
+				CommonName: "test",
+			},
+			NotBefore: time.Now(),
+			NotAfter:  time.Now().Add(365 * 24 * time.Hour),
+			URIs:      []*url.URL{uri},
+		}
+		certBytes, err := x509.CreateCertificate(rand.Reader, certTemplate, certTemplate, &key.PublicKey, key)
+		require.NoError(t, err)
+
+		// Mock server that only speaks TLS 1.2
+		serverConfig := &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{certBytes}, PrivateKey: key}},
+			MaxVersion:   tls.VersionTLS12,
+		}
+
+		clientConfig, err := NewTLSConfig(WithTLS13Only())
+		require.NoError(t, err)
+
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		go func() {
+			tls.Server(serverConn, serverConfig).Handshake()
+			serverConn.Close()
+		}()
+
+		err = tls.Client(clientConn, clientConfig).Handshake()
+		assert.Error(t, err)
+	})
+}
+
+func TestWithSNIOverride(t *testing.T) {
+	t.Run("sets server name", func(t *testing.T) {
+		config, err := NewTLSConfig(WithSNIOverride("spire-server.internal"))
+		require.NoError(t, err)
+		assert.Equal(t, "spire-server.internal", config.ServerName)
+	})
+
+	t.Run("composes with other options", func(t *testing.T) {
+		config, err := NewTLSConfig(
+			WithTLS13Only(),
+			WithSNIOverride("spire-server.internal"),
+			WithTrustDomainPin("example.org"),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "spire-server.internal", config.ServerName)
+		assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+		err = config.VerifyPeerCertificate([][]byte{testSPIFFECerts["single SPIFFE ID"].Raw}, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("last option wins", func(t *testing.T) {
+		config, err := NewTLSConfig(WithSNIOverride("first.internal"), WithSNIOverride("second.internal"))
+		require.NoError(t, err)
+		assert.Equal(t, "second.internal", config.ServerName)
+	})
+
+	t.Run("sent in ClientHello", func(t *testing.T) {
+		clientConfig, err := NewTLSConfig(WithSNIOverride("spire-server.internal"))
+		require.NoError(t, err)
+
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+		defer serverConn.Close()
+
+		serverName := make(chan string, 1)
+		go func() {
+			// Abort after reading the ClientHello; only the SNI is of interest
+			tls.Server(serverConn, &tls.Config{
+				GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+					serverName <- hello.ServerName
+					return nil, errors.New("stop")
+				},
+			}).Handshake()
+			serverConn.Close()
+		}()
+
+		tls.Client(clientConn, clientConfig).Handshake()
+		assert.Equal(t, "spire-server.internal", <-serverName)
+	})
+}
+
+func TestWithSessionTickets(t *testing.T) {
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{newTestCASignedKeyPair(t, "spiffe://example.org/spire/server")},
+	}
+
+	// connect performs a handshake over net.Pipe and reads the server's greeting,
+	// which makes the client process the session ticket sent after the handshake
+	connect := func(t *testing.T, clientConfig *tls.Config) (tls.ConnectionState, error) {
+		t.Helper()
+
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+
+		go func() {
+			defer serverConn.Close()
+			server := tls.Server(serverConn, serverConfig)
+			if err := server.Handshake(); err != nil {
+				return
+			}
+			server.Write([]byte("ok"))
+		}()
+
+		client := tls.Client(clientConn, clientConfig)
+		if err := client.Handshake(); err != nil {
+			return tls.ConnectionState{}, err
+		}
+		buf := make([]byte, 2)
+		if _, err := client.Read(buf); err != nil {
+			return tls.ConnectionState{}, err
+		}
+		return client.ConnectionState(), nil
+	}
+
+	t.Run("second handshake resumes the session", func(t *testing.T) {
+		verified := 0
+		countVerify := func(c *tls.Config) {
+			next := c.VerifyPeerCertificate
+			c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+				verified++
+				return next(rawCerts, verifiedChains)
+			}
+		}
+
+		clientConfig, err := NewTLSConfig(
+			WithSessionTickets(NewLRUSessionCache(8)),
+			WithSNIOverride("spire-server"),
+			WithAuthorizedSPIFFEID("spiffe://example.org/spire/server"),
+			countVerify,
+		)
+		require.NoError(t, err)
+
+		first, err := connect(t, clientConfig)
+		require.NoError(t, err)
+		assert.False(t, first.DidResume)
+
+		second, err := connect(t, clientConfig)
+		require.NoError(t, err)
+		assert.True(t, second.DidResume, "second handshake should reuse the session ticket")
+		assert.Equal(t, 2, verified, "VerifyPeerCertificate should run on the resumed session")
+	})
+
+	t.Run("resumed session is rejected for an unauthorized SPIFFE ID", func(t *testing.T) {
+		cache := NewLRUSessionCache(8)
+
+		clientConfig, err := NewTLSConfig(WithSessionTickets(cache), WithSNIOverride("spire-server"))
+		require.NoError(t, err)
+		_, err = connect(t, clientConfig)
+		require.NoError(t, err)
+
+		// A config sharing the cache but authorizing another ID must not accept the cached session
+		pinned, err := NewTLSConfig(
+			WithSessionTickets(cache),
+			WithSNIOverride("spire-server"),
+			WithAuthorizedSPIFFEID("spiffe://example.org/other"),
+		)
+		require.NoError(t, err)
+		_, err = connect(t, pinned)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "spiffe://example.org/other")
+	})
+
+	t.Run("without session tickets", func(t *testing.T) {
+		clientConfig, err := NewTLSConfig(WithSNIOverride("spire-server"))
+		require.NoError(t, err)
+		assert.Nil(t, clientConfig.ClientSessionCache)
+
+		_, err = connect(t, clientConfig)
+		require.NoError(t, err)
+		second, err := connect(t, clientConfig)
+		require.NoError(t, err)
+		assert.False(t, second.DidResume)
+	})
+}
+
+func TestStartExpiryWatcher(t *testing.T) {
+	// x509 validity has second precision, so short expiries are set on a parsed leaf
+	newExpiringCert := func(expiresIn time.Duration) *tls.Certificate {
+		return &tls.Certificate{Leaf: &x509.Certificate{NotAfter: time.Now().Add(expiresIn)}}
+	}
+
+	t.Run("fires before expiry", func(t *testing.T) {
+		cert := newExpiringCert(200 * time.Millisecond)
+		fired := make(chan time.Time, 1)
+
+		stop := StartExpiryWatcher(context.Background(), cert, 100*time.Millisecond, func(expiresAt time.Time) {
+			fired <- expiresAt
+		})
+		defer stop()
+
+		select {
+		case expiresAt := <-fired:
+			assert.Equal(t, cert.Leaf.NotAfter, expiresAt)
+			assert.True(t, time.Now().Before(expiresAt), "callback should fire before the certificate expires")
+		case <-time.After(200 * time.Millisecond):
+			t.Fatal("onExpiringSoon was not called within 200ms")
+		}
+	})
+
+	t.Run("already within warning window", func(t *testing.T) {
+		// newTestCASignedKeyPair leaves Leaf unset, so the expiry comes from the DER certificate
+		cert := newTestCASignedKeyPair(t, "spiffe://example.org/workload")
+		fired := make(chan time.Time, 1)
+		stop := StartExpiryWatcher(context.Background(), &cert, 2*365*24*time.Hour, func(expiresAt time.Time) {
+			fired <- expiresAt
+		})
+		defer stop()
+
+		select {
+		case expiresAt := <-fired:
+			assert.WithinDuration(t, time.Now().Add(365*24*time.Hour), expiresAt, time.Minute)
+		case <-time.After(time.Second):
+			t.Fatal("onExpiringSoon was not called for a certificate already in the warning window")
+		}
+	})
+
+	t.Run("stop cancels", func(t *testing.T) {
+		fired := make(chan time.Time, 1)
+		stop := StartExpiryWatcher(context.Background(), newExpiringCert(200*time.Millisecond), 100*time.Millisecond, func(expiresAt time.Time) {
+			fired <- expiresAt
+		})
+		stop()
+		stop()
+
+		select {
+		case <-fired:
+			t.Fatal("onExpiringSoon was called after stop")
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+
+	t.Run("context cancels", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		fired := make(chan time.Time, 1)
+		stop := StartExpiryWatcher(ctx, newExpiringCert(200*time.Millisecond), 100*time.Millisecond, func(expiresAt time.Time) {
+			fired <- expiresAt
+		})
+		defer stop()
+		cancel()
+
+		select {
+		case <-fired:
+			t.Fatal("onExpiringSoon was called after the context was cancelled")
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		for _, cert := range []*tls.Certificate{nil, {}, {Certificate: [][]byte{[]byte("garbage")}}} {
+			stop := StartExpiryWatcher(context.Background(), cert, time.Hour, func(time.Time) {
+				t.Error("onExpiringSoon should not be called without a parsable certificate")
+			})
+			stop()
+		}
+	})
 }
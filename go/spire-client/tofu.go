@@ -0,0 +1,118 @@
+package spireclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// tofuState is the JSON content of a WithTOFU state file
+type tofuState struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+// WithTOFU pins the server to the first leaf certificate it presents (trust on first use)
+// The SHA-256 fingerprint of that certificate is written to stateFile, and later connections are rejected
+// if the server presents a different certificate. Intended for development without a distributed trust bundle
+func WithTOFU(stateFile string) TLSOption {
+	var mu sync.Mutex
+
+	return func(c *tls.Config) {
+		next := c.VerifyPeerCertificate
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if next != nil {
+				if err := next(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+			}
+
+			if len(rawCerts) == 0 {
+				return &TLSConfigError{Err: errors.New("no server certificate presented")}
+			}
+
+			sum := sha256.Sum256(rawCerts[0])
+			fingerprint := hex.EncodeToString(sum[:])
+
+			// Serialize handshakes so concurrent first connections pin a single certificate
+			mu.Lock()
+			defer mu.Unlock()
+
+			pinned, err := readTOFUPin(stateFile)
+			if errors.Is(err, os.ErrNotExist) {
+				return writeTOFUPin(stateFile, fingerprint)
+			}
+			if err != nil {
+				return err
+			}
+
+			if !strings.EqualFold(pinned, fingerprint) {
+				return &TLSConfigError{Err: fmt.Errorf("server certificate fingerprint %s does not match the pinned fingerprint %s in %s", fingerprint, pinned, stateFile)}
+			}
+			return nil
+		}
+	}
+}
+
+// ClearTOFUPin removes the pin recorded by WithTOFU so the next connection is trusted again
+// Clearing a state file that does not exist is not an error
+func ClearTOFUPin(stateFile string) error {
+	if err := os.Remove(stateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear TOFU pin: %w", err)
+	}
+	return nil
+}
+
+// readTOFUPin returns the fingerprint stored in stateFile
+// The returned error wraps os.ErrNotExist when no pin has been recorded yet
+func readTOFUPin(stateFile string) (string, error) {
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err != nil {
+		return "", &TLSConfigError{Err: fmt.Errorf("failed to read TOFU state file: %w", err)}
+	}
+
+	var state tofuState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", &TLSConfigError{Err: fmt.Errorf("failed to parse TOFU state file %s: %w", stateFile, err)}
+	}
+	if state.Fingerprint == "" {
+		return "", &TLSConfigError{Err: fmt.Errorf("TOFU state file %s has no fingerprint", stateFile)}
+	}
+	return state.Fingerprint, nil
+}
+
+// writeTOFUPin records fingerprint in stateFile, replacing it atomically so a reader never sees a partial pin
+func writeTOFUPin(stateFile, fingerprint string) error {
+	data, err := json.Marshal(tofuState{Fingerprint: fingerprint})
+	if err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("failed to encode TOFU state: %w", err)}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(stateFile), filepath.Base(stateFile)+".tmp-*")
+	if err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("failed to write TOFU state file: %w", err)}
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return &TLSConfigError{Err: fmt.Errorf("failed to write TOFU state file: %w", err)}
+	}
+	if err := tmp.Close(); err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("failed to write TOFU state file: %w", err)}
+	}
+	if err := os.Rename(tmp.Name(), stateFile); err != nil {
+		return &TLSConfigError{Err: fmt.Errorf("failed to write TOFU state file: %w", err)}
+	}
+	return nil
+}
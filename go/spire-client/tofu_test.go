@@ -0,0 +1,117 @@
+package spireclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tofuHandshake connects a client using clientConfig to a server presenting serverCert over net.Pipe
+func tofuHandshake(t *testing.T, clientConfig *tls.Config, serverCert tls.Certificate) error {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{serverCert}}).Handshake()
+	}()
+
+	return tls.Client(clientConn, clientConfig).Handshake()
+}
+
+func TestWithTOFU(t *testing.T) {
+	serverCert := newTestCASignedKeyPair(t, "spiffe://example.org/spire/server")
+	impostorCert := newTestCASignedKeyPair(t, "spiffe://example.org/spire/server")
+
+	sum := sha256.Sum256(serverCert.Certificate[0])
+	wantFingerprint := hex.EncodeToString(sum[:])
+
+	newConfig := func(t *testing.T, stateFile string) *tls.Config {
+		t.Helper()
+		config, err := NewTLSConfig(WithTOFU(stateFile))
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("first connection pins the certificate", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "tofu.json")
+
+		require.NoError(t, tofuHandshake(t, newConfig(t, stateFile), serverCert))
+
+		data, err := os.ReadFile(stateFile)
+		require.NoError(t, err)
+		var state map[string]string
+		require.NoError(t, json.Unmarshal(data, &state))
+		assert.Equal(t, map[string]string{"fingerprint": wantFingerprint}, state)
+	})
+
+	t.Run("second connection with the same certificate", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "tofu.json")
+		require.NoError(t, tofuHandshake(t, newConfig(t, stateFile), serverCert))
+
+		// A new config reads the pin back from the state file
+		assert.NoError(t, tofuHandshake(t, newConfig(t, stateFile), serverCert))
+	})
+
+	t.Run("second connection with a different certificate", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "tofu.json")
+		config := newConfig(t, stateFile)
+		require.NoError(t, tofuHandshake(t, config, serverCert))
+
+		err := tofuHandshake(t, config, impostorCert)
+		var tlsErr *TLSConfigError
+		require.ErrorAs(t, err, &tlsErr)
+		assert.Contains(t, err.Error(), "does not match the pinned fingerprint "+wantFingerprint)
+	})
+
+	t.Run("ClearTOFUPin trusts the next certificate", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "tofu.json")
+		config := newConfig(t, stateFile)
+		require.NoError(t, tofuHandshake(t, config, serverCert))
+
+		require.NoError(t, ClearTOFUPin(stateFile))
+		assert.NoFileExists(t, stateFile)
+		require.NoError(t, tofuHandshake(t, config, impostorCert))
+
+		assert.Error(t, tofuHandshake(t, config, serverCert), "the new certificate should now be pinned")
+		assert.NoError(t, ClearTOFUPin(filepath.Join(t.TempDir(), "missing.json")))
+	})
+
+	t.Run("invalid state file", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"malformed JSON":    "{",
+			"empty fingerprint": `{"fingerprint": ""}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				stateFile := filepath.Join(t.TempDir(), "tofu.json")
+				require.NoError(t, os.WriteFile(stateFile, []byte(content), 0600))
+
+				err := tofuHandshake(t, newConfig(t, stateFile), serverCert)
+				var tlsErr *TLSConfigError
+				require.ErrorAs(t, err, &tlsErr)
+
+				data, err := os.ReadFile(stateFile)
+				require.NoError(t, err)
+				assert.Equal(t, content, string(data), "an unreadable pin must not be overwritten")
+			})
+		}
+	})
+
+	t.Run("SPIFFE validation still applies", func(t *testing.T) {
+		stateFile := filepath.Join(t.TempDir(), "tofu.json")
+
+		err := tofuHandshake(t, newConfig(t, stateFile), newTestCASignedKeyPair(t, "https://example.org/not-spiffe"))
+		require.Error(t, err)
+		assert.NoFileExists(t, stateFile, "a rejected certificate must not be pinned")
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// OpenFGAクライアントのオプション
+type OpenFGAOption func(*OpenFGAClient)
+
+// 権限チェックの結果を監査ログとして出力するオプション
+// 許可はINFO、拒否はWARN、エラーはERRORレベルで記録する
+func WithAuditLogger(logger *slog.Logger) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.auditLogger = logger
+	}
+}
+
+// 1回の権限チェックを監査ログに記録
+func (c *OpenFGAClient) audit(ctx context.Context, user, relation, object string, allowed bool, latency time.Duration, err error) {
+	if c.auditLogger == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	msg := "permission allowed"
+	switch {
+	case err != nil:
+		level = slog.LevelError
+		msg = "permission check failed"
+	case !allowed:
+		level = slog.LevelWarn
+		msg = "permission denied"
+	}
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	c.auditLogger.LogAttrs(ctx, level, msg,
+		slog.String("user", user),
+		slog.String("relation", relation),
+		slog.String("object", object),
+		slog.Bool("allowed", allowed),
+		slog.Float64("latency_ms", float64(latency)/float64(time.Millisecond)),
+		slog.String("error", errMsg),
+		slog.String("store_id", c.storeID),
+	)
+}
+
+// --audit-outputに書き出す1件の権限チェック結果（JSON Lines形式の1行）
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Relation  string    `json:"relation"`
+	Object    string    `json:"object"`
+	Allowed   bool      `json:"allowed"`
+	LatencyNS int64     `json:"latency_ns"`
+	StoreID   string    `json:"store_id"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// 権限チェック結果をJSON Lines形式で書き出す監査出力
+// SIEMなどへの取り込みを想定し、1件ごとに1行のJSONオブジェクトを追記する
+type auditOutput struct {
+	enc     *json.Encoder
+	closer  io.Closer
+	storeID string
+	// 書き込みエラーの出力先。権限チェックの出力は中断しない
+	errOut io.Writer
+}
+
+// wにJSON Linesを書き出す監査出力を作成
+func newAuditOutput(w io.Writer, storeID string) *auditOutput {
+	return &auditOutput{
+		enc:     json.NewEncoder(w),
+		storeID: storeID,
+		errOut:  os.Stderr,
+	}
+}
+
+// pathを追記モードで開き、監査出力を作成する
+func openAuditOutput(path, storeID string) (*auditOutput, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit output %s: %v", path, err)
+	}
+
+	a := newAuditOutput(f, storeID)
+	a.closer = f
+	return a, nil
+}
+
+// 1件の権限チェック結果を書き出す。nilの場合は何もしない
+// 書き込みに失敗してもエラーは返さず、errOutに記録する
+func (a *auditOutput) write(check CheckRequest, allowed bool, latency time.Duration, checkErr error) {
+	if a == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Timestamp: time.Now().UTC(),
+		User:      check.User,
+		Relation:  check.Relation,
+		Object:    check.Object,
+		Allowed:   allowed,
+		LatencyNS: latency.Nanoseconds(),
+		StoreID:   a.storeID,
+	}
+	if checkErr != nil {
+		record.Error = checkErr.Error()
+	}
+
+	if err := a.enc.Encode(record); err != nil {
+		fmt.Fprintf(a.errOut, "failed to write audit record for %s %s %s: %v\n", check.User, check.Relation, check.Object, err)
+	}
+}
+
+// 監査出力のファイルを閉じる
+func (a *auditOutput) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 権限チェックリクエストの検証エラー
+type ValidationError struct {
+	// 不正なフィールド名（user・relation・object）
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// 検証済みの権限チェックリクエストを作成
+// userとobjectは "type:id" 形式、relationは空でない必要がある
+func NewCheckRequest(user, relation, object string) (CheckRequest, error) {
+	req := CheckRequest{User: user, Relation: relation, Object: object}
+	if err := req.Validate(); err != nil {
+		return CheckRequest{}, err
+	}
+	return req, nil
+}
+
+// リクエストの各フィールドを検証し、不正な場合は *ValidationError を返す
+func (r CheckRequest) Validate() error {
+	if err := validateTypedID("user", r.User); err != nil {
+		return err
+	}
+	if r.Relation == "" {
+		return &ValidationError{Field: "relation", Value: r.Relation, Reason: "must not be empty"}
+	}
+	return validateTypedID("object", r.Object)
+}
+
+// valueが "type:id" 形式であることを検証
+func validateTypedID(field, value string) error {
+	if value == "" {
+		return &ValidationError{Field: field, Value: value, Reason: "must not be empty"}
+	}
+	typ, id, ok := strings.Cut(value, ":")
+	if !ok || typ == "" || id == "" {
+		return &ValidationError{Field: field, Value: value, Reason: "must be in type:id format"}
+	}
+	return nil
+}
+
+// CheckRequestを段階的に組み立てるビルダー
+type CheckRequestBuilder struct {
+	req CheckRequest
+}
+
+func NewCheckRequestBuilder() *CheckRequestBuilder {
+	return &CheckRequestBuilder{}
+}
+
+func (b *CheckRequestBuilder) User(user string) *CheckRequestBuilder {
+	b.req.User = user
+	return b
+}
+
+func (b *CheckRequestBuilder) Relation(relation string) *CheckRequestBuilder {
+	b.req.Relation = relation
+	return b
+}
+
+func (b *CheckRequestBuilder) Object(object string) *CheckRequestBuilder {
+	b.req.Object = object
+	return b
+}
+
+// NewCheckRequestと同じ検証を行ってリクエストを返す
+func (b *CheckRequestBuilder) Build() (CheckRequest, error) {
+	return NewCheckRequest(b.req.User, b.req.Relation, b.req.Object)
+}
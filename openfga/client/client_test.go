@@ -1,11 +1,38 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/hiyosi/sandbox/openfga/client/test/mockfga"
+	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockOpenFGAClient はテスト用のモッククライアント
@@ -13,21 +40,17 @@ type MockOpenFGAClient struct {
 	mock.Mock
 }
 
-func (m *MockOpenFGAClient) CheckPermission(ctx context.Context, user, relation, object string) (bool, error) {
+func (m *MockOpenFGAClient) CheckPermission(ctx context.Context, user, relation, object string, opts ...CheckOption) (bool, error) {
 	args := m.Called(ctx, user, relation, object)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockOpenFGAClient) BatchCheck(ctx context.Context, checks []CheckRequest) ([]bool, error) {
+func (m *MockOpenFGAClient) BatchCheck(ctx context.Context, checks []CheckRequest, opts ...CheckOption) ([]bool, error) {
 	args := m.Called(ctx, checks)
 	return args.Get(0).([]bool), args.Error(1)
 }
 
-// PermissionChecker インターフェース
-type PermissionChecker interface {
-	CheckPermission(ctx context.Context, user, relation, object string) (bool, error)
-	BatchCheck(ctx context.Context, checks []CheckRequest) ([]bool, error)
-}
+var _ PermissionChecker = (*MockOpenFGAClient)(nil)
 
 func TestPermissionChecks(t *testing.T) {
 	tests := []struct {
@@ -317,4 +340,1835 @@ func TestPermissionMatrix(t *testing.T) {
 	}
 
 	mockClient.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+// MockJWTSVIDFetcher はテスト用のJWT SVIDソース
+type MockJWTSVIDFetcher struct {
+	mock.Mock
+}
+
+func (m *MockJWTSVIDFetcher) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
+	args := m.Called(ctx, params)
+	svid, _ := args.Get(0).(*jwtsvid.SVID)
+	return svid, args.Error(1)
+}
+
+// 署名なしのJWT SVIDを作成
+func newTestJWTSVID(t *testing.T, id string, expiry time.Time) *jwtsvid.SVID {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	token := encode(map[string]string{"alg": "ES256", "typ": "JWT"}) + "." +
+		encode(map[string]interface{}{
+			"sub": "spiffe://example.org/openfga-client",
+			"aud": []string{"openfga"},
+			"exp": expiry.Unix(),
+			"jti": id,
+		}) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("signature"))
+
+	svid, err := jwtsvid.ParseInsecure(token, []string{"openfga"})
+	require.NoError(t, err)
+	return svid
+}
+
+func TestJWTSVIDTransport(t *testing.T) {
+	// 受信したAuthorizationヘッダーを記録するサーバー
+	var headers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	params := jwtsvid.Params{Audience: "openfga"}
+
+	t.Run("refreshes_svid_near_expiry", func(t *testing.T) {
+		headers = nil
+
+		// 1つ目のSVIDは更新閾値より早く期限切れになる
+		first := newTestJWTSVID(t, "first", time.Now().Add(10*time.Second))
+		second := newTestJWTSVID(t, "second", time.Now().Add(time.Hour))
+
+		fetcher := new(MockJWTSVIDFetcher)
+		fetcher.On("FetchJWTSVID", mock.Anything, params).Return(first, nil).Once()
+		fetcher.On("FetchJWTSVID", mock.Anything, params).Return(second, nil).Once()
+
+		httpClient := &http.Client{Transport: &jwtSVIDTransport{
+			base:     http.DefaultTransport,
+			source:   fetcher,
+			audience: "openfga",
+		}}
+
+		for i := 0; i < 2; i++ {
+			resp, err := httpClient.Get(server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		require.Len(t, headers, 2)
+		assert.Equal(t, "Bearer "+first.Marshal(), headers[0])
+		assert.Equal(t, "Bearer "+second.Marshal(), headers[1])
+		assert.NotEqual(t, headers[0], headers[1])
+		fetcher.AssertExpectations(t)
+	})
+
+	t.Run("reuses_cached_svid", func(t *testing.T) {
+		headers = nil
+
+		svid := newTestJWTSVID(t, "cached", time.Now().Add(time.Hour))
+
+		fetcher := new(MockJWTSVIDFetcher)
+		fetcher.On("FetchJWTSVID", mock.Anything, params).Return(svid, nil).Once()
+
+		httpClient := &http.Client{Transport: &jwtSVIDTransport{
+			base:     http.DefaultTransport,
+			source:   fetcher,
+			audience: "openfga",
+		}}
+
+		for i := 0; i < 3; i++ {
+			resp, err := httpClient.Get(server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		require.Len(t, headers, 3)
+		for _, header := range headers {
+			assert.Equal(t, "Bearer "+svid.Marshal(), header)
+		}
+		fetcher.AssertExpectations(t)
+	})
+
+	t.Run("fetch_error", func(t *testing.T) {
+		fetcher := new(MockJWTSVIDFetcher)
+		fetcher.On("FetchJWTSVID", mock.Anything, params).Return(nil, assert.AnError)
+
+		httpClient := &http.Client{Transport: &jwtSVIDTransport{
+			base:     http.DefaultTransport,
+			source:   fetcher,
+			audience: "openfga",
+		}}
+
+		_, err := httpClient.Get(server.URL)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to fetch JWT SVID")
+	})
+}
+
+func TestNewOpenFGAClientFromEnv(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	caCertPath := filepath.Join(t.TempDir(), "ca.crt")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caCertPath, caCert, 0600))
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		missingVar string
+		errMsg     string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{envStoreID: "store", envAPIToken: "token"},
+		},
+		{
+			name: "all_set",
+			env: map[string]string{
+				envAPIURL:     "https://openfga.example.org:8443",
+				envStoreID:    "store",
+				envAPIToken:   "token",
+				envCACertPath: caCertPath,
+				envInsecure:   "false",
+			},
+		},
+		{
+			name:       "missing_store_id",
+			env:        map[string]string{envAPIToken: "token"},
+			missingVar: envStoreID,
+		},
+		{
+			name:       "missing_api_token",
+			env:        map[string]string{envStoreID: "store"},
+			missingVar: envAPIToken,
+		},
+		{
+			name:   "invalid_api_url",
+			env:    map[string]string{envAPIURL: "openfga:8443", envStoreID: "store", envAPIToken: "token"},
+			errMsg: "invalid OPENFGA_API_URL",
+		},
+		{
+			name:   "invalid_insecure",
+			env:    map[string]string{envStoreID: "store", envAPIToken: "token", envInsecure: "maybe"},
+			errMsg: "invalid OPENFGA_INSECURE",
+		},
+		{
+			name:   "missing_ca_cert",
+			env:    map[string]string{envStoreID: "store", envAPIToken: "token", envCACertPath: "/nonexistent/ca.crt"},
+			errMsg: "failed to read CA certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 外部環境の値が混ざらないように全て空にしてから設定する
+			for _, name := range []string{envAPIURL, envStoreID, envAPIToken, envCACertPath, envInsecure} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			c, err := NewOpenFGAClientFromEnv()
+
+			switch {
+			case tt.missingVar != "":
+				var missing *MissingEnvVarError
+				require.True(t, errors.As(err, &missing))
+				assert.Equal(t, tt.missingVar, missing.Name)
+			case tt.errMsg != "":
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.env[envStoreID], c.storeID)
+			}
+		})
+	}
+}
+
+const testStoreID = "01JBQF9Z8P9QX1X1X1X1X1X1X1"
+
+// user:alice は許可、user:error はエラー、それ以外は拒否を返すOpenFGA Check API
+func newCheckAPIServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			TupleKey struct {
+				User string `json:"user"`
+			} `json:"tuple_key"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch body.TupleKey.User {
+		case "user:error":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"code": "validation_error", "message": "invalid user"})
+		default:
+			json.NewEncoder(w).Encode(map[string]bool{"allowed": body.TupleKey.User == "user:alice"})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuditLogger(t *testing.T) {
+	const storeID = testStoreID
+	server := newCheckAPIServer(t)
+
+	tests := []struct {
+		name      string
+		user      string
+		wantLevel string
+		allowed   bool
+		wantErr   bool
+	}{
+		{name: "allowed", user: "user:alice", wantLevel: "INFO", allowed: true},
+		{name: "denied", user: "user:bob", wantLevel: "WARN", allowed: false},
+		{name: "error", user: "user:error", wantLevel: "ERROR", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			c, err := NewOpenFGAClient(server.URL, storeID, "token", WithAuditLogger(logger))
+			require.NoError(t, err)
+
+			allowed, err := c.CheckPermission(context.Background(), tt.user, "can_read", "resource:public-data")
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.allowed, allowed)
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.Equal(t, tt.wantLevel, entry["level"])
+			assert.Equal(t, tt.user, entry["user"])
+			assert.Equal(t, "can_read", entry["relation"])
+			assert.Equal(t, "resource:public-data", entry["object"])
+			assert.Equal(t, tt.allowed, entry["allowed"])
+			assert.Equal(t, storeID, entry["store_id"])
+			assert.Contains(t, entry, "latency_ms")
+			if tt.wantErr {
+				assert.NotEmpty(t, entry["error"])
+			} else {
+				assert.Equal(t, "", entry["error"])
+			}
+		})
+	}
+
+	t.Run("batch_check", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+		c, err := NewOpenFGAClient(server.URL, storeID, "token", WithAuditLogger(logger))
+		require.NoError(t, err)
+
+		results, err := c.BatchCheck(context.Background(), []CheckRequest{
+			{"user:alice", "can_read", "resource:public-data"},
+			{"user:bob", "can_read", "resource:public-data"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, results)
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		assert.Len(t, lines, 2)
+	})
+}
+
+func TestBatchCheckWithContexts(t *testing.T) {
+	server := newCheckAPIServer(t)
+	c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+	require.NoError(t, err)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("cancelled_extra_context", func(t *testing.T) {
+		results, err := c.BatchCheckWithContexts(context.Background(), []ContextualCheckRequest{
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}},
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:sensitive-data"}, ExtraContext: cancelled},
+			{CheckRequest: CheckRequest{"user:bob", "can_read", "resource:public-data"}, ExtraContext: context.Background()},
+		})
+		require.Error(t, err)
+		assert.Equal(t, []bool{true, false, false}, results)
+
+		// キャンセルしたチェックのみがエラーになる
+		var checkErr *CheckError
+		require.True(t, errors.As(err, &checkErr))
+		assert.Equal(t, 1, checkErr.Index)
+		assert.Equal(t, "resource:sensitive-data", checkErr.Object)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 1)
+	})
+
+	t.Run("expired_extra_deadline", func(t *testing.T) {
+		expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		results, err := c.BatchCheckWithContexts(context.Background(), []ContextualCheckRequest{
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}, ExtraContext: expired},
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, []bool{false, true}, results)
+
+		var checkErr *CheckError
+		require.True(t, errors.As(err, &checkErr))
+		assert.Equal(t, 0, checkErr.Index)
+	})
+
+	t.Run("cancelled_parent_context", func(t *testing.T) {
+		results, err := c.BatchCheckWithContexts(cancelled, []ContextualCheckRequest{
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}, ExtraContext: context.Background()},
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, []bool{false, false}, results)
+		assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+	})
+
+	t.Run("all_succeed", func(t *testing.T) {
+		results, err := c.BatchCheckWithContexts(context.Background(), []ContextualCheckRequest{
+			{CheckRequest: CheckRequest{"user:alice", "can_read", "resource:public-data"}, ExtraContext: context.Background()},
+			{CheckRequest: CheckRequest{"user:bob", "can_read", "resource:public-data"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, results)
+	})
+}
+
+// 指定した拡張子の一時設定ファイルを作成してパスを返す
+func writeConfigFile(t *testing.T, pattern, content string) string {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), pattern)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	want := &OpenFGAConfig{
+		APIURL:             "https://openfga.example.org:8443",
+		StoreID:            testStoreID,
+		APIToken:           "token",
+		CACertPath:         "/opt/certs/ca.crt",
+		InsecureSkipVerify: true,
+		TimeoutSeconds:     10,
+		PoolSize:           20,
+		RetryMaxAttempts:   5,
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		content string
+		want    *OpenFGAConfig
+		errMsg  string
+	}{
+		{
+			name:    "json",
+			pattern: "config-*.json",
+			content: `{
+  "api_url": "https://openfga.example.org:8443",
+  "store_id": "01JBQF9Z8P9QX1X1X1X1X1X1X1",
+  "api_token": "token",
+  "ca_cert_path": "/opt/certs/ca.crt",
+  "insecure_skip_verify": true,
+  "timeout_seconds": 10,
+  "pool_size": 20,
+  "retry_max_attempts": 5
+}`,
+			want: want,
+		},
+		{
+			name:    "yaml",
+			pattern: "config-*.yaml",
+			content: `api_url: https://openfga.example.org:8443
+store_id: 01JBQF9Z8P9QX1X1X1X1X1X1X1
+api_token: token
+ca_cert_path: /opt/certs/ca.crt
+insecure_skip_verify: true
+timeout_seconds: 10
+pool_size: 20
+retry_max_attempts: 5
+`,
+			want: want,
+		},
+		{
+			name:    "yml_defaults",
+			pattern: "config-*.yml",
+			content: "store_id: 01JBQF9Z8P9QX1X1X1X1X1X1X1\napi_token: token\n",
+			want: &OpenFGAConfig{
+				APIURL:         defaultAPIURL,
+				StoreID:        testStoreID,
+				APIToken:       "token",
+				TimeoutSeconds: defaultTimeoutSeconds,
+			},
+		},
+		{
+			name:    "unsupported_extension",
+			pattern: "config-*.toml",
+			content: "store_id = \"store\"",
+			errMsg:  "unsupported config file extension",
+		},
+		{
+			name:    "unknown_field",
+			pattern: "config-*.json",
+			content: `{"store_id": "store", "api_token": "token", "storeid": "typo"}`,
+			errMsg:  "failed to parse config file",
+		},
+		{
+			name:    "missing_store_id",
+			pattern: "config-*.yaml",
+			content: "api_token: token\n",
+			errMsg:  "store_id is required",
+		},
+		{
+			name:    "too_many_retries",
+			pattern: "config-*.yaml",
+			content: "store_id: store\napi_token: token\nretry_max_attempts: 17\n",
+			errMsg:  "retry_max_attempts must be between 0 and 16",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigFromFile(writeConfigFile(t, tt.pattern, tt.content))
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+
+	t.Run("not_found", func(t *testing.T) {
+		_, err := LoadConfigFromFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read config file")
+	})
+}
+
+func TestNewOpenFGAClientFromConfig(t *testing.T) {
+	server := newCheckAPIServer(t)
+
+	path := writeConfigFile(t, "config-*.yaml", fmt.Sprintf(
+		"api_url: %s\nstore_id: %s\napi_token: token\ntimeout_seconds: 5\npool_size: 4\nretry_max_attempts: 2\n",
+		server.URL, testStoreID))
+	cfg, err := LoadConfigFromFile(path)
+	require.NoError(t, err)
+
+	c, err := NewOpenFGAClientFromConfig(cfg)
+	require.NoError(t, err)
+
+	allowed, err := c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	t.Run("invalid_config", func(t *testing.T) {
+		_, err := NewOpenFGAClientFromConfig(&OpenFGAConfig{APIURL: server.URL, StoreID: testStoreID})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "api_token is required")
+	})
+}
+
+func TestRunPermissionTests(t *testing.T) {
+	newChecker := func() *MockOpenFGAClient {
+		m := new(MockOpenFGAClient)
+		m.On("CheckPermission", mock.Anything, "user:alice", "can_read", "resource:public-data").Return(true, nil)
+		m.On("CheckPermission", mock.Anything, "user:bob", "can_read", "resource:sensitive-data").Return(false, assert.AnError)
+		m.On("CheckPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+		return m
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := runPermissionTests(context.Background(), newChecker(), outputJSON, &stdout, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 of 7 permission checks failed")
+
+		var results []PermissionCheckResult
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+		require.Len(t, results, 7)
+		assert.Equal(t, PermissionCheckResult{User: "user:alice", Relation: "can_read", Object: "resource:public-data", Allowed: true}, results[0])
+		assert.Equal(t, PermissionCheckResult{User: "user:alice", Relation: "can_write", Object: "resource:public-data"}, results[1])
+		assert.Equal(t, "user:bob", results[2].User)
+		assert.False(t, results[2].Allowed)
+		assert.Equal(t, assert.AnError.Error(), results[2].Error)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := runPermissionTests(context.Background(), newChecker(), outputText, &stdout, nil)
+		require.Error(t, err)
+
+		assert.Contains(t, stdout.String(), "✅ ALLOWED: user:alice can_read resource:public-data")
+		assert.Contains(t, stdout.String(), "❌ DENIED: user:alice can_write resource:public-data")
+		assert.Contains(t, stdout.String(), "ERROR: user:bob can_read resource:sensitive-data")
+	})
+
+	t.Run("no_errors", func(t *testing.T) {
+		m := new(MockOpenFGAClient)
+		m.On("CheckPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+
+		var stdout bytes.Buffer
+		require.NoError(t, runPermissionTests(context.Background(), m, outputJSON, &stdout, nil))
+
+		var results []PermissionCheckResult
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+		assert.Len(t, results, 7)
+	})
+}
+
+// 監査出力ファイルをJSON Linesとして1行ずつ読み込む
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "line %d", len(records)+1)
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+// 常に失敗するio.Writer
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAuditOutput(t *testing.T) {
+	t.Run("json_lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		audit, err := openAuditOutput(path, testStoreID)
+		require.NoError(t, err)
+
+		before := time.Now().UTC()
+		audit.write(CheckRequest{"user:alice", "can_read", "resource:public-data"}, true, 1500*time.Microsecond, nil)
+		audit.write(CheckRequest{"user:alice", "can_write", "resource:public-data"}, false, time.Millisecond, nil)
+		audit.write(CheckRequest{"user:bob", "can_read", "resource:sensitive-data"}, false, 2*time.Millisecond, assert.AnError)
+		require.NoError(t, audit.Close())
+
+		records := readAuditRecords(t, path)
+		require.Len(t, records, 3)
+		assert.Equal(t, "user:alice", records[0].User)
+		assert.Equal(t, "can_read", records[0].Relation)
+		assert.Equal(t, "resource:public-data", records[0].Object)
+		assert.True(t, records[0].Allowed)
+		assert.Equal(t, int64(1500000), records[0].LatencyNS)
+		assert.Equal(t, testStoreID, records[0].StoreID)
+		assert.False(t, records[0].Timestamp.Before(before))
+		assert.False(t, records[1].Allowed)
+		assert.Empty(t, records[1].Error)
+		assert.Equal(t, assert.AnError.Error(), records[2].Error)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("appends", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		for i := 0; i < 2; i++ {
+			audit, err := openAuditOutput(path, testStoreID)
+			require.NoError(t, err)
+			audit.write(CheckRequest{"user:alice", "can_read", "resource:public-data"}, true, time.Millisecond, nil)
+			require.NoError(t, audit.Close())
+		}
+		assert.Len(t, readAuditRecords(t, path), 2)
+	})
+
+	t.Run("run_permission_tests", func(t *testing.T) {
+		m := new(MockOpenFGAClient)
+		m.On("CheckPermission", mock.Anything, "user:alice", "can_read", "resource:public-data").Return(true, nil)
+		m.On("CheckPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		audit, err := openAuditOutput(path, testStoreID)
+		require.NoError(t, err)
+
+		var stdout bytes.Buffer
+		require.NoError(t, runPermissionTests(context.Background(), m, outputText, &stdout, audit))
+		require.NoError(t, audit.Close())
+
+		records := readAuditRecords(t, path)
+		require.Len(t, records, 7)
+		assert.Equal(t, "user:alice", records[0].User)
+		assert.True(t, records[0].Allowed)
+		assert.Contains(t, stdout.String(), "✅ ALLOWED: user:alice can_read resource:public-data")
+	})
+
+	t.Run("write_error_does_not_abort", func(t *testing.T) {
+		m := new(MockOpenFGAClient)
+		m.On("CheckPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+
+		var stderr bytes.Buffer
+		audit := newAuditOutput(failingWriter{}, testStoreID)
+		audit.errOut = &stderr
+
+		var stdout bytes.Buffer
+		require.NoError(t, runPermissionTests(context.Background(), m, outputJSON, &stdout, audit))
+
+		var results []PermissionCheckResult
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+		assert.Len(t, results, 7)
+		assert.Contains(t, stderr.String(), "failed to write audit record for user:alice can_read resource:public-data: disk full")
+	})
+
+	t.Run("open_error", func(t *testing.T) {
+		_, err := openAuditOutput(filepath.Join(t.TempDir(), "missing", "audit.jsonl"), testStoreID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to open audit output")
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	server := newCheckAPIServer(t)
+
+	t.Run("current_rate", func(t *testing.T) {
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithRateLimit(2.5, 3))
+		require.NoError(t, err)
+		assert.Equal(t, 2.5, c.CurrentRate())
+
+		c, err = NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, c.CurrentRate())
+	})
+
+	t.Run("batch_check", func(t *testing.T) {
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithRateLimit(1, 1))
+		require.NoError(t, err)
+
+		checks := make([]CheckRequest, 5)
+		for i := range checks {
+			checks[i] = CheckRequest{User: "user:alice", Relation: "can_read", Object: fmt.Sprintf("resource:%d", i)}
+		}
+
+		start := time.Now()
+		results, err := c.BatchCheck(context.Background(), checks)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, true, true, true, true}, results)
+		// バースト1件の後、残り4件は1秒ごとに許可される
+		assert.GreaterOrEqual(t, time.Since(start), 4*time.Second-50*time.Millisecond)
+	})
+
+	t.Run("concurrent_checks", func(t *testing.T) {
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithRateLimit(10, 1))
+		require.NoError(t, err)
+
+		start := time.Now()
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				_, err := c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data")
+				errs <- err
+			}()
+		}
+		for i := 0; i < 5; i++ {
+			require.NoError(t, <-errs)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond-50*time.Millisecond)
+	})
+
+	t.Run("context_cancellation", func(t *testing.T) {
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithRateLimit(0.1, 1))
+		require.NoError(t, err)
+
+		// 最初のチェックでバーストを使い切る
+		_, err = c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err = c.BatchCheck(ctx, []CheckRequest{{User: "user:alice", Relation: "can_read", Object: "resource:public-data"}})
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+
+		_, err = c.CheckPermission(ctx, "user:alice", "can_read", "resource:public-data")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// go-joseでES256署名したJWTを生成する
+func newTestSignedJWT(t *testing.T, claims any) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestInspectJWTSVID(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	t.Run("valid", func(t *testing.T) {
+		token := newTestSignedJWT(t, jwt.Claims{
+			Subject:  "spiffe://example.org/openfga-client",
+			Audience: jwt.Audience{"openfga", "spire"},
+			Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+			IssuedAt: jwt.NewNumericDate(now),
+		})
+
+		info, err := InspectJWTSVID(token)
+		require.NoError(t, err)
+		assert.Equal(t, "spiffe://example.org/openfga-client", info.SPIFFEID)
+		assert.Equal(t, []string{"openfga", "spire"}, info.Audience)
+		assert.True(t, info.ExpiresAt.Equal(now.Add(5*time.Minute)))
+		assert.True(t, info.IssuedAt.Equal(now))
+		assert.False(t, info.IsExpired)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token := newTestSignedJWT(t, jwt.Claims{
+			Subject:  "spiffe://example.org/openfga-client",
+			Audience: jwt.Audience{"openfga"},
+			Expiry:   jwt.NewNumericDate(now.Add(-time.Minute)),
+		})
+
+		info, err := InspectJWTSVID(token)
+		require.NoError(t, err)
+		assert.True(t, info.IsExpired)
+		assert.True(t, info.IssuedAt.IsZero())
+	})
+
+	errorTests := []struct {
+		name   string
+		token  string
+		errMsg string
+	}{
+		{name: "malformed", token: "not-a-jwt", errMsg: "failed to parse JWT"},
+		{
+			name:   "missing sub",
+			token:  newTestSignedJWT(t, jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Minute))}),
+			errMsg: "JWT has no sub claim",
+		},
+		{
+			name:   "non-SPIFFE sub",
+			token:  newTestSignedJWT(t, jwt.Claims{Subject: "user:alice", Expiry: jwt.NewNumericDate(now.Add(time.Minute))}),
+			errMsg: "invalid SPIFFE ID in sub claim",
+		},
+		{
+			name:   "missing exp",
+			token:  newTestSignedJWT(t, jwt.Claims{Subject: "spiffe://example.org/openfga-client"}),
+			errMsg: "JWT has no exp claim",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := InspectJWTSVID(tt.token)
+			assert.ErrorContains(t, err, tt.errMsg)
+			assert.Nil(t, info)
+		})
+	}
+}
+
+func TestOpenFGAClientWithMockFGAServer(t *testing.T) {
+	server := mockfga.NewMockFGAServer(map[string]bool{
+		mockfga.Key("user:alice", "can_read", "resource:public-data"):    true,
+		mockfga.Key("user:alice", "can_read", "resource:sensitive-data"): false,
+		mockfga.Key("user:bob", "can_write", "resource:public-data"):     true,
+	})
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	t.Run("check_permission", func(t *testing.T) {
+		allowed, err := c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		allowed, err = c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:sensitive-data")
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("batch_check", func(t *testing.T) {
+		results, err := c.BatchCheck(context.Background(), []CheckRequest{
+			{"user:bob", "can_write", "resource:public-data"},
+			{"user:bob", "can_read", "resource:public-data"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, results)
+	})
+
+	// クライアントが送信したリクエストの内容を確認
+	checks := server.Checks()
+	require.Len(t, checks, 4)
+	assert.Equal(t, mockfga.CheckRequest{
+		StoreID:  testStoreID,
+		User:     "user:alice",
+		Relation: "can_read",
+		Object:   "resource:public-data",
+	}, checks[0])
+}
+
+func TestCheckConsistency(t *testing.T) {
+	server := mockfga.NewMockFGAServer(map[string]bool{
+		mockfga.Key("user:alice", "can_read", "resource:public-data"): true,
+	})
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts []CheckOption
+		want string
+	}{
+		{name: "default", want: ""},
+		{name: "higher_consistency", opts: []CheckOption{WithHigherConsistency()}, want: "HIGHER_CONSISTENCY"},
+		{name: "minimize_latency", opts: []CheckOption{WithMinimizeLatency()}, want: "MINIMIZE_LATENCY"},
+		{name: "last_option_wins", opts: []CheckOption{WithHigherConsistency(), WithMinimizeLatency()}, want: "MINIMIZE_LATENCY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(server.Checks())
+
+			allowed, err := c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data", tt.opts...)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+
+			checks := server.Checks()[before:]
+			require.Len(t, checks, 1)
+			assert.Equal(t, tt.want, checks[0].Consistency)
+		})
+	}
+
+	t.Run("batch_check", func(t *testing.T) {
+		before := len(server.Checks())
+
+		_, err := c.BatchCheck(context.Background(), []CheckRequest{
+			{"user:alice", "can_read", "resource:public-data"},
+			{"user:bob", "can_read", "resource:public-data"},
+		}, WithHigherConsistency())
+		require.NoError(t, err)
+
+		checks := server.Checks()[before:]
+		require.Len(t, checks, 2)
+		for _, check := range checks {
+			assert.Equal(t, "HIGHER_CONSISTENCY", check.Consistency)
+		}
+	})
+}
+
+func TestDebugServer(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	api := newCheckAPIServer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// user:slow のチェックは release が閉じられるまで応答しない
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("user:slow")) {
+			close(started)
+			<-release
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		api.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithDebugServer("127.0.0.1:0"))
+	require.NoError(t, err)
+	require.NotEmpty(t, c.DebugAddr())
+
+	getStats := func(t *testing.T) map[string]any {
+		t.Helper()
+
+		resp, err := http.Get("http://" + c.DebugAddr() + "/stats")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var stats map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+		return stats
+	}
+
+	assert.Equal(t, map[string]any{"in_flight_checks": 0.0, "total_checks": 0.0, "total_errors": 0.0}, getStats(t))
+
+	_, err = c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:public-data")
+	require.NoError(t, err)
+	_, err = c.CheckPermission(context.Background(), "user:error", "can_read", "resource:public-data")
+	require.Error(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.CheckPermission(context.Background(), "user:slow", "can_read", "resource:public-data")
+	}()
+	<-started
+
+	assert.Equal(t, map[string]any{"in_flight_checks": 1.0, "total_checks": 2.0, "total_errors": 1.0}, getStats(t))
+
+	close(release)
+	<-done
+	assert.Equal(t, ClientStats{InFlightChecks: 0, TotalChecks: 3, TotalErrors: 1}, c.Stats())
+
+	// Close()でデバッグ用サーバーが停止する
+	addr := c.DebugAddr()
+	require.NoError(t, c.Close())
+	_, err = http.Get("http://" + addr + "/stats")
+	assert.Error(t, err)
+}
+
+func TestDebugServerDisabled(t *testing.T) {
+	c, err := NewOpenFGAClient("http://localhost:8080", testStoreID, "token")
+	require.NoError(t, err)
+	assert.Empty(t, c.DebugAddr())
+	assert.NoError(t, c.Close())
+
+	_, err = NewOpenFGAClient("http://localhost:8080", testStoreID, "token", WithDebugServer("invalid-address"))
+	assert.ErrorContains(t, err, "failed to start debug server")
+}
+
+// testdata/model.json と同じ認可モデル
+func newTestAuthorizationModel() AuthorizationModel {
+	return AuthorizationModel{
+		TypeDefinitions: []TypeDefinition{
+			*NewTypeDefinition("user"),
+			*NewTypeDefinition("group").
+				WithRelation("member", "[user]"),
+			*NewTypeDefinition("folder").
+				WithRelation("viewer", "[user]"),
+			*NewTypeDefinition("document").
+				WithRelation("parent", "[folder]").
+				WithRelation("editor", "[user, group#member]").
+				WithRelation("viewer", "[user:*]", "editor", "viewer from parent").
+				WithRelation("can_delete", "editor"),
+		},
+	}
+}
+
+func TestAuthorizationModelToRequest(t *testing.T) {
+	want, err := os.ReadFile("testdata/model.json")
+	require.NoError(t, err)
+
+	req, err := newTestAuthorizationModel().toRequest()
+	require.NoError(t, err)
+	got, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+
+	errorTests := []struct {
+		name   string
+		model  AuthorizationModel
+		errMsg string
+	}{
+		{
+			name:   "empty type",
+			model:  AuthorizationModel{TypeDefinitions: []TypeDefinition{{}}},
+			errMsg: "type name is required",
+		},
+		{
+			name:   "no usersets",
+			model:  AuthorizationModel{TypeDefinitions: []TypeDefinition{*NewTypeDefinition("document").WithRelation("viewer")}},
+			errMsg: "invalid relation document#viewer",
+		},
+		{
+			name:   "invalid userset",
+			model:  AuthorizationModel{TypeDefinitions: []TypeDefinition{*NewTypeDefinition("document").WithRelation("viewer", "viewer of parent")}},
+			errMsg: `invalid userset "viewer of parent"`,
+		},
+		{
+			name:   "empty direct type",
+			model:  AuthorizationModel{TypeDefinitions: []TypeDefinition{*NewTypeDefinition("document").WithRelation("viewer", "[user,]")}},
+			errMsg: "empty type",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.model.toRequest()
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestWriteModelFromStruct(t *testing.T) {
+	server := mockfga.NewMockFGAServer(nil)
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	id, err := c.WriteModelFromStruct(context.Background(), newTestAuthorizationModel())
+	require.NoError(t, err)
+	assert.Equal(t, mockfga.ModelID(1), id)
+
+	want, err := os.ReadFile("testdata/model.json")
+	require.NoError(t, err)
+	models := server.AuthorizationModels()
+	require.Len(t, models, 1)
+	assert.JSONEq(t, string(want), string(models[0]))
+
+	// 不正なモデルはAPIを呼び出さずにエラーを返す
+	_, err = c.WriteModelFromStruct(context.Background(), AuthorizationModel{TypeDefinitions: []TypeDefinition{{}}})
+	assert.Error(t, err)
+	assert.Len(t, server.AuthorizationModels(), 1)
+}
+
+func TestDiffModels(t *testing.T) {
+	base := newTestAuthorizationModel()
+
+	t.Run("identical models", func(t *testing.T) {
+		other := newTestAuthorizationModel()
+		diff := DiffModels(&base, &other)
+		assert.Equal(t, ModelDiff{}, diff)
+		assert.True(t, diff.IsEmpty())
+	})
+
+	t.Run("userset order and spacing are ignored", func(t *testing.T) {
+		other := AuthorizationModel{
+			TypeDefinitions: []TypeDefinition{
+				*NewTypeDefinition("document").
+					WithRelation("parent", "[folder]").
+					WithRelation("editor", "[group#member,user]").
+					WithRelation("viewer", "viewer  from parent", "editor", "[user:*]").
+					WithRelation("can_delete", "editor"),
+				*NewTypeDefinition("folder").
+					WithRelation("viewer", "[user]"),
+				*NewTypeDefinition("group").
+					WithRelation("member", "[user]"),
+				*NewTypeDefinition("user"),
+			},
+		}
+		assert.True(t, DiffModels(&base, &other).IsEmpty())
+	})
+
+	t.Run("added, removed and changed", func(t *testing.T) {
+		other := AuthorizationModel{
+			TypeDefinitions: []TypeDefinition{
+				*NewTypeDefinition("user"),
+				*NewTypeDefinition("team").
+					WithRelation("member", "[user]"),
+				*NewTypeDefinition("folder").
+					WithRelation("viewer", "[user]"),
+				*NewTypeDefinition("document").
+					WithRelation("parent", "[folder]").
+					WithRelation("owner", "[user]").
+					WithRelation("editor", "[user, team#member]").
+					WithRelation("viewer", "[user:*]", "editor"),
+			},
+		}
+
+		assert.Equal(t, ModelDiff{
+			AddedTypes:   []string{"team"},
+			RemovedTypes: []string{"group"},
+			ChangedTypes: []TypeDiff{
+				{
+					Type:             "document",
+					AddedRelations:   []string{"owner"},
+					RemovedRelations: []string{"can_delete"},
+					ChangedRelations: []string{"editor", "viewer"},
+				},
+			},
+		}, DiffModels(&base, &other))
+
+		// 逆方向では追加と削除が入れ替わる
+		reverse := DiffModels(&other, &base)
+		assert.Equal(t, []string{"group"}, reverse.AddedTypes)
+		assert.Equal(t, []string{"team"}, reverse.RemovedTypes)
+		require.Len(t, reverse.ChangedTypes, 1)
+		assert.Equal(t, []string{"can_delete"}, reverse.ChangedTypes[0].AddedRelations)
+		assert.Equal(t, []string{"owner"}, reverse.ChangedTypes[0].RemovedRelations)
+	})
+
+	t.Run("nil model", func(t *testing.T) {
+		assert.Equal(t, ModelDiff{AddedTypes: []string{"document", "folder", "group", "user"}}, DiffModels(nil, &base))
+		assert.Equal(t, ModelDiff{RemovedTypes: []string{"document", "folder", "group", "user"}}, DiffModels(&base, nil))
+	})
+}
+
+func TestDiffModelsFromStore(t *testing.T) {
+	server := mockfga.NewMockFGAServer(nil)
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	idA, err := c.WriteModelFromStruct(ctx, newTestAuthorizationModel())
+	require.NoError(t, err)
+
+	updated := newTestAuthorizationModel()
+	updated.TypeDefinitions[3].WithRelation("owner", "[user]")
+	idB, err := c.WriteModelFromStruct(ctx, updated)
+	require.NoError(t, err)
+
+	// ストアから読み込んだモデルは書き込んだ構造体と同じものとして比較される
+	diff, err := DiffModelsFromStore(ctx, c, idA, idA)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty())
+
+	diff, err = DiffModelsFromStore(ctx, c, idA, idB)
+	require.NoError(t, err)
+	assert.Equal(t, ModelDiff{
+		ChangedTypes: []TypeDiff{{Type: "document", AddedRelations: []string{"owner"}}},
+	}, diff)
+
+	_, err = DiffModelsFromStore(ctx, c, idA, mockfga.ModelID(99))
+	assert.ErrorContains(t, err, "failed to read authorization model "+mockfga.ModelID(99))
+}
+
+func TestBatchCheckWithOptions(t *testing.T) {
+	api := newCheckAPIServer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// user:slow のチェックはクライアントが切断するまで応答しない
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("user:slow")) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		api.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+	require.NoError(t, err)
+
+	checks := []CheckRequest{
+		{"user:alice", "can_read", "resource:public-data"},
+		{"user:slow", "can_read", "resource:public-data"},
+		{"user:bob", "can_read", "resource:public-data"},
+	}
+
+	t.Run("per_check_timeout", func(t *testing.T) {
+		// SDKはタイムアウト後もリトライの待機を続けるため、応答を待ち続けていないことだけを確認する
+		start := time.Now()
+		results, err := c.BatchCheckWithOptions(context.Background(), checks, BatchOptions{PerCheckTimeout: 200 * time.Millisecond})
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		assert.Equal(t, []bool{true, false, false}, results)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var checkErr *CheckError
+		require.ErrorAs(t, err, &checkErr)
+		assert.Equal(t, 1, checkErr.Index)
+		assert.Equal(t, "user:slow", checkErr.User)
+	})
+
+	t.Run("no_timeout", func(t *testing.T) {
+		results, err := c.BatchCheckWithOptions(context.Background(), []CheckRequest{checks[0], checks[2]}, BatchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true, false}, results)
+	})
+
+	t.Run("parent_deadline_still_applies", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		results, err := c.BatchCheckWithOptions(ctx, checks[1:2], BatchOptions{PerCheckTimeout: time.Minute})
+		assert.Equal(t, []bool{false}, results)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestNewCheckRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		relation  string
+		object    string
+		wantField string
+	}{
+		{name: "valid", user: "user:alice", relation: "can_read", object: "resource:public-data"},
+		{name: "userset", user: "team:engineering#member", relation: "can_read", object: "resource:public-data"},
+		{name: "wildcard", user: "user:*", relation: "can_read", object: "resource:public-data"},
+		{name: "empty_user", user: "", relation: "can_read", object: "resource:public-data", wantField: "user"},
+		{name: "user_without_type", user: "alice", relation: "can_read", object: "resource:public-data", wantField: "user"},
+		{name: "user_empty_id", user: "user:", relation: "can_read", object: "resource:public-data", wantField: "user"},
+		{name: "empty_relation", user: "user:alice", relation: "", object: "resource:public-data", wantField: "relation"},
+		{name: "empty_object", user: "user:alice", relation: "can_read", object: "", wantField: "object"},
+		{name: "object_empty_type", user: "user:alice", relation: "can_read", object: ":public-data", wantField: "object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewCheckRequest(tt.user, tt.relation, tt.object)
+			if tt.wantField == "" {
+				require.NoError(t, err)
+				assert.Equal(t, CheckRequest{User: tt.user, Relation: tt.relation, Object: tt.object}, req)
+				return
+			}
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.wantField, validationErr.Field)
+			assert.Equal(t, CheckRequest{}, req)
+		})
+	}
+}
+
+func TestCheckRequestBuilder(t *testing.T) {
+	req, err := NewCheckRequestBuilder().
+		User("user:alice").
+		Relation("can_read").
+		Object("resource:public-data").
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, CheckRequest{"user:alice", "can_read", "resource:public-data"}, req)
+
+	_, err = NewCheckRequestBuilder().User("user:alice").Relation("can_read").Build()
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "object", validationErr.Field)
+	assert.EqualError(t, err, `invalid object "": must not be empty`)
+}
+
+func TestBatchCheckValidation(t *testing.T) {
+	var calls atomic.Int32
+	api := newCheckAPIServer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		api.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+	require.NoError(t, err)
+
+	results, err := c.BatchCheck(context.Background(), []CheckRequest{
+		{"user:alice", "can_read", "resource:public-data"},
+		{"bob", "can_read", "resource:public-data"},
+	})
+	assert.Nil(t, results)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "user", validationErr.Field)
+
+	var checkErr *CheckError
+	require.ErrorAs(t, err, &checkErr)
+	assert.Equal(t, 1, checkErr.Index)
+	assert.Zero(t, calls.Load(), "no API call should be made when a request is invalid")
+}
+
+// n個のタプルを作成する
+func newTestTuples(n int, relation string) []TupleKey {
+	tuples := make([]TupleKey, n)
+	for i := range tuples {
+		tuples[i] = TupleKey{User: fmt.Sprintf("user:%d", i), Relation: relation, Object: "document:1"}
+	}
+	return tuples
+}
+
+// Write APIのリクエストを記録するサーバー
+// failOnが1以上の場合、failOn回目のリクエストから続けてfailCount回エラーを返す
+type writeAPIServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []writeAPIRequest
+}
+
+type writeAPIRequest struct {
+	Writes  []TupleKey
+	Deletes []TupleKey
+}
+
+func newWriteAPIServer(t *testing.T, failOn, failCount int) *writeAPIServer {
+	s := &writeAPIServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type tupleKeys struct {
+			TupleKeys []struct {
+				User     string `json:"user"`
+				Relation string `json:"relation"`
+				Object   string `json:"object"`
+			} `json:"tuple_keys"`
+		}
+		var body struct {
+			Writes  *tupleKeys `json:"writes"`
+			Deletes *tupleKeys `json:"deletes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		toTuples := func(keys *tupleKeys) []TupleKey {
+			if keys == nil {
+				return nil
+			}
+			tuples := make([]TupleKey, 0, len(keys.TupleKeys))
+			for _, k := range keys.TupleKeys {
+				tuples = append(tuples, TupleKey{User: k.User, Relation: k.Relation, Object: k.Object})
+			}
+			return tuples
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, writeAPIRequest{Writes: toTuples(body.Writes), Deletes: toTuples(body.Deletes)})
+		n := len(s.requests)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if failOn > 0 && n >= failOn && n < failOn+failCount {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"code": "write_failed_due_to_invalid_input", "message": "invalid tuple"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *writeAPIServer) Requests() []writeAPIRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]writeAPIRequest(nil), s.requests...)
+}
+
+func TestSplitWriteChunks(t *testing.T) {
+	sizes := func(chunks []writeChunk) [][2]int {
+		var got [][2]int
+		for _, c := range chunks {
+			got = append(got, [2]int{len(c.writes), len(c.deletes)})
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		writes  int
+		deletes int
+		want    [][2]int
+	}{
+		{name: "empty", want: nil},
+		{name: "within the limit", writes: 60, deletes: 40, want: [][2]int{{60, 40}}},
+		{name: "250 writes", writes: 250, want: [][2]int{{100, 0}, {100, 0}, {50, 0}}},
+		{name: "250 deletes", deletes: 250, want: [][2]int{{0, 100}, {0, 100}, {0, 50}}},
+		{name: "writes and deletes share the limit", writes: 150, deletes: 100, want: [][2]int{{100, 0}, {50, 50}, {0, 50}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitWriteChunks(newTestTuples(tt.writes, "viewer"), newTestTuples(tt.deletes, "editor"), 100)
+			assert.Equal(t, tt.want, sizes(chunks))
+		})
+	}
+
+	// 分割しても順序は保たれる
+	writes := newTestTuples(250, "viewer")
+	var joined []TupleKey
+	for _, c := range splitWriteChunks(writes, nil, 100) {
+		joined = append(joined, c.writes...)
+	}
+	assert.Equal(t, writes, joined)
+}
+
+func TestBatchWriteTuples(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("single transaction", func(t *testing.T) {
+		server := newWriteAPIServer(t, 0, 0)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+
+		writes := newTestTuples(60, "viewer")
+		deletes := newTestTuples(40, "editor")
+		require.NoError(t, c.BatchWriteTuples(ctx, writes, deletes))
+
+		assert.Equal(t, []writeAPIRequest{{Writes: writes, Deletes: deletes}}, server.Requests())
+	})
+
+	t.Run("250 tuples are split into transactions of 100", func(t *testing.T) {
+		server := newWriteAPIServer(t, 0, 0)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+
+		writes := newTestTuples(250, "viewer")
+		require.NoError(t, c.BatchWriteTuples(ctx, writes, nil))
+
+		assert.Equal(t, []writeAPIRequest{
+			{Writes: writes[:100]},
+			{Writes: writes[100:200]},
+			{Writes: writes[200:]},
+		}, server.Requests())
+	})
+
+	t.Run("custom limit", func(t *testing.T) {
+		server := newWriteAPIServer(t, 0, 0)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithMaxWriteTransactionSize(10))
+		require.NoError(t, err)
+
+		require.NoError(t, c.BatchWriteTuples(ctx, newTestTuples(25, "viewer"), nil))
+		assert.Len(t, server.Requests(), 3)
+	})
+
+	t.Run("failure rolls back applied transactions", func(t *testing.T) {
+		// 3回目のWriteが失敗する
+		server := newWriteAPIServer(t, 3, 1)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+
+		writes := newTestTuples(150, "viewer")
+		deletes := newTestTuples(100, "editor")
+		err = c.BatchWriteTuples(ctx, writes, deletes)
+		assert.ErrorContains(t, err, "failed to write tuples (transaction 3/3)")
+
+		requests := server.Requests()
+		require.Len(t, requests, 5)
+		// 反映済みのWriteを新しい順に、書き込みと削除を入れ替えて取り消す
+		assert.Equal(t, writeAPIRequest{Writes: deletes[:50], Deletes: writes[100:]}, requests[3])
+		assert.Equal(t, writeAPIRequest{Deletes: writes[:100]}, requests[4])
+	})
+
+	t.Run("rollback failure is reported", func(t *testing.T) {
+		// 2回目のWriteとその取り消しが失敗する
+		server := newWriteAPIServer(t, 2, 2)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+
+		err = c.BatchWriteTuples(ctx, newTestTuples(250, "viewer"), nil)
+		assert.ErrorContains(t, err, "failed to write tuples (transaction 2/3)")
+		assert.ErrorContains(t, err, "failed to roll back transaction 1")
+		assert.Len(t, server.Requests(), 3)
+	})
+
+	t.Run("nothing to write", func(t *testing.T) {
+		server := newWriteAPIServer(t, 0, 0)
+		c, err := NewOpenFGAClient(server.URL, testStoreID, "token")
+		require.NoError(t, err)
+
+		require.NoError(t, c.BatchWriteTuples(ctx, nil, nil))
+		assert.Empty(t, server.Requests())
+	})
+}
+
+func TestWithMaxCheckTimeout(t *testing.T) {
+	api := newCheckAPIServer(t)
+	// user:slow のチェックは100ms後に応答する
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if bytes.Contains(body, []byte("user:slow")) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, api.URL+r.URL.Path, bytes.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.URL, testStoreID, "token", WithMaxCheckTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	t.Run("parent without deadline", func(t *testing.T) {
+		start := time.Now()
+		_, err := c.CheckPermission(context.Background(), "user:slow", "can_read", "resource:doc")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("shorter parent deadline wins", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.CheckPermission(ctx, "user:slow", "can_read", "resource:doc")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("each batch check is bounded", func(t *testing.T) {
+		results, err := c.BatchCheck(context.Background(), []CheckRequest{
+			{User: "user:alice", Relation: "can_read", Object: "resource:doc"},
+			{User: "user:slow", Relation: "can_read", Object: "resource:doc"},
+		})
+		assert.ErrorContains(t, err, "context deadline exceeded")
+		assert.Nil(t, results)
+	})
+
+	t.Run("fast checks are unaffected", func(t *testing.T) {
+		allowed, err := c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:doc")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	})
+}
+
+func TestReadAllTuples(t *testing.T) {
+	// 1回のReadで取得できる100件を超えるタプル
+	fixtures := make(map[string]bool)
+	for i := 0; i < 150; i++ {
+		fixtures[mockfga.Key(fmt.Sprintf("user:%03d", i), "viewer", "document:1")] = true
+	}
+	fixtures[mockfga.Key("user:denied", "viewer", "document:1")] = false
+	server := mockfga.NewMockFGAServer(fixtures)
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	tuples, err := c.ReadAllTuples(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tuples, 150)
+	assert.Equal(t, TupleKey{User: "user:000", Relation: "viewer", Object: "document:1"}, tuples[0])
+	assert.Equal(t, TupleKey{User: "user:149", Relation: "viewer", Object: "document:1"}, tuples[149])
+}
+
+func TestExportTuplesToRego(t *testing.T) {
+	server := mockfga.NewMockFGAServer(map[string]bool{
+		mockfga.Key("user:bob", "viewer", "document:2"):          true,
+		mockfga.Key("user:alice", "viewer", "document:1"):        true,
+		mockfga.Key("group:eng#member", "editor", "document:1"):  true,
+		mockfga.Key("user:alice", "member", "group:eng"):         true,
+		mockfga.Key("folder:root", "parent", "document:1"):       true,
+		mockfga.Key("user:mallory", "viewer", "document:secret"): false,
+	})
+	defer server.Close()
+
+	c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+	require.NoError(t, err)
+
+	rego, err := ExportTuplesToRego(context.Background(), c)
+	require.NoError(t, err)
+
+	assert.Contains(t, rego, "package openfga.tuples\n")
+	assert.Contains(t, rego, "default allow := false\n")
+	assert.Contains(t, rego, `# document#viewer
+allow if {
+	input.relation == "viewer"
+	[input.object, input.user] in {
+		["document:1", "user:alice"],
+		["document:2", "user:bob"],
+	}
+}
+`)
+	assert.Contains(t, rego, `# document#editor
+allow if {
+	input.relation == "editor"
+	[input.object, input.user] in {
+		["document:1", "group:eng#member"],
+	}
+}
+`)
+	assert.Contains(t, rego, `# group#member
+allow if {
+	input.relation == "member"
+	[input.object, input.user] in {
+		["group:eng", "user:alice"],
+	}
+}
+`)
+	assert.NotContains(t, rego, "user:mallory")
+
+	// 型・リレーションの順に並ぶ
+	order := []string{"# document#editor", "# document#parent", "# document#viewer", "# group#member"}
+	last := -1
+	for _, header := range order {
+		i := strings.Index(rego, header)
+		require.Greater(t, i, last, "%s is out of order", header)
+		last = i
+	}
+}
+
+func TestParseRegoToTuples(t *testing.T) {
+	tuples := []TupleKey{
+		{User: "user:bob", Relation: "viewer", Object: "document:2"},
+		{User: "user:alice", Relation: "viewer", Object: "document:1"},
+		{User: "group:eng#member", Relation: "editor", Object: "document:1"},
+		{User: "user:*", Relation: "viewer", Object: "document:public"},
+		{User: `user:"quoted"`, Relation: "member", Object: "group:eng"},
+	}
+
+	rego := tuplesToRego(tuples)
+	got, err := ParseRegoToTuples(rego)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, tuples, got)
+
+	// 出力は入力の順序によらない
+	reversed := append([]TupleKey(nil), tuples...)
+	slices.Reverse(reversed)
+	assert.Equal(t, rego, tuplesToRego(reversed))
+
+	empty, err := ParseRegoToTuples(tuplesToRego(nil))
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	errorTests := []struct {
+		name   string
+		rego   string
+		errMsg string
+	}{
+		{name: "no package", rego: "allow if {\n\tinput.relation == \"viewer\"\n}\n", errMsg: "package openfga.tuples not found"},
+		{name: "other package", rego: "package authz\n", errMsg: `unexpected package "authz"`},
+		{name: "tuple without relation", rego: "package openfga.tuples\nallow if {\n\t[\"document:1\", \"user:alice\"],\n}\n", errMsg: "tuple outside of an allow rule"},
+		{name: "malformed tuple", rego: "package openfga.tuples\nallow if {\n\tinput.relation == \"viewer\"\n\t[\"document:1\"\"],\n}\n", errMsg: "invalid tuple on line 4"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRegoToTuples(tt.rego)
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	userAgents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"allowed": true})
+	}))
+	defer server.Close()
+
+	newConfig := func(userAgent string) *OpenFGAConfig {
+		return &OpenFGAConfig{APIURL: server.URL, StoreID: testStoreID, APIToken: "token", UserAgent: userAgent}
+	}
+
+	tests := []struct {
+		name string
+		new  func() (*OpenFGAClient, error)
+		want string
+	}{
+		{
+			name: "config",
+			new:  func() (*OpenFGAClient, error) { return NewOpenFGAClientFromConfig(newConfig("billing-service/1.2.3")) },
+			want: "billing-service/1.2.3",
+		},
+		{
+			name: "option",
+			new: func() (*OpenFGAClient, error) {
+				return NewOpenFGAClient(server.URL, testStoreID, "token", WithUserAgent("report-worker/0.1 (linux)"))
+			},
+			want: "report-worker/0.1 (linux)",
+		},
+		{
+			name: "option overrides config",
+			new: func() (*OpenFGAClient, error) {
+				return NewOpenFGAClientFromConfig(newConfig("billing-service/1.2.3"), WithUserAgent("billing-service/2.0.0"))
+			},
+			want: "billing-service/2.0.0",
+		},
+		{
+			name: "default",
+			new:  func() (*OpenFGAClient, error) { return NewOpenFGAClient(server.URL, testStoreID, "token") },
+			want: "openfga-client/dev (" + runtime.GOOS + ")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.new()
+			require.NoError(t, err)
+
+			_, err = c.CheckPermission(context.Background(), "user:alice", "can_read", "resource:doc")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, <-userAgents)
+		})
+	}
+}
+
+// 期待どおりでない権限テストの結果をテストの失敗として報告する
+// testingパッケージをCLIのバイナリに含めないよう、テストファイルに置く
+func AssertPermissionTests(t testing.TB, results []PermissionTestResult) {
+	t.Helper()
+
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			t.Errorf("%s %s %s: permission check failed: %v", r.User, r.Relation, r.Object, r.Err)
+		case r.Allowed != r.ExpectedAllowed:
+			t.Errorf("%s %s %s: allowed = %t, expected %t", r.User, r.Relation, r.Object, r.Allowed, r.ExpectedAllowed)
+		}
+	}
+}
+
+// AssertPermissionTestsが報告した失敗を記録するtesting.TB
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRunPermissionTestsFromFile(t *testing.T) {
+	fixtures := map[string]bool{
+		mockfga.Key("user:alice", "can_read", "resource:public-data"):     true,
+		mockfga.Key("user:admin", "can_delete", "resource:sensitive-data"): true,
+	}
+
+	t.Run("all tests pass", func(t *testing.T) {
+		server := mockfga.NewMockFGAServer(fixtures)
+		defer server.Close()
+		c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+		require.NoError(t, err)
+
+		results, err := RunPermissionTestsFromFile(context.Background(), c, "testdata/permission_tests.yaml")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.Equal(t, PermissionTestResult{
+			PermissionTestCase: PermissionTestCase{User: "user:alice", Relation: "can_read", Object: "resource:public-data", ExpectedAllowed: true},
+			Allowed:            true,
+		}, results[0])
+		for _, r := range results {
+			assert.True(t, r.Passed(), "%s %s %s", r.User, r.Relation, r.Object)
+		}
+		assert.Len(t, server.Checks(), 4)
+
+		AssertPermissionTests(t, results)
+	})
+
+	t.Run("unexpected results are reported", func(t *testing.T) {
+		// user:admin の削除権限がない
+		server := mockfga.NewMockFGAServer(map[string]bool{
+			mockfga.Key("user:alice", "can_read", "resource:public-data"): true,
+		})
+		defer server.Close()
+		c, err := NewOpenFGAClient(server.Addr(), testStoreID, "token")
+		require.NoError(t, err)
+
+		results, err := RunPermissionTestsFromFile(context.Background(), c, "testdata/permission_tests.yaml")
+		require.NoError(t, err)
+		assert.False(t, results[3].Passed())
+
+		rec := &recordingTB{}
+		AssertPermissionTests(rec, results)
+		assert.Equal(t, []string{"user:admin can_delete resource:sensitive-data: allowed = false, expected true"}, rec.errors)
+	})
+
+	t.Run("check errors are recorded per test", func(t *testing.T) {
+		m := new(MockOpenFGAClient)
+		m.On("CheckPermission", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, errors.New("connection refused"))
+
+		results, err := RunPermissionTestsFromFile(context.Background(), m, "testdata/permission_tests.yaml")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.EqualError(t, results[0].Err, "connection refused")
+
+		rec := &recordingTB{}
+		AssertPermissionTests(rec, results)
+		assert.Len(t, rec.errors, 4)
+		assert.Contains(t, rec.errors[0], "permission check failed: connection refused")
+	})
+
+	t.Run("invalid files", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			content string
+			errMsg  string
+		}{
+			{name: "unknown field", content: "tests:\n  - user: user:alice\n    relation: can_read\n    object: resource:doc\n    expected: true\n", errMsg: "field expected not found"},
+			{name: "no tests", content: "tests: []\n", errMsg: "no tests found"},
+			{name: "invalid user", content: "tests:\n  - user: alice\n    relation: can_read\n    object: resource:doc\n", errMsg: "invalid test 0"},
+			{name: "not YAML", content: "tests: [", errMsg: "failed to parse permission test file"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "tests.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+				_, err := RunPermissionTestsFromFile(context.Background(), new(MockOpenFGAClient), path)
+				assert.ErrorContains(t, err, tt.errMsg)
+			})
+		}
+
+		_, err := RunPermissionTestsFromFile(context.Background(), new(MockOpenFGAClient), "testdata/missing.yaml")
+		assert.ErrorContains(t, err, "failed to read permission test file")
+	})
+}
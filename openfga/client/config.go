@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openfga "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+	"github.com/openfga/go-sdk/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// 設定ファイルで省略された場合のデフォルト値
+const (
+	defaultTimeoutSeconds = 30
+	// SDKのデフォルトと同じリトライ間隔
+	defaultRetryMinWaitMs = 100
+	// SDKが許容する最大リトライ回数（15回）+ 初回
+	maxRetryAttempts = 16
+)
+
+// OpenFGAクライアントの設定
+type OpenFGAConfig struct {
+	// OpenFGA APIのURL（省略時は defaultAPIURL）
+	APIURL string `json:"api_url" yaml:"api_url"`
+	// ストアID（必須）
+	StoreID string `json:"store_id" yaml:"store_id"`
+	// Bearerトークンとして送信するAPIトークン（必須）
+	APIToken string `json:"api_token" yaml:"api_token"`
+	// サーバー証明書を検証するCA証明書のパス（省略時はシステムのルートCA）
+	CACertPath string `json:"ca_cert_path" yaml:"ca_cert_path"`
+	// サーバー証明書を検証しない
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	// HTTPリクエストのタイムアウト秒数（省略時は30秒）
+	TimeoutSeconds int `json:"timeout_seconds" yaml:"timeout_seconds"`
+	// ホストごとの最大アイドル接続数（省略時はnet/httpのデフォルト）
+	PoolSize int `json:"pool_size" yaml:"pool_size"`
+	// 初回を含む最大試行回数（省略時はSDKのデフォルト）
+	RetryMaxAttempts int `json:"retry_max_attempts" yaml:"retry_max_attempts"`
+	// リクエストに送信するUser-Agent（省略時は "openfga-client/<バージョン> (<OS>)"）
+	UserAgent string `json:"user_agent" yaml:"user_agent"`
+}
+
+// 設定ファイルを読み込む。拡張子が .json の場合はJSON、.yaml/.yml の場合はYAMLとして解析する
+func LoadConfigFromFile(path string) (*OpenFGAConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// 設定ミスに気づけるよう未知のフィールドはエラーにする
+	cfg := &OpenFGAConfig{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q: must be .json, .yaml or .yml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *OpenFGAConfig) setDefaults() {
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultAPIURL
+	}
+	if cfg.TimeoutSeconds == 0 {
+		cfg.TimeoutSeconds = defaultTimeoutSeconds
+	}
+}
+
+// 設定値を検証
+func (cfg *OpenFGAConfig) Validate() error {
+	if u, err := url.Parse(cfg.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid api_url %q: must be an http or https URL", cfg.APIURL)
+	}
+	if cfg.StoreID == "" {
+		return fmt.Errorf("store_id is required")
+	}
+	if cfg.APIToken == "" {
+		return fmt.Errorf("api_token is required")
+	}
+	if cfg.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if cfg.PoolSize < 0 {
+		return fmt.Errorf("pool_size must not be negative")
+	}
+	if cfg.RetryMaxAttempts < 0 || cfg.RetryMaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry_max_attempts must be between 0 and %d", maxRetryAttempts)
+	}
+	return nil
+}
+
+// 設定からOpenFGAクライアントを作成
+func NewOpenFGAClientFromConfig(cfg *OpenFGAConfig, opts ...OpenFGAOption) (*OpenFGAClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newOpenFGAClientFromConfig(cfg, opts...)
+}
+
+// 検証せずに設定からOpenFGAクライアントを作成
+func newOpenFGAClientFromConfig(cfg *OpenFGAConfig, opts ...OpenFGAOption) (*OpenFGAClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CACertPath != "" {
+		caCert, err := os.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertPath)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	// User-Agentはオプションで上書きできるため、SDKクライアントの作成前にオプションを適用する
+	c := &OpenFGAClient{
+		storeID:   cfg.StoreID,
+		userAgent: cfg.UserAgent,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeoutSeconds * time.Second
+	}
+
+	configuration := client.ClientConfiguration{
+		ApiUrl: cfg.APIURL,
+		Credentials: &credentials.Credentials{
+			Method: credentials.CredentialsMethodApiToken,
+			Config: &credentials.Config{
+				ApiToken: cfg.APIToken,
+			},
+		},
+		// CA証明書を信頼するためのHTTPクライアント設定
+		HTTPClient: &http.Client{
+			Timeout: timeout,
+			Transport: &UserAgentTransport{
+				Base: &http.Transport{
+					TLSClientConfig:     tlsConfig,
+					MaxIdleConnsPerHost: cfg.PoolSize,
+				},
+				UserAgent: c.userAgent,
+			},
+		},
+	}
+	if cfg.RetryMaxAttempts > 0 {
+		configuration.RetryParams = &openfga.RetryParams{
+			MaxRetry:    cfg.RetryMaxAttempts - 1,
+			MinWaitInMs: defaultRetryMinWaitMs,
+		}
+	}
+
+	fgaClient, err := client.NewSdkClient(&configuration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenFGA client: %v", err)
+	}
+
+	c.client = fgaClient
+	if err := c.startDebugServer(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
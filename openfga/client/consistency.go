@@ -0,0 +1,37 @@
+package main
+
+import (
+	openfga "github.com/openfga/go-sdk"
+)
+
+// 1回の権限チェック（BatchCheckでは全チェック）に適用するオプション
+type CheckOption func(*checkOptions)
+
+type checkOptions struct {
+	consistency *openfga.ConsistencyPreference
+}
+
+// キャッシュを使わず最新の書き込みを反映した結果を返すよう要求する
+// タプルを書き込んだ直後のチェックで使用する（レイテンシは増加する）
+func WithHigherConsistency() CheckOption {
+	return withConsistency(openfga.CONSISTENCYPREFERENCE_HIGHER_CONSISTENCY)
+}
+
+// 整合性よりもレイテンシを優先するよう要求する（OpenFGAのデフォルトと同じ動作）
+func WithMinimizeLatency() CheckOption {
+	return withConsistency(openfga.CONSISTENCYPREFERENCE_MINIMIZE_LATENCY)
+}
+
+func withConsistency(preference openfga.ConsistencyPreference) CheckOption {
+	return func(o *checkOptions) {
+		o.consistency = &preference
+	}
+}
+
+func newCheckOptions(opts []CheckOption) checkOptions {
+	var o checkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
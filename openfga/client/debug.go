@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// 権限チェックの実行状況
+type ClientStats struct {
+	InFlightChecks int64 `json:"in_flight_checks"`
+	TotalChecks    int64 `json:"total_checks"`
+	TotalErrors    int64 `json:"total_errors"`
+}
+
+// 権限チェックの件数を数えるカウンター
+type checkCounters struct {
+	inFlight atomic.Int64
+	total    atomic.Int64
+	errors   atomic.Int64
+}
+
+// 実行状況をJSONで返すデバッグ用HTTPサーバーを起動するオプション
+// GET /stats でClientStatsを返す。サーバーはClose()で停止する
+func WithDebugServer(addr string) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.debugAddr = addr
+	}
+}
+
+// 現在の実行状況を返す
+func (c *OpenFGAClient) Stats() ClientStats {
+	return ClientStats{
+		InFlightChecks: c.counters.inFlight.Load(),
+		TotalChecks:    c.counters.total.Load(),
+		TotalErrors:    c.counters.errors.Load(),
+	}
+}
+
+// デバッグ用HTTPサーバーの待ち受けアドレスを返す（起動していない場合は空）
+func (c *OpenFGAClient) DebugAddr() string {
+	if c.debugListener == nil {
+		return ""
+	}
+	return c.debugListener.Addr().String()
+}
+
+// デバッグ用HTTPサーバーを停止する
+func (c *OpenFGAClient) Close() error {
+	if c.debugServer == nil {
+		return nil
+	}
+	if err := c.debugServer.Close(); err != nil {
+		return fmt.Errorf("failed to stop debug server: %v", err)
+	}
+	return nil
+}
+
+// WithDebugServerが指定されている場合にデバッグ用HTTPサーバーを起動
+func (c *OpenFGAClient) startDebugServer() error {
+	if c.debugAddr == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", c.debugAddr)
+	if err != nil {
+		return fmt.Errorf("failed to start debug server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Stats())
+	})
+
+	c.debugListener = listener
+	c.debugServer = &http.Server{Handler: mux}
+	go c.debugServer.Serve(listener)
+	return nil
+}
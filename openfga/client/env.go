@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// 環境変数名
+const (
+	// OpenFGA APIのURL（省略時は defaultAPIURL）
+	envAPIURL = "OPENFGA_API_URL"
+	// ストアID（必須）
+	envStoreID = "OPENFGA_STORE_ID"
+	// Bearerトークンとして送信するAPIトークン（必須）
+	envAPIToken = "OPENFGA_API_TOKEN"
+	// サーバー証明書を検証するCA証明書のパス（省略時はシステムのルートCA）
+	envCACertPath = "OPENFGA_CA_CERT_PATH"
+	// "true" の場合はサーバー証明書を検証しない（省略時は false）
+	envInsecure = "OPENFGA_INSECURE"
+)
+
+// OPENFGA_API_URL が未設定の場合に使用するURL
+const defaultAPIURL = "https://openfga:18443"
+
+// 必須の環境変数が設定されていないことを示すエラー
+type MissingEnvVarError struct {
+	Name string
+}
+
+func (e *MissingEnvVarError) Error() string {
+	return fmt.Sprintf("required environment variable %s is not set", e.Name)
+}
+
+// 環境変数から設定を読み込んでOpenFGAクライアントを作成
+func NewOpenFGAClientFromEnv(opts ...OpenFGAOption) (*OpenFGAClient, error) {
+	apiURL := os.Getenv(envAPIURL)
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	if u, err := url.Parse(apiURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s %q: must be an http or https URL", envAPIURL, apiURL)
+	}
+
+	storeID := os.Getenv(envStoreID)
+	if storeID == "" {
+		return nil, &MissingEnvVarError{Name: envStoreID}
+	}
+
+	apiToken := os.Getenv(envAPIToken)
+	if apiToken == "" {
+		return nil, &MissingEnvVarError{Name: envAPIToken}
+	}
+
+	insecure := false
+	if v := os.Getenv(envInsecure); v != "" {
+		var err error
+		insecure, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", envInsecure, v, err)
+		}
+	}
+
+	return NewOpenFGAClientFromConfig(&OpenFGAConfig{
+		APIURL:             apiURL,
+		StoreID:            storeID,
+		APIToken:           apiToken,
+		CACertPath:         os.Getenv(envCACertPath),
+		InsecureSkipVerify: insecure,
+	}, opts...)
+}
@@ -0,0 +1,13 @@
+package main
+
+import "context"
+
+// PermissionChecker は権限チェックを行うクライアントのインターフェース
+// 呼び出し側は具体的な構造体ではなくこのインターフェースに依存することでモックに差し替えられる
+type PermissionChecker interface {
+	CheckPermission(ctx context.Context, user, relation, object string, opts ...CheckOption) (bool, error)
+	BatchCheck(ctx context.Context, checks []CheckRequest, opts ...CheckOption) ([]bool, error)
+}
+
+// OpenFGAClient が PermissionChecker を実装していることをコンパイル時に保証する
+var _ PermissionChecker = (*OpenFGAClient)(nil)
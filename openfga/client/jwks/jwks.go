@@ -0,0 +1,127 @@
+// Package jwks はSPIFFEのX.509トラストバンドルをJWK Set (RFC 7517) として公開する
+// OpenFGAサーバーのJWKSエンドポイント設定からSPIFFEのCA公開鍵を参照できるようにする
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+)
+
+// JWK Setのレスポンスに使用するContent-Type
+const ContentType = "application/jwk-set+json"
+
+// JWK Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// 1つの公開鍵を表すJWK
+// RSA鍵はn/e、EC鍵はcrv/x/yを使用する
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// バンドルのX.509認証局をJWK SetのJSONに変換
+func BundleToJWKS(bundle *x509bundle.Bundle) ([]byte, error) {
+	if bundle == nil {
+		return nil, fmt.Errorf("bundle is nil")
+	}
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, cert := range bundle.X509Authorities() {
+		key, err := certToJWK(cert)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JWK set: %v", err)
+	}
+	return data, nil
+}
+
+// バンドルをJWK Setとして返すHTTPハンドラー
+// JSONはリクエストごとに生成するため、バンドルの更新内容がそのまま反映される
+func ServeJWKS(bundle *x509bundle.Bundle) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := BundleToJWKS(bundle)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to build JWK set: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		w.Write(data)
+	})
+}
+
+// 証明書の公開鍵をJWKに変換
+// kidには証明書DERのSHA-1サムプリント (x5tと同じ値) を使用する
+func certToJWK(cert *x509.Certificate) (JWK, error) {
+	thumbprint := sha1.Sum(cert.Raw)
+	key := JWK{
+		Kid: base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		Use: "sig",
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = encodeBigInt(pub.N, 0)
+		key.E = encodeBigInt(big.NewInt(int64(pub.E)), 0)
+	case *ecdsa.PublicKey:
+		crv := pub.Curve.Params().Name
+		switch crv {
+		case "P-256", "P-384", "P-521":
+		default:
+			return JWK{}, fmt.Errorf("unsupported EC curve %s for certificate %s", crv, cert.Subject)
+		}
+		// 座標は曲線のバイト長に合わせてゼロ埋めする (RFC 7518 6.2.1.2)
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key.Kty = "EC"
+		key.Crv = crv
+		key.X = encodeBigInt(pub.X, size)
+		key.Y = encodeBigInt(pub.Y, size)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T for certificate %s", cert.PublicKey, cert.Subject)
+	}
+
+	return key, nil
+}
+
+// 整数をビッグエンディアンのbase64url (パディングなし) に変換
+// sizeが0より大きい場合はそのバイト長までゼロ埋めする
+func encodeBigInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		padded := make([]byte, size)
+		copy(padded[size-len(b):], b)
+		b = padded
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,86 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTrustDomain = spiffeid.RequireTrustDomainFromString("example.org")
+
+// testdata/bundle.pem (RSA 2048とP-256のCA) を読み込む
+// testdata/jwks.jsonはopensslで同じ証明書から独立に作成した期待値
+func loadTestBundle(t *testing.T) *x509bundle.Bundle {
+	t.Helper()
+
+	bundle, err := x509bundle.Load(testTrustDomain, "testdata/bundle.pem")
+	require.NoError(t, err)
+	require.Len(t, bundle.X509Authorities(), 2)
+	return bundle
+}
+
+func TestBundleToJWKS(t *testing.T) {
+	expected, err := os.ReadFile("testdata/jwks.json")
+	require.NoError(t, err)
+
+	data, err := BundleToJWKS(loadTestBundle(t))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(data))
+}
+
+func TestBundleToJWKSEmptyBundle(t *testing.T) {
+	data, err := BundleToJWKS(x509bundle.New(testTrustDomain))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"keys":[]}`, string(data))
+
+	_, err = BundleToJWKS(nil)
+	assert.Error(t, err)
+}
+
+func TestBundleToJWKSUnsupportedKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), IsCA: true, BasicConstraintsValid: true}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	_, err = BundleToJWKS(x509bundle.FromX509Authorities(testTrustDomain, []*x509.Certificate{cert}))
+	assert.ErrorContains(t, err, "unsupported public key type")
+}
+
+func TestServeJWKS(t *testing.T) {
+	expected, err := os.ReadFile("testdata/jwks.json")
+	require.NoError(t, err)
+
+	handler := ServeJWKS(loadTestBundle(t))
+
+	t.Run("GET", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jwks.json", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, string(expected), rec.Body.String())
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jwks.json", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+	})
+}
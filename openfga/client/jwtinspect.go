@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+)
+
+// JWT SVIDのクレームから取り出した情報
+type JWTSVIDInfo struct {
+	SPIFFEID  string
+	Audience  []string
+	ExpiresAt time.Time
+	IssuedAt  time.Time // iatクレームがない場合はゼロ値
+	IsExpired bool
+}
+
+// JWT SVIDのヘッダーとクレームを解析し、OpenFGAに送る前に期限切れや不正な形式を検出する
+// 署名は検証しない（検証はサーバー側の役割）ため、結果を認可の判断に使ってはならない
+func InspectJWTSVID(token string) (*JWTSVIDInfo, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT: %v", err)
+	}
+
+	var claims jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT claims: %v", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("JWT has no sub claim")
+	}
+	id, err := spiffeid.FromString(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SPIFFE ID in sub claim: %v", err)
+	}
+
+	// JWT SVIDの仕様ではexpクレームは必須
+	if claims.Expiry == nil {
+		return nil, fmt.Errorf("JWT has no exp claim")
+	}
+
+	info := &JWTSVIDInfo{
+		SPIFFEID:  id.String(),
+		Audience:  []string(claims.Audience),
+		ExpiresAt: claims.Expiry.Time(),
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time()
+	}
+	info.IsExpired = !time.Now().Before(info.ExpiresAt)
+
+	return info, nil
+}
@@ -4,56 +4,116 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/openfga/go-sdk/client"
-	"github.com/openfga/go-sdk/credentials"
 	"github.com/spiffe/go-spiffe/v2/svid/jwtsvid"
 	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"golang.org/x/time/rate"
 )
 
 type OpenFGAClient struct {
-	client  *client.OpenFgaClient
-	storeID string
+	client      *client.OpenFgaClient
+	storeID     string
+	auditLogger *slog.Logger
+	limiter     *rate.Limiter
+	counters    checkCounters
+
+	// 1回の権限チェックにかける時間の上限（0の場合は親コンテキストの期限のみ）
+	maxCheckTimeout time.Duration
+
+	// OpenFGA APIへのリクエストに送信するUser-Agent（空の場合はデフォルト）
+	userAgent string
+
+	// 1回のWriteで送信するタプル数の上限（0の場合は既定値）
+	maxWriteTransactionSize int
+
+	debugAddr     string
+	debugListener net.Listener
+	debugServer   *http.Server
 }
 
-func NewOpenFGAClient(apiURL, storeID string, jwtToken string) (*OpenFGAClient, error) {
-	configuration := client.ClientConfiguration{
-		ApiUrl: apiURL,
-		Credentials: &credentials.Credentials{
-			Method: credentials.CredentialsMethodApiToken,
-			Config: &credentials.Config{
-				ApiToken: jwtToken,
-			},
-		},
-		// CA証明書を信頼するためのHTTPクライアント設定
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true, // デモ用のため
-				},
-			},
-		},
+func NewOpenFGAClient(apiURL, storeID string, jwtToken string, opts ...OpenFGAOption) (*OpenFGAClient, error) {
+	return newOpenFGAClientFromConfig(&OpenFGAConfig{
+		APIURL:             apiURL,
+		StoreID:            storeID,
+		APIToken:           jwtToken,
+		InsecureSkipVerify: true, // デモ用のため
+	}, opts...)
+}
+
+// JWT SVIDの有効期限がこの時間以内になったら再取得する
+const jwtRefreshThreshold = 30 * time.Second
+
+// JWT SVIDを取得するソース（workloadapi.JWTSourceが実装）
+type jwtSVIDFetcher interface {
+	FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error)
+}
+
+// リクエストごとにJWT SVIDをAuthorizationヘッダーに設定するRoundTripper
+// 有効期限が近づくまではキャッシュしたSVIDを再利用する
+type jwtSVIDTransport struct {
+	base     http.RoundTripper
+	source   jwtSVIDFetcher
+	audience string
+
+	mu   sync.Mutex
+	svid *jwtsvid.SVID
+}
+
+func (t *jwtSVIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token(req.Context())
+	if err != nil {
+		return nil, err
 	}
 
-	fgaClient, err := client.NewSdkClient(&configuration)
+	// RoundTripperは元のリクエストを変更してはいけないためコピーする
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(req)
+}
+
+// キャッシュしたJWT SVIDを返す。期限切れ間近なら再取得する
+func (t *jwtSVIDTransport) token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.svid != nil && time.Until(t.svid.Expiry) > jwtRefreshThreshold {
+		return t.svid.Marshal(), nil
+	}
+
+	svid, err := t.source.FetchJWTSVID(ctx, jwtsvid.Params{
+		Audience: t.audience,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create OpenFGA client: %v", err)
+		return "", fmt.Errorf("failed to fetch JWT SVID: %v", err)
 	}
 
-	return &OpenFGAClient{
-		client:  fgaClient,
-		storeID: storeID,
-	}, nil
+	log.Printf("Obtained JWT SVID for SPIFFE ID: %s (expires at %s)", svid.ID, svid.Expiry.Format(time.RFC3339))
+	t.svid = svid
+	return svid.Marshal(), nil
+}
+
+// JWTSourceを開いたまま保持し、JWT SVIDを自動更新するOpenFGAクライアント
+type SPIREOpenFGAClient struct {
+	*OpenFGAClient
+	source *workloadapi.JWTSource
 }
 
 // SPIRE認証を使用してOpenFGAクライアントを作成
-func NewOpenFGAClientWithSPIRE(apiURL, storeID string) (*OpenFGAClient, error) {
+func NewOpenFGAClientWithSPIRE(apiURL, storeID string, opts ...OpenFGAOption) (*SPIREOpenFGAClient, error) {
 	// SPIRE Workload APIからJWT SVIDを取得
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -72,76 +132,113 @@ func NewOpenFGAClientWithSPIRE(apiURL, storeID string) (*OpenFGAClient, error) {
 	log.Printf("SPIRE Agent socket found at: %s", socketPath)
 	log.Printf("Connecting to SPIRE Agent at: unix://%s", socketPath)
 
+	// JWTSourceはクライアントが閉じられるまで開いたままにする
 	source, err := workloadapi.NewJWTSource(ctx, workloadapi.WithClientOptions(workloadapi.WithAddr("unix://"+socketPath)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create JWT source: %v", err)
 	}
-	defer source.Close()
 
 	log.Printf("JWT Source created successfully, fetching JWT SVID...")
 
-	// aud=openfgaのJWT SVIDを取得
-	svid, err := source.FetchJWTSVID(ctx, jwtsvid.Params{
-		Audience: "openfga",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWT SVID: %v", err)
+	// User-Agentはオプションで指定するため、SDKクライアントの作成前にオプションを適用する
+	c := &OpenFGAClient{storeID: storeID}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// aud=openfgaのJWT SVIDを取得するトランスポート
+	transport := &jwtSVIDTransport{
+		base:     newSPIRETransport(),
+		source:   source,
+		audience: "openfga",
 	}
 
-	log.Printf("Obtained JWT SVID for SPIFFE ID: %s", svid.ID)
-	log.Printf("JWT Token (first 50 chars): %s...", svid.Marshal()[:50])
+	// 起動時に一度取得して、SPIRE Agentから取得できることを確認
+	if _, err := transport.token(ctx); err != nil {
+		source.Close()
+		return nil, err
+	}
 
 	configuration := client.ClientConfiguration{
 		ApiUrl: apiURL,
-		Credentials: &credentials.Credentials{
-			Method: credentials.CredentialsMethodApiToken,
-			Config: &credentials.Config{
-				ApiToken: svid.Marshal(),
-			},
+		HTTPClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &UserAgentTransport{Base: transport, UserAgent: c.userAgent},
 		},
 		Debug: true,
 	}
 
 	fgaClient, err := client.NewSdkClient(&configuration)
 	if err != nil {
+		source.Close()
 		return nil, fmt.Errorf("failed to create OpenFGA client: %v", err)
 	}
-	
-	// HTTPClientのTLS設定を変更（CA証明書を使用）
-	httpClient := fgaClient.APIClient.GetConfig().HTTPClient
-	if httpClient.Transport == nil {
-		httpClient.Transport = &http.Transport{}
-	}
-	if transport, ok := httpClient.Transport.(*http.Transport); ok {
-		// CA証明書を読み込み
-		caCert, err := os.ReadFile("/opt/certs/ca.crt")
-		if err != nil {
-			log.Printf("Warning: Failed to read CA certificate, falling back to insecure: %v", err)
-			if transport.TLSClientConfig == nil {
-				transport.TLSClientConfig = &tls.Config{}
-			}
-			transport.TLSClientConfig.InsecureSkipVerify = true
-		} else {
-			// CA証明書プールを作成
-			caCertPool := x509.NewCertPool()
-			caCertPool.AppendCertsFromPEM(caCert)
-			
-			if transport.TLSClientConfig == nil {
-				transport.TLSClientConfig = &tls.Config{}
-			}
-			transport.TLSClientConfig.RootCAs = caCertPool
-			log.Printf("CA certificate loaded successfully")
-		}
+
+	c.client = fgaClient
+	if err := c.startDebugServer(); err != nil {
+		source.Close()
+		return nil, err
 	}
 
-	return &OpenFGAClient{
-		client:  fgaClient,
-		storeID: storeID,
+	return &SPIREOpenFGAClient{
+		OpenFGAClient: c,
+		source:        source,
 	}, nil
 }
 
+// デバッグ用HTTPサーバーを停止し、JWTSourceを閉じる
+func (c *SPIREOpenFGAClient) Close() error {
+	return errors.Join(c.OpenFGAClient.Close(), c.source.Close())
+}
+
+// CA証明書を信頼するHTTPトランスポートを作成
+func newSPIRETransport() *http.Transport {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{},
+	}
+
+	// CA証明書を読み込み
+	caCert, err := os.ReadFile("/opt/certs/ca.crt")
+	if err != nil {
+		log.Printf("Warning: Failed to read CA certificate, falling back to insecure: %v", err)
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		return transport
+	}
+
+	// CA証明書プールを作成
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	transport.TLSClientConfig.RootCAs = caCertPool
+	log.Printf("CA certificate loaded successfully")
+	return transport
+}
+
 // ユーザーの権限をチェック
-func (c *OpenFGAClient) CheckPermission(ctx context.Context, user, relation, object string) (bool, error) {
+func (c *OpenFGAClient) CheckPermission(ctx context.Context, user, relation, object string, opts ...CheckOption) (bool, error) {
+	c.counters.inFlight.Add(1)
+	defer c.counters.inFlight.Add(-1)
+
+	start := time.Now()
+	allowed, err := c.checkPermissionWithMaxTimeout(ctx, user, relation, object, newCheckOptions(opts))
+	c.counters.total.Add(1)
+	if err != nil {
+		c.counters.errors.Add(1)
+	}
+	c.audit(ctx, user, relation, object, allowed, time.Since(start), err)
+	return allowed, err
+}
+
+// 監査ログを出力せずに権限をチェック
+func (c *OpenFGAClient) checkPermission(ctx context.Context, user, relation, object string, opts checkOptions) (bool, error) {
+	// SDKは終了済みのコンテキストでもリトライを続けるため、呼び出し前に確認する
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if err := c.waitRateLimit(ctx); err != nil {
+		return false, err
+	}
+
 	body := client.ClientCheckRequest{
 		User:     user,
 		Relation: relation,
@@ -149,7 +246,8 @@ func (c *OpenFGAClient) CheckPermission(ctx context.Context, user, relation, obj
 	}
 
 	resp, err := c.client.Check(ctx).Body(body).Options(client.ClientCheckOptions{
-		StoreId: &c.storeID,
+		StoreId:     &c.storeID,
+		Consistency: opts.consistency,
 	}).Execute()
 	if err != nil {
 		return false, fmt.Errorf("failed to check permission: %v", err)
@@ -159,11 +257,20 @@ func (c *OpenFGAClient) CheckPermission(ctx context.Context, user, relation, obj
 }
 
 // 複数の権限をバッチでチェック
-func (c *OpenFGAClient) BatchCheck(ctx context.Context, checks []CheckRequest) ([]bool, error) {
+// 監査ログはCheckPermissionを通じて1件ごとに記録され、optsはすべてのチェックに適用される
+// API呼び出しの前にすべてのリクエストを検証し、不正なリクエストがあれば *ValidationError を
+// ラップした *CheckError を返す
+func (c *OpenFGAClient) BatchCheck(ctx context.Context, checks []CheckRequest, opts ...CheckOption) ([]bool, error) {
+	for i, check := range checks {
+		if err := check.Validate(); err != nil {
+			return nil, &CheckError{Index: i, CheckRequest: check, Err: err}
+		}
+	}
+
 	results := make([]bool, len(checks))
 
 	for i, check := range checks {
-		allowed, err := c.CheckPermission(ctx, check.User, check.Relation, check.Object)
+		allowed, err := c.CheckPermission(ctx, check.User, check.Relation, check.Object, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to check permission for %s %s %s: %v",
 				check.User, check.Relation, check.Object, err)
@@ -180,33 +287,178 @@ type CheckRequest struct {
 	Object   string
 }
 
+// チェックごとに個別のコンテキストを指定できる権限チェックリクエスト
+type ContextualCheckRequest struct {
+	CheckRequest
+	// 親コンテキストに加えて適用するコンテキスト（nilの場合は親コンテキストのみ）
+	// キャンセルと期限のみが反映され、値は引き継がれない
+	ExtraContext context.Context
+}
+
+// 個別の権限チェックの失敗を示すエラー
+type CheckError struct {
+	Index int
+	CheckRequest
+	Err error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("failed to check permission for %s %s %s: %v", e.User, e.Relation, e.Object, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// チェックごとのコンテキストを親コンテキストと組み合わせて権限をチェック
+// 一部のチェックが失敗しても残りのチェックは続行し、失敗したチェックは false として
+// *CheckError をまとめたエラーとともに返す
+func (c *OpenFGAClient) BatchCheckWithContexts(ctx context.Context, checks []ContextualCheckRequest) ([]bool, error) {
+	results := make([]bool, len(checks))
+	var errs []error
+
+	for i, check := range checks {
+		allowed, err := c.checkWithExtraContext(ctx, check)
+		if err != nil {
+			errs = append(errs, &CheckError{Index: i, CheckRequest: check.CheckRequest, Err: err})
+			continue
+		}
+		results[i] = allowed
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// 親コンテキストとExtraContextのどちらかが終了した時点でキャンセルされるコンテキストでチェック
+func (c *OpenFGAClient) checkWithExtraContext(ctx context.Context, check ContextualCheckRequest) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if extra := check.ExtraContext; extra != nil {
+		// 終了済みのコンテキストに対するAfterFuncは非同期に実行されるため先にキャンセルする
+		if extra.Err() != nil {
+			cancel()
+		}
+		if deadline, ok := extra.Deadline(); ok {
+			var cancelDeadline context.CancelFunc
+			ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+			defer cancelDeadline()
+		}
+		stop := context.AfterFunc(extra, cancel)
+		defer stop()
+	}
+
+	return c.CheckPermission(ctx, check.User, check.Relation, check.Object)
+}
+
+// BatchCheckWithOptionsの設定
+type BatchOptions struct {
+	// 各チェックに適用するタイムアウト（0の場合は親コンテキストの期限のみ）
+	PerCheckTimeout time.Duration
+}
+
+// チェックごとのタイムアウトを設定して複数の権限をチェック
+// BatchCheckWithContextsと同様に、一部のチェックが失敗しても残りのチェックは続行し、
+// 失敗したチェックは false として *CheckError をまとめたエラーとともに返す
+func (c *OpenFGAClient) BatchCheckWithOptions(ctx context.Context, checks []CheckRequest, opts BatchOptions) ([]bool, error) {
+	results := make([]bool, len(checks))
+	var errs []error
+
+	for i, check := range checks {
+		allowed, err := c.checkWithTimeout(ctx, check, opts.PerCheckTimeout)
+		if err != nil {
+			errs = append(errs, &CheckError{Index: i, CheckRequest: check, Err: err})
+			continue
+		}
+		results[i] = allowed
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// timeoutが0より大きい場合はその時間で打ち切って権限をチェック
+func (c *OpenFGAClient) checkWithTimeout(ctx context.Context, check CheckRequest, timeout time.Duration) (bool, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	allowed, err := c.CheckPermission(ctx, check.User, check.Relation, check.Object)
+	// SDKのエラーからはタイムアウトを判別できないため、コンテキストのエラーを優先する
+	if err != nil && ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return allowed, err
+}
+
+// runPermissionTestsの出力形式
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// JSON出力における1件の権限チェック結果
+type PermissionCheckResult struct {
+	User     string `json:"user"`
+	Relation string `json:"relation"`
+	Object   string `json:"object"`
+	Allowed  bool   `json:"allowed"`
+	Error    string `json:"error,omitempty"`
+}
+
 func main() {
-	apiURL := os.Getenv("OPENFGA_API_URL")
+	output := flag.String("output", outputText, "Output format of permission check results: text or json")
+	auditPath := flag.String("audit-output", "", "File to append permission check results to as JSON Lines")
+	flag.Parse()
+
+	if *output != outputText && *output != outputJSON {
+		log.Fatalf("Invalid --output %q: must be %s or %s", *output, outputText, outputJSON)
+	}
+
+	apiURL := os.Getenv(envAPIURL)
 	if apiURL == "" {
-		apiURL = "https://openfga:18443"
+		apiURL = defaultAPIURL
 	}
 
-	storeID := os.Getenv("OPENFGA_STORE_ID")
+	storeID := os.Getenv(envStoreID)
 	if storeID == "" {
-		log.Fatal("OPENFGA_STORE_ID environment variable is required")
+		log.Fatal(&MissingEnvVarError{Name: envStoreID})
 	}
 
 	ctx := context.Background()
-	runWithSPIRE(ctx, apiURL, storeID)
+	if err := runWithSPIRE(ctx, apiURL, storeID, *output, *auditPath); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func runWithSPIRE(ctx context.Context, apiURL, storeID string) {
-	fmt.Println("=== SPIRE Authentication with OpenFGA ===")
+func runWithSPIRE(ctx context.Context, apiURL, storeID, output, auditPath string) error {
+	// JSON出力を壊さないよう見出しはテキスト形式の場合のみ出力
+	if output == outputText {
+		fmt.Println("=== SPIRE Authentication with OpenFGA ===")
+	}
 
 	client, err := NewOpenFGAClientWithSPIRE(apiURL, storeID)
 	if err != nil {
-		log.Fatalf("Failed to create OpenFGA client with SPIRE: %v", err)
+		return fmt.Errorf("failed to create OpenFGA client with SPIRE: %v", err)
+	}
+	defer client.Close()
+
+	var audit *auditOutput
+	if auditPath != "" {
+		audit, err = openAuditOutput(auditPath, storeID)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
 	}
 
-	runPermissionTests(ctx, client)
+	return runPermissionTests(ctx, client.OpenFGAClient, output, os.Stdout, audit)
 }
 
-func runPermissionTests(ctx context.Context, client *OpenFGAClient) {
+// 権限チェックを実行して結果をwに出力する。いずれかのチェックがエラーになった場合はエラーを返す
+// auditがnilでなければ各チェック結果を監査出力にも書き出す
+func runPermissionTests(ctx context.Context, client PermissionChecker, output string, w io.Writer, audit *auditOutput) error {
 	testCases := []CheckRequest{
 		{"user:alice", "can_read", "resource:public-data"},
 		{"user:alice", "can_write", "resource:public-data"},
@@ -217,19 +469,48 @@ func runPermissionTests(ctx context.Context, client *OpenFGAClient) {
 		{"user:frank", "can_write", "resource:user-interface-config"},
 	}
 
-	fmt.Println("\n--- Permission Check Results ---")
+	results := make([]PermissionCheckResult, 0, len(testCases))
+	failed := 0
+
+	if output == outputText {
+		fmt.Fprintln(w, "\n--- Permission Check Results ---")
+	}
 	for _, test := range testCases {
+		result := PermissionCheckResult{User: test.User, Relation: test.Relation, Object: test.Object}
+
+		start := time.Now()
 		allowed, err := client.CheckPermission(ctx, test.User, test.Relation, test.Object)
+		audit.write(test, allowed, time.Since(start), err)
 		if err != nil {
-			fmt.Printf("ERROR: %s %s %s -> %v\n", test.User, test.Relation, test.Object, err)
+			failed++
+			result.Error = err.Error()
+			results = append(results, result)
+			if output == outputText {
+				fmt.Fprintf(w, "ERROR: %s %s %s -> %v\n", test.User, test.Relation, test.Object, err)
+			}
 			continue
 		}
 
-		status := "❌ DENIED"
-		if allowed {
-			status = "✅ ALLOWED"
+		result.Allowed = allowed
+		results = append(results, result)
+		if output == outputText {
+			status := "❌ DENIED"
+			if allowed {
+				status = "✅ ALLOWED"
+			}
+
+			fmt.Fprintf(w, "%s: %s %s %s\n", status, test.User, test.Relation, test.Object)
+		}
+	}
+
+	if output == outputJSON {
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			return fmt.Errorf("failed to encode results: %v", err)
 		}
+	}
 
-		fmt.Printf("%s: %s %s %s\n", status, test.User, test.Relation, test.Object)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d permission checks failed", failed, len(testCases))
 	}
+	return nil
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	openfga "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+)
+
+// 書き込む認可モデルのスキーマバージョン
+const modelSchemaVersion = "1.1"
+
+// Goの構造体で記述する認可モデル
+type AuthorizationModel struct {
+	TypeDefinitions []TypeDefinition
+}
+
+// 1つの型とそのリレーションの定義
+type TypeDefinition struct {
+	Type      string
+	Relations map[string]Relation
+}
+
+// リレーションの定義
+// Usersetsのいずれかに該当するユーザーがリレーションを持つ（複数の場合はunion）
+// 各要素は以下のいずれかの書式で指定する
+//
+//	"[user]", "[user, group#member]", "[user:*]"  直接割り当て可能なユーザーの型
+//	"editor"                                     同じオブジェクトの別のリレーション
+//	"viewer from parent"                         parentで関連付けられたオブジェクトのリレーション
+type Relation struct {
+	Usersets []string
+}
+
+// リレーションを持たない型の定義を作成
+func NewTypeDefinition(typeName string) *TypeDefinition {
+	return &TypeDefinition{Type: typeName}
+}
+
+// リレーションを追加した型の定義を返す
+func (t *TypeDefinition) WithRelation(name string, usersets ...string) *TypeDefinition {
+	if t.Relations == nil {
+		t.Relations = make(map[string]Relation)
+	}
+	t.Relations[name] = Relation{Usersets: usersets}
+	return t
+}
+
+// 構造体で記述した認可モデルをOpenFGAに書き込み、作成されたモデルのIDを返す
+func (c *OpenFGAClient) WriteModelFromStruct(ctx context.Context, model AuthorizationModel) (string, error) {
+	body, err := model.toRequest()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.client.WriteAuthorizationModel(ctx).Body(body).Options(client.ClientWriteAuthorizationModelOptions{
+		StoreId: &c.storeID,
+	}).Execute()
+	if err != nil {
+		return "", fmt.Errorf("failed to write authorization model: %v", err)
+	}
+
+	return resp.GetAuthorizationModelId(), nil
+}
+
+// OpenFGA APIのWriteAuthorizationModelリクエストに変換
+func (m AuthorizationModel) toRequest() (client.ClientWriteAuthorizationModelRequest, error) {
+	req := client.ClientWriteAuthorizationModelRequest{
+		SchemaVersion:   modelSchemaVersion,
+		TypeDefinitions: make([]openfga.TypeDefinition, 0, len(m.TypeDefinitions)),
+	}
+
+	for _, td := range m.TypeDefinitions {
+		typeDef, err := td.toSDK()
+		if err != nil {
+			return req, err
+		}
+		req.TypeDefinitions = append(req.TypeDefinitions, typeDef)
+	}
+
+	return req, nil
+}
+
+func (t TypeDefinition) toSDK() (openfga.TypeDefinition, error) {
+	if t.Type == "" {
+		return openfga.TypeDefinition{}, fmt.Errorf("type name is required")
+	}
+
+	typeDef := openfga.TypeDefinition{Type: t.Type}
+	if len(t.Relations) == 0 {
+		return typeDef, nil
+	}
+
+	relations := make(map[string]openfga.Userset, len(t.Relations))
+	metadata := make(map[string]openfga.RelationMetadata, len(t.Relations))
+	for name, relation := range t.Relations {
+		userset, directTypes, err := relation.toSDK()
+		if err != nil {
+			return openfga.TypeDefinition{}, fmt.Errorf("invalid relation %s#%s: %v", t.Type, name, err)
+		}
+		relations[name] = userset
+		metadata[name] = openfga.RelationMetadata{DirectlyRelatedUserTypes: &directTypes}
+	}
+
+	typeDef.Relations = &relations
+	typeDef.Metadata = &openfga.Metadata{Relations: &metadata}
+	return typeDef, nil
+}
+
+// リレーションをUsersetと直接割り当て可能な型の一覧に変換
+func (r Relation) toSDK() (openfga.Userset, []openfga.RelationReference, error) {
+	if len(r.Usersets) == 0 {
+		return openfga.Userset{}, nil, fmt.Errorf("at least one userset is required")
+	}
+
+	directTypes := []openfga.RelationReference{}
+	var children []openfga.Userset
+	for _, userset := range r.Usersets {
+		userset = strings.TrimSpace(userset)
+
+		if strings.HasPrefix(userset, "[") && strings.HasSuffix(userset, "]") {
+			refs, err := parseDirectTypes(userset[1 : len(userset)-1])
+			if err != nil {
+				return openfga.Userset{}, nil, err
+			}
+			directTypes = append(directTypes, refs...)
+			continue
+		}
+
+		child, err := parseUserset(userset)
+		if err != nil {
+			return openfga.Userset{}, nil, err
+		}
+		children = append(children, child)
+	}
+
+	// 直接割り当て可能な型がある場合は this を先頭に置く
+	if len(directTypes) > 0 {
+		children = append([]openfga.Userset{{This: &map[string]interface{}{}}}, children...)
+	}
+
+	if len(children) == 1 {
+		return children[0], directTypes, nil
+	}
+	return openfga.Userset{Union: &openfga.Usersets{Child: children}}, directTypes, nil
+}
+
+// "user, group#member, user:*" 形式の型の一覧を解析
+func parseDirectTypes(value string) ([]openfga.RelationReference, error) {
+	var refs []openfga.RelationReference
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty type in [%s]", value)
+		}
+
+		switch {
+		case strings.HasSuffix(item, ":*"):
+			refs = append(refs, openfga.RelationReference{
+				Type:     strings.TrimSuffix(item, ":*"),
+				Wildcard: &map[string]interface{}{},
+			})
+		case strings.Contains(item, "#"):
+			typeName, relation, _ := strings.Cut(item, "#")
+			refs = append(refs, openfga.RelationReference{Type: typeName, Relation: &relation})
+		default:
+			refs = append(refs, openfga.RelationReference{Type: item})
+		}
+	}
+	return refs, nil
+}
+
+// "editor" または "viewer from parent" 形式のUsersetを解析
+func parseUserset(value string) (openfga.Userset, error) {
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 1:
+		return openfga.Userset{ComputedUserset: &openfga.ObjectRelation{Relation: &fields[0]}}, nil
+	case len(fields) == 3 && fields[1] == "from":
+		return openfga.Userset{TupleToUserset: &openfga.TupleToUserset{
+			Tupleset:        openfga.ObjectRelation{Relation: &fields[2]},
+			ComputedUserset: openfga.ObjectRelation{Relation: &fields[0]},
+		}}, nil
+	default:
+		return openfga.Userset{}, fmt.Errorf("invalid userset %q", value)
+	}
+}
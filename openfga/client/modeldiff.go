@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	openfga "github.com/openfga/go-sdk"
+	"github.com/openfga/go-sdk/client"
+)
+
+// 2つの認可モデルの差分
+// 各スライスは名前順に並ぶ
+type ModelDiff struct {
+	// bにのみ存在する型
+	AddedTypes []string
+	// aにのみ存在する型
+	RemovedTypes []string
+	// 両方に存在し、リレーションが異なる型
+	ChangedTypes []TypeDiff
+}
+
+// 1つの型のリレーションの差分
+type TypeDiff struct {
+	Type string
+	// bにのみ存在するリレーション
+	AddedRelations []string
+	// aにのみ存在するリレーション
+	RemovedRelations []string
+	// 両方に存在し、定義が異なるリレーション
+	ChangedRelations []string
+}
+
+// 差分がない場合はtrueを返す
+func (d ModelDiff) IsEmpty() bool {
+	return len(d.AddedTypes) == 0 && len(d.RemovedTypes) == 0 && len(d.ChangedTypes) == 0
+}
+
+// 認可モデルaからbへの変更を比較する
+// リレーションのUsersetsは順序や空白の違いを無視して比較する
+// nilのモデルは型を持たないモデルとして扱う
+func DiffModels(a, b *AuthorizationModel) ModelDiff {
+	typesA := modelTypes(a)
+	typesB := modelTypes(b)
+
+	var diff ModelDiff
+	for _, name := range sortedKeys(typesB) {
+		if _, ok := typesA[name]; !ok {
+			diff.AddedTypes = append(diff.AddedTypes, name)
+		}
+	}
+	for _, name := range sortedKeys(typesA) {
+		typeB, ok := typesB[name]
+		if !ok {
+			diff.RemovedTypes = append(diff.RemovedTypes, name)
+			continue
+		}
+		if typeDiff := diffRelations(name, typesA[name].Relations, typeB.Relations); typeDiff != nil {
+			diff.ChangedTypes = append(diff.ChangedTypes, *typeDiff)
+		}
+	}
+	return diff
+}
+
+// ストアに保存されている2つの認可モデルを取得して比較する
+func DiffModelsFromStore(ctx context.Context, c *OpenFGAClient, modelIDa, modelIDb string) (ModelDiff, error) {
+	a, err := c.readModel(ctx, modelIDa)
+	if err != nil {
+		return ModelDiff{}, err
+	}
+	b, err := c.readModel(ctx, modelIDb)
+	if err != nil {
+		return ModelDiff{}, err
+	}
+	return DiffModels(&a, &b), nil
+}
+
+// ストアから認可モデルを読み込み、構造体の表現に変換する
+func (c *OpenFGAClient) readModel(ctx context.Context, modelID string) (AuthorizationModel, error) {
+	resp, err := c.client.ReadAuthorizationModel(ctx).Options(client.ClientReadAuthorizationModelOptions{
+		StoreId:              &c.storeID,
+		AuthorizationModelId: &modelID,
+	}).Execute()
+	if err != nil {
+		return AuthorizationModel{}, fmt.Errorf("failed to read authorization model %s: %v", modelID, err)
+	}
+
+	model, err := modelFromSDK(resp.GetAuthorizationModel())
+	if err != nil {
+		return AuthorizationModel{}, fmt.Errorf("failed to convert authorization model %s: %v", modelID, err)
+	}
+	return model, nil
+}
+
+func modelTypes(m *AuthorizationModel) map[string]TypeDefinition {
+	types := make(map[string]TypeDefinition)
+	if m == nil {
+		return types
+	}
+	for _, td := range m.TypeDefinitions {
+		types[td.Type] = td
+	}
+	return types
+}
+
+// リレーションに差分がない場合はnilを返す
+func diffRelations(typeName string, a, b map[string]Relation) *TypeDiff {
+	diff := TypeDiff{Type: typeName}
+	for _, name := range sortedKeys(b) {
+		if _, ok := a[name]; !ok {
+			diff.AddedRelations = append(diff.AddedRelations, name)
+		}
+	}
+	for _, name := range sortedKeys(a) {
+		relationB, ok := b[name]
+		if !ok {
+			diff.RemovedRelations = append(diff.RemovedRelations, name)
+			continue
+		}
+		if !slices.Equal(normalizeUsersets(a[name].Usersets), normalizeUsersets(relationB.Usersets)) {
+			diff.ChangedRelations = append(diff.ChangedRelations, name)
+		}
+	}
+
+	if len(diff.AddedRelations) == 0 && len(diff.RemovedRelations) == 0 && len(diff.ChangedRelations) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+// Usersetsを比較可能な形に正規化する
+// 直接割り当て可能な型は1つの "[...]" にまとめて先頭に置き、残りは名前順に並べる
+func normalizeUsersets(usersets []string) []string {
+	var directTypes, others []string
+	for _, userset := range usersets {
+		userset = strings.TrimSpace(userset)
+		if strings.HasPrefix(userset, "[") && strings.HasSuffix(userset, "]") {
+			for _, item := range strings.Split(userset[1:len(userset)-1], ",") {
+				directTypes = append(directTypes, strings.TrimSpace(item))
+			}
+			continue
+		}
+		others = append(others, strings.Join(strings.Fields(userset), " "))
+	}
+
+	sort.Strings(directTypes)
+	sort.Strings(others)
+	if len(directTypes) == 0 {
+		return others
+	}
+	return append([]string{"[" + strings.Join(directTypes, ", ") + "]"}, others...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OpenFGA APIの認可モデルを構造体の表現に変換する
+// WriteModelFromStructで記述できないUserset（intersection, difference）を含む場合はエラーを返す
+func modelFromSDK(m openfga.AuthorizationModel) (AuthorizationModel, error) {
+	model := AuthorizationModel{TypeDefinitions: make([]TypeDefinition, 0, len(m.TypeDefinitions))}
+	for _, td := range m.TypeDefinitions {
+		typeDef := NewTypeDefinition(td.Type)
+		for name, userset := range td.GetRelations() {
+			var directTypes []openfga.RelationReference
+			if td.Metadata != nil && td.Metadata.Relations != nil {
+				if metadata, ok := (*td.Metadata.Relations)[name]; ok && metadata.DirectlyRelatedUserTypes != nil {
+					directTypes = *metadata.DirectlyRelatedUserTypes
+				}
+			}
+
+			usersets, err := usersetsFromSDK(userset, directTypes)
+			if err != nil {
+				return AuthorizationModel{}, fmt.Errorf("invalid relation %s#%s: %v", td.Type, name, err)
+			}
+			typeDef.WithRelation(name, usersets...)
+		}
+		model.TypeDefinitions = append(model.TypeDefinitions, *typeDef)
+	}
+	return model, nil
+}
+
+func usersetsFromSDK(userset openfga.Userset, directTypes []openfga.RelationReference) ([]string, error) {
+	switch {
+	case userset.This != nil:
+		items := make([]string, 0, len(directTypes))
+		for _, ref := range directTypes {
+			switch {
+			case ref.Wildcard != nil:
+				items = append(items, ref.Type+":*")
+			case ref.Relation != nil:
+				items = append(items, ref.Type+"#"+*ref.Relation)
+			default:
+				items = append(items, ref.Type)
+			}
+		}
+		return []string{"[" + strings.Join(items, ", ") + "]"}, nil
+	case userset.ComputedUserset != nil:
+		return []string{userset.ComputedUserset.GetRelation()}, nil
+	case userset.TupleToUserset != nil:
+		ttu := userset.TupleToUserset
+		return []string{ttu.ComputedUserset.GetRelation() + " from " + ttu.Tupleset.GetRelation()}, nil
+	case userset.Union != nil:
+		var usersets []string
+		for _, child := range userset.Union.Child {
+			children, err := usersetsFromSDK(child, directTypes)
+			if err != nil {
+				return nil, err
+			}
+			usersets = append(usersets, children...)
+		}
+		return usersets, nil
+	default:
+		return nil, fmt.Errorf("unsupported userset")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLファイルに記述する権限テストケース
+type PermissionTestCase struct {
+	User            string `yaml:"user"`
+	Relation        string `yaml:"relation"`
+	Object          string `yaml:"object"`
+	ExpectedAllowed bool   `yaml:"expected_allowed"`
+}
+
+// 権限テストファイルの内容
+type permissionTestFile struct {
+	Tests []PermissionTestCase `yaml:"tests"`
+}
+
+// 1件の権限テストの結果
+type PermissionTestResult struct {
+	PermissionTestCase
+	// 権限チェックの結果（Errがnilの場合のみ有効）
+	Allowed bool
+	// 権限チェックが失敗した場合のエラー
+	Err error
+}
+
+// 権限チェックが成功し、結果が期待どおりの場合はtrueを返す
+func (r PermissionTestResult) Passed() bool {
+	return r.Err == nil && r.Allowed == r.ExpectedAllowed
+}
+
+// 権限テストファイルを読み込む
+// 設定ミスに気づけるよう未知のフィールドと不正なチェック（type:id 形式でないユーザーなど）はエラーにする
+func LoadPermissionTests(testFile string) ([]PermissionTestCase, error) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission test file: %v", err)
+	}
+
+	var file permissionTestFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse permission test file %s: %v", testFile, err)
+	}
+	if len(file.Tests) == 0 {
+		return nil, fmt.Errorf("no tests found in %s", testFile)
+	}
+
+	for i, test := range file.Tests {
+		if _, err := NewCheckRequest(test.User, test.Relation, test.Object); err != nil {
+			return nil, fmt.Errorf("invalid test %d in %s: %v", i, testFile, err)
+		}
+	}
+	return file.Tests, nil
+}
+
+// YAMLファイルの権限テストを順に実行し、結果を返す
+// 個々の権限チェックの失敗は結果のErrに記録し、ファイルを読み込めない場合のみエラーを返す
+//
+//	tests:
+//	  - user: user:alice
+//	    relation: can_read
+//	    object: resource:public-data
+//	    expected_allowed: true
+func RunPermissionTestsFromFile(ctx context.Context, client PermissionChecker, testFile string) ([]PermissionTestResult, error) {
+	tests, err := LoadPermissionTests(testFile)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PermissionTestResult, 0, len(tests))
+	for _, test := range tests {
+		allowed, err := client.CheckPermission(ctx, test.User, test.Relation, test.Object)
+		results = append(results, PermissionTestResult{PermissionTestCase: test, Allowed: allowed, Err: err})
+	}
+	return results, nil
+}
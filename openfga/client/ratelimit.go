@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// OpenFGA APIへのリクエスト数を制限するオプション
+// rpsは1秒あたりのリクエスト数、burstは一度に許可するリクエスト数（1未満の場合は1）
+// CheckPermission・BatchCheckを含むすべての権限チェックがAPI呼び出し前に待機する
+func WithRateLimit(rps float64, burst int) OpenFGAOption {
+	if burst < 1 {
+		burst = 1
+	}
+	return func(c *OpenFGAClient) {
+		c.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// 設定されているレート（1秒あたりのリクエスト数）を返す
+// レート制限が設定されていない場合は0を返す
+func (c *OpenFGAClient) CurrentRate() float64 {
+	if c.limiter == nil {
+		return 0
+	}
+	return float64(c.limiter.Limit())
+}
+
+// レート制限が設定されている場合、API呼び出しが許可されるまで待機
+// 待機中にコンテキストが終了した場合はそのエラーを返す
+func (c *OpenFGAClient) waitRateLimit(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("failed to wait for rate limiter: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 生成するRegoモジュールのパッケージ名
+const regoPackage = "openfga.tuples"
+
+// ストアのすべてのタプルをOPAのRegoモジュールに変換する（監査用）
+// オブジェクトの型・リレーションごとにallowルールを生成し、入力 {"user", "relation", "object"} が
+// いずれかのタプルに一致する場合にallowとなる
+// 出力は型・リレーション・オブジェクト・ユーザーの順にソートされるため、差分を比較できる
+func ExportTuplesToRego(ctx context.Context, c *OpenFGAClient) (string, error) {
+	tuples, err := c.ReadAllTuples(ctx)
+	if err != nil {
+		return "", err
+	}
+	return tuplesToRego(tuples), nil
+}
+
+// タプルをRegoモジュールに変換する
+func tuplesToRego(tuples []TupleKey) string {
+	// 型 -> リレーション -> タプル
+	groups := make(map[string]map[string][]TupleKey)
+	for _, t := range tuples {
+		typeName, _, _ := strings.Cut(t.Object, ":")
+		if groups[typeName] == nil {
+			groups[typeName] = make(map[string][]TupleKey)
+		}
+		groups[typeName][t.Relation] = append(groups[typeName][t.Relation], t)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by ExportTuplesToRego from OpenFGA relationship tuples.\n")
+	fmt.Fprintf(&b, "package %s\n\nimport rego.v1\n\ndefault allow := false\n", regoPackage)
+
+	for _, typeName := range sortedKeys(groups) {
+		relations := groups[typeName]
+		for _, relation := range sortedKeys(relations) {
+			group := relations[relation]
+			sort.Slice(group, func(i, j int) bool {
+				if group[i].Object != group[j].Object {
+					return group[i].Object < group[j].Object
+				}
+				return group[i].User < group[j].User
+			})
+
+			fmt.Fprintf(&b, "\n# %s#%s\nallow if {\n", typeName, relation)
+			fmt.Fprintf(&b, "\tinput.relation == %s\n", regoString(relation))
+			b.WriteString("\t[input.object, input.user] in {\n")
+			for _, t := range group {
+				fmt.Fprintf(&b, "\t\t[%s, %s],\n", regoString(t.Object), regoString(t.User))
+			}
+			b.WriteString("\t}\n}\n")
+		}
+	}
+
+	return b.String()
+}
+
+// Regoの文字列リテラルはJSONの文字列と互換のためJSONとしてエンコードする
+func regoString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// ExportTuplesToRegoで生成したRegoモジュールをタプルに戻す
+// 生成した形式のみを解析し、任意のRegoは扱わない
+func ParseRegoToTuples(rego string) ([]TupleKey, error) {
+	var tuples []TupleKey
+	var relation string
+	hasPackage := false
+
+	scanner := bufio.NewScanner(strings.NewReader(rego))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "package "):
+			if name := strings.TrimSpace(strings.TrimPrefix(line, "package ")); name != regoPackage {
+				return nil, fmt.Errorf("unexpected package %q on line %d", name, lineNum)
+			}
+			hasPackage = true
+		case line == "allow if {":
+			// リレーションは各ルールの先頭で指定される
+			relation = ""
+		case strings.HasPrefix(line, "input.relation == "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "input.relation == ")), &relation); err != nil {
+				return nil, fmt.Errorf("invalid relation on line %d: %v", lineNum, err)
+			}
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "],"):
+			if relation == "" {
+				return nil, fmt.Errorf("tuple outside of an allow rule on line %d", lineNum)
+			}
+			var pair [2]string
+			if err := json.Unmarshal([]byte(strings.TrimSuffix(line, ",")), &pair); err != nil {
+				return nil, fmt.Errorf("invalid tuple on line %d: %v", lineNum, err)
+			}
+			tuples = append(tuples, TupleKey{User: pair[1], Relation: relation, Object: pair[0]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Rego module: %v", err)
+	}
+	if !hasPackage {
+		return nil, fmt.Errorf("package %s not found", regoPackage)
+	}
+
+	return tuples, nil
+}
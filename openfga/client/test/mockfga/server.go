@@ -0,0 +1,220 @@
+// Package mockfga は単体テスト用の最小限のOpenFGAサーバーを提供する
+// Check APIは事前に登録したフィクスチャに従って許可/拒否を返し、Read APIは許可のフィクスチャをタプルとして返す
+// WriteAuthorizationModel APIは受け取ったモデルを記録し、ReadAuthorizationModel APIで返す
+package mockfga
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// httptest.Serverで動作するOpenFGAのモックサーバー
+type MockFGAServer struct {
+	server   *httptest.Server
+	fixtures map[string]bool
+
+	mu     sync.Mutex
+	checks []CheckRequest
+	models []json.RawMessage
+}
+
+// サーバーが受け取ったCheckリクエスト
+type CheckRequest struct {
+	StoreID  string
+	User     string
+	Relation string
+	Object   string
+	// リクエストで指定された整合性の設定（未指定の場合は空）
+	Consistency string
+}
+
+// フィクスチャのキーを生成する（OpenFGAのタプル表記 object#relation@user）
+func Key(user, relation, object string) string {
+	return object + "#" + relation + "@" + user
+}
+
+// フィクスチャを読み込んだモックサーバーを起動する
+// キーはKeyで生成し、登録されていないタプルは拒否（allowed: false）として扱う
+func NewMockFGAServer(fixtures map[string]bool) *MockFGAServer {
+	s := &MockFGAServer{fixtures: make(map[string]bool, len(fixtures))}
+	for k, v := range fixtures {
+		s.fixtures[k] = v
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /stores/{store_id}/check", s.handleCheck)
+	mux.HandleFunc("POST /stores/{store_id}/read", s.handleRead)
+	mux.HandleFunc("POST /stores/{store_id}/authorization-models", s.handleWriteAuthorizationModel)
+	mux.HandleFunc("GET /stores/{store_id}/authorization-models/{id}", s.handleReadAuthorizationModel)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// NewOpenFGAClientに渡すAPIのURL
+func (s *MockFGAServer) Addr() string {
+	return s.server.URL
+}
+
+// サーバーを停止する
+func (s *MockFGAServer) Close() {
+	s.server.Close()
+}
+
+// これまでに受け取ったCheckリクエストを返す
+func (s *MockFGAServer) Checks() []CheckRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CheckRequest(nil), s.checks...)
+}
+
+// これまでに書き込まれた認可モデルのJSONを返す
+func (s *MockFGAServer) AuthorizationModels() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]json.RawMessage(nil), s.models...)
+}
+
+// 書き込まれた認可モデルを記録し、書き込み順に連番のモデルIDを返す
+func (s *MockFGAServer) handleWriteAuthorizationModel(w http.ResponseWriter, r *http.Request) {
+	var model json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&model); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "validation_error", "message": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	s.models = append(s.models, model)
+	id := ModelID(len(s.models))
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, map[string]string{"authorization_model_id": id})
+}
+
+// 記録した認可モデルにIDを付けて返す
+func (s *MockFGAServer) handleReadAuthorizationModel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	var model json.RawMessage
+	for i, m := range s.models {
+		if ModelID(i+1) == id {
+			model = m
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if model == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"code": "authorization_model_not_found", "message": "authorization model " + id + " not found"})
+		return
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(model, &body); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"code": "internal_error", "message": err.Error()})
+		return
+	}
+	body["id"] = id
+	writeJSON(w, http.StatusOK, map[string]any{"authorization_model": body})
+}
+
+// n番目（1から数える）に書き込まれた認可モデルのID（SDKが検証するULID形式）
+func ModelID(n int) string {
+	return fmt.Sprintf("01JBQFM0DE%016d", n)
+}
+
+func (s *MockFGAServer) handleCheck(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TupleKey struct {
+			User     string `json:"user"`
+			Relation string `json:"relation"`
+			Object   string `json:"object"`
+		} `json:"tuple_key"`
+		Consistency string `json:"consistency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "validation_error", "message": err.Error()})
+		return
+	}
+
+	tuple := body.TupleKey
+	s.mu.Lock()
+	s.checks = append(s.checks, CheckRequest{
+		StoreID:     r.PathValue("store_id"),
+		User:        tuple.User,
+		Relation:    tuple.Relation,
+		Object:      tuple.Object,
+		Consistency: body.Consistency,
+	})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]bool{"allowed": s.fixtures[Key(tuple.User, tuple.Relation, tuple.Object)]})
+}
+
+// 許可（true）のフィクスチャをキー順にタプルとして返す
+// continuation_tokenは次に返すタプルの位置
+func (s *MockFGAServer) handleRead(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PageSize          int    `json:"page_size"`
+		ContinuationToken string `json:"continuation_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "validation_error", "message": err.Error()})
+		return
+	}
+
+	var keys []string
+	for k, allowed := range s.fixtures {
+		if allowed {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if body.ContinuationToken != "" {
+		n, err := strconv.Atoi(body.ContinuationToken)
+		if err != nil || n < 0 || n > len(keys) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"code": "invalid_continuation_token", "message": "invalid continuation token"})
+			return
+		}
+		start = n
+	}
+	end := len(keys)
+	if body.PageSize > 0 && start+body.PageSize < end {
+		end = start + body.PageSize
+	}
+
+	type tupleKey struct {
+		User     string `json:"user"`
+		Relation string `json:"relation"`
+		Object   string `json:"object"`
+	}
+	type tuple struct {
+		Key tupleKey `json:"key"`
+	}
+	tuples := make([]tuple, 0, end-start)
+	for _, k := range keys[start:end] {
+		object, rest, _ := strings.Cut(k, "#")
+		relation, user, _ := strings.Cut(rest, "@")
+		tuples = append(tuples, tuple{Key: tupleKey{User: user, Relation: relation, Object: object}})
+	}
+
+	token := ""
+	if end < len(keys) {
+		token = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tuples": tuples, "continuation_token": token})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,154 @@
+package mockfga
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestMockFGAServer(t *testing.T) {
+	s := NewMockFGAServer(map[string]bool{
+		Key("user:alice", "can_read", "resource:doc"): true,
+		Key("user:bob", "can_read", "resource:doc"):   false,
+	})
+	defer s.Close()
+
+	tests := []struct {
+		name    string
+		user    string
+		allowed bool
+	}{
+		{name: "allowed", user: "user:alice", allowed: true},
+		{name: "denied", user: "user:bob", allowed: false},
+		{name: "unknown", user: "user:charlie", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"tuple_key":{"user":"` + tt.user + `","relation":"can_read","object":"resource:doc"}}`
+			resp := post(t, s.Addr()+"/stores/store-1/check", body)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var got struct {
+				Allowed bool `json:"allowed"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+			assert.Equal(t, tt.allowed, got.Allowed)
+		})
+	}
+
+	checks := s.Checks()
+	require.Len(t, checks, 3)
+	assert.Equal(t, CheckRequest{StoreID: "store-1", User: "user:alice", Relation: "can_read", Object: "resource:doc"}, checks[0])
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp := post(t, s.Addr()+"/stores/store-1/check", "{")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		resp := post(t, s.Addr()+"/stores/store-1/expand", "{}")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestMockFGAServer_WriteAuthorizationModel(t *testing.T) {
+	s := NewMockFGAServer(nil)
+	defer s.Close()
+
+	for i := 1; i <= 2; i++ {
+		resp := post(t, s.Addr()+"/stores/store-1/authorization-models", `{"schema_version":"1.1","type_definitions":[{"type":"user"}]}`)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var got struct {
+			AuthorizationModelID string `json:"authorization_model_id"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		assert.Equal(t, ModelID(i), got.AuthorizationModelID)
+	}
+
+	models := s.AuthorizationModels()
+	require.Len(t, models, 2)
+	assert.JSONEq(t, `{"schema_version":"1.1","type_definitions":[{"type":"user"}]}`, string(models[0]))
+}
+
+func TestMockFGAServer_ReadAuthorizationModel(t *testing.T) {
+	s := NewMockFGAServer(nil)
+	defer s.Close()
+
+	resp := post(t, s.Addr()+"/stores/store-1/authorization-models", `{"schema_version":"1.1","type_definitions":[{"type":"user"}]}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, err := http.Get(s.Addr() + "/stores/store-1/authorization-models/" + ModelID(1))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var got struct {
+		AuthorizationModel json.RawMessage `json:"authorization_model"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.JSONEq(t, `{"id":"`+ModelID(1)+`","schema_version":"1.1","type_definitions":[{"type":"user"}]}`, string(got.AuthorizationModel))
+
+	resp, err = http.Get(s.Addr() + "/stores/store-1/authorization-models/" + ModelID(2))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestMockFGAServer_Read(t *testing.T) {
+	s := NewMockFGAServer(map[string]bool{
+		Key("user:alice", "viewer", "document:1"):       true,
+		Key("group:eng#member", "editor", "document:1"): true,
+		Key("user:bob", "viewer", "document:2"):         true,
+		Key("user:carol", "viewer", "document:3"):       false,
+	})
+	defer s.Close()
+
+	type page struct {
+		Tuples []struct {
+			Key struct {
+				User     string `json:"user"`
+				Relation string `json:"relation"`
+				Object   string `json:"object"`
+			} `json:"key"`
+		} `json:"tuples"`
+		ContinuationToken string `json:"continuation_token"`
+	}
+	read := func(body string) page {
+		resp := post(t, s.Addr()+"/stores/store-1/read", body)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var got page
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+		return got
+	}
+
+	first := read(`{"page_size":2}`)
+	require.Len(t, first.Tuples, 2)
+	assert.Equal(t, "group:eng#member", first.Tuples[0].Key.User)
+	assert.Equal(t, "editor", first.Tuples[0].Key.Relation)
+	assert.Equal(t, "document:1", first.Tuples[0].Key.Object)
+	require.NotEmpty(t, first.ContinuationToken)
+
+	// 拒否のフィクスチャはタプルとして返さない
+	second := read(`{"page_size":2,"continuation_token":"` + first.ContinuationToken + `"}`)
+	require.Len(t, second.Tuples, 1)
+	assert.Equal(t, "user:bob", second.Tuples[0].Key.User)
+	assert.Empty(t, second.ContinuationToken)
+
+	resp := post(t, s.Addr()+"/stores/store-1/read", `{"continuation_token":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
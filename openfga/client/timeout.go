@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// 1回の権限チェックにかける時間の上限を設定するオプション
+// CheckPermissionとBatchCheckの各チェックは、親コンテキストの期限とdのうち早い方で打ち切られる
+// 親コンテキストに期限がない場合もdで打ち切られる（0以下の場合は上限なし）
+func WithMaxCheckTimeout(d time.Duration) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		c.maxCheckTimeout = d
+	}
+}
+
+type checkResult struct {
+	allowed bool
+	err     error
+}
+
+// 上限が設定されている場合、その時間で打ち切って権限をチェック
+// SDKはリトライの待機中にコンテキストの終了を確認しないため、期限に達した時点で
+// 応答を待たずにコンテキストのエラーを返す（SDKの呼び出しはバックグラウンドで終了する）
+func (c *OpenFGAClient) checkPermissionWithMaxTimeout(ctx context.Context, user, relation, object string, opts checkOptions) (bool, error) {
+	if c.maxCheckTimeout <= 0 {
+		return c.checkPermission(ctx, user, relation, object, opts)
+	}
+
+	// context.WithTimeoutは親コンテキストの期限の方が早ければそちらを維持する
+	ctx, cancel := context.WithTimeout(ctx, c.maxCheckTimeout)
+	defer cancel()
+
+	done := make(chan checkResult, 1)
+	go func() {
+		allowed, err := c.checkPermission(ctx, user, relation, object, opts)
+		done <- checkResult{allowed: allowed, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.allowed, result.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openfga/go-sdk/client"
+)
+
+// OpenFGAサーバーが1回のWriteで受け付けるタプル数の既定値（書き込みと削除の合計）
+const defaultMaxWriteTransactionSize = 100
+
+// 書き込み・削除するリレーションシップタプル
+type TupleKey struct {
+	User     string
+	Relation string
+	Object   string
+}
+
+// ReadAllTuplesが1回のReadで取得するタプル数
+const readTuplesPageSize = 100
+
+// 1回のWriteで送信するタプル数の上限を変更するオプション
+// サーバーの max-tuples-per-write に合わせて設定する（1未満の場合は既定値の100）
+func WithMaxWriteTransactionSize(n int) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		if n < 1 {
+			n = defaultMaxWriteTransactionSize
+		}
+		c.maxWriteTransactionSize = n
+	}
+}
+
+// 1回のWriteで送信するタプルの組
+type writeChunk struct {
+	writes  []TupleKey
+	deletes []TupleKey
+}
+
+// タプルの書き込みと削除をまとめて実行する
+// 上限以内であれば1回のWriteでアトミックに反映される
+// 上限を超える場合は複数のWriteに分割して順に送信し、途中で失敗した場合は
+// それまでに反映したWriteを逆の操作で取り消す（取り消しは元のコンテキストがキャンセルされても実行する）
+func (c *OpenFGAClient) BatchWriteTuples(ctx context.Context, writes, deletes []TupleKey) error {
+	chunks := splitWriteChunks(writes, deletes, c.writeTransactionSize())
+
+	for i, chunk := range chunks {
+		if err := c.writeChunk(ctx, chunk); err != nil {
+			err = fmt.Errorf("failed to write tuples (transaction %d/%d): %v", i+1, len(chunks), err)
+			if rollbackErr := c.rollbackChunks(context.WithoutCancel(ctx), chunks[:i]); rollbackErr != nil {
+				return fmt.Errorf("%v; %v", err, rollbackErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *OpenFGAClient) writeTransactionSize() int {
+	if c.maxWriteTransactionSize < 1 {
+		return defaultMaxWriteTransactionSize
+	}
+	return c.maxWriteTransactionSize
+}
+
+// 反映済みのWriteを新しい順に逆の操作で取り消す
+func (c *OpenFGAClient) rollbackChunks(ctx context.Context, applied []writeChunk) error {
+	for i := len(applied) - 1; i >= 0; i-- {
+		reverse := writeChunk{writes: applied[i].deletes, deletes: applied[i].writes}
+		if err := c.writeChunk(ctx, reverse); err != nil {
+			return fmt.Errorf("failed to roll back transaction %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *OpenFGAClient) writeChunk(ctx context.Context, chunk writeChunk) error {
+	body := client.ClientWriteRequest{}
+	for _, t := range chunk.writes {
+		body.Writes = append(body.Writes, client.ClientTupleKey{User: t.User, Relation: t.Relation, Object: t.Object})
+	}
+	for _, t := range chunk.deletes {
+		body.Deletes = append(body.Deletes, client.ClientTupleKeyWithoutCondition{User: t.User, Relation: t.Relation, Object: t.Object})
+	}
+
+	_, err := c.client.Write(ctx).Body(body).Options(client.ClientWriteOptions{
+		StoreId: &c.storeID,
+	}).Execute()
+	return err
+}
+
+// ストアのすべてのタプルをページングしながら読み込む
+func (c *OpenFGAClient) ReadAllTuples(ctx context.Context) ([]TupleKey, error) {
+	pageSize := int32(readTuplesPageSize)
+	var tuples []TupleKey
+	var token string
+
+	for {
+		options := client.ClientReadOptions{
+			StoreId:  &c.storeID,
+			PageSize: &pageSize,
+		}
+		if token != "" {
+			options.ContinuationToken = &token
+		}
+
+		resp, err := c.client.Read(ctx).Body(client.ClientReadRequest{}).Options(options).Execute()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tuples: %v", err)
+		}
+		for _, t := range resp.GetTuples() {
+			tuples = append(tuples, TupleKey{User: t.Key.User, Relation: t.Key.Relation, Object: t.Key.Object})
+		}
+
+		token = resp.GetContinuationToken()
+		if token == "" {
+			return tuples, nil
+		}
+	}
+}
+
+// 書き込みと削除を合計maxSize件以下の組に分割する
+// 書き込みを先に詰め、残りの枠に削除を詰める
+func splitWriteChunks(writes, deletes []TupleKey, maxSize int) []writeChunk {
+	var chunks []writeChunk
+	var current writeChunk
+	size := 0
+
+	flush := func() {
+		if size > 0 {
+			chunks = append(chunks, current)
+			current = writeChunk{}
+			size = 0
+		}
+	}
+
+	for _, t := range writes {
+		current.writes = append(current.writes, t)
+		if size++; size == maxSize {
+			flush()
+		}
+	}
+	for _, t := range deletes {
+		current.deletes = append(current.deletes, t)
+		if size++; size == maxSize {
+			flush()
+		}
+	}
+	flush()
+
+	return chunks
+}
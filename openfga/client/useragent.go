@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// クライアントのバージョン（ビルド時に -ldflags "-X main.version=..." で設定）
+var version = "dev"
+
+// UserAgentが指定されていない場合に送信するUser-Agent
+func defaultUserAgent() string {
+	return fmt.Sprintf("openfga-client/%s (%s)", version, runtime.GOOS)
+}
+
+// OpenFGA APIへのリクエストに送信するUser-Agentを設定するオプション
+// OpenFGAサーバーのログでクライアントを区別するために使う（空の場合はデフォルト）
+// NewOpenFGAClientFromConfigではOpenFGAConfig.UserAgentより優先される
+func WithUserAgent(userAgent string) OpenFGAOption {
+	return func(c *OpenFGAClient) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// リクエストのUser-Agentヘッダーを設定するRoundTripper
+// SDKが設定するUser-Agentは上書きする
+type UserAgentTransport struct {
+	// 実際にリクエストを送信するRoundTripper（nilの場合はhttp.DefaultTransport）
+	Base http.RoundTripper
+	// 送信するUser-Agent（空の場合はデフォルト）
+	UserAgent string
+}
+
+func (t *UserAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	userAgent := t.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent()
+	}
+
+	// RoundTripperは元のリクエストを変更してはいけないためコピーする
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent)
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,11 @@
+module bundle-endpoint
+
+go 1.25.1
+
+require github.com/spiffe/go-spiffe/v2 v2.1.6
+
+require (
+	github.com/go-jose/go-jose/v3 v3.0.0 // indirect
+	github.com/zeebo/errs v1.3.0 // indirect
+	golang.org/x/crypto v0.6.0 // indirect
+)
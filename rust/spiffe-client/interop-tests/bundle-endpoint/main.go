@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+)
+
+// bundlePath is the well-known path federated peers fetch the trust bundle from
+const bundlePath = "/.well-known/spiffe-bundle"
+
+var (
+	port        = flag.Int("port", 8445, "Port to listen on")
+	certDir     = flag.String("cert-dir", "certs", "Certificate directory path")
+	caCertFile  = flag.String("ca-cert", "ca.crt", "CA certificate file name")
+	caKeyFile   = flag.String("ca-key", "ca.key", "CA private key file name")
+	trustDomain = flag.String("trust-domain", "example.org", "Trust domain served by this endpoint")
+	refreshHint = flag.Duration("refresh-hint", 5*time.Minute, "Refresh hint advertised in the bundle")
+)
+
+// BundleEndpointServer serves a trust bundle as a SPIFFE bundle endpoint.
+// Its serving certificate is issued by the CA in the bundle with the SPIFFE ID
+// spiffe://<trust-domain>/bundle-endpoint, so peers can authenticate it with
+// either the https_web or the https_spiffe profile.
+type BundleEndpointServer struct {
+	bundle    *spiffebundle.Bundle
+	tlsConfig *tls.Config
+}
+
+// NewBundleEndpointServer creates a bundle endpoint for td whose bundle contains caCert
+func NewBundleEndpointServer(td spiffeid.TrustDomain, caCert *x509.Certificate, caKey crypto.Signer) (*BundleEndpointServer, error) {
+	bundle := spiffebundle.FromX509Authorities(td, []*x509.Certificate{caCert})
+	bundle.SetRefreshHint(*refreshHint)
+
+	cert, err := issueServingCert(td, caCert, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BundleEndpointServer{
+		bundle: bundle,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}, nil
+}
+
+// TLSConfig returns the TLS configuration presenting the endpoint's serving certificate
+func (s *BundleEndpointServer) TLSConfig() *tls.Config {
+	return s.tlsConfig
+}
+
+// ServeHTTP returns the trust bundle as a JWKS document
+func (s *BundleEndpointServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != bundlePath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := s.bundle.Marshal()
+	if err != nil {
+		log.Printf("⚠ Failed to marshal trust bundle: %v", err)
+		http.Error(w, "failed to marshal trust bundle", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jose+json")
+	w.Write(data)
+}
+
+// issueServingCert issues a TLS serving certificate signed by the CA key
+func issueServingCert(td spiffeid.TrustDomain, caCert *x509.Certificate, caKey crypto.Signer) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	id, err := spiffeid.FromPath(td, "/bundle-endpoint")
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to build SPIFFE ID: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "bundle-endpoint"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     caCert.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		URIs:         []*url.URL{id.URL()},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create serving certificate: %v", err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der, caCert.Raw},
+		PrivateKey:  key,
+	}, nil
+}
+
+// loadCA reads the CA certificate and its PKCS#8 private key
+func loadCA(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("no CERTIFICATE block found in %s", certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA key: %v", err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block found in %s", keyPath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("CA key of type %T cannot sign", key)
+	}
+
+	return cert, signer, nil
+}
+
+func main() {
+	flag.Parse()
+
+	log.Printf("Starting SPIFFE bundle endpoint for interop testing")
+
+	td, err := spiffeid.TrustDomainFromString(*trustDomain)
+	if err != nil {
+		log.Fatalf("Invalid trust domain: %v", err)
+	}
+
+	caCert, caKey, err := loadCA(filepath.Join(*certDir, *caCertFile), filepath.Join(*certDir, *caKeyFile))
+	if err != nil {
+		log.Fatalf("Failed to load CA: %v", err)
+	}
+
+	log.Printf("✓ Loaded CA certificate: %s", caCert.Subject)
+
+	server, err := NewBundleEndpointServer(td, caCert, caKey)
+	if err != nil {
+		log.Fatalf("Failed to create bundle endpoint: %v", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", *port),
+		Handler:           server,
+		TLSConfig:         server.TLSConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("✓ Serving trust bundle for %s at https://localhost:%d%s", td, *port, bundlePath)
+	if err := httpServer.ListenAndServeTLS("", ""); err != nil {
+		log.Fatalf("Bundle endpoint failed: %v", err)
+	}
+}
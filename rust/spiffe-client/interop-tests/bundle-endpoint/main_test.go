@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+func TestBundleEndpointServer(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	caCert, caKey := newTestCA(t)
+
+	endpoint, err := NewBundleEndpointServer(td, caCert, caKey)
+	if err != nil {
+		t.Fatalf("NewBundleEndpointServer() unexpected error: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(endpoint)
+	server.TLS = endpoint.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	// The serving certificate must verify against the CA in the bundle
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	t.Run("serves bundle", func(t *testing.T) {
+		resp, err := client.Get(server.URL + bundlePath)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", bundlePath, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "application/jose+json" {
+			t.Errorf("Content-Type = %q, want application/jose+json", got)
+		}
+
+		id, err := x509svid.IDFromCert(resp.TLS.PeerCertificates[0])
+		if err != nil {
+			t.Fatalf("serving certificate has no SPIFFE ID: %v", err)
+		}
+		if want := "spiffe://example.org/bundle-endpoint"; id.String() != want {
+			t.Errorf("serving SPIFFE ID = %s, want %s", id, want)
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		bundle, err := spiffebundle.Parse(td, data)
+		if err != nil {
+			t.Fatalf("failed to parse bundle: %v", err)
+		}
+		if !bundle.HasX509Authority(caCert) {
+			t.Error("bundle does not contain the CA certificate")
+		}
+		if hint, ok := bundle.RefreshHint(); !ok || hint != *refreshHint {
+			t.Errorf("refresh hint = %s, want %s", hint, *refreshHint)
+		}
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/bundle")
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		resp, err := client.Post(server.URL+bundlePath, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+		}
+	})
+}
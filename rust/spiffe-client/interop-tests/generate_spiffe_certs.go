@@ -1,19 +1,29 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,16 +34,93 @@ var (
 	serverSpiffeID = flag.String("server-spiffe-id", "spiffe://example.org/go-server", "Server SPIFFE ID")
 	rustServerID   = flag.String("rust-server-spiffe-id", "spiffe://example.org/rust-server", "Rust Server SPIFFE ID")
 	rustClientID   = flag.String("rust-client-spiffe-id", "spiffe://example.org/rust-client", "Rust Client SPIFFE ID")
+	keyType        = flag.String("key-type", keyTypeRSA2048, "Key type: rsa2048, rsa4096, or ecdsa-p256")
+	dnsNamesFlag   = flag.String("dns-names", "", "Comma-separated DNS SANs for server certificates (default: localhost,server)")
+	ipAddressFlag  = flag.String("ip-addresses", "", "Comma-separated IP SANs for server certificates (default: 127.0.0.1,::1)")
+	checksum       = flag.Bool("checksum", false, "Write a SHA-256 checksum file (<cert>.sha256) alongside each certificate")
+	verifyOnly     = flag.Bool("verify", false, "Verify existing certificates and checksum files in cert-dir instead of generating")
+	dryRun         = flag.Bool("dry-run", false, "Print the certificates that would be generated without writing any files")
+	createDir      = flag.Bool("create-dir", false, "Create cert-dir if it does not exist")
+	validity       = flag.Duration("validity", 365*24*time.Hour, "Validity period of leaf certificates")
+	caValidity     = flag.Duration("ca-validity", 10*365*24*time.Hour, "Validity period of the CA certificate")
+	extraCAFiles   = flag.String("extra-ca-files", "", "Comma-separated PEM files of additional CAs to append to the trust bundle (also written to combined-trust-bundle.pem)")
+	migrateKeyType = flag.String("migrate-key-type", "", "Re-issue the leaf certificates in cert-dir under the existing CA with this key type, writing <name>.new.crt and <name>.new.key")
+)
+
+// leafCerts lists the certificate and key files written by main
+var leafCerts = []struct{ cert, key string }{
+	{"go-client.crt", "go-client.key"},
+	{"go-server.crt", "go-server.key"},
+	{"rust-client.crt", "rust-client.key"},
+	{"rust-server.crt", "rust-server.key"},
+}
+
+// combinedTrustBundleFile holds the generated CA and the extra CAs, and is preferred by the Go client and server
+const combinedTrustBundleFile = "combined-trust-bundle.pem"
+
+// shortValidityThreshold is the validity below which a warning is printed for a generated certificate
+const shortValidityThreshold = 24 * time.Hour
+
+// warnOutput receives short validity warnings
+var warnOutput io.Writer = os.Stderr
+
+// migrationMinRemaining is the remaining validity below which MigrateKeyType skips a certificate
+const migrationMinRemaining = 7 * 24 * time.Hour
+
+// Supported key types
+const (
+	keyTypeRSA2048   = "rsa2048"
+	keyTypeRSA4096   = "rsa4096"
+	keyTypeECDSAP256 = "ecdsa-p256"
 )
 
 func main() {
 	flag.Parse()
 
+	if *verifyOnly {
+		if err := verifyCerts(); err != nil {
+			log.Fatalf("Certificate verification failed: %v", err)
+		}
+		log.Printf("✓ All certificates in %s/ verified", *certDir)
+		return
+	}
+
+	if *migrateKeyType != "" {
+		if err := MigrateKeyType(*certDir, *migrateKeyType); err != nil {
+			log.Fatalf("Key type migration failed: %v", err)
+		}
+		log.Printf("✓ Migrated certificates in %s/ to %s", *certDir, *migrateKeyType)
+		return
+	}
+
 	log.Printf("Generating SPIFFE-compliant certificates for trust domain: %s", *trustDomain)
 
-	// Create certificate directory
-	if err := os.MkdirAll(*certDir, 0755); err != nil {
-		log.Fatalf("Failed to create cert directory: %v", err)
+	if _, err := keyUsageFor(*keyType); err != nil {
+		log.Fatalf("Invalid key type: %v", err)
+	}
+	if err := checkValidity("validity", *validity); err != nil {
+		log.Fatalf("Invalid validity: %v", err)
+	}
+	if err := checkValidity("ca-validity", *caValidity); err != nil {
+		log.Fatalf("Invalid CA validity: %v", err)
+	}
+
+	dnsNames := parseList(*dnsNamesFlag)
+	ipAddresses, err := parseIPAddresses(*ipAddressFlag)
+	if err != nil {
+		log.Fatalf("Invalid IP addresses: %v", err)
+	}
+	extraCAs, err := loadCAFiles(parseList(*extraCAFiles))
+	if err != nil {
+		log.Fatalf("Invalid extra CA files: %v", err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: no files will be written")
+	}
+
+	if err := prepareCertDir(); err != nil {
+		log.Fatalf("Invalid cert directory: %v", err)
 	}
 
 	// Generate CA certificate
@@ -42,44 +129,104 @@ func main() {
 		log.Fatalf("Failed to generate CA: %v", err)
 	}
 
-	// Generate Go client certificate
-	if err := generateCert("go-client.crt", "go-client.key", *clientSpiffeID, x509.ExtKeyUsageClientAuth, caCert, caKey); err != nil {
-		log.Fatalf("Failed to generate Go client cert: %v", err)
+	// Create trust bundle from the newly generated CA certificate
+	trustBundlePath, err := writeTrustBundle(caCert, extraCAs...)
+	if err != nil {
+		log.Fatalf("Failed to write trust bundle: %v", err)
 	}
 
-	// Generate Go server certificate
-	if err := generateCert("go-server.crt", "go-server.key", *serverSpiffeID, x509.ExtKeyUsageServerAuth, caCert, caKey); err != nil {
-		log.Fatalf("Failed to generate Go server cert: %v", err)
+	if err := generatePeerCerts(dnsNames, ipAddresses, caCert, caKey); err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	// Generate Rust client certificate
-	if err := generateCert("rust-client.crt", "rust-client.key", *rustClientID, x509.ExtKeyUsageClientAuth, caCert, caKey); err != nil {
-		log.Fatalf("Failed to generate Rust client cert: %v", err)
+	if *dryRun {
+		log.Printf("✓ Dry run complete, nothing written to %s/", *certDir)
+		return
 	}
 
-	// Generate Rust server certificate
-	if err := generateCert("rust-server.crt", "rust-server.key", *rustServerID, x509.ExtKeyUsageServerAuth, caCert, caKey); err != nil {
-		log.Fatalf("Failed to generate Rust server cert: %v", err)
+	log.Printf("✓ Generated SPIFFE-compliant certificates in %s/", *certDir)
+	log.Printf("✓ Trust bundle created: %s", trustBundlePath)
+}
+
+// certSpec describes a leaf certificate to issue from the CA
+type certSpec struct {
+	certFile    string
+	keyFile     string
+	spiffeID    string
+	extKeyUsage x509.ExtKeyUsage
+	dnsNames    []string
+	ipAddresses []net.IP
+}
+
+// generatePeerCerts issues the Go and Rust client and server certificates signed by the CA
+func generatePeerCerts(dnsNames []string, ipAddresses []net.IP, caCert *x509.Certificate, caKey crypto.PrivateKey) error {
+	specs := []certSpec{
+		{certFile: "go-client.crt", keyFile: "go-client.key", spiffeID: *clientSpiffeID, extKeyUsage: x509.ExtKeyUsageClientAuth},
+		{certFile: "go-server.crt", keyFile: "go-server.key", spiffeID: *serverSpiffeID, extKeyUsage: x509.ExtKeyUsageServerAuth, dnsNames: dnsNames, ipAddresses: ipAddresses},
+		{certFile: "rust-client.crt", keyFile: "rust-client.key", spiffeID: *rustClientID, extKeyUsage: x509.ExtKeyUsageClientAuth},
+		{certFile: "rust-server.crt", keyFile: "rust-server.key", spiffeID: *rustServerID, extKeyUsage: x509.ExtKeyUsageServerAuth, dnsNames: dnsNames, ipAddresses: ipAddresses},
 	}
+	return generateCerts(specs, runtime.NumCPU(), caCert, caKey)
+}
 
-	// Create trust bundle (CA certificate)
-	trustBundlePath := filepath.Join(*certDir, "trust-bundle.pem")
-	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
-	if err := os.WriteFile(trustBundlePath, caCertPEM, 0644); err != nil {
-		log.Fatalf("Failed to write trust bundle: %v", err)
+// generateCerts issues the certificates in specs with up to parallelism running at once, since key generation is CPU-bound
+// The first error is returned after the running generations finish; specs not yet started are skipped
+func generateCerts(specs []certSpec, parallelism int, caCert *x509.Certificate, caKey crypto.PrivateKey) error {
+	if parallelism < 1 {
+		parallelism = 1
 	}
 
-	log.Printf("✓ Generated SPIFFE-compliant certificates in %s/", *certDir)
-	log.Printf("✓ Trust bundle created: %s", trustBundlePath)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, parallelism)
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	for _, spec := range specs {
+		sem <- struct{}{}
+		if failed() {
+			break
+		}
+
+		wg.Add(1)
+		go func(spec certSpec) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := generateCert(spec.certFile, spec.keyFile, spec.spiffeID, spec.extKeyUsage, spec.dnsNames, spec.ipAddresses, caCert, caKey); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to generate %s: %v", spec.certFile, err)
+				}
+				mu.Unlock()
+			}
+		}(spec)
+	}
+
+	wg.Wait()
+	return firstErr
 }
 
-func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
-	log.Printf("Generating CA certificate for trust domain: %s", *trustDomain)
+func generateCA() (*x509.Certificate, crypto.PrivateKey, error) {
+	log.Printf("Generating CA certificate for trust domain: %s (key type: %s)", *trustDomain, *keyType)
 
-	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err := checkValidity("ca-validity", *caValidity); err != nil {
+		return nil, nil, err
+	}
+	caKey, err := generateKey(*keyType)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to generate CA key: %v", err)
 	}
+	keyUsage, err := keyUsageFor(*keyType)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	caTemplate := x509.Certificate{
 		SerialNumber: big.NewInt(1),
@@ -88,14 +235,14 @@ func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
 			Organization: []string{*trustDomain},
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour), // 10 years
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		NotAfter:              time.Now().Add(*caValidity),
+		KeyUsage:              keyUsage | x509.KeyUsageCertSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  true,
 	}
 
-	caCertDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	caCertDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, caKey.Public(), caKey)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
 	}
@@ -105,6 +252,14 @@ func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
 		return nil, nil, fmt.Errorf("failed to parse CA certificate: %v", err)
 	}
 
+	caCertPath := filepath.Join(*certDir, "ca.crt")
+	caKeyPath := filepath.Join(*certDir, "ca.key")
+	previewCert(caCertPath, caKeyPath, "spiffe://"+*trustDomain, &caTemplate)
+	warnShortValidity(caCertPath, *caValidity)
+	if *dryRun {
+		return caCert, caKey, nil
+	}
+
 	// Save CA certificate and key
 	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertDER})
 	caKeyDER, err := x509.MarshalPKCS8PrivateKey(caKey)
@@ -113,10 +268,10 @@ func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
 	}
 	caKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: caKeyDER})
 
-	if err := os.WriteFile(filepath.Join(*certDir, "ca.crt"), caCertPEM, 0644); err != nil {
+	if err := os.WriteFile(caCertPath, caCertPEM, 0644); err != nil {
 		return nil, nil, fmt.Errorf("failed to write CA cert: %v", err)
 	}
-	if err := os.WriteFile(filepath.Join(*certDir, "ca.key"), caKeyPEM, 0600); err != nil {
+	if err := os.WriteFile(caKeyPath, caKeyPEM, 0600); err != nil {
 		return nil, nil, fmt.Errorf("failed to write CA key: %v", err)
 	}
 
@@ -124,14 +279,31 @@ func generateCA() (*x509.Certificate, *rsa.PrivateKey, error) {
 	return caCert, caKey, nil
 }
 
-func generateCert(certFile, keyFile, spiffeID string, extKeyUsage x509.ExtKeyUsage, caCert *x509.Certificate, caKey *rsa.PrivateKey) error {
+// newSerialNumber returns a random 128-bit certificate serial number
+// Random serials stay unique when certificates are generated concurrently
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serial, nil
+}
+
+func generateCert(certFile, keyFile, spiffeID string, extKeyUsage x509.ExtKeyUsage, dnsNames []string, ipAddresses []net.IP, caCert *x509.Certificate, caKey crypto.PrivateKey) error {
 	log.Printf("Generating certificate for SPIFFE ID: %s", spiffeID)
 
+	if err := checkValidity("validity", *validity); err != nil {
+		return err
+	}
 	// Generate private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	privateKey, err := generateKey(*keyType)
 	if err != nil {
 		return fmt.Errorf("failed to generate private key: %v", err)
 	}
+	keyUsage, err := keyUsageFor(*keyType)
+	if err != nil {
+		return err
+	}
 
 	// Parse SPIFFE ID
 	spiffeURI, err := url.Parse(spiffeID)
@@ -139,51 +311,417 @@ func generateCert(certFile, keyFile, spiffeID string, extKeyUsage x509.ExtKeyUsa
 		return fmt.Errorf("failed to parse SPIFFE URI: %v", err)
 	}
 
+	serial, err := newSerialNumber()
+	if err != nil {
+		return err
+	}
+
 	// Create certificate template
 	template := x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			CommonName:   spiffeID,
 			Organization: []string{*trustDomain},
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		NotAfter:              time.Now().Add(*validity),
+		KeyUsage:              keyUsage,
 		ExtKeyUsage:           []x509.ExtKeyUsage{extKeyUsage},
 		BasicConstraintsValid: true,
 		URIs:                  []*url.URL{spiffeURI},
 	}
 
-	// Add DNS names for server certificates
-	if extKeyUsage == x509.ExtKeyUsageServerAuth {
-		template.DNSNames = []string{"localhost", "server"}
-		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	// Add DNS names and IP addresses, falling back to loopback defaults for server certificates
+	if len(dnsNames) == 0 && len(ipAddresses) == 0 && extKeyUsage == x509.ExtKeyUsageServerAuth {
+		dnsNames = []string{"localhost", "server"}
+		ipAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
 	}
+	template.DNSNames = dnsNames
+	template.IPAddresses = ipAddresses
 
 	// Create certificate
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, caCert, &privateKey.PublicKey, caKey)
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, caCert, privateKey.Public(), caKey)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate: %v", err)
 	}
 
+	certPath := filepath.Join(*certDir, certFile)
+	keyPath := filepath.Join(*certDir, keyFile)
+	previewCert(certPath, keyPath, spiffeID, &template)
+	warnShortValidity(certPath, *validity)
+	if *dryRun {
+		return nil
+	}
+
+	if err := writeKeyPair(certPath, keyPath, certDER, privateKey); err != nil {
+		return err
+	}
+	if *checksum {
+		if err := writeChecksum(certPath); err != nil {
+			return fmt.Errorf("failed to write checksum: %v", err)
+		}
+	}
+
+	log.Printf("✓ Generated certificate: %s", certFile)
+	return nil
+}
+
+// checkValidity rejects validity periods that would produce already expired certificates
+func checkValidity(name string, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, d)
+	}
+	return nil
+}
+
+// warnShortValidity prints a warning to warnOutput when a certificate expires within shortValidityThreshold
+func warnShortValidity(certPath string, d time.Duration) {
+	if d >= shortValidityThreshold {
+		return
+	}
+	fmt.Fprintf(warnOutput, "⚠ WARNING: %s expires in %s (less than %s); regenerate it before then\n", certPath, d, shortValidityThreshold)
+}
+
+// writeKeyPair writes a certificate and its private key as PEM and verifies they can be loaded back
+func writeKeyPair(certPath, keyPath string, certDER []byte, privateKey crypto.PrivateKey) error {
 	// Save certificate
 	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
-	certPath := filepath.Join(*certDir, certFile)
 	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
 		return fmt.Errorf("failed to write certificate: %v", err)
 	}
 
 	// Save private key
+	// PKCS#8 is used for ECDSA keys as well, since x509svid.Load only accepts "PRIVATE KEY" blocks
 	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
 	if err != nil {
 		return fmt.Errorf("failed to marshal private key: %v", err)
 	}
 	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
-	keyPath := filepath.Join(*certDir, keyFile)
 	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
 		return fmt.Errorf("failed to write private key: %v", err)
 	}
 
-	log.Printf("✓ Generated certificate: %s", certFile)
+	return verifyCertFile(certPath, keyPath)
+}
+
+// MigrateKeyType re-issues the leaf certificates in dir with keys of newKeyType, signed by the existing CA
+// The new certificates keep the subject, SANs and expiry of the originals and are written to
+// <name>.new.crt and <name>.new.key; the originals, the CA and the trust bundle are left untouched
+// Certificates expiring within migrationMinRemaining are skipped with a warning
+func MigrateKeyType(dir, newKeyType string) error {
+	keyUsage, err := keyUsageFor(newKeyType)
+	if err != nil {
+		return err
+	}
+
+	caCert, caKey, err := loadCA(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range leafCerts {
+		certPath := filepath.Join(dir, c.cert)
+		if _, err := os.Stat(certPath); os.IsNotExist(err) {
+			continue
+		}
+		cert, err := readCertFile(certPath)
+		if err != nil {
+			return err
+		}
+
+		if remaining := time.Until(cert.NotAfter); remaining < migrationMinRemaining {
+			log.Printf("⚠ Skipping %s: expires %s, within %s", c.cert, cert.NotAfter.Format(time.RFC3339), migrationMinRemaining)
+			continue
+		}
+
+		privateKey, err := generateKey(newKeyType)
+		if err != nil {
+			return fmt.Errorf("failed to generate private key for %s: %v", c.cert, err)
+		}
+
+		serial, err := newSerialNumber()
+		if err != nil {
+			return err
+		}
+
+		template := x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               cert.Subject,
+			NotBefore:             time.Now(),
+			NotAfter:              cert.NotAfter,
+			KeyUsage:              keyUsage,
+			ExtKeyUsage:           cert.ExtKeyUsage,
+			BasicConstraintsValid: true,
+			URIs:                  cert.URIs,
+			DNSNames:              cert.DNSNames,
+			IPAddresses:           cert.IPAddresses,
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, &template, caCert, privateKey.Public(), caKey)
+		if err != nil {
+			return fmt.Errorf("failed to create certificate for %s: %v", c.cert, err)
+		}
+
+		name := strings.TrimSuffix(c.cert, ".crt")
+		if err := writeKeyPair(filepath.Join(dir, name+".new.crt"), filepath.Join(dir, name+".new.key"), certDER, privateKey); err != nil {
+			return err
+		}
+		log.Printf("✓ Migrated %s to %s: %s.new.crt", c.cert, newKeyType, name)
+	}
+	return nil
+}
+
+// loadCA reads the CA certificate and PKCS#8 private key written by generateCA
+func loadCA(dir string) (*x509.Certificate, crypto.PrivateKey, error) {
+	caCert, err := readCertFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPath := filepath.Join(dir, "ca.key")
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block in %s", keyPath)
+	}
+	caKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key: %v", err)
+	}
+	return caCert, caKey, nil
+}
+
+// readCertFile parses the first PEM certificate in path
+func readCertFile(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in %s", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return cert, nil
+}
+
+// prepareCertDir checks that cert-dir exists, creating it only when -create-dir is set
+func prepareCertDir() error {
+	info, err := os.Stat(*certDir)
+	switch {
+	case err == nil:
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", *certDir)
+		}
+		return nil
+	case !os.IsNotExist(err):
+		return err
+	case !*createDir:
+		return fmt.Errorf("%s does not exist (use -create-dir to create it)", *certDir)
+	case *dryRun:
+		log.Printf("Would create directory %s", *certDir)
+		return nil
+	default:
+		return os.MkdirAll(*certDir, 0755)
+	}
+}
+
+// previewCert logs the certificate and key that are about to be written
+func previewCert(certPath, keyPath, spiffeID string, template *x509.Certificate) {
+	log.Printf("Would create %s: SPIFFE ID %s, key type %s, valid %s to %s",
+		certPath, spiffeID, *keyType,
+		template.NotBefore.Format(time.RFC3339), template.NotAfter.Format(time.RFC3339))
+	log.Printf("Would create %s: %s private key", keyPath, *keyType)
+}
+
+// verifyCertFile reads back a written certificate and key and checks that they form a valid key pair
+func verifyCertFile(certPath, keyPath string) error {
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		return fmt.Errorf("failed to verify certificate %s with key %s: %v", certPath, keyPath, err)
+	}
+	return nil
+}
+
+// writeChecksum writes the SHA-256 of certPath to certPath.sha256 in sha256sum format
+func writeChecksum(certPath string) error {
+	sum, err := fileChecksum(certPath)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(certPath))
+	return os.WriteFile(certPath+".sha256", []byte(line), 0644)
+}
+
+// verifyChecksum checks certPath against the digest recorded in certPath.sha256
+func verifyChecksum(certPath string) error {
+	data, err := os.ReadFile(certPath + ".sha256")
+	if err != nil {
+		return fmt.Errorf("failed to read checksum file: %v", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file %s.sha256 is empty", certPath)
+	}
+
+	sum, err := fileChecksum(certPath)
+	if err != nil {
+		return err
+	}
+	if sum != fields[0] {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", certPath, fields[0], sum)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of the file at path
+func fileChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// verifyCerts checks every generated certificate against its checksum file and key
+func verifyCerts() error {
+	for _, c := range leafCerts {
+		certPath := filepath.Join(*certDir, c.cert)
+		if err := verifyChecksum(certPath); err != nil {
+			return err
+		}
+		if err := verifyCertFile(certPath, filepath.Join(*certDir, c.key)); err != nil {
+			return err
+		}
+		log.Printf("✓ Verified %s", c.cert)
+	}
+	return nil
+}
+
+// writeTrustBundle writes the CA certificate followed by extraCAs as the trust bundle and returns its path
+// When extraCAs are given the same bundle is also written to combinedTrustBundleFile; otherwise a combined
+// bundle left by an earlier run is removed so the Go client and server do not keep trusting its CAs
+func writeTrustBundle(caCert *x509.Certificate, extraCAs ...*x509.Certificate) (string, error) {
+	trustBundlePath := filepath.Join(*certDir, "trust-bundle.pem")
+	combinedPath := filepath.Join(*certDir, combinedTrustBundleFile)
+	paths := []string{trustBundlePath}
+	if len(extraCAs) > 0 {
+		paths = append(paths, combinedPath)
+	}
+
+	var bundlePEM []byte
+	for _, cert := range append([]*x509.Certificate{caCert}, extraCAs...) {
+		bundlePEM = append(bundlePEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	for _, path := range paths {
+		log.Printf("Would create %s: trust bundle for %s with %d CA(s)", path, *trustDomain, 1+len(extraCAs))
+		if *dryRun {
+			continue
+		}
+		if err := os.WriteFile(path, bundlePEM, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	if len(extraCAs) == 0 {
+		if _, err := os.Stat(combinedPath); err == nil {
+			log.Printf("Would remove stale %s", combinedPath)
+			if !*dryRun {
+				if err := os.Remove(combinedPath); err != nil {
+					return "", fmt.Errorf("failed to remove stale %s: %v", combinedPath, err)
+				}
+			}
+		}
+	}
+	return trustBundlePath, nil
+}
+
+// loadCAFiles reads every certificate from the given PEM files
+func loadCAFiles(paths []string) ([]*x509.Certificate, error) {
+	var cas []*x509.Certificate
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+
+		var found int
+		for rest := data; len(rest) > 0; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate in %s: %v", path, err)
+			}
+			cas = append(cas, cert)
+			found++
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+	}
+	return cas, nil
+}
+
+// parseList splits a comma-separated flag value, ignoring empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseIPAddresses parses a comma-separated list of IP addresses
+func parseIPAddresses(value string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, item := range parseList(value) {
+		ip := net.ParseIP(item)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", item)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// generateKey creates a private key of the given type
+func generateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case keyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case keyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case keyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// keyUsageFor returns the key usage for certificates with the given key type
+// ECDSA keys cannot be used for key encipherment, so only digital signature is set
+func keyUsageFor(keyType string) (x509.KeyUsage, error) {
+	switch keyType {
+	case keyTypeRSA2048, keyTypeRSA4096:
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature, nil
+	case keyTypeECDSAP256:
+		return x509.KeyUsageDigitalSignature, nil
+	default:
+		return 0, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
@@ -0,0 +1,819 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "spiffe-certs-test")
+	if err != nil {
+		log.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	*certDir = dir
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// withKeyType sets the key-type flag for the duration of the test
+func withKeyType(t *testing.T, kt string) {
+	t.Helper()
+
+	prev := *keyType
+	*keyType = kt
+	t.Cleanup(func() { *keyType = prev })
+}
+
+func readCert(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(*certDir, name))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("No PEM block in %s", name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", name, err)
+	}
+	return cert
+}
+
+func TestGenerateCertsKeyTypes(t *testing.T) {
+	tests := []struct {
+		keyType   string
+		algorithm x509.PublicKeyAlgorithm
+		keyUsage  x509.KeyUsage
+	}{
+		{keyTypeRSA2048, x509.RSA, x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature},
+		{keyTypeRSA4096, x509.RSA, x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature},
+		{keyTypeECDSAP256, x509.ECDSA, x509.KeyUsageDigitalSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			withKeyType(t, tt.keyType)
+
+			caCert, caKey, err := generateCA()
+			if err != nil {
+				t.Fatalf("generateCA() error = %v", err)
+			}
+			if caCert.PublicKeyAlgorithm != tt.algorithm {
+				t.Errorf("CA key algorithm = %v, want %v", caCert.PublicKeyAlgorithm, tt.algorithm)
+			}
+			if want := tt.keyUsage | x509.KeyUsageCertSign; caCert.KeyUsage != want {
+				t.Errorf("CA key usage = %v, want %v", caCert.KeyUsage, want)
+			}
+
+			switch tt.algorithm {
+			case x509.RSA:
+				if _, ok := caKey.(*rsa.PrivateKey); !ok {
+					t.Errorf("CA key type = %T, want *rsa.PrivateKey", caKey)
+				}
+			case x509.ECDSA:
+				if _, ok := caKey.(*ecdsa.PrivateKey); !ok {
+					t.Errorf("CA key type = %T, want *ecdsa.PrivateKey", caKey)
+				}
+			}
+
+			spiffeID := "spiffe://example.org/test-server"
+			if err := generateCert("test.crt", "test.key", spiffeID, x509.ExtKeyUsageServerAuth, nil, nil, caCert, caKey); err != nil {
+				t.Fatalf("generateCert() error = %v", err)
+			}
+
+			cert := readCert(t, "test.crt")
+			if cert.PublicKeyAlgorithm != tt.algorithm {
+				t.Errorf("certificate key algorithm = %v, want %v", cert.PublicKeyAlgorithm, tt.algorithm)
+			}
+			if cert.KeyUsage != tt.keyUsage {
+				t.Errorf("certificate key usage = %v, want %v", cert.KeyUsage, tt.keyUsage)
+			}
+			if len(cert.URIs) != 1 || cert.URIs[0].String() != spiffeID {
+				t.Errorf("certificate URIs = %v, want [%s]", cert.URIs, spiffeID)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AddCert(caCert)
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err != nil {
+				t.Errorf("certificate does not verify against CA: %v", err)
+			}
+
+			// The key must be loadable alongside the certificate
+			if _, err := tls.LoadX509KeyPair(filepath.Join(*certDir, "test.crt"), filepath.Join(*certDir, "test.key")); err != nil {
+				t.Errorf("failed to load key pair: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyUnsupported(t *testing.T) {
+	if _, err := generateKey("dsa1024"); err == nil {
+		t.Error("generateKey() expected error for unsupported key type")
+	}
+	if _, err := keyUsageFor("dsa1024"); err == nil {
+		t.Error("keyUsageFor() expected error for unsupported key type")
+	}
+}
+
+func TestParseIPAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []net.IP
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"single IPv4", "10.0.0.1", []net.IP{net.ParseIP("10.0.0.1")}, false},
+		{"mixed with spaces", "10.0.0.1, ::1", []net.IP{net.ParseIP("10.0.0.1"), net.IPv6loopback}, false},
+		{"invalid", "10.0.0.1,not-an-ip", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIPAddresses(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIPAddresses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIPAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCertSANs(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		extKeyUsage x509.ExtKeyUsage
+		dnsNames    []string
+		ipAddresses []net.IP
+		wantDNS     []string
+		wantIPs     []net.IP
+	}{
+		{
+			name:        "server defaults",
+			extKeyUsage: x509.ExtKeyUsageServerAuth,
+			wantDNS:     []string{"localhost", "server"},
+			wantIPs:     []net.IP{net.IPv4(127, 0, 0, 1).To4(), net.IPv6loopback},
+		},
+		{
+			name:        "server custom",
+			extKeyUsage: x509.ExtKeyUsageServerAuth,
+			dnsNames:    []string{"go-server.default.svc", "go-server"},
+			ipAddresses: []net.IP{net.ParseIP("10.1.2.3")},
+			wantDNS:     []string{"go-server.default.svc", "go-server"},
+			wantIPs:     []net.IP{net.ParseIP("10.1.2.3").To4()},
+		},
+		{
+			name:        "server DNS only",
+			extKeyUsage: x509.ExtKeyUsageServerAuth,
+			dnsNames:    []string{"go-server"},
+			wantDNS:     []string{"go-server"},
+		},
+		{
+			name:        "client without SANs",
+			extKeyUsage: x509.ExtKeyUsageClientAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := generateCert("san.crt", "san.key", "spiffe://example.org/san", tt.extKeyUsage, tt.dnsNames, tt.ipAddresses, caCert, caKey); err != nil {
+				t.Fatalf("generateCert() error = %v", err)
+			}
+
+			cert := readCert(t, "san.crt")
+			if !reflect.DeepEqual(cert.DNSNames, tt.wantDNS) {
+				t.Errorf("DNSNames = %v, want %v", cert.DNSNames, tt.wantDNS)
+			}
+			if !reflect.DeepEqual(cert.IPAddresses, tt.wantIPs) {
+				t.Errorf("IPAddresses = %v, want %v", cert.IPAddresses, tt.wantIPs)
+			}
+		})
+	}
+}
+
+func TestWriteTrustBundleRegenerated(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	for i := 0; i < 2; i++ {
+		caCert, _, err := generateCA()
+		if err != nil {
+			t.Fatalf("generateCA() error = %v", err)
+		}
+		if _, err := writeTrustBundle(caCert); err != nil {
+			t.Fatalf("writeTrustBundle() error = %v", err)
+		}
+
+		bundleCert := readCert(t, "trust-bundle.pem")
+		if !bundleCert.Equal(caCert) {
+			t.Fatalf("trust bundle does not match CA generated in round %d", i)
+		}
+	}
+}
+
+// corruptFile flips one byte of the file at path, offset bytes into the PEM body
+func corruptFile(t *testing.T, path string, offset int) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	i := bytes.IndexByte(data, '\n') + 1 + offset
+	if data[i] == 'A' {
+		data[i] = 'B'
+	} else {
+		data[i] = 'A'
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestVerifyCertFile(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	if err := generateCert("verify.crt", "verify.key", "spiffe://example.org/verify", x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+		t.Fatalf("generateCert() error = %v", err)
+	}
+	if err := generateCert("other.crt", "other.key", "spiffe://example.org/other", x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+		t.Fatalf("generateCert() error = %v", err)
+	}
+
+	certPath := filepath.Join(*certDir, "verify.crt")
+	keyPath := filepath.Join(*certDir, "verify.key")
+	if err := verifyCertFile(certPath, keyPath); err != nil {
+		t.Fatalf("verifyCertFile() error = %v", err)
+	}
+
+	if err := verifyCertFile(certPath, filepath.Join(*certDir, "other.key")); err == nil {
+		t.Error("verifyCertFile() expected error for mismatched key")
+	}
+
+	// Corrupting the DER header makes the certificate unparseable
+	corruptFile(t, certPath, 2)
+	err = verifyCertFile(certPath, keyPath)
+	if err == nil {
+		t.Fatal("verifyCertFile() expected error for corrupted certificate")
+	}
+	if !strings.Contains(err.Error(), "verify.crt") {
+		t.Errorf("verifyCertFile() error = %v, want it to name the certificate", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	prev := *checksum
+	*checksum = true
+	t.Cleanup(func() { *checksum = prev })
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	for _, c := range leafCerts {
+		if err := generateCert(c.cert, c.key, "spiffe://example.org/"+c.cert, x509.ExtKeyUsageServerAuth, nil, nil, caCert, caKey); err != nil {
+			t.Fatalf("generateCert() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(*certDir, "go-server.crt.sha256"))
+	if err != nil {
+		t.Fatalf("Failed to read checksum file: %v", err)
+	}
+	if fields := strings.Fields(string(data)); len(fields) != 2 || len(fields[0]) != 64 || fields[1] != "go-server.crt" {
+		t.Errorf("checksum file = %q, want \"<sha256>  go-server.crt\"", data)
+	}
+
+	if err := verifyCerts(); err != nil {
+		t.Fatalf("verifyCerts() error = %v", err)
+	}
+
+	// Any modification after generation is reported as a checksum mismatch
+	certPath := filepath.Join(*certDir, "go-server.crt")
+	corruptFile(t, certPath, 500)
+	err = verifyChecksum(certPath)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("verifyChecksum() error = %v, want checksum mismatch", err)
+	}
+	if err := verifyCerts(); err == nil {
+		t.Error("verifyCerts() expected error after corruption")
+	}
+}
+
+// withDryRun enables the dry-run flag and points cert-dir at dir for the duration of the test
+func withDryRun(t *testing.T, dir string) {
+	t.Helper()
+
+	prevDryRun, prevCertDir := *dryRun, *certDir
+	*dryRun, *certDir = true, dir
+	t.Cleanup(func() { *dryRun, *certDir = prevDryRun, prevCertDir })
+}
+
+func TestDryRun(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+	dir := t.TempDir()
+	withDryRun(t, dir)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	if _, err := writeTrustBundle(caCert); err != nil {
+		t.Fatalf("writeTrustBundle() error = %v", err)
+	}
+	for _, c := range leafCerts {
+		if err := generateCert(c.cert, c.key, "spiffe://example.org/"+c.cert, x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+			t.Fatalf("generateCert() error = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read cert dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("dry run wrote %d files to cert dir, want none", len(entries))
+	}
+
+	out := logs.String()
+	for _, want := range []string{
+		filepath.Join(dir, "ca.crt"),
+		filepath.Join(dir, "trust-bundle.pem"),
+		filepath.Join(dir, "go-server.key"),
+		"SPIFFE ID spiffe://example.org/go-client.crt",
+		"key type " + keyTypeECDSAP256,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output does not mention %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrepareCertDir(t *testing.T) {
+	prevCreateDir := *createDir
+	t.Cleanup(func() { *createDir = prevCreateDir })
+
+	t.Run("missing without create-dir", func(t *testing.T) {
+		withDryRun(t, filepath.Join(t.TempDir(), "missing"))
+		*createDir = false
+
+		if err := prepareCertDir(); err == nil || !strings.Contains(err.Error(), "-create-dir") {
+			t.Errorf("prepareCertDir() error = %v, want a hint to use -create-dir", err)
+		}
+	})
+
+	t.Run("missing with create-dir in dry run", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "missing")
+		withDryRun(t, dir)
+		*createDir = true
+
+		if err := prepareCertDir(); err != nil {
+			t.Fatalf("prepareCertDir() error = %v", err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("dry run created %s", dir)
+		}
+	})
+
+	t.Run("missing with create-dir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "missing")
+		withDryRun(t, dir)
+		*dryRun, *createDir = false, true
+
+		if err := prepareCertDir(); err != nil {
+			t.Fatalf("prepareCertDir() error = %v", err)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("prepareCertDir() did not create %s: %v", dir, err)
+		}
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+		withDryRun(t, file)
+
+		if err := prepareCertDir(); err == nil {
+			t.Error("prepareCertDir() expected error for a regular file")
+		}
+	})
+}
+
+// withCertDir points the cert-dir flag at dir for the duration of the test
+func withCertDir(t *testing.T, dir string) {
+	t.Helper()
+
+	prev := *certDir
+	*certDir = dir
+	t.Cleanup(func() { *certDir = prev })
+}
+
+func TestMigrateKeyType(t *testing.T) {
+	withKeyType(t, keyTypeRSA2048)
+	dir := t.TempDir()
+	withCertDir(t, dir)
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	for _, c := range leafCerts[:3] {
+		if err := generateCert(c.cert, c.key, "spiffe://example.org/"+strings.TrimSuffix(c.cert, ".crt"), x509.ExtKeyUsageServerAuth, nil, nil, caCert, caKey); err != nil {
+			t.Fatalf("generateCert() error = %v", err)
+		}
+	}
+
+	// rust-server.crt expires within the migration window and must be skipped
+	expiring := leafCerts[3]
+	expiringKey, err := generateKey(keyTypeRSA2048)
+	if err != nil {
+		t.Fatalf("generateKey() error = %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, caCert, expiringKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	if err := writeKeyPair(filepath.Join(dir, expiring.cert), filepath.Join(dir, expiring.key), der, expiringKey); err != nil {
+		t.Fatalf("writeKeyPair() error = %v", err)
+	}
+
+	caBefore, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatalf("Failed to read CA: %v", err)
+	}
+
+	if err := MigrateKeyType(dir, keyTypeECDSAP256); err != nil {
+		t.Fatalf("MigrateKeyType() error = %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	for _, c := range leafCerts[:3] {
+		name := strings.TrimSuffix(c.cert, ".crt")
+		original := readCert(t, c.cert)
+		migrated := readCert(t, name+".new.crt")
+
+		if original.PublicKeyAlgorithm != x509.RSA {
+			t.Errorf("%s key algorithm = %v, want original RSA key kept", c.cert, original.PublicKeyAlgorithm)
+		}
+		if migrated.PublicKeyAlgorithm != x509.ECDSA {
+			t.Errorf("%s.new.crt key algorithm = %v, want ECDSA", name, migrated.PublicKeyAlgorithm)
+		}
+		if migrated.KeyUsage != x509.KeyUsageDigitalSignature {
+			t.Errorf("%s.new.crt key usage = %v, want digital signature only", name, migrated.KeyUsage)
+		}
+		if !reflect.DeepEqual(migrated.URIs, original.URIs) || !reflect.DeepEqual(migrated.DNSNames, original.DNSNames) {
+			t.Errorf("%s.new.crt SANs = %v %v, want %v %v", name, migrated.URIs, migrated.DNSNames, original.URIs, original.DNSNames)
+		}
+		if !migrated.NotAfter.Equal(original.NotAfter) {
+			t.Errorf("%s.new.crt NotAfter = %v, want %v", name, migrated.NotAfter, original.NotAfter)
+		}
+		if _, err := migrated.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err != nil {
+			t.Errorf("%s.new.crt does not verify against the existing CA: %v", name, err)
+		}
+		if _, err := tls.LoadX509KeyPair(filepath.Join(dir, name+".new.crt"), filepath.Join(dir, name+".new.key")); err != nil {
+			t.Errorf("failed to load migrated key pair: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "rust-server.new.crt")); !os.IsNotExist(err) {
+		t.Errorf("expiring certificate was migrated: %v", err)
+	}
+
+	caAfter, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatalf("Failed to read CA: %v", err)
+	}
+	if !bytes.Equal(caBefore, caAfter) {
+		t.Error("MigrateKeyType() modified the CA certificate")
+	}
+
+	if err := MigrateKeyType(dir, "dsa1024"); err == nil {
+		t.Error("MigrateKeyType() expected error for unsupported key type")
+	}
+	if err := MigrateKeyType(t.TempDir(), keyTypeECDSAP256); err == nil {
+		t.Error("MigrateKeyType() expected error for a directory without a CA")
+	}
+}
+
+// withValidity sets the validity flags for the duration of the test
+func withValidity(t *testing.T, leaf, ca time.Duration) {
+	t.Helper()
+
+	prevLeaf, prevCA := *validity, *caValidity
+	*validity, *caValidity = leaf, ca
+	t.Cleanup(func() { *validity, *caValidity = prevLeaf, prevCA })
+}
+
+// captureWarnings redirects short validity warnings to the returned buffer for the duration of the test
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prev := warnOutput
+	warnOutput = &buf
+	t.Cleanup(func() { warnOutput = prev })
+	return &buf
+}
+
+func TestValidity(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	t.Run("short validity warns", func(t *testing.T) {
+		withValidity(t, time.Second, time.Hour)
+		warnings := captureWarnings(t)
+
+		caCert, caKey, err := generateCA()
+		if err != nil {
+			t.Fatalf("generateCA() error = %v", err)
+		}
+		if err := generateCert("short.crt", "short.key", "spiffe://example.org/short", x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+			t.Fatalf("generateCert() error = %v", err)
+		}
+
+		cert := readCert(t, "short.crt")
+		if got := cert.NotAfter.Sub(cert.NotBefore); got > 2*time.Second {
+			t.Errorf("certificate validity = %v, want 1s", got)
+		}
+		if got := caCert.NotAfter.Sub(caCert.NotBefore); got < 59*time.Minute || got > time.Hour+time.Second {
+			t.Errorf("CA validity = %v, want 1h", got)
+		}
+
+		out := warnings.String()
+		for _, want := range []string{"WARNING", "short.crt expires in 1s", "ca.crt expires in 1h0m0s"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("warnings do not contain %q:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("default validity does not warn", func(t *testing.T) {
+		warnings := captureWarnings(t)
+
+		caCert, caKey, err := generateCA()
+		if err != nil {
+			t.Fatalf("generateCA() error = %v", err)
+		}
+		if err := generateCert("long.crt", "long.key", "spiffe://example.org/long", x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+			t.Fatalf("generateCert() error = %v", err)
+		}
+		if warnings.Len() != 0 {
+			t.Errorf("unexpected warnings:\n%s", warnings)
+		}
+	})
+
+	t.Run("non-positive validity is rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+
+		withValidity(t, time.Hour, 0)
+		if _, _, err := generateCA(); err == nil {
+			t.Error("generateCA() expected error for zero CA validity")
+		}
+
+		withValidity(t, -time.Hour, time.Hour)
+		caCert, caKey, err := generateCA()
+		if err != nil {
+			t.Fatalf("generateCA() error = %v", err)
+		}
+		if err := os.Remove(filepath.Join(dir, "ca.crt")); err != nil {
+			t.Fatalf("Failed to remove CA: %v", err)
+		}
+		if err := os.Remove(filepath.Join(dir, "ca.key")); err != nil {
+			t.Fatalf("Failed to remove CA key: %v", err)
+		}
+
+		if err := generateCert("negative.crt", "negative.key", "spiffe://example.org/negative", x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err == nil {
+			t.Error("generateCert() expected error for negative validity")
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("files were written despite invalid validity: %v", entries)
+		}
+	})
+}
+
+// readBundle returns every certificate in the PEM bundle at path
+func readBundle(t *testing.T, path string) []*x509.Certificate {
+	t.Helper()
+
+	certs, err := loadCAFiles([]string{path})
+	if err != nil {
+		t.Fatalf("loadCAFiles(%s) error = %v", path, err)
+	}
+	return certs
+}
+
+func TestExtraCAFiles(t *testing.T) {
+	withKeyType(t, keyTypeECDSAP256)
+
+	dirA := t.TempDir()
+	withCertDir(t, dirA)
+	caA, keyA, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	if err := generateCert("leaf.crt", "leaf.key", "spiffe://example.org/leaf", x509.ExtKeyUsageServerAuth, nil, nil, caA, keyA); err != nil {
+		t.Fatalf("generateCert() error = %v", err)
+	}
+	caFileA := filepath.Join(dirA, "ca.crt")
+
+	dirB := t.TempDir()
+	withCertDir(t, dirB)
+	caB, _, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+
+	extraCAs, err := loadCAFiles(parseList(caFileA))
+	if err != nil {
+		t.Fatalf("loadCAFiles() error = %v", err)
+	}
+	if _, err := writeTrustBundle(caB, extraCAs...); err != nil {
+		t.Fatalf("writeTrustBundle() error = %v", err)
+	}
+
+	for _, name := range []string{"trust-bundle.pem", combinedTrustBundleFile} {
+		certs := readBundle(t, filepath.Join(dirB, name))
+		if len(certs) != 2 {
+			t.Fatalf("%s has %d certificates, want 2", name, len(certs))
+		}
+		if !certs[0].Equal(caB) || !certs[1].Equal(caA) {
+			t.Errorf("%s does not contain the generated CA followed by the extra CA", name)
+		}
+	}
+
+	pool := x509.NewCertPool()
+	for _, cert := range readBundle(t, filepath.Join(dirB, combinedTrustBundleFile)) {
+		pool.AddCert(cert)
+	}
+	leaf := readBundle(t, filepath.Join(dirA, "leaf.crt"))[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("leaf from the extra CA does not verify against the combined bundle: %v", err)
+	}
+
+	t.Run("no extra CAs", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		if _, err := writeTrustBundle(caB); err != nil {
+			t.Fatalf("writeTrustBundle() error = %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, combinedTrustBundleFile)); !os.IsNotExist(err) {
+			t.Errorf("%s written without extra CAs", combinedTrustBundleFile)
+		}
+	})
+
+	t.Run("regenerated without extra CAs", func(t *testing.T) {
+		// dirB holds a combined bundle from the run above
+		withCertDir(t, dirB)
+		if _, err := writeTrustBundle(caB); err != nil {
+			t.Fatalf("writeTrustBundle() error = %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dirB, combinedTrustBundleFile)); !os.IsNotExist(err) {
+			t.Errorf("stale %s survived regeneration without extra CAs", combinedTrustBundleFile)
+		}
+		if certs := readBundle(t, filepath.Join(dirB, "trust-bundle.pem")); len(certs) != 1 || !certs[0].Equal(caB) {
+			t.Errorf("trust-bundle.pem does not contain only the generated CA")
+		}
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.pem")
+		if err := os.WriteFile(path, []byte("not a certificate"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		if _, err := loadCAFiles([]string{path}); err == nil {
+			t.Error("loadCAFiles() expected error for file without certificates")
+		}
+		if _, err := loadCAFiles([]string{filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+			t.Error("loadCAFiles() expected error for missing file")
+		}
+	})
+}
+
+// newCertSpecs returns n client certificate specs named cert-<i>
+func newCertSpecs(n int) []certSpec {
+	specs := make([]certSpec, n)
+	for i := range specs {
+		name := fmt.Sprintf("cert-%d", i)
+		specs[i] = certSpec{
+			certFile:    name + ".crt",
+			keyFile:     name + ".key",
+			spiffeID:    "spiffe://example.org/" + name,
+			extKeyUsage: x509.ExtKeyUsageClientAuth,
+		}
+	}
+	return specs
+}
+
+func TestGenerateCertsParallel(t *testing.T) {
+	withKeyType(t, keyTypeRSA2048)
+	withCertDir(t, t.TempDir())
+
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("generateCA() error = %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	t.Run("generates every certificate", func(t *testing.T) {
+		specs := newCertSpecs(10)
+		if err := generateCerts(specs, runtime.NumCPU(), caCert, caKey); err != nil {
+			t.Fatalf("generateCerts() error = %v", err)
+		}
+
+		serials := make(map[string]bool)
+		for _, spec := range specs {
+			cert := readCert(t, spec.certFile)
+			if len(cert.URIs) != 1 || cert.URIs[0].String() != spec.spiffeID {
+				t.Errorf("%s URIs = %v, want [%s]", spec.certFile, cert.URIs, spec.spiffeID)
+			}
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+				t.Errorf("%s does not verify against CA: %v", spec.certFile, err)
+			}
+			if serials[cert.SerialNumber.String()] {
+				t.Errorf("%s reuses serial number %s", spec.certFile, cert.SerialNumber)
+			}
+			serials[cert.SerialNumber.String()] = true
+		}
+	})
+
+	t.Run("faster than sequential generation", func(t *testing.T) {
+		if runtime.NumCPU() < 2 {
+			t.Skip("parallel generation needs more than one CPU")
+		}
+
+		start := time.Now()
+		if err := generateCerts(newCertSpecs(10), 1, caCert, caKey); err != nil {
+			t.Fatalf("sequential generateCerts() error = %v", err)
+		}
+		sequential := time.Since(start)
+
+		start = time.Now()
+		if err := generateCerts(newCertSpecs(10), runtime.NumCPU(), caCert, caKey); err != nil {
+			t.Fatalf("parallel generateCerts() error = %v", err)
+		}
+		parallel := time.Since(start)
+
+		if parallel >= sequential {
+			t.Errorf("parallel generation took %s, want less than sequential %s", parallel, sequential)
+		}
+	})
+
+	t.Run("returns the first error", func(t *testing.T) {
+		specs := newCertSpecs(3)
+		specs[1].spiffeID = "spiffe://example.org/%zz"
+
+		err := generateCerts(specs, 2, caCert, caKey)
+		if err == nil || !strings.Contains(err.Error(), "failed to generate cert-1.crt") {
+			t.Errorf("generateCerts() error = %v, want failure for cert-1.crt", err)
+		}
+	})
+}
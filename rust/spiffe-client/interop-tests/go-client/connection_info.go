@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLSConnectionInfo summarizes the security parameters negotiated for a TLS connection
+type TLSConnectionInfo struct {
+	Version            string
+	CipherSuite        string
+	NegotiatedProtocol string
+	ServerName         string
+	PeerSPIFFEIDs      []string
+}
+
+// ConnectionInfo extracts the negotiated parameters from conn
+// It must be called after the handshake has completed, otherwise the fields are empty
+func ConnectionInfo(conn *tls.Conn) TLSConnectionInfo {
+	state := conn.ConnectionState()
+
+	info := TLSConnectionInfo{
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+	}
+	if state.HandshakeComplete {
+		info.Version = tls.VersionName(state.Version)
+		info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		for _, uri := range state.PeerCertificates[0].URIs {
+			if uri.Scheme == "spiffe" {
+				info.PeerSPIFFEIDs = append(info.PeerSPIFFEIDs, uri.String())
+			}
+		}
+	}
+
+	return info
+}
+
+// String formats the info as a single log-friendly line
+func (i TLSConnectionInfo) String() string {
+	protocol := i.NegotiatedProtocol
+	if protocol == "" {
+		protocol = "none"
+	}
+	return fmt.Sprintf("version=%s cipher_suite=%s alpn=%s server_name=%s peer_spiffe_ids=[%s]",
+		i.Version, i.CipherSuite, protocol, i.ServerName, strings.Join(i.PeerSPIFFEIDs, ","))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Each interop program is a separate main module, and the interop-tests root is the certificate
+// generator's main package, which no module can import. DialWithRetry therefore lives with its only
+// caller; go-server accepts connections and never dials, so it has no copy
+
+// DialWithRetry dials address with TLS up to maxAttempts times, sleeping retryInterval between attempts
+// This covers servers that are still starting, e.g. under docker-compose; maxAttempts below 1 means a single attempt
+func DialWithRetry(address string, config *tls.Config, maxAttempts int, retryInterval time.Duration) (*tls.Conn, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		conn, err := tls.Dial("tcp", address, config)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("✓ Connected to %s on attempt %d", address, attempt)
+			}
+			return conn, nil
+		}
+		lastErr = err
+
+		if attempt < maxAttempts {
+			log.Printf("⚠ Attempt %d/%d to connect to %s failed, retrying in %s: %v", attempt, maxAttempts, address, retryInterval, err)
+			time.Sleep(retryInterval)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to connect to %s after %d attempt(s): %v", address, maxAttempts, lastErr)
+}
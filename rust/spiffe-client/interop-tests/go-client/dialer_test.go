@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reserveAddress returns a local address that nothing is listening on yet
+func reserveAddress(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestDialWithRetry(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{newSPIFFECertificate(t, "spiffe://example.org/go-server")}}
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+
+	t.Run("connects once the server starts listening", func(t *testing.T) {
+		address := reserveAddress(t)
+		start := make(chan struct{})
+		listening := make(chan error, 1)
+
+		// The server only starts listening once start is closed, after the first attempts have failed
+		go func() {
+			<-start
+			l, err := tls.Listen("tcp", address, serverConfig)
+			listening <- err
+			if err != nil {
+				return
+			}
+			defer l.Close()
+
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.(*tls.Conn).Handshake()
+		}()
+
+		time.AfterFunc(200*time.Millisecond, func() { close(start) })
+
+		conn, err := DialWithRetry(address, clientConfig, 50, 20*time.Millisecond)
+		if err != nil {
+			t.Fatalf("DialWithRetry() error = %v", err)
+		}
+		defer conn.Close()
+
+		if err := <-listening; err != nil {
+			t.Fatalf("tls.Listen() error = %v", err)
+		}
+		if !conn.ConnectionState().HandshakeComplete {
+			t.Error("handshake not complete")
+		}
+	})
+
+	t.Run("gives up after maxAttempts", func(t *testing.T) {
+		address := reserveAddress(t)
+
+		start := time.Now()
+		_, err := DialWithRetry(address, clientConfig, 3, 20*time.Millisecond)
+		if err == nil {
+			t.Fatal("DialWithRetry() expected error when nothing is listening")
+		}
+		if !strings.Contains(err.Error(), "after 3 attempt(s)") {
+			t.Errorf("error = %v, want attempt count", err)
+		}
+		// Two sleeps separate the three attempts
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+			t.Errorf("DialWithRetry() returned after %s, want at least 40ms", elapsed)
+		}
+	})
+
+	t.Run("single attempt when maxAttempts is not positive", func(t *testing.T) {
+		_, err := DialWithRetry(reserveAddress(t), clientConfig, 0, time.Hour)
+		if err == nil || !strings.Contains(err.Error(), "after 1 attempt(s)") {
+			t.Errorf("error = %v, want a single attempt", err)
+		}
+	})
+}
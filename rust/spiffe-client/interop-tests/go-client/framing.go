@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// maxMessageSize bounds the length prefix so a corrupt or hostile peer cannot force a huge allocation
+const maxMessageSize = 1 << 20
+
+// FramedConn exchanges messages prefixed with a 4-byte big-endian length over a connection
+// Unlike newline-delimited messages, payloads may contain any bytes, including PEM blocks
+type FramedConn struct {
+	net.Conn
+}
+
+// NewFramedConn wraps conn for length-prefixed messaging
+func NewFramedConn(conn net.Conn) *FramedConn {
+	return &FramedConn{Conn: conn}
+}
+
+// WriteMessage writes msg as a single frame
+func (c *FramedConn) WriteMessage(msg []byte) error {
+	if len(msg) > maxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum of %d bytes", len(msg), maxMessageSize)
+	}
+
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// ReadMessage reads the next frame and returns its payload
+// io.EOF is returned when the peer closes the connection between frames
+func (c *FramedConn) ReadMessage() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d bytes", size, maxMessageSize)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(c.Conn, msg); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %v", err)
+	}
+	return msg, nil
+}
@@ -1,17 +1,19 @@
 package main
 
 import (
-	"bufio"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
 	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
 	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
@@ -26,7 +28,12 @@ var (
 	clientKey      = flag.String("client-key", "go-client.key", "Client private key file name")
 	trustBundle    = flag.String("trust-bundle", "trust-bundle.pem", "Trust bundle file name")
 	clientSpiffeID = flag.String("client-spiffe-id", "spiffe://example.org/go-client", "Client SPIFFE ID")
-	serverSpiffeID = flag.String("server-spiffe-id", "", "Expected server SPIFFE ID (optional)")
+	serverSpiffeID = flag.String("server-spiffe-id", "", "Expected server SPIFFE ID (optional); a mismatch is logged as a warning unless -strict is set")
+	strict         = flag.Bool("strict", false, "Require -server-spiffe-id and exit with code 1 if the server presents a different SPIFFE ID")
+	retryAttempts  = flag.Int("retry-attempts", 1, "Number of connection attempts before giving up, for servers that are still starting")
+	retryInterval  = flag.Duration("retry-interval", time.Second, "Time to wait between connection attempts")
+	minTTL         = flag.Duration("min-ttl", 5*time.Minute, "Minimum remaining validity required for the client SVID")
+	serverName     = flag.String("server-name", "", "TLS server name (SNI) to send when dialing through an L4 proxy or load balancer; defaults to the -server host. Only overrides SNI, the server is still authorized by its SPIFFE ID")
 )
 
 func main() {
@@ -55,6 +62,11 @@ func main() {
 
 	log.Printf("✓ Loaded SPIFFE SVID for: %s", svid.ID)
 
+	// Fail early with a clear message instead of an opaque handshake error
+	if err := validateSVIDExpiry(svid, *minTTL); err != nil {
+		log.Fatalf("Invalid SPIFFE SVID: %v", err)
+	}
+
 	// Load trust bundle from file
 	trustBundlePath := filepath.Join(*certDir, *trustBundle)
 	bundle, err := x509bundle.Load(spiffeID.TrustDomain(), trustBundlePath)
@@ -71,30 +83,36 @@ func main() {
 	log.Printf("✓ Loaded trust bundle for domain: %s", spiffeID.TrustDomain())
 
 	// Configure TLS with SPIFFE validation
-	var tlsConfig *tls.Config
+	// The handshake accepts any server from the trust domain; the expected server SPIFFE ID is
+	// compared afterwards so that a mismatch can be reported as a warning outside strict mode
+	var expectedServerID spiffeid.ID
+	serverTD := spiffeID.TrustDomain()
 	if *serverSpiffeID != "" {
-		// Validate specific server SPIFFE ID
-		serverID, err := spiffeid.FromString(*serverSpiffeID)
+		expectedServerID, err = spiffeid.FromString(*serverSpiffeID)
 		if err != nil {
 			log.Fatalf("Invalid server SPIFFE ID: %v", err)
 		}
-		tlsConfig = tlsconfig.MTLSClientConfig(svid, bundle, tlsconfig.AuthorizeID(serverID))
-		log.Printf("✓ Configured to validate server SPIFFE ID: %s", serverID)
-	} else {
-		// Accept any SPIFFE ID from the same trust domain
-		tlsConfig = tlsconfig.MTLSClientConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(spiffeID.TrustDomain()))
-		log.Printf("✓ Configured to accept any server from trust domain: %s", spiffeID.TrustDomain())
+		serverTD = expectedServerID.TrustDomain()
+		log.Printf("✓ Configured to validate server SPIFFE ID: %s (strict: %t)", expectedServerID, *strict)
+	} else if *strict {
+		log.Fatalf("-strict requires -server-spiffe-id")
+	}
+	tlsConfig := newClientTLSConfig(svid, bundle, serverTD, *serverName)
+	log.Printf("✓ Configured to accept any server from trust domain: %s", serverTD)
+	if *serverName != "" {
+		log.Printf("✓ Overriding TLS server name (SNI): %s", *serverName)
 	}
 
 	// Connect to server
 	address := fmt.Sprintf("%s:%d", *serverAddr, *port)
-	conn, err := tls.Dial("tcp", address, tlsConfig)
+	conn, err := DialWithRetry(address, tlsConfig, *retryAttempts, *retryInterval)
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
 	defer conn.Close()
 
 	log.Printf("✓ SPIFFE mTLS handshake successful")
+	log.Printf("TLS connection info: %s", ConnectionInfo(conn))
 
 	// Verify server certificate contains SPIFFE ID
 	state := conn.ConnectionState()
@@ -109,59 +127,235 @@ func main() {
 			}
 		}
 	}
+	if !expectedServerID.IsZero() {
+		if err := verifyServerSPIFFEID(state, expectedServerID, *strict); err != nil {
+			log.Fatalf("Server SPIFFE ID verification failed: %v", err)
+		}
+	}
 
-	// Send test messages
-	writer := bufio.NewWriter(conn)
-	reader := bufio.NewReader(conn)
+	// Send length-prefixed test messages
+	framed := NewFramedConn(conn)
 
 	for i := 1; i <= 3; i++ {
-		message := fmt.Sprintf("Test message %d from SPIFFE Go client\n", i)
-		log.Printf("Sending: %s", message[:len(message)-1])
+		message := fmt.Sprintf("Test message %d from SPIFFE Go client", i)
+		log.Printf("Sending: %s", message)
 
-		_, err := writer.WriteString(message)
-		if err != nil {
+		if err := framed.WriteMessage([]byte(message)); err != nil {
 			log.Fatalf("Failed to send message: %v", err)
 		}
-		writer.Flush()
 
 		// Read response
-		response, err := reader.ReadString('\n')
+		response, err := framed.ReadMessage()
 		if err != nil {
 			log.Printf("Failed to read response: %v", err)
 			break
 		}
-		log.Printf("Received: %s", response[:len(response)-1])
+		log.Printf("Received: %s", response)
 
 		time.Sleep(1 * time.Second)
 	}
 
 	// Send close message
-	writer.WriteString("CLOSE\n")
-	writer.Flush()
+	if err := framed.WriteMessage([]byte("CLOSE")); err != nil {
+		log.Printf("Failed to send close message: %v", err)
+	}
 
 	log.Printf("✓ SPIFFE interop test completed successfully")
 }
 
+// newClientTLSConfig builds the mTLS client configuration that authorizes any server from serverTD
+// A non-empty serverName is sent as SNI instead of the host derived from the dial address.
+// go-spiffe verifies the server by SPIFFE ID rather than hostname, so serverName does not affect authorization
+func newClientTLSConfig(svid *x509svid.SVID, bundle *x509bundle.Bundle, serverTD spiffeid.TrustDomain, serverName string) *tls.Config {
+	config := tlsconfig.MTLSClientConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(serverTD))
+	if serverName != "" {
+		config.ServerName = serverName
+	}
+	return config
+}
+
+// verifyServerSPIFFEID compares the SPIFFE ID the server presented during the handshake with expected
+// A mismatch is returned as an error in strict mode and only logged as a warning otherwise
+func verifyServerSPIFFEID(state tls.ConnectionState, expected spiffeid.ID, strict bool) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+
+	actual, err := x509svid.IDFromCert(state.PeerCertificates[0])
+	if err != nil {
+		return fmt.Errorf("server certificate has no SPIFFE ID: %v", err)
+	}
+	if actual == expected {
+		log.Printf("✓ Server SPIFFE ID matches expected: %s", expected)
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("server SPIFFE ID %s does not match expected %s", actual, expected)
+	}
+	log.Printf("⚠ Server SPIFFE ID %s does not match expected %s (use -strict to fail)", actual, expected)
+	return nil
+}
+
+// validateSVIDExpiry checks that the leaf certificate remains valid for at least minTTL
+func validateSVIDExpiry(svid *x509svid.SVID, minTTL time.Duration) error {
+	if len(svid.Certificates) == 0 {
+		return fmt.Errorf("SVID for %s has no certificates", svid.ID)
+	}
+
+	cert := svid.Certificates[0]
+	remaining := cert.NotAfter.Sub(time.Now())
+	if remaining <= 0 {
+		return fmt.Errorf("SVID for %s expired at %s; regenerate the certificates in %s",
+			svid.ID, cert.NotAfter.Format(time.RFC3339), *certDir)
+	}
+	if remaining < minTTL {
+		return fmt.Errorf("SVID for %s expires at %s (in %s), less than the minimum TTL of %s",
+			svid.ID, cert.NotAfter.Format(time.RFC3339), remaining.Round(time.Second), minTTL)
+	}
+
+	return nil
+}
+
+// combinedTrustBundleFile is written by generate_spiffe_certs.go when extra CA files are aggregated
+const combinedTrustBundleFile = "combined-trust-bundle.pem"
+
 // createTrustBundleFromCAs creates a trust bundle from available CA certificates
+// The combined trust bundle is preferred when it exists, since it already holds every CA
 func createTrustBundleFromCAs(td spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
 	bundle := x509bundle.New(td)
 
+	if combinedPEM, err := os.ReadFile(filepath.Join(*certDir, combinedTrustBundleFile)); err == nil {
+		certs, err := parsePEMBundle(combinedPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", combinedTrustBundleFile, err)
+		}
+		if len(certs) > 0 {
+			for _, cert := range certs {
+				bundle.AddX509Authority(cert)
+			}
+			log.Printf("✓ Added %d CA certificate(s) from %s to trust bundle", len(certs), combinedTrustBundleFile)
+			return bundle, nil
+		}
+	}
+
 	// Try to load available CA certificates
 	caFiles := []string{"go-ca.crt", "ca.crt", "rust-ca.crt"}
 
 	for _, caFile := range caFiles {
 		caPath := filepath.Join(*certDir, caFile)
-		if caCertPEM, err := os.ReadFile(caPath); err == nil {
-			// Parse PEM blocks
-			block, _ := pem.Decode(caCertPEM)
-			if block != nil {
-				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
-					bundle.AddX509Authority(cert)
-					log.Printf("✓ Added CA certificate from %s to trust bundle", caFile)
-				}
-			}
+		caCertPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			continue
+		}
+
+		certs, err := parsePEMBundle(caCertPEM)
+		if err != nil {
+			log.Printf("⚠ Skipping %s: %v", caFile, err)
+			continue
+		}
+		for _, cert := range certs {
+			bundle.AddX509Authority(cert)
+		}
+		if len(certs) > 0 {
+			log.Printf("✓ Added %d CA certificate(s) from %s to trust bundle", len(certs), caFile)
 		}
 	}
 
 	return bundle, nil
-}
\ No newline at end of file
+}
+
+// parsePEMBundle parses every CERTIFICATE block in data, skipping other block types
+func parsePEMBundle(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := data
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %v", len(certs), err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// FetchFederatedBundle fetches a trust bundle from a SPIFFE bundle endpoint.
+// The endpoint certificate is verified against the CA in caCertPath, and the
+// trust domain of the bundle is taken from the SPIFFE ID the endpoint presents.
+func FetchFederatedBundle(bundleURL, caCertPath string) (*x509bundle.Bundle, error) {
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle endpoint URL: %v", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("bundle endpoint URL must use https, got %q", u.Scheme)
+	}
+
+	caCertPEM, err := os.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	caCerts, err := parsePEMBundle(caCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(caCerts) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+	}
+
+	roots := x509.NewCertPool()
+	for _, cert := range caCerts {
+		roots.AddCert(cert)
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		},
+		// A redirect to plain HTTP would deliver the bundle without authenticating the endpoint
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to non-https URL %s", req.URL)
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Get(bundleURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching bundle: %s", resp.Status)
+	}
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("bundle endpoint did not present a certificate")
+	}
+	endpointID, err := x509svid.IDFromCert(resp.TLS.PeerCertificates[0])
+	if err != nil {
+		return nil, fmt.Errorf("bundle endpoint did not present a SPIFFE ID: %v", err)
+	}
+
+	bundle, err := spiffebundle.Read(endpointID.TrustDomain(), resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %v", err)
+	}
+
+	log.Printf("✓ Fetched federated bundle for %s from %s", endpointID.TrustDomain(), bundleURL)
+	return bundle.X509Bundle(), nil
+}
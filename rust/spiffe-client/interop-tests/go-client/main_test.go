@@ -0,0 +1,462 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// newTestSVID creates a self-signed SVID valid between notBefore and notAfter
+func newTestSVID(t *testing.T, notBefore, notAfter time.Time) *x509svid.SVID {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	id := spiffeid.RequireFromString("spiffe://example.org/go-client")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		URIs:         []*url.URL{id.URL()},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return &x509svid.SVID{ID: id, Certificates: []*x509.Certificate{cert}, PrivateKey: key}
+}
+
+func TestValidateSVIDExpiry(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		svid    *x509svid.SVID
+		minTTL  time.Duration
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			svid:   newTestSVID(t, now.Add(-time.Hour), now.Add(time.Hour)),
+			minTTL: 5 * time.Minute,
+		},
+		{
+			name:    "expired",
+			svid:    newTestSVID(t, now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			minTTL:  5 * time.Minute,
+			wantErr: "SVID for spiffe://example.org/go-client expired at",
+		},
+		{
+			name:    "below minimum TTL",
+			svid:    newTestSVID(t, now.Add(-time.Hour), now.Add(time.Minute)),
+			minTTL:  5 * time.Minute,
+			wantErr: "less than the minimum TTL of 5m0s",
+		},
+		{
+			name:    "no certificates",
+			svid:    &x509svid.SVID{ID: spiffeid.RequireFromString("spiffe://example.org/go-client")},
+			minTTL:  5 * time.Minute,
+			wantErr: "has no certificates",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSVIDExpiry(tt.svid, tt.minTTL)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateSVIDExpiry() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateSVIDExpiry() expected error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSVIDExpiry() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// pemChain encodes n self-signed certificates into a single PEM file, interleaved with a key block
+func pemChain(t *testing.T, n int) ([]byte, []*x509.Certificate) {
+	t.Helper()
+
+	var data []byte
+	var certs []*x509.Certificate
+	for i := 0; i < n; i++ {
+		svid := newTestSVID(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svid.Certificates[0].Raw})...)
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("ignored")})...)
+		certs = append(certs, svid.Certificates[0])
+	}
+	return data, certs
+}
+
+func TestParsePEMBundle(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		t.Run(fmt.Sprintf("%d certificates", n), func(t *testing.T) {
+			data, want := pemChain(t, n)
+
+			got, err := parsePEMBundle(data)
+			if err != nil {
+				t.Fatalf("parsePEMBundle() unexpected error: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("parsePEMBundle() returned %d certificates, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Errorf("certificate %d does not match", i)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid certificate", func(t *testing.T) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+		if _, err := parsePEMBundle(data); err == nil {
+			t.Error("parsePEMBundle() expected error for invalid certificate")
+		}
+	})
+}
+
+// newTestBundleEndpoint starts a TLS server whose certificate carries endpointID and is signed by a
+// fresh CA, serving that CA as a SPIFFE bundle. It returns the server, the CA file path and the CA.
+func newTestBundleEndpoint(t *testing.T, endpointID spiffeid.ID) (*httptest.Server, string, *x509.Certificate) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		URIs:                  []*url.URL{endpointID.TrustDomain().ID().URL()},
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:         []*url.URL{endpointID.URL()},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	bundle, err := spiffebundle.FromX509Authorities(endpointID.TrustDomain(), []*x509.Certificate{caCert}).Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal bundle: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+			return
+		}
+		if r.URL.Path != "/.well-known/spiffe-bundle" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jose+json")
+		w.Write(bundle)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0644); err != nil {
+		t.Fatalf("failed to write CA certificate: %v", err)
+	}
+
+	return server, caPath, caCert
+}
+
+func TestFetchFederatedBundle(t *testing.T) {
+	endpointID := spiffeid.RequireFromString("spiffe://federated.example/bundle-endpoint")
+	server, caPath, caCert := newTestBundleEndpoint(t, endpointID)
+
+	t.Run("valid endpoint", func(t *testing.T) {
+		bundle, err := FetchFederatedBundle(server.URL+"/.well-known/spiffe-bundle", caPath)
+		if err != nil {
+			t.Fatalf("FetchFederatedBundle() unexpected error: %v", err)
+		}
+		if bundle.TrustDomain() != endpointID.TrustDomain() {
+			t.Errorf("bundle trust domain = %s, want %s", bundle.TrustDomain(), endpointID.TrustDomain())
+		}
+		if !bundle.HasX509Authority(caCert) {
+			t.Error("bundle does not contain the endpoint CA")
+		}
+	})
+
+	t.Run("untrusted CA", func(t *testing.T) {
+		_, otherCAPath, _ := newTestBundleEndpoint(t, endpointID)
+		_, err := FetchFederatedBundle(server.URL+"/.well-known/spiffe-bundle", otherCAPath)
+		if err == nil || !strings.Contains(err.Error(), "failed to fetch bundle") {
+			t.Errorf("FetchFederatedBundle() error = %v, want certificate verification failure", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := FetchFederatedBundle(server.URL+"/missing", caPath)
+		if err == nil || !strings.Contains(err.Error(), "unexpected status") {
+			t.Errorf("FetchFederatedBundle() error = %v, want unexpected status", err)
+		}
+	})
+
+	t.Run("plain http URL", func(t *testing.T) {
+		_, err := FetchFederatedBundle("http://127.0.0.1/.well-known/spiffe-bundle", caPath)
+		if err == nil || !strings.Contains(err.Error(), "must use https") {
+			t.Errorf("FetchFederatedBundle() error = %v, want https required", err)
+		}
+	})
+
+	t.Run("redirect to plain http", func(t *testing.T) {
+		plain := httptest.NewServer(http.NotFoundHandler())
+		t.Cleanup(plain.Close)
+
+		_, err := FetchFederatedBundle(server.URL+"/redirect?to="+url.QueryEscape(plain.URL+"/.well-known/spiffe-bundle"), caPath)
+		if err == nil || !strings.Contains(err.Error(), "redirect to non-https URL") {
+			t.Errorf("FetchFederatedBundle() error = %v, want redirect rejected", err)
+		}
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		_, err := FetchFederatedBundle(server.URL+"/.well-known/spiffe-bundle", filepath.Join(t.TempDir(), "missing.crt"))
+		if err == nil || !strings.Contains(err.Error(), "failed to read CA certificate") {
+			t.Errorf("FetchFederatedBundle() error = %v, want read failure", err)
+		}
+	})
+}
+
+// withCertDir points the cert-dir flag at dir for the duration of the test
+func withCertDir(t *testing.T, dir string) {
+	t.Helper()
+
+	prev := *certDir
+	*certDir = dir
+	t.Cleanup(func() { *certDir = prev })
+}
+
+func TestCreateTrustBundleFromCAs(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+
+	t.Run("CA files", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		data, cas := pemChain(t, 1)
+		if err := os.WriteFile(filepath.Join(dir, "ca.crt"), data, 0644); err != nil {
+			t.Fatalf("failed to write CA: %v", err)
+		}
+
+		bundle, err := createTrustBundleFromCAs(td)
+		if err != nil {
+			t.Fatalf("createTrustBundleFromCAs() error = %v", err)
+		}
+		if !bundle.HasX509Authority(cas[0]) || len(bundle.X509Authorities()) != 1 {
+			t.Errorf("bundle authorities = %d, want only ca.crt", len(bundle.X509Authorities()))
+		}
+	})
+
+	t.Run("prefers combined bundle", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		caData, _ := pemChain(t, 1)
+		combinedData, combined := pemChain(t, 2)
+		if err := os.WriteFile(filepath.Join(dir, "ca.crt"), caData, 0644); err != nil {
+			t.Fatalf("failed to write CA: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, combinedTrustBundleFile), combinedData, 0644); err != nil {
+			t.Fatalf("failed to write bundle: %v", err)
+		}
+
+		bundle, err := createTrustBundleFromCAs(td)
+		if err != nil {
+			t.Fatalf("createTrustBundleFromCAs() error = %v", err)
+		}
+		if len(bundle.X509Authorities()) != 2 || !bundle.HasX509Authority(combined[0]) || !bundle.HasX509Authority(combined[1]) {
+			t.Errorf("bundle authorities = %d, want the CAs from %s", len(bundle.X509Authorities()), combinedTrustBundleFile)
+		}
+	})
+
+	t.Run("invalid combined bundle", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+		if err := os.WriteFile(filepath.Join(dir, combinedTrustBundleFile), data, 0644); err != nil {
+			t.Fatalf("failed to write bundle: %v", err)
+		}
+
+		if _, err := createTrustBundleFromCAs(td); err == nil {
+			t.Error("createTrustBundleFromCAs() expected error for invalid combined bundle")
+		}
+	})
+}
+
+func TestVerifyServerSPIFFEID(t *testing.T) {
+	now := time.Now()
+	// newTestSVID presents spiffe://example.org/go-client, standing in for the server certificate here
+	cert := newTestSVID(t, now.Add(-time.Hour), now.Add(time.Hour)).Certificates[0]
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	mismatched := spiffeid.RequireFromString("spiffe://example.org/go-server")
+
+	tests := []struct {
+		name     string
+		state    tls.ConnectionState
+		expected spiffeid.ID
+		strict   bool
+		wantErr  string
+		wantLog  string
+	}{
+		{
+			name:     "match",
+			state:    state,
+			expected: spiffeid.RequireFromString("spiffe://example.org/go-client"),
+			strict:   true,
+			wantLog:  "✓ Server SPIFFE ID matches expected",
+		},
+		{
+			name:     "mismatch in strict mode",
+			state:    state,
+			expected: mismatched,
+			strict:   true,
+			wantErr:  "does not match expected spiffe://example.org/go-server",
+		},
+		{
+			name:     "mismatch warns without strict mode",
+			state:    state,
+			expected: mismatched,
+			wantLog:  "⚠ Server SPIFFE ID spiffe://example.org/go-client does not match expected spiffe://example.org/go-server",
+		},
+		{
+			name:     "no peer certificate",
+			expected: mismatched,
+			wantErr:  "no certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs strings.Builder
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			err := verifyServerSPIFFEID(tt.state, tt.expected, tt.strict)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyServerSPIFFEID() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyServerSPIFFEID() unexpected error: %v", err)
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log output %q does not contain %q", logs.String(), tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestNewClientTLSConfig(t *testing.T) {
+	now := time.Now()
+	svid := newTestSVID(t, now.Add(-time.Hour), now.Add(time.Hour))
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	bundle := x509bundle.FromX509Authorities(td, svid.Certificates)
+
+	tests := []struct {
+		name       string
+		serverName string
+	}{
+		{name: "override", serverName: "spire-server.internal"},
+		{name: "derived from the dial address", serverName: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newClientTLSConfig(svid, bundle, td, tt.serverName)
+			if config.ServerName != tt.serverName {
+				t.Errorf("ServerName = %q, want %q", config.ServerName, tt.serverName)
+			}
+			if config.VerifyPeerCertificate == nil {
+				t.Error("VerifyPeerCertificate is nil, SPIFFE ID validation must stay in place")
+			}
+		})
+	}
+
+	t.Run("sent as SNI", func(t *testing.T) {
+		clientPipe, serverPipe := net.Pipe()
+		defer clientPipe.Close()
+
+		sni := make(chan string, 1)
+		go func() {
+			defer serverPipe.Close()
+			tls.Server(serverPipe, &tls.Config{
+				GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+					sni <- hello.ServerName
+					return nil, fmt.Errorf("stop after the ClientHello")
+				},
+			}).Handshake()
+		}()
+
+		// The handshake is aborted by the server; only the ClientHello matters here
+		tls.Client(clientPipe, newClientTLSConfig(svid, bundle, td, "spire-server.internal")).Handshake()
+		if got := <-sni; got != "spire-server.internal" {
+			t.Errorf("server received SNI %q, want %q", got, "spire-server.internal")
+		}
+	})
+}
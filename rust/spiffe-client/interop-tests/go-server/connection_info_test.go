@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newSPIFFECertificate creates a self-signed TLS certificate carrying spiffeID as a URI SAN
+func newSPIFFECertificate(t *testing.T, spiffeID string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	id, err := url.Parse(spiffeID)
+	if err != nil {
+		t.Fatalf("failed to parse SPIFFE ID: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{id},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestConnectionInfo(t *testing.T) {
+	clientPipe, serverPipe := net.Pipe()
+	defer clientPipe.Close()
+	defer serverPipe.Close()
+
+	server := tls.Server(serverPipe, &tls.Config{
+		Certificates: []tls.Certificate{newSPIFFECertificate(t, "spiffe://example.org/go-server")},
+		ClientAuth:   tls.RequireAnyClientCert,
+		NextProtos:   []string{"spiffe-interop"},
+	})
+	client := tls.Client(clientPipe, &tls.Config{
+		Certificates:       []tls.Certificate{newSPIFFECertificate(t, "spiffe://example.org/go-client")},
+		ServerName:         "localhost",
+		NextProtos:         []string{"spiffe-interop"},
+		InsecureSkipVerify: true,
+	})
+
+	if info := ConnectionInfo(client); info.Version != "" || info.CipherSuite != "" {
+		t.Errorf("ConnectionInfo() before handshake = %+v, want empty version and cipher suite", info)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Handshake() }()
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		conn   *tls.Conn
+		peerID string
+	}{
+		{name: "server side", conn: server, peerID: "spiffe://example.org/go-client"},
+		{name: "client side", conn: client, peerID: "spiffe://example.org/go-server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ConnectionInfo(tt.conn)
+
+			if info.Version != "TLS 1.3" {
+				t.Errorf("Version = %q, want %q", info.Version, "TLS 1.3")
+			}
+			if info.CipherSuite == "" {
+				t.Error("CipherSuite is empty")
+			}
+			if info.NegotiatedProtocol != "spiffe-interop" {
+				t.Errorf("NegotiatedProtocol = %q, want %q", info.NegotiatedProtocol, "spiffe-interop")
+			}
+			if info.ServerName != "localhost" {
+				t.Errorf("ServerName = %q, want %q", info.ServerName, "localhost")
+			}
+			if len(info.PeerSPIFFEIDs) != 1 || info.PeerSPIFFEIDs[0] != tt.peerID {
+				t.Errorf("PeerSPIFFEIDs = %v, want [%s]", info.PeerSPIFFEIDs, tt.peerID)
+			}
+		})
+	}
+}
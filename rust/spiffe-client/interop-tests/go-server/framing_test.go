@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestFramedConn_RoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	pemBlock := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: bytes.Repeat([]byte{0xAB}, 300)})
+	if bytes.Count(pemBlock, []byte("\n")) < 3 {
+		t.Fatalf("PEM block should span several lines")
+	}
+	messages := [][]byte{pemBlock, {}, []byte("CLOSE")}
+
+	go func() {
+		framed := NewFramedConn(client)
+		for _, msg := range messages {
+			if err := framed.WriteMessage(msg); err != nil {
+				t.Errorf("WriteMessage() error = %v", err)
+				return
+			}
+		}
+		client.Close()
+	}()
+
+	framed := NewFramedConn(server)
+	for i, want := range messages {
+		got, err := framed.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() %d error = %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadMessage() %d = %q, want %q", i, got, want)
+		}
+	}
+
+	if _, err := framed.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage() after close error = %v, want io.EOF", err)
+	}
+}
+
+func TestFramedConn_Limits(t *testing.T) {
+	t.Run("write too large", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		if err := NewFramedConn(client).WriteMessage(make([]byte, maxMessageSize+1)); err == nil {
+			t.Error("WriteMessage() expected error for oversized message")
+		}
+	})
+
+	t.Run("read too large", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		go func() {
+			var header [4]byte
+			binary.BigEndian.PutUint32(header[:], maxMessageSize+1)
+			client.Write(header[:])
+		}()
+
+		if _, err := NewFramedConn(server).ReadMessage(); err == nil {
+			t.Error("ReadMessage() expected error for oversized length prefix")
+		}
+	})
+
+	t.Run("truncated body", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer server.Close()
+
+		go func() {
+			var header [4]byte
+			binary.BigEndian.PutUint32(header[:], 10)
+			client.Write(append(header[:], "short"...))
+			client.Close()
+		}()
+
+		if _, err := NewFramedConn(server).ReadMessage(); err == nil {
+			t.Error("ReadMessage() expected error for truncated message")
+		}
+	})
+}
@@ -2,6 +2,12 @@ module go-server
 
 go 1.25.1
 
-require github.com/spiffe/go-spiffe/v2 v2.1.6
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/spiffe/go-spiffe/v2 v2.1.6
+)
 
-require github.com/zeebo/errs v1.3.0 // indirect
+require (
+	github.com/zeebo/errs v1.3.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+)
@@ -1,17 +1,21 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
@@ -20,19 +24,52 @@ import (
 )
 
 var (
-	port           = flag.Int("port", 8444, "Server port")
-	certDir        = flag.String("cert-dir", "certs", "Certificate directory path")
-	serverCert     = flag.String("server-cert", "go-server.crt", "Server certificate file name")
-	serverKey      = flag.String("server-key", "go-server.key", "Server private key file name")
-	trustBundle    = flag.String("trust-bundle", "trust-bundle.pem", "Trust bundle file name")
-	serverSpiffeID = flag.String("server-spiffe-id", "spiffe://example.org/go-server", "Server SPIFFE ID")
+	port            = flag.Int("port", 8444, "Server port")
+	certDir         = flag.String("cert-dir", "certs", "Certificate directory path")
+	serverCert      = flag.String("server-cert", "go-server.crt", "Server certificate file name")
+	serverKey       = flag.String("server-key", "go-server.key", "Server private key file name")
+	trustBundle     = flag.String("trust-bundle", "trust-bundle.pem", "Trust bundle file name")
+	serverSpiffeID  = flag.String("server-spiffe-id", "spiffe://example.org/go-server", "Server SPIFFE ID")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "Time to wait for active connections to finish on SIGINT or SIGTERM")
+	logFormat       = flag.String("log-format", logFormatText, "Log output format: text or json")
 )
 
+// Log output formats accepted by -log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogHandler returns the slog handler writing to stdout in the given format
+func newLogHandler(format string) (slog.Handler, error) {
+	switch format {
+	case logFormatText:
+		return slog.NewTextHandler(os.Stdout, nil), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(os.Stdout, nil), nil
+	default:
+		return nil, fmt.Errorf("invalid -log-format %q: must be %s or %s", format, logFormatText, logFormatJSON)
+	}
+}
+
+// fatal logs err and exits
+func fatal(ctx context.Context, msg string, err error) {
+	slog.ErrorContext(ctx, msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
 	flag.Parse()
 
-	log.Printf("Starting SPIFFE Go mTLS server for interop testing")
-	log.Printf("Listening on port %d", *port)
+	handler, err := newLogHandler(*logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(slog.New(handler))
+
+	ctx := context.Background()
+	slog.InfoContext(ctx, "Starting SPIFFE Go mTLS server for interop testing", "port", *port)
 
 	// Load SPIFFE SVID from files
 	serverCertPath := filepath.Join(*certDir, *serverCert)
@@ -40,138 +77,203 @@ func main() {
 
 	svid, err := x509svid.Load(serverCertPath, serverKeyPath)
 	if err != nil {
-		log.Fatalf("Failed to load SPIFFE SVID: %v", err)
+		fatal(ctx, "Failed to load SPIFFE SVID", err)
 	}
 
 	// Parse server SPIFFE ID
 	spiffeID, err := spiffeid.FromString(*serverSpiffeID)
 	if err != nil {
-		log.Fatalf("Invalid server SPIFFE ID: %v", err)
+		fatal(ctx, "Invalid server SPIFFE ID", err)
 	}
 
-	log.Printf("✓ Loaded SPIFFE SVID for: %s", svid.ID)
+	slog.InfoContext(ctx, "Loaded SPIFFE SVID", "spiffe_id", svid.ID.String())
 
 	// Load trust bundle from file
 	trustBundlePath := filepath.Join(*certDir, *trustBundle)
 	bundle, err := x509bundle.Load(spiffeID.TrustDomain(), trustBundlePath)
 	if err != nil {
-		log.Printf("⚠ Failed to load trust bundle, will create from available CAs: %v", err)
+		slog.WarnContext(ctx, "Failed to load trust bundle, will create from available CAs", "error", err)
 
 		// Fallback: create bundle from available CA certificates
 		bundle, err = createTrustBundleFromCAs(spiffeID.TrustDomain())
 		if err != nil {
-			log.Fatalf("Failed to create trust bundle: %v", err)
+			fatal(ctx, "Failed to create trust bundle", err)
 		}
 	}
 
-	log.Printf("✓ Loaded trust bundle for domain: %s", spiffeID.TrustDomain())
+	slog.InfoContext(ctx, "Loaded trust bundle", "trust_domain", spiffeID.TrustDomain().String())
 
 	// Configure TLS with SPIFFE validation
 	// Accept any client from the same trust domain
 	tlsConfig := tlsconfig.MTLSServerConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(spiffeID.TrustDomain()))
 
-	// Start listening
-	address := fmt.Sprintf(":%d", *port)
-	listener, err := tls.Listen("tcp", address, tlsConfig)
+	// Start the server, reloading the SVID whenever the certificate files change
+	server, err := NewAutoRotatingServer(tlsConfig, svid, bundle, *certDir)
 	if err != nil {
-		log.Fatalf("Failed to start TLS listener: %v", err)
+		fatal(ctx, "Failed to start certificate watcher", err)
 	}
-	defer listener.Close()
-
-	log.Printf("SPIFFE mTLS server listening on %s", address)
-
-	// Accept connections
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("Failed to accept connection: %v", err)
-			continue
+	defer server.Close()
+
+	// Drain active connections on SIGINT or SIGTERM before exiting
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		slog.InfoContext(ctx, "Received signal, waiting for active connections", "signal", sig.String(), "timeout", *shutdownTimeout)
+
+		ctx, cancel := context.WithTimeout(ctx, *shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.WarnContext(ctx, "Shutdown did not complete", "error", err)
+			return
 		}
+		slog.InfoContext(ctx, "All connections drained")
+	}()
 
-		go handleClient(conn)
+	address := fmt.Sprintf(":%d", *port)
+	if err := server.Serve(address, handleClient); err != nil {
+		fatal(ctx, "Server failed", err)
 	}
+	<-shutdownDone
 }
 
 func handleClient(conn net.Conn) {
 	defer conn.Close()
 
-	// Get client info
-	clientAddr := conn.RemoteAddr()
-	log.Printf("Connection from %s", clientAddr)
+	ctx := context.Background()
+	logger := slog.With("client_addr", conn.RemoteAddr().String())
+	logger.InfoContext(ctx, "Connection accepted")
 
 	// Extract client certificate info
 	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Accepted connections handshake lazily; complete it now so the negotiated state is available
+		if err := tlsConn.Handshake(); err != nil {
+			logger.ErrorContext(ctx, "TLS handshake failed", "error", err)
+			return
+		}
 		state := tlsConn.ConnectionState()
-		log.Printf("✓ SPIFFE mTLS handshake successful")
+		info := ConnectionInfo(tlsConn)
+		logger.InfoContext(ctx, "SPIFFE mTLS handshake successful", "tls_version", info.Version, "cipher_suite", info.CipherSuite)
 
 		if len(state.PeerCertificates) > 0 {
 			cert := state.PeerCertificates[0]
-			log.Printf("Client certificate subject: %s", cert.Subject)
 
 			// Check for SPIFFE ID in SAN
 			for _, uri := range cert.URIs {
 				if uri.Scheme == "spiffe" {
-					log.Printf("✓ Client SPIFFE ID verified: %s", uri.String())
+					logger = logger.With("spiffe_id", uri.String())
 				}
 			}
-			log.Printf("✓ Client certificate verified")
+			logger.InfoContext(ctx, "Client certificate verified", "subject", cert.Subject.String())
 		} else {
-			log.Printf("No client certificates presented")
+			logger.InfoContext(ctx, "No client certificates presented")
 		}
 	}
 
-	// Handle messages (simple echo server)
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
+	// Handle length-prefixed messages (simple echo server)
+	framed := NewFramedConn(conn)
+	messageCount := 0
 
 	for {
-		message, err := reader.ReadString('\n')
+		message, err := framed.ReadMessage()
 		if err != nil {
-			log.Printf("Client %s disconnected", clientAddr)
+			if !errors.Is(err, io.EOF) {
+				logger.ErrorContext(ctx, "Failed to read message", "message_count", messageCount, "error", err)
+			}
 			break
 		}
+		messageCount++
 
-		message = strings.TrimSpace(message)
-		log.Printf("Received from %s: %s", clientAddr, message)
+		logger.InfoContext(ctx, "Received message", "message_count", messageCount, "message", string(message))
 
-		if message == "CLOSE" {
-			log.Printf("Client %s requested close", clientAddr)
+		if string(message) == "CLOSE" {
+			logger.InfoContext(ctx, "Client requested close", "message_count", messageCount)
 			break
 		}
 
 		// Echo back with confirmation
-		response := fmt.Sprintf("SPIFFE_GO_SERVER_ECHO: %s\n", message)
-		_, err = writer.WriteString(response)
-		if err != nil {
-			log.Printf("Failed to send response: %v", err)
+		response := fmt.Sprintf("SPIFFE_GO_SERVER_ECHO: %s", message)
+		if err := framed.WriteMessage([]byte(response)); err != nil {
+			logger.ErrorContext(ctx, "Failed to send response", "message_count", messageCount, "error", err)
 			break
 		}
-		writer.Flush()
 	}
 
-	log.Printf("Client %s disconnected", clientAddr)
+	logger.InfoContext(ctx, "Client disconnected", "message_count", messageCount)
 }
 
+// combinedTrustBundleFile is written by generate_spiffe_certs.go when extra CA files are aggregated
+const combinedTrustBundleFile = "combined-trust-bundle.pem"
+
 // createTrustBundleFromCAs creates a trust bundle from available CA certificates
+// The combined trust bundle is preferred when it exists, since it already holds every CA
 func createTrustBundleFromCAs(td spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
 	bundle := x509bundle.New(td)
 
+	if combinedPEM, err := os.ReadFile(filepath.Join(*certDir, combinedTrustBundleFile)); err == nil {
+		certs, err := parsePEMBundle(combinedPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", combinedTrustBundleFile, err)
+		}
+		if len(certs) > 0 {
+			for _, cert := range certs {
+				bundle.AddX509Authority(cert)
+			}
+			slog.Info("Added CA certificates to trust bundle", "count", len(certs), "file", combinedTrustBundleFile)
+			return bundle, nil
+		}
+	}
+
 	// Try to load available CA certificates
 	caFiles := []string{"go-ca.crt", "ca.crt", "rust-ca.crt"}
 
 	for _, caFile := range caFiles {
 		caPath := filepath.Join(*certDir, caFile)
-		if caCertPEM, err := os.ReadFile(caPath); err == nil {
-			// Parse PEM blocks
-			block, _ := pem.Decode(caCertPEM)
-			if block != nil {
-				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
-					bundle.AddX509Authority(cert)
-					log.Printf("✓ Added CA certificate from %s to trust bundle", caFile)
-				}
-			}
+		caCertPEM, err := os.ReadFile(caPath)
+		if err != nil {
+			continue
+		}
+
+		certs, err := parsePEMBundle(caCertPEM)
+		if err != nil {
+			slog.Warn("Skipping CA file", "file", caFile, "error", err)
+			continue
+		}
+		for _, cert := range certs {
+			bundle.AddX509Authority(cert)
+		}
+		if len(certs) > 0 {
+			slog.Info("Added CA certificates to trust bundle", "count", len(certs), "file", caFile)
 		}
 	}
 
 	return bundle, nil
-}
\ No newline at end of file
+}
+
+// parsePEMBundle parses every CERTIFICATE block in data, skipping other block types
+func parsePEMBundle(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := data
+	for len(rest) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %v", len(certs), err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// withCertDir points the cert-dir flag at dir for the duration of the test
+func withCertDir(t *testing.T, dir string) {
+	t.Helper()
+
+	prev := *certDir
+	*certDir = dir
+	t.Cleanup(func() { *certDir = prev })
+}
+
+func TestParsePEMBundle(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		t.Run(fmt.Sprintf("%d certificates", n), func(t *testing.T) {
+			var data []byte
+			var want []*testCA
+			for i := 0; i < n; i++ {
+				ca := newTestCA(t)
+				data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+				data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("ignored")})...)
+				want = append(want, ca)
+			}
+
+			got, err := parsePEMBundle(data)
+			if err != nil {
+				t.Fatalf("parsePEMBundle() unexpected error: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("parsePEMBundle() returned %d certificates, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Equal(want[i].cert) {
+					t.Errorf("certificate %d does not match", i)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid certificate", func(t *testing.T) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+		if _, err := parsePEMBundle(data); err == nil {
+			t.Error("parsePEMBundle() expected error for invalid certificate")
+		}
+	})
+}
+
+func TestCreateTrustBundleFromCAs(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	local, federated := newTestCA(t), newTestCA(t)
+
+	writePEM := func(t *testing.T, dir, name string, cas ...*testCA) {
+		t.Helper()
+
+		var data []byte
+		for _, ca := range cas {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	t.Run("CA files", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		writePEM(t, dir, "ca.crt", local)
+
+		bundle, err := createTrustBundleFromCAs(td)
+		if err != nil {
+			t.Fatalf("createTrustBundleFromCAs() error = %v", err)
+		}
+		if !bundle.HasX509Authority(local.cert) || len(bundle.X509Authorities()) != 1 {
+			t.Errorf("bundle authorities = %d, want only the local CA", len(bundle.X509Authorities()))
+		}
+	})
+
+	t.Run("prefers combined bundle", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		writePEM(t, dir, "ca.crt", local)
+		writePEM(t, dir, combinedTrustBundleFile, local, federated)
+		writePEM(t, dir, "rust-ca.crt", newTestCA(t))
+
+		bundle, err := createTrustBundleFromCAs(td)
+		if err != nil {
+			t.Fatalf("createTrustBundleFromCAs() error = %v", err)
+		}
+		if len(bundle.X509Authorities()) != 2 || !bundle.HasX509Authority(local.cert) || !bundle.HasX509Authority(federated.cert) {
+			t.Errorf("bundle authorities = %d, want the local and federated CAs from %s", len(bundle.X509Authorities()), combinedTrustBundleFile)
+		}
+	})
+
+	t.Run("invalid combined bundle", func(t *testing.T) {
+		dir := t.TempDir()
+		withCertDir(t, dir)
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+		if err := os.WriteFile(filepath.Join(dir, combinedTrustBundleFile), data, 0644); err != nil {
+			t.Fatalf("failed to write bundle: %v", err)
+		}
+
+		if _, err := createTrustBundleFromCAs(td); err == nil {
+			t.Error("createTrustBundleFromCAs() expected error for invalid combined bundle")
+		}
+	})
+}
+
+// startServerProcess builds the server and runs it with args, returning its address and a channel of stdout lines
+func startServerProcess(t *testing.T, args ...string) (string, <-chan string) {
+	t.Helper()
+
+	binary := filepath.Join(t.TempDir(), "go_server")
+	if out, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build server: %v\n%s", err, out)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cmd := exec.Command(binary, append(args, "-port", fmt.Sprint(port), "-shutdown-timeout", "1s")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to open stdout: %v", err)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Signal(syscall.SIGTERM)
+		cmd.Wait()
+	})
+
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start listening on %s: %v", address, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return address, lines
+}
+
+// waitForLogRecord decodes JSON log lines until one with msg is found, failing on any non-JSON line
+func waitForLogRecord(t *testing.T, lines <-chan string, msg string) map[string]any {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("server exited before logging %q", msg)
+			}
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("log line is not JSON: %q", line)
+			}
+			if record["msg"] == msg {
+				return record
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for log record %q", msg)
+		}
+	}
+}
+
+func TestMain_JSONLogging(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the server binary")
+	}
+
+	ca := newTestCA(t)
+	serverDir, clientDir := t.TempDir(), t.TempDir()
+	ca.writeSVID(t, serverDir, "spiffe://example.org/go-server", 100)
+	ca.writeSVID(t, clientDir, "spiffe://example.org/go-client", 101)
+	bundlePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	if err := os.WriteFile(filepath.Join(serverDir, *trustBundle), bundlePEM, 0644); err != nil {
+		t.Fatalf("failed to write trust bundle: %v", err)
+	}
+
+	address, lines := startServerProcess(t, "-cert-dir", serverDir, "-log-format", logFormatJSON)
+
+	t.Run("handshake failure", func(t *testing.T) {
+		// The readiness probe above connects without TLS, so its handshake failure is logged first
+		record := waitForLogRecord(t, lines, "TLS handshake failed")
+		if record["level"] != "ERROR" {
+			t.Errorf("level = %v, want ERROR", record["level"])
+		}
+		if record["client_addr"] == nil || record["error"] == nil {
+			t.Errorf("record = %v, want client_addr and error fields", record)
+		}
+	})
+
+	t.Run("echo session", func(t *testing.T) {
+		clientSVID, err := x509svid.Load(filepath.Join(clientDir, *serverCert), filepath.Join(clientDir, *serverKey))
+		if err != nil {
+			t.Fatalf("failed to load client SVID: %v", err)
+		}
+		bundle := x509bundle.FromX509Authorities(clientSVID.ID.TrustDomain(), []*x509.Certificate{ca.cert})
+		serverID := spiffeid.RequireFromString("spiffe://example.org/go-server")
+
+		conn, err := tls.Dial("tcp", address, tlsconfig.MTLSClientConfig(clientSVID, bundle, tlsconfig.AuthorizeID(serverID)))
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		defer conn.Close()
+
+		framed := NewFramedConn(conn)
+		echo(t, framed, "hello")
+		echo(t, framed, "world")
+		if err := framed.WriteMessage([]byte("CLOSE")); err != nil {
+			t.Fatalf("failed to send CLOSE: %v", err)
+		}
+
+		record := waitForLogRecord(t, lines, "Client disconnected")
+		if record["level"] != "INFO" {
+			t.Errorf("level = %v, want INFO", record["level"])
+		}
+		if record["client_addr"] != conn.LocalAddr().String() {
+			t.Errorf("client_addr = %v, want %s", record["client_addr"], conn.LocalAddr())
+		}
+		if record["spiffe_id"] != "spiffe://example.org/go-client" {
+			t.Errorf("spiffe_id = %v, want spiffe://example.org/go-client", record["spiffe_id"])
+		}
+		// JSON numbers decode as float64; CLOSE is counted along with the two echoed messages
+		if record["message_count"] != float64(3) {
+			t.Errorf("message_count = %v, want 3", record["message_count"])
+		}
+	})
+}
+
+func TestNewLogHandler(t *testing.T) {
+	for _, format := range []string{logFormatText, logFormatJSON} {
+		if _, err := newLogHandler(format); err != nil {
+			t.Errorf("newLogHandler(%q) error = %v", format, err)
+		}
+	}
+	if _, err := newLogHandler("xml"); err == nil {
+		t.Error("newLogHandler(\"xml\") expected error")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+// AutoRotatingServer is an mTLS server that reloads its SVID when the certificate files change
+// On rotation the listener is re-created with the new TLS configuration; accepted connections stay open
+type AutoRotatingServer struct {
+	bundle   *x509bundle.Bundle
+	certPath string
+	keyPath  string
+	watcher  *fsnotify.Watcher
+
+	// active tracks connections whose handler has not returned yet
+	active sync.WaitGroup
+	// activeConnections counts the same connections as active, which cannot be read
+	activeConnections atomic.Int64
+
+	mu        sync.RWMutex
+	tlsConfig *tls.Config
+	svid      *x509svid.SVID
+	listener  net.Listener
+	closed    bool
+}
+
+// NewAutoRotatingServer creates a server that watches watcherPath for changes to the server certificate and key
+func NewAutoRotatingServer(tlsConfig *tls.Config, svid *x509svid.SVID, bundle *x509bundle.Bundle, watcherPath string) (*AutoRotatingServer, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %v", err)
+	}
+
+	// Watch the directory so that files replaced by rename are still observed
+	if err := watcher.Add(watcherPath); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %v", watcherPath, err)
+	}
+
+	s := &AutoRotatingServer{
+		bundle:    bundle,
+		certPath:  filepath.Join(watcherPath, *serverCert),
+		keyPath:   filepath.Join(watcherPath, *serverKey),
+		watcher:   watcher,
+		tlsConfig: tlsConfig,
+		svid:      svid,
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// TLSConfig returns the current TLS configuration
+func (s *AutoRotatingServer) TLSConfig() *tls.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tlsConfig
+}
+
+// SVID returns the current server SVID
+func (s *AutoRotatingServer) SVID() *x509svid.SVID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.svid
+}
+
+// Addr returns the address of the current listener, or nil if the server is not listening
+func (s *AutoRotatingServer) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// ActiveConnections returns the number of accepted connections whose handler has not returned yet
+func (s *AutoRotatingServer) ActiveConnections() int64 {
+	return s.activeConnections.Load()
+}
+
+// Serve accepts connections on address and passes them to handler until Close or Shutdown is called
+func (s *AutoRotatingServer) Serve(address string, handler func(net.Conn)) error {
+	for {
+		listener, err := tls.Listen("tcp", address, s.TLSConfig())
+		if err != nil {
+			return fmt.Errorf("failed to start TLS listener: %v", err)
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			listener.Close()
+			return nil
+		}
+		s.listener = listener
+		s.mu.Unlock()
+
+		slog.Info("SPIFFE mTLS server listening", "address", address)
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					break
+				}
+				slog.Error("Failed to accept connection", "error", err)
+				continue
+			}
+
+			// Register the connection under the lock so Shutdown cannot start waiting before it is counted
+			s.mu.RLock()
+			if s.closed {
+				s.mu.RUnlock()
+				conn.Close()
+				continue
+			}
+			s.active.Add(1)
+			s.activeConnections.Add(1)
+			s.mu.RUnlock()
+
+			go func() {
+				defer s.active.Done()
+				defer s.activeConnections.Add(-1)
+				handler(conn)
+			}()
+		}
+
+		s.mu.RLock()
+		closed := s.closed
+		s.mu.RUnlock()
+		if closed {
+			return nil
+		}
+
+		slog.Info("Restarting listener with rotated SVID")
+	}
+}
+
+// Shutdown stops accepting connections and waits for active connections to finish
+// If ctx expires first, the remaining connections are left open and ctx's error is returned
+func (s *AutoRotatingServer) Shutdown(ctx context.Context) error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops watching for certificate changes and closes the listener
+// Active connections are not waited for; use Shutdown to drain them
+func (s *AutoRotatingServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	listener := s.listener
+	s.mu.Unlock()
+
+	if listener != nil {
+		listener.Close()
+	}
+	return s.watcher.Close()
+}
+
+// watch reloads the SVID whenever the certificate or key file changes
+func (s *AutoRotatingServer) watch() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Name != s.certPath && event.Name != s.keyPath {
+				continue
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			if err := s.reload(); err != nil {
+				// The certificate and key are written separately, so a mismatch is expected
+				// until both files have been updated
+				slog.Warn("Failed to reload SVID", "event", event.String(), "error", err)
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("File watcher error", "error", err)
+		}
+	}
+}
+
+// reload loads the SVID from disk, rebuilds the TLS configuration, and restarts the listener
+func (s *AutoRotatingServer) reload() error {
+	svid, err := x509svid.Load(s.certPath, s.keyPath)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := tlsconfig.MTLSServerConfig(svid, s.bundle, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain()))
+
+	s.mu.Lock()
+	if s.svid != nil && s.svid.Certificates[0].Equal(svid.Certificates[0]) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.svid = svid
+	s.tlsConfig = tlsConfig
+	listener := s.listener
+	s.mu.Unlock()
+
+	slog.Info("Rotated server SVID",
+		"spiffe_id", svid.ID.String(),
+		"serial", svid.Certificates[0].SerialNumber.String(),
+		"expires", svid.Certificates[0].NotAfter)
+
+	if listener != nil {
+		listener.Close()
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: "example.org"}},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+// writeSVID issues a leaf certificate for spiffeID and writes it with its key into dir
+func (ca *testCA) writeSVID(t *testing.T, dir, spiffeID string, serial int64) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	id, err := url.Parse(spiffeID)
+	if err != nil {
+		t.Fatalf("failed to parse SPIFFE ID: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "go-server"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{id},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	// Write the key first so the certificate write triggers a reload with a matching pair
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, *serverKey), keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, *serverCert), certPEM, 0644); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+}
+
+func TestAutoRotatingServer_ReloadsOnCertificateChange(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	ca.writeSVID(t, dir, "spiffe://example.org/go-server", 100)
+
+	svid, err := x509svid.Load(filepath.Join(dir, *serverCert), filepath.Join(dir, *serverKey))
+	if err != nil {
+		t.Fatalf("failed to load SVID: %v", err)
+	}
+	bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{ca.cert})
+	tlsConfig := tlsconfig.MTLSServerConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain()))
+
+	server, err := NewAutoRotatingServer(tlsConfig, svid, bundle, dir)
+	if err != nil {
+		t.Fatalf("NewAutoRotatingServer() error = %v", err)
+	}
+	defer server.Close()
+
+	ca.writeSVID(t, dir, "spiffe://example.org/go-server", 200)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if server.SVID().Certificates[0].SerialNumber.Int64() == 200 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got := server.SVID().Certificates[0].SerialNumber.Int64(); got != 200 {
+		t.Fatalf("SVID serial = %d, want 200", got)
+	}
+	if server.TLSConfig() == tlsConfig {
+		t.Error("TLSConfig() was not rebuilt after rotation")
+	}
+}
+
+func TestAutoRotatingServer_KeepsSVIDOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	ca.writeSVID(t, dir, "spiffe://example.org/go-server", 100)
+
+	svid, err := x509svid.Load(filepath.Join(dir, *serverCert), filepath.Join(dir, *serverKey))
+	if err != nil {
+		t.Fatalf("failed to load SVID: %v", err)
+	}
+	bundle := x509bundle.FromX509Authorities(svid.ID.TrustDomain(), []*x509.Certificate{ca.cert})
+	tlsConfig := tlsconfig.MTLSServerConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain()))
+
+	server, err := NewAutoRotatingServer(tlsConfig, svid, bundle, dir)
+	if err != nil {
+		t.Fatalf("NewAutoRotatingServer() error = %v", err)
+	}
+	defer server.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, *serverCert), []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if server.SVID() != svid {
+		t.Error("SVID() changed after an invalid certificate was written")
+	}
+	if server.TLSConfig() != tlsConfig {
+		t.Error("TLSConfig() changed after an invalid certificate was written")
+	}
+}
+
+func TestAutoRotatingServer_WatchPathMissing(t *testing.T) {
+	_, err := NewAutoRotatingServer(nil, nil, nil, filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("NewAutoRotatingServer() expected error for missing directory")
+	}
+}
+
+// startTestServer serves handleClient with a CA-issued SVID and returns the server and a client TLS configuration
+func startTestServer(t *testing.T) (*AutoRotatingServer, *tls.Config) {
+	t.Helper()
+
+	ca := newTestCA(t)
+	serverDir, clientDir := t.TempDir(), t.TempDir()
+	ca.writeSVID(t, serverDir, "spiffe://example.org/go-server", 100)
+	ca.writeSVID(t, clientDir, "spiffe://example.org/go-client", 101)
+
+	svid, err := x509svid.Load(filepath.Join(serverDir, *serverCert), filepath.Join(serverDir, *serverKey))
+	if err != nil {
+		t.Fatalf("failed to load server SVID: %v", err)
+	}
+	clientSVID, err := x509svid.Load(filepath.Join(clientDir, *serverCert), filepath.Join(clientDir, *serverKey))
+	if err != nil {
+		t.Fatalf("failed to load client SVID: %v", err)
+	}
+	bundle := x509bundle.FromX509Authorities(svid.ID.TrustDomain(), []*x509.Certificate{ca.cert})
+	tlsConfig := tlsconfig.MTLSServerConfig(svid, bundle, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain()))
+
+	server, err := NewAutoRotatingServer(tlsConfig, svid, bundle, serverDir)
+	if err != nil {
+		t.Fatalf("NewAutoRotatingServer() error = %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	go server.Serve("127.0.0.1:0", handleClient)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return server, tlsconfig.MTLSClientConfig(clientSVID, bundle, tlsconfig.AuthorizeID(svid.ID))
+}
+
+// echo sends message over conn and returns the server's reply
+func echo(t *testing.T, conn *FramedConn, message string) string {
+	t.Helper()
+
+	if err := conn.WriteMessage([]byte(message)); err != nil {
+		t.Fatalf("failed to send %q: %v", message, err)
+	}
+	reply, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read reply to %q: %v", message, err)
+	}
+	return string(reply)
+}
+
+func TestAutoRotatingServer_ShutdownDrainsConnections(t *testing.T) {
+	server, clientConfig := startTestServer(t)
+	addr := server.Addr().String()
+
+	var conns []*FramedConn
+	for i := 0; i < 2; i++ {
+		conn, err := tls.Dial("tcp", addr, clientConfig)
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		defer conn.Close()
+		framed := NewFramedConn(conn)
+		if got := echo(t, framed, "hello"); got != "SPIFFE_GO_SERVER_ECHO: hello" {
+			t.Fatalf("reply = %q", got)
+		}
+		conns = append(conns, framed)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown() returned %v while connections were active", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Both connections are still serviced while the server drains
+	for i, conn := range conns {
+		message := fmt.Sprintf("draining %d", i)
+		if got := echo(t, conn, message); got != "SPIFFE_GO_SERVER_ECHO: "+message {
+			t.Errorf("reply = %q", got)
+		}
+		if err := conn.WriteMessage([]byte("CLOSE")); err != nil {
+			t.Fatalf("failed to send CLOSE: %v", err)
+		}
+	}
+
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Fatalf("Shutdown() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown() did not return after connections closed")
+	}
+
+	if _, err := tls.Dial("tcp", addr, clientConfig); err == nil {
+		t.Error("new connection accepted after Shutdown()")
+	}
+}
+
+func TestAutoRotatingServer_ShutdownTimeout(t *testing.T) {
+	server, clientConfig := startTestServer(t)
+
+	conn, err := tls.Dial("tcp", server.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	echo(t, NewFramedConn(conn), "hello")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestServer_ConcurrentConnections checks that many simultaneous mTLS clients are all served
+// and that every handler returns once its client disconnects
+// Run with the race detector: go test -race -run=TestServer_Concurrent -count=1
+func TestServer_ConcurrentConnections(t *testing.T) {
+	const clients = 50
+
+	// handleClient logs several lines per connection
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	server, clientConfig := startTestServer(t)
+	addr := server.Addr().String()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			conn, err := tls.Dial("tcp", addr, clientConfig)
+			if err != nil {
+				errs <- fmt.Errorf("client %d: failed to connect: %v", i, err)
+				return
+			}
+			defer conn.Close()
+
+			framed := NewFramedConn(conn)
+			message := fmt.Sprintf("message %d", i)
+			if err := framed.WriteMessage([]byte(message)); err != nil {
+				errs <- fmt.Errorf("client %d: failed to send: %v", i, err)
+				return
+			}
+			reply, err := framed.ReadMessage()
+			if err != nil {
+				errs <- fmt.Errorf("client %d: failed to read reply: %v", i, err)
+				return
+			}
+			if want := "SPIFFE_GO_SERVER_ECHO: " + message; string(reply) != want {
+				errs <- fmt.Errorf("client %d: reply = %q, want %q", i, reply, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	failed := 0
+	for err := range errs {
+		t.Error(err)
+		failed++
+	}
+	if failed > 0 {
+		t.Fatalf("%d of %d exchanges failed", failed, clients)
+	}
+
+	// Handlers return asynchronously after the clients close their connections
+	deadline := time.Now().Add(5 * time.Second)
+	for server.ActiveConnections() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("ActiveConnections() = %d after all clients closed, want 0", server.ActiveConnections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var interopBinaryDir = flag.String("interop-binary-dir", "", "Directory containing pre-built go_server, go_client, mtls_server and mtls_client binaries")
+
+// echoLine matches a client log line reporting a server echo, capturing the echo prefix
+var echoLine = regexp.MustCompile(`Received: (SPIFFE_\w+_ECHO): Test message \d`)
+
+// interopServer describes a server binary of the interop matrix
+type interopServer struct {
+	name   string
+	binary string
+	args   func(dir string, port int) []string
+	echo   string
+}
+
+// interopClient describes a client binary of the interop matrix
+type interopClient struct {
+	name   string
+	binary string
+	args   func(dir, host string, port int) []string
+}
+
+var (
+	interopServers = []interopServer{
+		{
+			name:   "go-server",
+			binary: "go_server",
+			args: func(dir string, port int) []string {
+				return []string{"-cert-dir", dir, "-port", fmt.Sprint(port), "-shutdown-timeout", "1s"}
+			},
+			echo: "SPIFFE_GO_SERVER_ECHO",
+		},
+		{
+			name:   "rust-server",
+			binary: "mtls_server",
+			args: func(dir string, port int) []string {
+				return []string{"--cert-dir", dir, "--port", fmt.Sprint(port)}
+			},
+			echo: "SPIFFE_RUST_SERVER_ECHO",
+		},
+	}
+
+	interopClients = []interopClient{
+		{
+			name:   "go-client",
+			binary: "go_client",
+			args: func(dir, host string, port int) []string {
+				return []string{"-cert-dir", dir, "-server", host, "-port", fmt.Sprint(port)}
+			},
+		},
+		{
+			name:   "rust-client",
+			binary: "mtls_client",
+			args: func(dir, host string, port int) []string {
+				return []string{"--cert-dir", dir, "--server", host, "--port", fmt.Sprint(port)}
+			},
+		},
+	}
+)
+
+// interopBinary returns the path of a pre-built binary in -interop-binary-dir
+func interopBinary(t *testing.T, name string) string {
+	t.Helper()
+
+	path := filepath.Join(*interopBinaryDir, name)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Missing interop binary %s: %v", name, err)
+	}
+	return path
+}
+
+// startInteropServer runs server against dir until the test finishes and returns its port
+func startInteropServer(t *testing.T, ctx context.Context, server interopServer, dir string) int {
+	t.Helper()
+
+	port := reservePort(t)
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, interopBinary(t, server.binary), server.args(dir, port)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Stop servers gracefully so the Go server drains its connections
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start %s: %v", server.name, err)
+	}
+
+	t.Cleanup(func() {
+		cmd.Process.Signal(syscall.SIGTERM)
+		cmd.Wait()
+		if t.Failed() {
+			t.Logf("%s output:\n%s", server.name, output.String())
+		}
+	})
+
+	waitForListener(t, server.name, fmt.Sprintf("127.0.0.1:%d", port))
+	return port
+}
+
+func TestInteropMatrix(t *testing.T) {
+	if os.Getenv("INTEGRATION_TEST") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TEST=true to run.")
+	}
+	if *interopBinaryDir == "" {
+		t.Fatal("-interop-binary-dir is required; build go-server, go-client and rust-impl first")
+	}
+
+	withCertDir(t, t.TempDir())
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if _, err := writeTrustBundle(caCert); err != nil {
+		t.Fatalf("Failed to write trust bundle: %v", err)
+	}
+	ipAddresses, err := parseIPAddresses(*ipAddressFlag)
+	if err != nil {
+		t.Fatalf("Invalid IP addresses: %v", err)
+	}
+	if err := generatePeerCerts(parseList(*dnsNamesFlag), ipAddresses, caCert, caKey); err != nil {
+		t.Fatalf("Failed to generate certificates: %v", err)
+	}
+	dir := *certDir
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	ports := make(map[string]int)
+	for _, server := range interopServers {
+		ports[server.name] = startInteropServer(t, ctx, server, dir)
+	}
+
+	// The parallel subtests all finish before the servers are stopped by the parent's cleanup
+	t.Run("matrix", func(t *testing.T) {
+		for _, server := range interopServers {
+			for _, client := range interopClients {
+				server, client := server, client
+				t.Run(client.name+"_to_"+server.name, func(t *testing.T) {
+					t.Parallel()
+
+					// Go logs to stderr and Rust traces to stdout, so both are searched for echo lines
+					cmd := exec.CommandContext(ctx, interopBinary(t, client.binary), client.args(dir, "127.0.0.1", ports[server.name])...)
+					output, err := cmd.CombinedOutput()
+					if err != nil {
+						t.Fatalf("%s failed against %s: %v\n%s", client.name, server.name, err, output)
+					}
+
+					matches := echoLine.FindAllSubmatch(output, -1)
+					if len(matches) != 3 {
+						t.Fatalf("%s received %d echo responses from %s, want 3\n%s", client.name, len(matches), server.name, output)
+					}
+					for _, m := range matches {
+						if got := string(m[1]); got != server.echo {
+							t.Errorf("Echo prefix = %s, want %s", got, server.echo)
+						}
+					}
+				})
+			}
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startGoServer builds the go-server and runs it against dir, returning its address
+func startGoServer(t *testing.T, dir string) string {
+	t.Helper()
+
+	binary := filepath.Join(t.TempDir(), "go_server")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = "go-server"
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build go-server: %v\n%s", err, out)
+	}
+
+	port := reservePort(t)
+	var output bytes.Buffer
+	cmd := exec.Command(binary, "-cert-dir", dir, "-port", fmt.Sprint(port), "-shutdown-timeout", "1s")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start go-server: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Signal(syscall.SIGTERM)
+		cmd.Wait()
+		if t.Failed() {
+			t.Logf("go-server output:\n%s", output.String())
+		}
+	})
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+	waitForListener(t, "go-server", address)
+	return address
+}
+
+// reservePort returns a free local port; the server binds it again immediately after
+func reservePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// waitForListener waits up to 10s for the named server to accept connections on address
+func waitForListener(t *testing.T, name, address string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not start listening on %s: %v", name, address, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// dialGoServer performs an mTLS handshake with the go-server using the go-client certificate
+func dialGoServer(address string, dir string, roots *x509.CertPool) (*tls.Conn, error) {
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "go-client.crt"), filepath.Join(dir, "go-client.key"))
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   "localhost",
+	})
+}
+
+func TestCertRotation(t *testing.T) {
+	if os.Getenv("INTEGRATION_TEST") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TEST=true to run.")
+	}
+
+	prev := *certDir
+	*certDir = t.TempDir()
+	t.Cleanup(func() { *certDir = prev })
+	withKeyType(t, keyTypeECDSAP256)
+
+	// Rotated certificates must chain to the same CA, since the server only reloads its SVID
+	caCert, caKey, err := generateCA()
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if _, err := writeTrustBundle(caCert); err != nil {
+		t.Fatalf("Failed to write trust bundle: %v", err)
+	}
+	if err := generateCert("go-client.crt", "go-client.key", *clientSpiffeID, x509.ExtKeyUsageClientAuth, nil, nil, caCert, caKey); err != nil {
+		t.Fatalf("Failed to generate client cert: %v", err)
+	}
+	if err := generateCert("go-server.crt", "go-server.key", *serverSpiffeID, x509.ExtKeyUsageServerAuth, nil, nil, caCert, caKey); err != nil {
+		t.Fatalf("Failed to generate initial server cert: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	address := startGoServer(t, *certDir)
+
+	first, err := dialGoServer(address, *certDir, roots)
+	if err != nil {
+		t.Fatalf("First connection failed: %v", err)
+	}
+	defer first.Close()
+	firstSerial := first.ConnectionState().PeerCertificates[0].SerialNumber
+
+	if err := generateCert("go-server.crt", "go-server.key", *serverSpiffeID, x509.ExtKeyUsageServerAuth, nil, nil, caCert, caKey); err != nil {
+		t.Fatalf("Failed to generate rotated server cert: %v", err)
+	}
+
+	// The listener is briefly re-created during rotation, so retry until the new certificate is served
+	var second *tls.Conn
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := dialGoServer(address, *certDir, roots)
+		if err == nil {
+			if conn.ConnectionState().PeerCertificates[0].SerialNumber.Cmp(firstSerial) != 0 {
+				second = conn
+				break
+			}
+			conn.Close()
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server did not present a rotated certificate within 10s (last error: %v)", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	defer second.Close()
+
+	secondSerial := second.ConnectionState().PeerCertificates[0].SerialNumber
+	t.Logf("Server certificate rotated: serial %s -> %s", firstSerial, secondSerial)
+
+	// The connection established before rotation keeps the original certificate
+	if got := first.ConnectionState().PeerCertificates[0].SerialNumber; got.Cmp(firstSerial) != 0 {
+		t.Errorf("First connection serial = %s, want %s", got, firstSerial)
+	}
+}
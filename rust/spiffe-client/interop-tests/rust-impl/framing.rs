@@ -0,0 +1,39 @@
+//! Length-prefixed message framing shared by the interop client and server
+//!
+//! Each message is a 4-byte big-endian length followed by the payload, matching
+//! the Go `FramedConn` so multi-line payloads such as PEM blocks survive intact.
+
+use anyhow::{bail, Result};
+use std::io::ErrorKind;
+use tokio::io::{AsyncRead, AsyncReadExt, AsyncWrite, AsyncWriteExt};
+
+/// Upper bound on a single message, kept in sync with the Go implementation
+pub const MAX_MESSAGE_SIZE: usize = 1 << 20;
+
+/// Write `msg` as a single length-prefixed frame
+pub async fn write_message<W: AsyncWrite + Unpin>(writer: &mut W, msg: &[u8]) -> Result<()> {
+    if msg.len() > MAX_MESSAGE_SIZE {
+        bail!("message of {} bytes exceeds the maximum of {} bytes", msg.len(), MAX_MESSAGE_SIZE);
+    }
+
+    writer.write_u32(msg.len() as u32).await?;
+    writer.write_all(msg).await?;
+    writer.flush().await?;
+    Ok(())
+}
+
+/// Read the next frame, returning `None` when the peer closed the connection between frames
+pub async fn read_message<R: AsyncRead + Unpin>(reader: &mut R) -> Result<Option<Vec<u8>>> {
+    let size = match reader.read_u32().await {
+        Ok(size) => size as usize,
+        Err(e) if e.kind() == ErrorKind::UnexpectedEof => return Ok(None),
+        Err(e) => return Err(e.into()),
+    };
+    if size > MAX_MESSAGE_SIZE {
+        bail!("message of {} bytes exceeds the maximum of {} bytes", size, MAX_MESSAGE_SIZE);
+    }
+
+    let mut msg = vec![0u8; size];
+    reader.read_exact(&mut msg).await?;
+    Ok(Some(msg))
+}
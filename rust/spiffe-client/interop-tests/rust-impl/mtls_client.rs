@@ -1,5 +1,7 @@
 //! mTLS client for interoperability testing with Go SPIFFE server
 
+mod framing;
+
 use anyhow::{Context, Result};
 use clap::Parser;
 use rcgen::{Certificate, CertificateParams, DistinguishedName, DnType, SanType, KeyPair, SignatureAlgorithm};
@@ -8,7 +10,7 @@ use std::fs;
 use std::net::SocketAddr;
 use std::path::Path;
 use std::sync::Arc;
-use tokio::io::{AsyncBufReadExt, AsyncWriteExt, BufReader};
+use framing::{read_message, write_message};
 use tokio::net::TcpStream;
 use tokio_rustls::rustls::{self, ClientConfig};
 use tokio_rustls::TlsConnector;
@@ -125,27 +127,24 @@ async fn main() -> Result<()> {
     }
 
     // Send test messages
-    let (reader, mut writer) = tokio::io::split(tls_stream);
-    let mut reader = BufReader::new(reader).lines();
+    let (mut reader, mut writer) = tokio::io::split(tls_stream);
 
-    // Send test messages
+    // Send length-prefixed test messages
     for i in 1..=3 {
-        let message = format!("Test message {} from Rust client\n", i);
-        info!("Sending: {}", message.trim());
-        writer.write_all(message.as_bytes()).await?;
-        writer.flush().await?;
+        let message = format!("Test message {} from Rust client", i);
+        info!("Sending: {}", message);
+        write_message(&mut writer, message.as_bytes()).await?;
 
         // Read response
-        if let Some(response) = reader.next_line().await? {
-            info!("Received: {}", response);
+        if let Some(response) = read_message(&mut reader).await? {
+            info!("Received: {}", String::from_utf8_lossy(&response));
         }
 
         tokio::time::sleep(tokio::time::Duration::from_secs(1)).await;
     }
 
     // Send close message
-    writer.write_all(b"CLOSE\n").await?;
-    writer.flush().await?;
+    write_message(&mut writer, b"CLOSE").await?;
 
     info!("✓ Interop test completed successfully");
 
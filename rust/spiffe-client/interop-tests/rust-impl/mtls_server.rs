@@ -1,5 +1,7 @@
 //! mTLS server for interoperability testing with Go SPIFFE client
 
+mod framing;
+
 use anyhow::{Context, Result};
 use clap::Parser;
 use rcgen::{Certificate, CertificateParams, DistinguishedName, DnType, SanType, KeyPair, SignatureAlgorithm};
@@ -8,7 +10,7 @@ use std::fs;
 use std::net::SocketAddr;
 use std::path::Path;
 use std::sync::Arc;
-use tokio::io::{AsyncBufReadExt, AsyncWriteExt, BufReader};
+use framing::{read_message, write_message};
 use tokio::net::{TcpListener, TcpStream};
 use tokio_rustls::rustls::{self, ServerConfig};
 use tokio_rustls::TlsAcceptor;
@@ -130,19 +132,18 @@ async fn handle_client(
     }
 
     // Simple echo server
-    let (reader, mut writer) = tokio::io::split(tls_stream);
-    let mut reader = BufReader::new(reader).lines();
+    let (mut reader, mut writer) = tokio::io::split(tls_stream);
 
-    while let Some(line) = reader.next_line().await? {
-        if line.trim() == "CLOSE" {
+    while let Some(message) = read_message(&mut reader).await? {
+        if message == b"CLOSE" {
             break;
         }
-        info!("Received: {}", line.trim());
+        let message = String::from_utf8_lossy(&message);
+        info!("Received: {}", message);
 
         // Echo back with confirmation
-        let response = format!("RUST_SERVER_ECHO: {}\n", line);
-        writer.write_all(response.as_bytes()).await?;
-        writer.flush().await?;
+        let response = format!("SPIFFE_RUST_SERVER_ECHO: {}", message);
+        write_message(&mut writer, response.as_bytes()).await?;
     }
 
     info!("Client disconnected");